@@ -0,0 +1,1090 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: api/calculator/v1/calculator.proto
+
+package calculatorv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Mode int32
+
+const (
+	// Same as MODE_EXACT.
+	Mode_MODE_UNSPECIFIED Mode = 0
+	// Only plans summing to exactly the amount.
+	Mode_MODE_EXACT Mode = 1
+	// The fewest items at or above the amount, then the fewest packs.
+	Mode_MODE_OVERFILL Mode = 2
+)
+
+// Enum value maps for Mode.
+var (
+	Mode_name = map[int32]string{
+		0: "MODE_UNSPECIFIED",
+		1: "MODE_EXACT",
+		2: "MODE_OVERFILL",
+	}
+	Mode_value = map[string]int32{
+		"MODE_UNSPECIFIED": 0,
+		"MODE_EXACT":       1,
+		"MODE_OVERFILL":    2,
+	}
+)
+
+func (x Mode) Enum() *Mode {
+	p := new(Mode)
+	*p = x
+	return p
+}
+
+func (x Mode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Mode) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_calculator_v1_calculator_proto_enumTypes[0].Descriptor()
+}
+
+func (Mode) Type() protoreflect.EnumType {
+	return &file_api_calculator_v1_calculator_proto_enumTypes[0]
+}
+
+func (x Mode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Mode.Descriptor instead.
+func (Mode) EnumDescriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{0}
+}
+
+type Objective int32
+
+const (
+	// Same as OBJECTIVE_FEWEST_PACKS.
+	Objective_OBJECTIVE_UNSPECIFIED  Objective = 0
+	Objective_OBJECTIVE_FEWEST_PACKS Objective = 1
+	// The lowest total cost, then the fewest packs. Requires costs for every size.
+	Objective_OBJECTIVE_LOWEST_COST Objective = 2
+)
+
+// Enum value maps for Objective.
+var (
+	Objective_name = map[int32]string{
+		0: "OBJECTIVE_UNSPECIFIED",
+		1: "OBJECTIVE_FEWEST_PACKS",
+		2: "OBJECTIVE_LOWEST_COST",
+	}
+	Objective_value = map[string]int32{
+		"OBJECTIVE_UNSPECIFIED":  0,
+		"OBJECTIVE_FEWEST_PACKS": 1,
+		"OBJECTIVE_LOWEST_COST":  2,
+	}
+)
+
+func (x Objective) Enum() *Objective {
+	p := new(Objective)
+	*p = x
+	return p
+}
+
+func (x Objective) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Objective) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_calculator_v1_calculator_proto_enumTypes[1].Descriptor()
+}
+
+func (Objective) Type() protoreflect.EnumType {
+	return &file_api_calculator_v1_calculator_proto_enumTypes[1]
+}
+
+func (x Objective) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Objective.Descriptor instead.
+func (Objective) EnumDescriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{1}
+}
+
+type CalculateRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	PackSizes []int64                `protobuf:"varint,1,rep,packed,name=pack_sizes,json=packSizes,proto3" json:"pack_sizes,omitempty"`
+	Amount    int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	Mode      Mode                   `protobuf:"varint,3,opt,name=mode,proto3,enum=calculator.v1.Mode" json:"mode,omitempty"`
+	Objective Objective              `protobuf:"varint,4,opt,name=objective,proto3,enum=calculator.v1.Objective" json:"objective,omitempty"`
+	// Pack size to packs in stock, sizes missing from the map are unlimited.
+	Stock map[int64]int64 `protobuf:"bytes,5,rep,name=stock,proto3" json:"stock,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Pack size to unit cost in cents.
+	Costs map[int64]int64 `protobuf:"bytes,6,rep,name=costs,proto3" json:"costs,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Number of ranked plans to return, 1 when unset.
+	Plans         int32 `protobuf:"varint,7,opt,name=plans,proto3" json:"plans,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CalculateRequest) Reset() {
+	*x = CalculateRequest{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CalculateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CalculateRequest) ProtoMessage() {}
+
+func (x *CalculateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CalculateRequest.ProtoReflect.Descriptor instead.
+func (*CalculateRequest) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CalculateRequest) GetPackSizes() []int64 {
+	if x != nil {
+		return x.PackSizes
+	}
+	return nil
+}
+
+func (x *CalculateRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *CalculateRequest) GetMode() Mode {
+	if x != nil {
+		return x.Mode
+	}
+	return Mode_MODE_UNSPECIFIED
+}
+
+func (x *CalculateRequest) GetObjective() Objective {
+	if x != nil {
+		return x.Objective
+	}
+	return Objective_OBJECTIVE_UNSPECIFIED
+}
+
+func (x *CalculateRequest) GetStock() map[int64]int64 {
+	if x != nil {
+		return x.Stock
+	}
+	return nil
+}
+
+func (x *CalculateRequest) GetCosts() map[int64]int64 {
+	if x != nil {
+		return x.Costs
+	}
+	return nil
+}
+
+func (x *CalculateRequest) GetPlans() int32 {
+	if x != nil {
+		return x.Plans
+	}
+	return 0
+}
+
+type Plan struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Pack size to number of packs.
+	Packages  map[int64]int64 `protobuf:"bytes,1,rep,name=packages,proto3" json:"packages,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Total     int64           `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Overshoot int64           `protobuf:"varint,3,opt,name=overshoot,proto3" json:"overshoot,omitempty"`
+	PackCount int64           `protobuf:"varint,4,opt,name=pack_count,json=packCount,proto3" json:"pack_count,omitempty"`
+	// Pack size to the total cost of those packs in cents, set when costs were given.
+	Costs         map[int64]int64 `protobuf:"bytes,5,rep,name=costs,proto3" json:"costs,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	TotalCost     *int64          `protobuf:"varint,6,opt,name=total_cost,json=totalCost,proto3,oneof" json:"total_cost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Plan) Reset() {
+	*x = Plan{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Plan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Plan) ProtoMessage() {}
+
+func (x *Plan) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Plan.ProtoReflect.Descriptor instead.
+func (*Plan) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Plan) GetPackages() map[int64]int64 {
+	if x != nil {
+		return x.Packages
+	}
+	return nil
+}
+
+func (x *Plan) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Plan) GetOvershoot() int64 {
+	if x != nil {
+		return x.Overshoot
+	}
+	return 0
+}
+
+func (x *Plan) GetPackCount() int64 {
+	if x != nil {
+		return x.PackCount
+	}
+	return 0
+}
+
+func (x *Plan) GetCosts() map[int64]int64 {
+	if x != nil {
+		return x.Costs
+	}
+	return nil
+}
+
+func (x *Plan) GetTotalCost() int64 {
+	if x != nil && x.TotalCost != nil {
+		return *x.TotalCost
+	}
+	return 0
+}
+
+type CalculateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the stored calculation, 0 when it was not saved.
+	Id            int32   `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Amount        int64   `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	Plan          *Plan   `protobuf:"bytes,3,opt,name=plan,proto3" json:"plan,omitempty"`
+	Alternatives  []*Plan `protobuf:"bytes,4,rep,name=alternatives,proto3" json:"alternatives,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CalculateResponse) Reset() {
+	*x = CalculateResponse{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CalculateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CalculateResponse) ProtoMessage() {}
+
+func (x *CalculateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CalculateResponse.ProtoReflect.Descriptor instead.
+func (*CalculateResponse) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CalculateResponse) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *CalculateResponse) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *CalculateResponse) GetPlan() *Plan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+func (x *CalculateResponse) GetAlternatives() []*Plan {
+	if x != nil {
+		return x.Alternatives
+	}
+	return nil
+}
+
+type BatchCalculateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      []*CalculateRequest    `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchCalculateRequest) Reset() {
+	*x = BatchCalculateRequest{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchCalculateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchCalculateRequest) ProtoMessage() {}
+
+func (x *BatchCalculateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchCalculateRequest.ProtoReflect.Descriptor instead.
+func (*BatchCalculateRequest) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchCalculateRequest) GetRequests() []*CalculateRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type BatchCalculateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One result per request, in request order.
+	Results       []*BatchCalculateResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchCalculateResponse) Reset() {
+	*x = BatchCalculateResponse{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchCalculateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchCalculateResponse) ProtoMessage() {}
+
+func (x *BatchCalculateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchCalculateResponse.ProtoReflect.Descriptor instead.
+func (*BatchCalculateResponse) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchCalculateResponse) GetResults() []*BatchCalculateResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BatchCalculateResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Outcome:
+	//
+	//	*BatchCalculateResult_Response
+	//	*BatchCalculateResult_Error
+	Outcome       isBatchCalculateResult_Outcome `protobuf_oneof:"outcome"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchCalculateResult) Reset() {
+	*x = BatchCalculateResult{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchCalculateResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchCalculateResult) ProtoMessage() {}
+
+func (x *BatchCalculateResult) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchCalculateResult.ProtoReflect.Descriptor instead.
+func (*BatchCalculateResult) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchCalculateResult) GetOutcome() isBatchCalculateResult_Outcome {
+	if x != nil {
+		return x.Outcome
+	}
+	return nil
+}
+
+func (x *BatchCalculateResult) GetResponse() *CalculateResponse {
+	if x != nil {
+		if x, ok := x.Outcome.(*BatchCalculateResult_Response); ok {
+			return x.Response
+		}
+	}
+	return nil
+}
+
+func (x *BatchCalculateResult) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Outcome.(*BatchCalculateResult_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+type isBatchCalculateResult_Outcome interface {
+	isBatchCalculateResult_Outcome()
+}
+
+type BatchCalculateResult_Response struct {
+	Response *CalculateResponse `protobuf:"bytes,1,opt,name=response,proto3,oneof"`
+}
+
+type BatchCalculateResult_Error struct {
+	Error *Error `protobuf:"bytes,2,opt,name=error,proto3,oneof"`
+}
+
+func (*BatchCalculateResult_Response) isBatchCalculateResult_Outcome() {}
+
+func (*BatchCalculateResult_Error) isBatchCalculateResult_Outcome() {}
+
+type Error struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// A google.golang.org/grpc/codes code, as Calculate would have returned it.
+	Code          uint32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Error) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// ListHistoryRequest pages through the history; unset filters match everything.
+type ListHistoryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// At most this many calculations; 0 means 50, the maximum is 200.
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// The next_page_token of the previous page, empty for the first page.
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	MinAmount *int64 `protobuf:"varint,3,opt,name=min_amount,json=minAmount,proto3,oneof" json:"min_amount,omitempty"`
+	MaxAmount *int64 `protobuf:"varint,4,opt,name=max_amount,json=maxAmount,proto3,oneof" json:"max_amount,omitempty"`
+	// Only calculations with exactly this set of pack sizes, in any order.
+	PackSizes []int64 `protobuf:"varint,5,rep,packed,name=pack_sizes,json=packSizes,proto3" json:"pack_sizes,omitempty"`
+	// Created at or after created_from and before created_to.
+	CreatedFrom     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_from,json=createdFrom,proto3" json:"created_from,omitempty"`
+	CreatedTo       *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_to,json=createdTo,proto3" json:"created_to,omitempty"`
+	ConfigurationId int32                  `protobuf:"varint,8,opt,name=configuration_id,json=configurationId,proto3" json:"configuration_id,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ListHistoryRequest) Reset() {
+	*x = ListHistoryRequest{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListHistoryRequest) ProtoMessage() {}
+
+func (x *ListHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListHistoryRequest.ProtoReflect.Descriptor instead.
+func (*ListHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListHistoryRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListHistoryRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListHistoryRequest) GetMinAmount() int64 {
+	if x != nil && x.MinAmount != nil {
+		return *x.MinAmount
+	}
+	return 0
+}
+
+func (x *ListHistoryRequest) GetMaxAmount() int64 {
+	if x != nil && x.MaxAmount != nil {
+		return *x.MaxAmount
+	}
+	return 0
+}
+
+func (x *ListHistoryRequest) GetPackSizes() []int64 {
+	if x != nil {
+		return x.PackSizes
+	}
+	return nil
+}
+
+func (x *ListHistoryRequest) GetCreatedFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedFrom
+	}
+	return nil
+}
+
+func (x *ListHistoryRequest) GetCreatedTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedTo
+	}
+	return nil
+}
+
+func (x *ListHistoryRequest) GetConfigurationId() int32 {
+	if x != nil {
+		return x.ConfigurationId
+	}
+	return 0
+}
+
+type ListHistoryResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Calculations []*Calculation         `protobuf:"bytes,1,rep,name=calculations,proto3" json:"calculations,omitempty"`
+	// Empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListHistoryResponse) Reset() {
+	*x = ListHistoryResponse{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListHistoryResponse) ProtoMessage() {}
+
+func (x *ListHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListHistoryResponse.ProtoReflect.Descriptor instead.
+func (*ListHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListHistoryResponse) GetCalculations() []*Calculation {
+	if x != nil {
+		return x.Calculations
+	}
+	return nil
+}
+
+func (x *ListHistoryResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type Calculation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	PackSizes     []int64                `protobuf:"varint,3,rep,packed,name=pack_sizes,json=packSizes,proto3" json:"pack_sizes,omitempty"`
+	Amount        int64                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Total         int64                  `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	Packages      map[int64]int64        `protobuf:"bytes,6,rep,name=packages,proto3" json:"packages,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Stock         map[int64]int64        `protobuf:"bytes,7,rep,name=stock,proto3" json:"stock,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Objective     string                 `protobuf:"bytes,8,opt,name=objective,proto3" json:"objective,omitempty"`
+	Costs         map[int64]int64        `protobuf:"bytes,9,rep,name=costs,proto3" json:"costs,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	TotalCost     *int64                 `protobuf:"varint,10,opt,name=total_cost,json=totalCost,proto3,oneof" json:"total_cost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Calculation) Reset() {
+	*x = Calculation{}
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Calculation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Calculation) ProtoMessage() {}
+
+func (x *Calculation) ProtoReflect() protoreflect.Message {
+	mi := &file_api_calculator_v1_calculator_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Calculation.ProtoReflect.Descriptor instead.
+func (*Calculation) Descriptor() ([]byte, []int) {
+	return file_api_calculator_v1_calculator_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Calculation) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Calculation) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Calculation) GetPackSizes() []int64 {
+	if x != nil {
+		return x.PackSizes
+	}
+	return nil
+}
+
+func (x *Calculation) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Calculation) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Calculation) GetPackages() map[int64]int64 {
+	if x != nil {
+		return x.Packages
+	}
+	return nil
+}
+
+func (x *Calculation) GetStock() map[int64]int64 {
+	if x != nil {
+		return x.Stock
+	}
+	return nil
+}
+
+func (x *Calculation) GetObjective() string {
+	if x != nil {
+		return x.Objective
+	}
+	return ""
+}
+
+func (x *Calculation) GetCosts() map[int64]int64 {
+	if x != nil {
+		return x.Costs
+	}
+	return nil
+}
+
+func (x *Calculation) GetTotalCost() int64 {
+	if x != nil && x.TotalCost != nil {
+		return *x.TotalCost
+	}
+	return 0
+}
+
+var File_api_calculator_v1_calculator_proto protoreflect.FileDescriptor
+
+const file_api_calculator_v1_calculator_proto_rawDesc = "" +
+	"\n" +
+	"\"api/calculator/v1/calculator.proto\x12\rcalculator.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb8\x03\n" +
+	"\x10CalculateRequest\x12\x1d\n" +
+	"\n" +
+	"pack_sizes\x18\x01 \x03(\x03R\tpackSizes\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\x12'\n" +
+	"\x04mode\x18\x03 \x01(\x0e2\x13.calculator.v1.ModeR\x04mode\x126\n" +
+	"\tobjective\x18\x04 \x01(\x0e2\x18.calculator.v1.ObjectiveR\tobjective\x12@\n" +
+	"\x05stock\x18\x05 \x03(\v2*.calculator.v1.CalculateRequest.StockEntryR\x05stock\x12@\n" +
+	"\x05costs\x18\x06 \x03(\v2*.calculator.v1.CalculateRequest.CostsEntryR\x05costs\x12\x14\n" +
+	"\x05plans\x18\a \x01(\x05R\x05plans\x1a8\n" +
+	"\n" +
+	"StockEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a8\n" +
+	"\n" +
+	"CostsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xf8\x02\n" +
+	"\x04Plan\x12=\n" +
+	"\bpackages\x18\x01 \x03(\v2!.calculator.v1.Plan.PackagesEntryR\bpackages\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\x12\x1c\n" +
+	"\tovershoot\x18\x03 \x01(\x03R\tovershoot\x12\x1d\n" +
+	"\n" +
+	"pack_count\x18\x04 \x01(\x03R\tpackCount\x124\n" +
+	"\x05costs\x18\x05 \x03(\v2\x1e.calculator.v1.Plan.CostsEntryR\x05costs\x12\"\n" +
+	"\n" +
+	"total_cost\x18\x06 \x01(\x03H\x00R\ttotalCost\x88\x01\x01\x1a;\n" +
+	"\rPackagesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a8\n" +
+	"\n" +
+	"CostsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01B\r\n" +
+	"\v_total_cost\"\x9d\x01\n" +
+	"\x11CalculateResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\x12'\n" +
+	"\x04plan\x18\x03 \x01(\v2\x13.calculator.v1.PlanR\x04plan\x127\n" +
+	"\falternatives\x18\x04 \x03(\v2\x13.calculator.v1.PlanR\falternatives\"T\n" +
+	"\x15BatchCalculateRequest\x12;\n" +
+	"\brequests\x18\x01 \x03(\v2\x1f.calculator.v1.CalculateRequestR\brequests\"W\n" +
+	"\x16BatchCalculateResponse\x12=\n" +
+	"\aresults\x18\x01 \x03(\v2#.calculator.v1.BatchCalculateResultR\aresults\"\x8f\x01\n" +
+	"\x14BatchCalculateResult\x12>\n" +
+	"\bresponse\x18\x01 \x01(\v2 .calculator.v1.CalculateResponseH\x00R\bresponse\x12,\n" +
+	"\x05error\x18\x02 \x01(\v2\x14.calculator.v1.ErrorH\x00R\x05errorB\t\n" +
+	"\aoutcome\"5\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\rR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xfa\x02\n" +
+	"\x12ListHistoryRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12\"\n" +
+	"\n" +
+	"min_amount\x18\x03 \x01(\x03H\x00R\tminAmount\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"max_amount\x18\x04 \x01(\x03H\x01R\tmaxAmount\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"pack_sizes\x18\x05 \x03(\x03R\tpackSizes\x12=\n" +
+	"\fcreated_from\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\vcreatedFrom\x129\n" +
+	"\n" +
+	"created_to\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedTo\x12)\n" +
+	"\x10configuration_id\x18\b \x01(\x05R\x0fconfigurationIdB\r\n" +
+	"\v_min_amountB\r\n" +
+	"\v_max_amount\"}\n" +
+	"\x13ListHistoryResponse\x12>\n" +
+	"\fcalculations\x18\x01 \x03(\v2\x1a.calculator.v1.CalculationR\fcalculations\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"\xe7\x04\n" +
+	"\vCalculation\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x129\n" +
+	"\n" +
+	"created_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"pack_sizes\x18\x03 \x03(\x03R\tpackSizes\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x03R\x06amount\x12\x14\n" +
+	"\x05total\x18\x05 \x01(\x03R\x05total\x12D\n" +
+	"\bpackages\x18\x06 \x03(\v2(.calculator.v1.Calculation.PackagesEntryR\bpackages\x12;\n" +
+	"\x05stock\x18\a \x03(\v2%.calculator.v1.Calculation.StockEntryR\x05stock\x12\x1c\n" +
+	"\tobjective\x18\b \x01(\tR\tobjective\x12;\n" +
+	"\x05costs\x18\t \x03(\v2%.calculator.v1.Calculation.CostsEntryR\x05costs\x12\"\n" +
+	"\n" +
+	"total_cost\x18\n" +
+	" \x01(\x03H\x00R\ttotalCost\x88\x01\x01\x1a;\n" +
+	"\rPackagesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a8\n" +
+	"\n" +
+	"StockEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a8\n" +
+	"\n" +
+	"CostsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x03R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01B\r\n" +
+	"\v_total_cost*?\n" +
+	"\x04Mode\x12\x14\n" +
+	"\x10MODE_UNSPECIFIED\x10\x00\x12\x0e\n" +
+	"\n" +
+	"MODE_EXACT\x10\x01\x12\x11\n" +
+	"\rMODE_OVERFILL\x10\x02*]\n" +
+	"\tObjective\x12\x19\n" +
+	"\x15OBJECTIVE_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16OBJECTIVE_FEWEST_PACKS\x10\x01\x12\x19\n" +
+	"\x15OBJECTIVE_LOWEST_COST\x10\x022\x98\x02\n" +
+	"\x11CalculatorService\x12N\n" +
+	"\tCalculate\x12\x1f.calculator.v1.CalculateRequest\x1a .calculator.v1.CalculateResponse\x12]\n" +
+	"\x0eBatchCalculate\x12$.calculator.v1.BatchCalculateRequest\x1a%.calculator.v1.BatchCalculateResponse\x12T\n" +
+	"\vListHistory\x12!.calculator.v1.ListHistoryRequest\x1a\".calculator.v1.ListHistoryResponseB&Z$ignis/api/calculator/v1;calculatorv1b\x06proto3"
+
+var (
+	file_api_calculator_v1_calculator_proto_rawDescOnce sync.Once
+	file_api_calculator_v1_calculator_proto_rawDescData []byte
+)
+
+func file_api_calculator_v1_calculator_proto_rawDescGZIP() []byte {
+	file_api_calculator_v1_calculator_proto_rawDescOnce.Do(func() {
+		file_api_calculator_v1_calculator_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_calculator_v1_calculator_proto_rawDesc), len(file_api_calculator_v1_calculator_proto_rawDesc)))
+	})
+	return file_api_calculator_v1_calculator_proto_rawDescData
+}
+
+var file_api_calculator_v1_calculator_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_api_calculator_v1_calculator_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_api_calculator_v1_calculator_proto_goTypes = []any{
+	(Mode)(0),                      // 0: calculator.v1.Mode
+	(Objective)(0),                 // 1: calculator.v1.Objective
+	(*CalculateRequest)(nil),       // 2: calculator.v1.CalculateRequest
+	(*Plan)(nil),                   // 3: calculator.v1.Plan
+	(*CalculateResponse)(nil),      // 4: calculator.v1.CalculateResponse
+	(*BatchCalculateRequest)(nil),  // 5: calculator.v1.BatchCalculateRequest
+	(*BatchCalculateResponse)(nil), // 6: calculator.v1.BatchCalculateResponse
+	(*BatchCalculateResult)(nil),   // 7: calculator.v1.BatchCalculateResult
+	(*Error)(nil),                  // 8: calculator.v1.Error
+	(*ListHistoryRequest)(nil),     // 9: calculator.v1.ListHistoryRequest
+	(*ListHistoryResponse)(nil),    // 10: calculator.v1.ListHistoryResponse
+	(*Calculation)(nil),            // 11: calculator.v1.Calculation
+	nil,                            // 12: calculator.v1.CalculateRequest.StockEntry
+	nil,                            // 13: calculator.v1.CalculateRequest.CostsEntry
+	nil,                            // 14: calculator.v1.Plan.PackagesEntry
+	nil,                            // 15: calculator.v1.Plan.CostsEntry
+	nil,                            // 16: calculator.v1.Calculation.PackagesEntry
+	nil,                            // 17: calculator.v1.Calculation.StockEntry
+	nil,                            // 18: calculator.v1.Calculation.CostsEntry
+	(*timestamppb.Timestamp)(nil),  // 19: google.protobuf.Timestamp
+}
+var file_api_calculator_v1_calculator_proto_depIdxs = []int32{
+	0,  // 0: calculator.v1.CalculateRequest.mode:type_name -> calculator.v1.Mode
+	1,  // 1: calculator.v1.CalculateRequest.objective:type_name -> calculator.v1.Objective
+	12, // 2: calculator.v1.CalculateRequest.stock:type_name -> calculator.v1.CalculateRequest.StockEntry
+	13, // 3: calculator.v1.CalculateRequest.costs:type_name -> calculator.v1.CalculateRequest.CostsEntry
+	14, // 4: calculator.v1.Plan.packages:type_name -> calculator.v1.Plan.PackagesEntry
+	15, // 5: calculator.v1.Plan.costs:type_name -> calculator.v1.Plan.CostsEntry
+	3,  // 6: calculator.v1.CalculateResponse.plan:type_name -> calculator.v1.Plan
+	3,  // 7: calculator.v1.CalculateResponse.alternatives:type_name -> calculator.v1.Plan
+	2,  // 8: calculator.v1.BatchCalculateRequest.requests:type_name -> calculator.v1.CalculateRequest
+	7,  // 9: calculator.v1.BatchCalculateResponse.results:type_name -> calculator.v1.BatchCalculateResult
+	4,  // 10: calculator.v1.BatchCalculateResult.response:type_name -> calculator.v1.CalculateResponse
+	8,  // 11: calculator.v1.BatchCalculateResult.error:type_name -> calculator.v1.Error
+	19, // 12: calculator.v1.ListHistoryRequest.created_from:type_name -> google.protobuf.Timestamp
+	19, // 13: calculator.v1.ListHistoryRequest.created_to:type_name -> google.protobuf.Timestamp
+	11, // 14: calculator.v1.ListHistoryResponse.calculations:type_name -> calculator.v1.Calculation
+	19, // 15: calculator.v1.Calculation.created_at:type_name -> google.protobuf.Timestamp
+	16, // 16: calculator.v1.Calculation.packages:type_name -> calculator.v1.Calculation.PackagesEntry
+	17, // 17: calculator.v1.Calculation.stock:type_name -> calculator.v1.Calculation.StockEntry
+	18, // 18: calculator.v1.Calculation.costs:type_name -> calculator.v1.Calculation.CostsEntry
+	2,  // 19: calculator.v1.CalculatorService.Calculate:input_type -> calculator.v1.CalculateRequest
+	5,  // 20: calculator.v1.CalculatorService.BatchCalculate:input_type -> calculator.v1.BatchCalculateRequest
+	9,  // 21: calculator.v1.CalculatorService.ListHistory:input_type -> calculator.v1.ListHistoryRequest
+	4,  // 22: calculator.v1.CalculatorService.Calculate:output_type -> calculator.v1.CalculateResponse
+	6,  // 23: calculator.v1.CalculatorService.BatchCalculate:output_type -> calculator.v1.BatchCalculateResponse
+	10, // 24: calculator.v1.CalculatorService.ListHistory:output_type -> calculator.v1.ListHistoryResponse
+	22, // [22:25] is the sub-list for method output_type
+	19, // [19:22] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
+}
+
+func init() { file_api_calculator_v1_calculator_proto_init() }
+func file_api_calculator_v1_calculator_proto_init() {
+	if File_api_calculator_v1_calculator_proto != nil {
+		return
+	}
+	file_api_calculator_v1_calculator_proto_msgTypes[1].OneofWrappers = []any{}
+	file_api_calculator_v1_calculator_proto_msgTypes[5].OneofWrappers = []any{
+		(*BatchCalculateResult_Response)(nil),
+		(*BatchCalculateResult_Error)(nil),
+	}
+	file_api_calculator_v1_calculator_proto_msgTypes[7].OneofWrappers = []any{}
+	file_api_calculator_v1_calculator_proto_msgTypes[9].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_calculator_v1_calculator_proto_rawDesc), len(file_api_calculator_v1_calculator_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_calculator_v1_calculator_proto_goTypes,
+		DependencyIndexes: file_api_calculator_v1_calculator_proto_depIdxs,
+		EnumInfos:         file_api_calculator_v1_calculator_proto_enumTypes,
+		MessageInfos:      file_api_calculator_v1_calculator_proto_msgTypes,
+	}.Build()
+	File_api_calculator_v1_calculator_proto = out.File
+	file_api_calculator_v1_calculator_proto_goTypes = nil
+	file_api_calculator_v1_calculator_proto_depIdxs = nil
+}
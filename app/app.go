@@ -2,18 +2,28 @@ package app
 
 import (
 	"context"
+	calculatorv1 "ignis/api/calculator/v1"
 	"ignis/closer"
 	"ignis/config"
 	"ignis/internal/adapter/api"
+	"ignis/internal/adapter/grpcapi"
 	"log"
+	"net"
 	"net/http"
 	"sync"
 	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 type App struct {
 	serviceProvider *serviceProvider
 	httpServer      *http.Server
+	swaggerServer   *http.Server
+	grpcServer      *grpc.Server
 }
 
 func NewApp() (*App, error) {
@@ -25,11 +35,20 @@ func NewApp() (*App, error) {
 
 	a.initServiceProvider()
 
+	a.initJobPool()
+
 	err = a.initHTTPServer()
 	if err != nil {
 		return nil, err
 	}
 
+	err = a.initSwaggerServer()
+	if err != nil {
+		return nil, err
+	}
+
+	a.initGRPCServer()
+
 	return a, nil
 }
 
@@ -47,6 +66,20 @@ func (a *App) Run() error {
 		}
 	})
 
+	wg.Go(func() {
+		err := a.runGRPCServer()
+		if err != nil {
+			log.Printf("failed to run gRPC server: %v\n", err)
+		}
+	})
+
+	wg.Go(func() {
+		err := a.runSwaggerServer()
+		if err != nil {
+			log.Printf("failed to run Swagger server: %v\n", err)
+		}
+	})
+
 	wg.Wait()
 
 	return nil
@@ -65,17 +98,65 @@ func (a *App) initServiceProvider() {
 	a.serviceProvider = newServiceProvider()
 }
 
-func (a *App) initHTTPServer() error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", api.RootHandler)
+// httpRoute is a ServeMux pattern, including its method, and its handler
+type httpRoute struct {
+	pattern string
+	handler http.HandlerFunc
+}
 
+// httpRoutes lists the routes of the HTTP server, each of them is described
+// in the OpenAPI spec served next to the Swagger UI
+func (a *App) httpRoutes() []httpRoute {
 	// Calculator handler
 	calcRepo := a.serviceProvider.DBRepository(context.Background())
-	calculatorHandler := api.NewCalculatorHandler(a.serviceProvider.PackageCalculator(), calcRepo)
-	mux.HandleFunc("/api/v1/calculate", calculatorHandler.Calculate)
-	mux.HandleFunc("/api/v1/history", calculatorHandler.History)
+	calculatorHandler := api.NewCalculatorHandler(a.serviceProvider.PackageCalculator(context.Background()), calcRepo)
+	configurationHandler := api.NewConfigurationHandler(calcRepo)
+	jobHandler := api.NewJobHandler(calcRepo, a.serviceProvider.JobPool(context.Background()))
+
+	return []httpRoute{
+		{"GET /{$}", api.RootHandler},
+		{"POST /api/v1/calculate", calculatorHandler.Calculate},
+		{"POST /api/v1/calculate/batch", calculatorHandler.CalculateBatch},
+		{"GET /api/v1/feasibility", calculatorHandler.Feasibility},
+		{"POST /api/v1/import", calculatorHandler.ImportOrders},
+		{"POST /api/v1/orders", calculatorHandler.CreateOrder},
+		{"GET /api/v1/orders/{id}", calculatorHandler.GetOrder},
+		{"POST /api/v1/jobs", jobHandler.Submit},
+		{"GET /api/v1/jobs/{id}", jobHandler.Get},
+		{"GET /api/v1/cache/stats", calculatorHandler.CacheStats},
+		{"GET /api/v1/history", calculatorHandler.History},
+		{"GET /api/v1/history/export", calculatorHandler.ExportHistory},
+		{"GET /api/v1/history/{id}", calculatorHandler.HistoryDetail},
+		{"POST /api/v1/history/{id}/replay", calculatorHandler.ReplayCalculation},
+		{"DELETE /api/v1/history/{id}", calculatorHandler.DeleteCalculation},
+		{"GET /api/v1/configurations", configurationHandler.List},
+		{"POST /api/v1/configurations", configurationHandler.Create},
+		{"GET /api/v1/configurations/{id}", configurationHandler.Get},
+		{"GET /api/v1/configurations/{id}/versions", configurationHandler.Versions},
+		{"PUT /api/v1/configurations/{id}", configurationHandler.Update},
+		{"DELETE /api/v1/configurations/{id}", configurationHandler.Delete},
+		{"GET /healthz", api.HealthHandler},
+	}
+}
+
+// initJobPool starts the background job workers. On shutdown they return
+// their running jobs to the queue, the next start picks them up again.
+func (a *App) initJobPool() {
+	pool := a.serviceProvider.JobPool(context.Background())
+	pool.Start()
+
+	closer.Add(pool.Close)
+}
+
+func (a *App) initHTTPServer() error {
+	mux := http.NewServeMux()
+	for _, route := range a.httpRoutes() {
+		mux.HandleFunc(route.pattern, route.handler)
+	}
 
-	mux.HandleFunc("/healthz", api.HealthHandler)
+	// Request contexts derive from baseCtx, cancelling it on shutdown stops
+	// in-flight calculations instead of waiting for them to finish
+	baseCtx, cancel := context.WithCancel(context.Background())
 
 	a.httpServer = &http.Server{
 		Addr:         a.serviceProvider.HTTPConfig().Address(),
@@ -83,15 +164,92 @@ func (a *App) initHTTPServer() error {
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 
 	closer.Add(func(ctx context.Context) error {
+		cancel()
 		return a.httpServer.Shutdown(ctx)
 	})
 
 	return nil
 }
 
+func (a *App) initGRPCServer() {
+	a.grpcServer = grpc.NewServer()
+
+	calcRepo := a.serviceProvider.DBRepository(context.Background())
+	calculatorv1.RegisterCalculatorServiceServer(a.grpcServer, grpcapi.NewCalculatorServer(a.serviceProvider.PackageCalculator(context.Background()), calcRepo))
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(a.grpcServer, healthServer)
+	reflection.Register(a.grpcServer)
+
+	// GracefulStop waits for in-flight calls, Stop cancels them once the shutdown deadline passes
+	closer.Add(func(ctx context.Context) error {
+		healthServer.Shutdown()
+
+		stopped := make(chan struct{})
+		go func() {
+			a.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			a.grpcServer.Stop()
+			return ctx.Err()
+		}
+	})
+}
+
+func (a *App) runGRPCServer() error {
+	lis, err := net.Listen("tcp", a.serviceProvider.GRPCConfig().Address())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("gRPC Server is running on %v\n", a.serviceProvider.GRPCConfig().Address())
+
+	return a.grpcServer.Serve(lis)
+}
+
+func (a *App) initSwaggerServer() error {
+	spec, err := api.OpenAPISpec()
+	if err != nil {
+		return err
+	}
+
+	a.swaggerServer = &http.Server{
+		Addr:         a.serviceProvider.SwaggerConfig().Address(),
+		Handler:      api.NewSwaggerHandler(spec, a.serviceProvider.HTTPConfig().Address()),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+
+	closer.Add(func(ctx context.Context) error {
+		return a.swaggerServer.Shutdown(ctx)
+	})
+
+	return nil
+}
+
+func (a *App) runSwaggerServer() error {
+	log.Printf("Swagger UI is running on %v\n", a.serviceProvider.SwaggerConfig().Address())
+
+	err := a.swaggerServer.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
 func (a *App) runHTTPServer() error {
 	log.Printf("HTTP Server is running on %v\n", a.serviceProvider.HTTPConfig().Address())
 
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"encoding/json"
+	"ignis/internal/adapter/api"
+	"ignis/internal/adapter/db"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stubRepository satisfies db.Repository for building routes without a database
+type stubRepository struct {
+	db.Repository
+}
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	t.Setenv("HTTP_HOST", "127.0.0.1")
+	t.Setenv("HTTP_PORT", "0")
+
+	a := &App{serviceProvider: &serviceProvider{dbRepository: stubRepository{}}}
+	if err := a.initHTTPServer(); err != nil {
+		t.Fatalf("failed to init HTTP server: %v", err)
+	}
+	mux := a.httpServer.Handler.(*http.ServeMux)
+
+	rawSpec, err := api.OpenAPISpec()
+	if err != nil {
+		t.Fatalf("failed to build spec: %v", err)
+	}
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rawSpec, &spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("expected an OpenAPI 3 document, got version %q", spec.OpenAPI)
+	}
+
+	// Every documented operation is served by a route for exactly that method and path
+	documented := make(map[string]bool)
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			operation := strings.ToUpper(method) + " " + path
+			documented[operation] = true
+
+			req := httptest.NewRequest(strings.ToUpper(method), path, nil)
+			_, pattern := mux.Handler(req)
+			if strings.TrimSuffix(pattern, "{$}") != operation {
+				t.Errorf("documented %s is served by route %q", operation, pattern)
+			}
+		}
+	}
+
+	// Every route is documented
+	for _, route := range a.httpRoutes() {
+		operation := strings.TrimSuffix(route.pattern, "{$}")
+		if !documented[operation] {
+			t.Errorf("route %q is missing from the spec", route.pattern)
+		}
+	}
+}
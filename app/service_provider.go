@@ -3,7 +3,9 @@ package app
 import (
 	"context"
 	"ignis/config"
+	"ignis/internal/adapter/api"
 	"ignis/internal/adapter/db"
+	"ignis/internal/adapter/jobs"
 	"ignis/internal/domain"
 	"ignis/internal/service"
 	"log"
@@ -13,11 +15,17 @@ import (
 
 type serviceProvider struct {
 	httpConfig             config.HTTPConfig
+	swaggerConfig          config.SwaggerConfig
+	grpcConfig             config.GRPCCnfig
 	gracefulShutdownConfig config.GracefulShutdownConfig
 	pgConfig               config.PGConfig
+	calculatorConfig       config.CalculatorConfig
+	jobsConfig             config.JobsConfig
+	cacheConfig            config.CacheConfig
 	pgPool                 *pgxpool.Pool
 	dbRepository           db.Repository
 	packageCalculator      domain.PackageCalculator
+	jobPool                *jobs.Pool
 }
 
 func newServiceProvider() *serviceProvider {
@@ -37,6 +45,32 @@ func (s *serviceProvider) HTTPConfig() config.HTTPConfig {
 	return s.httpConfig
 }
 
+func (s *serviceProvider) SwaggerConfig() config.SwaggerConfig {
+	if s.swaggerConfig == nil {
+		cfg, err := config.NewSwaggerConfig()
+		if err != nil {
+			log.Fatalf("failed to get swagger config: %s", err.Error())
+		}
+
+		s.swaggerConfig = cfg
+	}
+
+	return s.swaggerConfig
+}
+
+func (s *serviceProvider) GRPCConfig() config.GRPCCnfig {
+	if s.grpcConfig == nil {
+		cfg, err := config.NewGRPCConfig()
+		if err != nil {
+			log.Fatalf("failed to get grpc config: %s", err.Error())
+		}
+
+		s.grpcConfig = cfg
+	}
+
+	return s.grpcConfig
+}
+
 func (s *serviceProvider) GracefulShutdownConfig() config.GracefulShutdownConfig {
 	if s.gracefulShutdownConfig == nil {
 		cfg, err := config.NewGracefulShutdownConfig()
@@ -63,6 +97,45 @@ func (s *serviceProvider) PGConfig() config.PGConfig {
 	return s.pgConfig
 }
 
+func (s *serviceProvider) CalculatorConfig() config.CalculatorConfig {
+	if s.calculatorConfig == nil {
+		cfg, err := config.NewCalculatorConfig()
+		if err != nil {
+			log.Fatalf("failed to get calculator config: %s", err.Error())
+		}
+
+		s.calculatorConfig = cfg
+	}
+
+	return s.calculatorConfig
+}
+
+func (s *serviceProvider) JobsConfig() config.JobsConfig {
+	if s.jobsConfig == nil {
+		cfg, err := config.NewJobsConfig()
+		if err != nil {
+			log.Fatalf("failed to get jobs config: %s", err.Error())
+		}
+
+		s.jobsConfig = cfg
+	}
+
+	return s.jobsConfig
+}
+
+func (s *serviceProvider) CacheConfig() config.CacheConfig {
+	if s.cacheConfig == nil {
+		cfg, err := config.NewCacheConfig()
+		if err != nil {
+			log.Fatalf("failed to get cache config: %s", err.Error())
+		}
+
+		s.cacheConfig = cfg
+	}
+
+	return s.cacheConfig
+}
+
 func (s *serviceProvider) PGPool(ctx context.Context) *pgxpool.Pool {
 	if s.pgPool == nil {
 		pool, err := pgxpool.New(ctx, s.PGConfig().DSN())
@@ -89,10 +162,51 @@ func (s *serviceProvider) DBRepository(ctx context.Context) db.Repository {
 	return s.dbRepository
 }
 
-func (s *serviceProvider) PackageCalculator() domain.PackageCalculator {
+// PackageCalculator is the calculator with the configured limits, reusing DP
+// tables unless CALC_TABLE_CACHE_MB is 0 and behind a result cache when
+// CALC_CACHE_SIZE is set
+func (s *serviceProvider) PackageCalculator(ctx context.Context) domain.PackageCalculator {
 	if s.packageCalculator == nil {
-		s.packageCalculator = service.NewPackageCalculatorService()
+		cfg := s.CalculatorConfig()
+		limits := service.Limits{
+			MaxAmount:      cfg.MaxAmount(),
+			MaxPackSizes:   cfg.MaxPackSizes(),
+			MaxMemoryBytes: cfg.MaxMemoryBytes(),
+		}
+		if cfg.TableCacheBytes() > 0 {
+			s.packageCalculator = service.NewPackageCalculatorServiceWithTables(limits, service.NewTableRegistry(cfg.TableCacheBytes()))
+		} else {
+			s.packageCalculator = service.NewPackageCalculatorServiceWithLimits(limits)
+		}
+
+		if cacheCfg := s.CacheConfig(); cacheCfg.Size() > 0 {
+			opts := service.CacheOptions{Size: cacheCfg.Size(), TTL: cacheCfg.TTL()}
+			if cacheCfg.Persist() {
+				store := db.NewResultStore(s.DBRepository(ctx))
+				if pruned, err := store.Prune(ctx); err != nil {
+					log.Printf("failed to prune cached results: %v\n", err)
+				} else if pruned > 0 {
+					log.Printf("pruned %d expired cached results\n", pruned)
+				}
+				opts.Store = store
+			}
+			s.packageCalculator = service.NewCachingCalculator(s.packageCalculator, opts)
+		}
 	}
 
 	return s.packageCalculator
 }
+
+// JobPool runs background jobs with the calculator, its workers start with the app
+func (s *serviceProvider) JobPool(ctx context.Context) *jobs.Pool {
+	if s.jobPool == nil {
+		cfg := s.JobsConfig()
+		repo := s.DBRepository(ctx)
+		s.jobPool = jobs.NewPool(repo, api.NewJobRunner(s.PackageCalculator(ctx), repo), jobs.Options{
+			Workers: cfg.Workers(),
+			Lease:   cfg.Lease(),
+		})
+	}
+
+	return s.jobPool
+}
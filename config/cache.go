@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	calcCacheSize    = "CALC_CACHE_SIZE"
+	calcCacheTTL     = "CALC_CACHE_TTL"
+	calcCachePersist = "CALC_CACHE_PERSIST"
+
+	defaultCacheSize = 0
+)
+
+type CacheConfig interface {
+	// Size is the most results held in memory, 0 disables the cache
+	Size() int
+	// TTL is how long a result stays valid, 0 keeps it until evicted
+	TTL() time.Duration
+	// Persist stores results in Postgres as well
+	Persist() bool
+}
+
+type cacheConfig struct {
+	size    int
+	ttl     time.Duration
+	persist bool
+}
+
+// Size implements CacheConfig.
+func (cfg *cacheConfig) Size() int {
+	return cfg.size
+}
+
+// TTL implements CacheConfig.
+func (cfg *cacheConfig) TTL() time.Duration {
+	return cfg.ttl
+}
+
+// Persist implements CacheConfig.
+func (cfg *cacheConfig) Persist() bool {
+	return cfg.persist
+}
+
+// NewCacheConfig reads the result cache settings, the cache is off unless
+// CALC_CACHE_SIZE is set
+func NewCacheConfig() (CacheConfig, error) {
+	size, err := intEnv(calcCacheSize, defaultCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var ttl time.Duration
+	if ttlStr := os.Getenv(calcCacheTTL); len(ttlStr) > 0 {
+		ttl, err = time.ParseDuration(ttlStr)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("env %v must be a non-negative duration, e.g. 10m", calcCacheTTL)
+		}
+	}
+
+	var persist bool
+	if persistStr := os.Getenv(calcCachePersist); len(persistStr) > 0 {
+		persist, err = strconv.ParseBool(persistStr)
+		if err != nil {
+			return nil, fmt.Errorf("env %v must be a boolean", calcCachePersist)
+		}
+	}
+
+	return &cacheConfig{
+		size:    size,
+		ttl:     ttl,
+		persist: persist,
+	}, nil
+}
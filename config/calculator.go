@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	calcMaxAmount    = "CALC_MAX_AMOUNT"
+	calcMaxPackSizes = "CALC_MAX_PACK_SIZES"
+	calcMaxMemoryMB  = "CALC_MAX_MEMORY_MB"
+	calcTableCacheMB = "CALC_TABLE_CACHE_MB"
+
+	defaultMaxAmount    = 2_000_000_000
+	defaultMaxPackSizes = 64
+	defaultMaxMemoryMB  = 512
+	defaultTableCacheMB = 256
+)
+
+type CalculatorConfig interface {
+	MaxAmount() int
+	MaxPackSizes() int
+	MaxMemoryBytes() int64
+	// TableCacheBytes is the memory kept for DP tables reused across requests, 0 disables reuse
+	TableCacheBytes() int64
+}
+
+type calculatorConfig struct {
+	maxAmount    int
+	maxPackSizes int
+	maxMemoryMB  int
+	tableCacheMB int
+}
+
+// MaxAmount implements CalculatorConfig.
+func (cfg *calculatorConfig) MaxAmount() int {
+	return cfg.maxAmount
+}
+
+// MaxPackSizes implements CalculatorConfig.
+func (cfg *calculatorConfig) MaxPackSizes() int {
+	return cfg.maxPackSizes
+}
+
+// MaxMemoryBytes implements CalculatorConfig.
+func (cfg *calculatorConfig) MaxMemoryBytes() int64 {
+	return int64(cfg.maxMemoryMB) << 20
+}
+
+// TableCacheBytes implements CalculatorConfig.
+func (cfg *calculatorConfig) TableCacheBytes() int64 {
+	return int64(cfg.tableCacheMB) << 20
+}
+
+// NewCalculatorConfig reads the calculator limits, unset variables fall back to defaults
+func NewCalculatorConfig() (CalculatorConfig, error) {
+	maxAmount, err := intEnv(calcMaxAmount, defaultMaxAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	maxPackSizes, err := intEnv(calcMaxPackSizes, defaultMaxPackSizes)
+	if err != nil {
+		return nil, err
+	}
+
+	maxMemoryMB, err := intEnv(calcMaxMemoryMB, defaultMaxMemoryMB)
+	if err != nil {
+		return nil, err
+	}
+
+	tableCacheMB, err := intEnv(calcTableCacheMB, defaultTableCacheMB)
+	if err != nil {
+		return nil, err
+	}
+
+	return &calculatorConfig{
+		maxAmount:    maxAmount,
+		maxPackSizes: maxPackSizes,
+		maxMemoryMB:  maxMemoryMB,
+		tableCacheMB: tableCacheMB,
+	}, nil
+}
+
+// intEnv reads a non-negative integer env variable, returning def when it is unset
+func intEnv(name string, def int) (int, error) {
+	valueStr := os.Getenv(name)
+	if len(valueStr) == 0 {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("env %v must be a non-negative integer", name)
+	}
+
+	return value, nil
+}
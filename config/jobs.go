@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+const (
+	jobWorkers      = "JOB_WORKERS"
+	jobLeaseSeconds = "JOB_LEASE_SECONDS"
+
+	defaultJobWorkers      = 2
+	defaultJobLeaseSeconds = 60
+)
+
+type JobsConfig interface {
+	Workers() int
+	Lease() time.Duration
+}
+
+type jobsConfig struct {
+	workers      int
+	leaseSeconds int
+}
+
+// Workers implements JobsConfig.
+func (cfg *jobsConfig) Workers() int {
+	return cfg.workers
+}
+
+// Lease implements JobsConfig.
+func (cfg *jobsConfig) Lease() time.Duration {
+	return time.Duration(cfg.leaseSeconds) * time.Second
+}
+
+// NewJobsConfig reads the background job settings, unset variables fall back to defaults
+func NewJobsConfig() (JobsConfig, error) {
+	workers, err := intEnv(jobWorkers, defaultJobWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	leaseSeconds, err := intEnv(jobLeaseSeconds, defaultJobLeaseSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobsConfig{
+		workers:      workers,
+		leaseSeconds: leaseSeconds,
+	}, nil
+}
@@ -0,0 +1,358 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/domain"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxBatchItems caps the number of items of a single batch request
+const maxBatchItems = 10000
+
+// maxBatchBodyBytes caps the size of a batch request body
+const maxBatchBodyBytes = 8 << 20
+
+// batchItemJSON is one (packSizes, amount) pair of a batch
+type batchItemJSON struct {
+	PackSizes       []int            `json:"packSizes,omitempty"`
+	ConfigurationID int32            `json:"configurationId,omitempty"`
+	Amount          int              `json:"amount"`
+	Mode            string           `json:"mode,omitempty"`
+	Objective       string           `json:"objective,omitempty"`
+	Stock           map[int]int      `json:"stock,omitempty"`
+	Costs           map[int]int      `json:"costs,omitempty"`
+	Constraints     *constraintsJSON `json:"constraints,omitempty"`
+	Packaging       *packagingJSON   `json:"packaging,omitempty"`
+	Shipping        *shippingJSON    `json:"shipping,omitempty"`
+}
+
+// batchRequestJSON is the JSON body accepted by /api/v1/calculate/batch. Amounts
+// share the top-level pack sizes and settings, items bring their own. Results
+// list the amounts first, then the items, each in request order. A configuration
+// ID replaces the pack sizes with those of the saved configuration.
+type batchRequestJSON struct {
+	PackSizes       []int           `json:"packSizes,omitempty"`
+	ConfigurationID int32           `json:"configurationId,omitempty"`
+	Mode            string          `json:"mode,omitempty"`
+	Objective       string          `json:"objective,omitempty"`
+	Stock           map[int]int     `json:"stock,omitempty"`
+	Costs           map[int]int     `json:"costs,omitempty"`
+	Amounts         []int           `json:"amounts,omitempty"`
+	Items           []batchItemJSON `json:"items,omitempty"`
+}
+
+// batchItemResultJSON is the plan of a batch item, or its error with the status
+// /api/v1/calculate would have answered with
+type batchItemResultJSON struct {
+	Index  int   `json:"index"`
+	ID     int32 `json:"id,omitempty"`
+	Amount int   `json:"amount"`
+	*planJSON
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+// batchResponseJSON is the JSON answer of /api/v1/calculate/batch; BatchID is
+// omitted when the batch was not saved
+type batchResponseJSON struct {
+	BatchID   int32                 `json:"batchId,omitempty"`
+	Succeeded int                   `json:"succeeded"`
+	Failed    int                   `json:"failed"`
+	Items     []batchItemResultJSON `json:"items"`
+}
+
+// batchProgressChunk is how many items a background batch calculates between
+// progress reports. Items sharing a pack-size set only share a DP table within
+// a chunk, so chunks stay large.
+const batchProgressChunk = 500
+
+// errLoadConfiguration is a batch that failed because its configurations could not be loaded
+var errLoadConfiguration = errors.New("failed to load configuration")
+
+// CalculateBatch answers many amounts in one JSON request. Items sharing a
+// pack-size set are answered from one DP table, and the batch is stored as a unit.
+func (h *CalculatorHandler) CalculateBatch(w http.ResponseWriter, r *http.Request) {
+	items, ok := decodeBatchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.calculateBatch(r.Context(), items, nil)
+	if err != nil {
+		writeBatchError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// decodeBatchRequest reads a batch body into its items, amounts first. It
+// answers invalid bodies itself and then returns false.
+func decodeBatchRequest(w http.ResponseWriter, r *http.Request) ([]batchItemJSON, bool) {
+	if !isJSONBody(r) {
+		writeError(w, true, http.StatusUnsupportedMediaType, "Batch requests must be application/json")
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	var body batchRequestJSON
+	if err := decoder.Decode(&body); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			writeError(w, true, http.StatusRequestEntityTooLarge, fmt.Sprintf("Batch body exceeds %d bytes", maxBatchBodyBytes))
+		case errors.Is(err, io.EOF):
+			writeError(w, true, http.StatusBadRequest, "Invalid JSON body: empty")
+		default:
+			writeError(w, true, http.StatusBadRequest, fmt.Sprintf("Invalid JSON body: %s", err.Error()))
+		}
+		return nil, false
+	}
+
+	items := make([]batchItemJSON, 0, len(body.Amounts)+len(body.Items))
+	for _, amount := range body.Amounts {
+		items = append(items, batchItemJSON{
+			PackSizes:       body.PackSizes,
+			ConfigurationID: body.ConfigurationID,
+			Amount:          amount,
+			Mode:            body.Mode,
+			Objective:       body.Objective,
+			Stock:           body.Stock,
+			Costs:           body.Costs,
+		})
+	}
+	items = append(items, body.Items...)
+
+	if len(items) == 0 {
+		writeError(w, true, http.StatusBadRequest, "Invalid batch: no amounts or items")
+		return nil, false
+	}
+	if len(items) > maxBatchItems {
+		writeError(w, true, http.StatusRequestEntityTooLarge, fmt.Sprintf("Batch of %d items exceeds the limit of %d", len(items), maxBatchItems))
+		return nil, false
+	}
+
+	return items, true
+}
+
+// calculateBatch answers and stores the items of a batch. Items that fail
+// carry their error, only a cancelled ctx or a database failure fail the
+// batch. A non-nil progress is told the number of items done after each chunk.
+func (h *CalculatorHandler) calculateBatch(ctx context.Context, items []batchItemJSON, progress func(done int)) (batchResponseJSON, error) {
+	run, err := h.runBatch(ctx, items, progress)
+	if err != nil {
+		return batchResponseJSON{}, err
+	}
+	// A cancelled run, such as a job whose lease was taken over, stores nothing
+	if err := ctx.Err(); err != nil {
+		return batchResponseJSON{}, err
+	}
+
+	h.saveBatch(ctx, run)
+
+	return run.resp, nil
+}
+
+// batchRun is a calculated batch before it is stored
+type batchRun struct {
+	versions     []dbsqlc.PackConfigurationVersion // configuration version of each item, if any
+	reqs         []domain.CalculateRequest
+	results      []domain.BatchResult // results of the valid items
+	validIndexes []int                // item of each result
+	resp         batchResponseJSON
+}
+
+// runBatch answers the items of a batch like calculateBatch without storing them
+func (h *CalculatorHandler) runBatch(ctx context.Context, items []batchItemJSON, progress func(done int)) (*batchRun, error) {
+	// Items that do not parse fail on their own, the rest go to the calculator together
+	reqs := make([]domain.CalculateRequest, len(items))
+	errs := make([]error, len(items))
+	var valid []domain.CalculateRequest
+	var validIndexes []int
+	versions := make([]dbsqlc.PackConfigurationVersion, len(items))
+	active := make(map[int32]dbsqlc.PackConfigurationVersion) // unknown IDs map to the zero value
+	for i, item := range items {
+		reqs[i], errs[i] = item.toRequest()
+		if errs[i] == nil && item.ConfigurationID != 0 {
+			version, ok := active[item.ConfigurationID]
+			if !ok {
+				var err error
+				var unknownErr *unknownConfigurationError
+				version, err = lookupConfiguration(ctx, h.repo, item.ConfigurationID)
+				if err != nil && !errors.As(err, &unknownErr) {
+					return nil, fmt.Errorf("%w: %w", errLoadConfiguration, err)
+				}
+				active[item.ConfigurationID] = version
+			}
+			if version.ID == 0 {
+				errs[i] = &unknownConfigurationError{id: item.ConfigurationID}
+			}
+			reqs[i].PackSizes = db.PackSizesOf(version.PackSizes)
+			versions[i] = version
+		}
+		if errs[i] == nil {
+			valid = append(valid, reqs[i])
+			validIndexes = append(validIndexes, i)
+		}
+	}
+
+	chunk := len(valid)
+	if progress != nil {
+		chunk = batchProgressChunk
+	}
+	results := make([]domain.BatchResult, 0, len(valid))
+	for start := 0; start < len(valid); start += chunk {
+		end := min(start+chunk, len(valid))
+		chunkResults, err := h.calculator.CalculateBatch(ctx, valid[start:end])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunkResults...)
+		if progress != nil {
+			progress(len(items) - len(valid) + end)
+		}
+	}
+
+	resp := batchResponseJSON{Items: make([]batchItemResultJSON, len(items))}
+	for i := range items {
+		resp.Items[i] = batchItemResultJSON{Index: i, Amount: items[i].Amount}
+		if errs[i] != nil {
+			resp.Items[i].Error = fmt.Sprintf("Invalid %s", errs[i].Error())
+			resp.Items[i].Status = http.StatusBadRequest
+		}
+	}
+	for j, result := range results {
+		item := &resp.Items[validIndexes[j]]
+		if result.Err != nil {
+			item.Status = errorStatus(result.Err)
+			item.Error = fmt.Sprintf("Calculation error: %s", result.Err.Error())
+			if item.Status == http.StatusInternalServerError {
+				log.Printf("batch item %d failed: %v\n", item.Index, result.Err)
+				item.Error = "Calculation failed, please try again later"
+			}
+			continue
+		}
+		plan := newPlanJSON(result.Result)
+		item.planJSON = &plan
+	}
+
+	for _, item := range resp.Items {
+		if item.planJSON != nil {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+
+	return &batchRun{
+		versions:     versions,
+		reqs:         reqs,
+		results:      results,
+		validIndexes: validIndexes,
+		resp:         resp,
+	}, nil
+}
+
+// writeBatchError answers a batch that failed as a whole
+func writeBatchError(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	switch {
+	case errors.Is(err, errLoadConfiguration):
+		log.Printf("%v\n", err)
+		writeError(w, true, http.StatusInternalServerError, "Failed to load configuration, please try again later")
+	case status == http.StatusInternalServerError:
+		log.Printf("batch calculation failed: %v\n", err)
+		writeError(w, true, status, "Calculation failed, please try again later")
+	default:
+		writeError(w, true, status, fmt.Sprintf("Calculation error: %s", err.Error()))
+	}
+}
+
+// toRequest converts an item, errors name the invalid field like parseCalculateForm
+func (item batchItemJSON) toRequest() (domain.CalculateRequest, error) {
+	if item.ConfigurationID < 0 {
+		return domain.CalculateRequest{}, fmt.Errorf("configuration: %d", item.ConfigurationID)
+	}
+	mode, err := domain.ParseMode(item.Mode)
+	if err != nil {
+		return domain.CalculateRequest{}, fmt.Errorf("mode: %s", item.Mode)
+	}
+	objective, err := domain.ParseObjective(item.Objective)
+	if err != nil {
+		return domain.CalculateRequest{}, fmt.Errorf("objective: %s", item.Objective)
+	}
+
+	return domain.CalculateRequest{
+		PackSizes:   item.PackSizes,
+		Amount:      item.Amount,
+		Mode:        mode,
+		Stock:       item.Stock,
+		Objective:   objective,
+		Costs:       item.Costs,
+		Constraints: item.Constraints.toConstraints(),
+		Packaging:   item.Packaging.toPackaging(),
+		Shipping:    item.Shipping.toLimits(),
+	}, nil
+}
+
+// saveBatch stores the batch as a unit and fills in the batch and calculation IDs
+func (h *CalculatorHandler) saveBatch(ctx context.Context, run *batchRun) {
+	if h.repo == nil {
+		return
+	}
+
+	batch, ids, err := h.repo.SaveBatch(ctx, run.storedItems())
+	if err != nil {
+		log.Printf("failed to save batch: %v\n", err)
+		return
+	}
+
+	run.resp.BatchID = batch.ID
+	for i := range run.resp.Items {
+		run.resp.Items[i].ID = ids[i]
+	}
+}
+
+// storedItems lists the items of the batch as they are stored; items are
+// linked to the configuration version they used, if any
+func (run *batchRun) storedItems() []db.BatchItem {
+	items := make([]db.BatchItem, len(run.resp.Items))
+	for i, item := range run.resp.Items {
+		items[i].Error = item.Error
+	}
+	for j, result := range run.results {
+		if result.Err != nil {
+			continue
+		}
+		i := run.validIndexes[j]
+		params := db.NewCreateCalculationParams(db.FormatPackSizes(run.reqs[i].PackSizes), run.reqs[i], result.Result)
+		params.ConfigurationID = pgtype.Int4{Int32: run.versions[i].ConfigurationID, Valid: run.versions[i].ID != 0}
+		params.ConfigurationVersionID = pgtype.Int4{Int32: run.versions[i].ID, Valid: run.versions[i].ID != 0}
+		items[i].Calculation = &params
+	}
+
+	return items
+}
+
+// plans lists the plan of each item, nil for the items that failed
+func (run *batchRun) plans() []*domain.CalculateResult {
+	plans := make([]*domain.CalculateResult, len(run.resp.Items))
+	for j, result := range run.results {
+		if result.Err == nil {
+			plans[run.validIndexes[j]] = result.Result
+		}
+	}
+
+	return plans
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"ignis/internal/domain"
+	"net/http"
+)
+
+// cacheStatsJSON reports on the result cache of the calculator
+type cacheStatsJSON struct {
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hitRatio"`
+	Entries  int     `json:"entries"`
+	Capacity int     `json:"capacity"`
+}
+
+// CacheStats returns the hit and miss counts of the result cache, or 404 when
+// the calculator does not cache its results
+func (h *CalculatorHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := h.calculator.(domain.CacheStatsReporter)
+	if !ok {
+		writeError(w, true, http.StatusNotFound, "The result cache is disabled")
+		return
+	}
+
+	stats := reporter.CacheStats()
+	entry := cacheStatsJSON{
+		Hits:     stats.Hits,
+		Misses:   stats.Misses,
+		Entries:  stats.Entries,
+		Capacity: stats.Capacity,
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		entry.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+
+	writeJSON(w, http.StatusOK, entry)
+}
@@ -0,0 +1,52 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ignis/internal/adapter/api"
+	"ignis/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCalculatorHandler_CacheStats(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), &MockRepository{})
+		w := httptest.NewRecorder()
+		h.CacheStats(w, httptest.NewRequest(http.MethodGet, "/api/v1/cache/stats", nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("counts hits and misses", func(t *testing.T) {
+		cache := service.NewCachingCalculator(service.NewPackageCalculatorService(), service.CacheOptions{Size: 100})
+		h := api.NewCalculatorHandler(cache, &MockRepository{})
+
+		body := `{"packSizes":[23,31,53],"amount":263}`
+		for range 3 {
+			w := httptest.NewRecorder()
+			h.Calculate(w, jsonRequest(http.MethodPost, "/api/v1/calculate", body))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+			}
+		}
+
+		w := httptest.NewRecorder()
+		h.CacheStats(w, httptest.NewRequest(http.MethodGet, "/api/v1/cache/stats", nil))
+		var stats struct {
+			Hits     uint64  `json:"hits"`
+			Misses   uint64  `json:"misses"`
+			HitRatio float64 `json:"hitRatio"`
+			Entries  int     `json:"entries"`
+			Capacity int     `json:"capacity"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("failed to decode stats: %v", err)
+		}
+		if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 || stats.Capacity != 100 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,463 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxConfigurationNameLength caps the name of a saved configuration
+const maxConfigurationNameLength = 100
+
+// configurationJSON is a saved pack-size catalog as of one of its versions
+type configurationJSON struct {
+	ID          int32      `json:"id"`
+	Name        string     `json:"name"`
+	PackSizes   []int      `json:"packSizes"`
+	Description string     `json:"description"`
+	VersionID   int32      `json:"versionId"`
+	ValidFrom   time.Time  `json:"validFrom"`
+	ValidTo     *time.Time `json:"validTo,omitempty"` // omitted while the version is open-ended
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// configurationVersionJSON is the pack sizes a configuration had over a period
+type configurationVersionJSON struct {
+	ID        int32      `json:"id"`
+	PackSizes []int      `json:"packSizes"`
+	ValidFrom time.Time  `json:"validFrom"`
+	ValidTo   *time.Time `json:"validTo,omitempty"`
+}
+
+// configurationRequestJSON is the JSON body accepted when creating or updating
+// a configuration
+type configurationRequestJSON struct {
+	Name        string     `json:"name"`
+	PackSizes   []int      `json:"packSizes"`
+	Description string     `json:"description,omitempty"`
+	ValidFrom   *time.Time `json:"validFrom,omitempty"` // when changed pack sizes take effect, now when omitted
+}
+
+// ConfigurationHandler serves the CRUD endpoints of named pack-size catalogs.
+// Changing the pack sizes of a configuration adds a version instead of
+// overwriting them, so calculations keep referring to the sizes they used.
+type ConfigurationHandler struct {
+	repo db.Repository
+}
+
+func NewConfigurationHandler(repo db.Repository) *ConfigurationHandler {
+	return &ConfigurationHandler{
+		repo: repo,
+	}
+}
+
+// List returns the configurations by name with the version valid at the "at"
+// query parameter, now by default. HTMX clients get <option> elements for the
+// calculator's dropdown, carrying the pack sizes to fill in.
+func (h *ConfigurationHandler) List(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+
+	at, err := parseAt(r.URL.Query().Get("at"))
+	if err != nil {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	configurations, err := h.repo.ListPackConfigurations(r.Context())
+	if err != nil {
+		log.Printf("failed to load configurations: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to load configurations")
+		return
+	}
+	versions, err := h.repo.ListPackConfigurationVersionsAt(r.Context(), db.Timestamp(at))
+	if err != nil {
+		log.Printf("failed to load configuration versions: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to load configurations")
+		return
+	}
+	active := make(map[int32]dbsqlc.PackConfigurationVersion, len(versions))
+	for _, version := range versions {
+		active[version.ConfigurationID] = version
+	}
+
+	// Configurations whose first version starts later are not listed yet
+	if asJSON {
+		list := make([]configurationJSON, 0, len(configurations))
+		for _, configuration := range configurations {
+			if version, ok := active[configuration.ID]; ok {
+				list = append(list, newConfigurationJSON(configuration, version))
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+
+	var html strings.Builder
+	html.WriteString("<option value=''>Custom pack sizes</option>")
+	for _, configuration := range configurations {
+		version, ok := active[configuration.ID]
+		if !ok {
+			continue
+		}
+		packSizes := db.FormatPackSizes(db.PackSizesOf(version.PackSizes))
+		html.WriteString(fmt.Sprintf("<option value='%d' data-pack-sizes='%s'>%s (%s)</option>",
+			configuration.ID,
+			template.HTMLEscapeString(packSizes),
+			template.HTMLEscapeString(configuration.Name),
+			template.HTMLEscapeString(packSizes)))
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(html.String()))
+}
+
+// Get returns a configuration as it was at the "at" query parameter, now by default
+func (h *ConfigurationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := configurationID(w, r)
+	if !ok {
+		return
+	}
+	at, err := parseAt(r.URL.Query().Get("at"))
+	if err != nil {
+		writeError(w, true, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	configuration, err := h.repo.GetPackConfiguration(r.Context(), id)
+	if err != nil {
+		writeConfigurationError(w, true, id, err)
+		return
+	}
+	version, err := h.repo.GetPackConfigurationVersionAt(r.Context(), dbsqlc.GetPackConfigurationVersionAtParams{
+		ConfigurationID: id,
+		At:              db.Timestamp(at),
+	})
+	if db.IsNotFound(err) {
+		writeError(w, true, http.StatusNotFound, fmt.Sprintf("Configuration %d has no version valid at %s", id, at.Format(time.RFC3339)))
+		return
+	}
+	if err != nil {
+		writeConfigurationError(w, true, id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newConfigurationJSON(configuration, version))
+}
+
+// Versions returns every version of a configuration, oldest first
+func (h *ConfigurationHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	id, ok := configurationID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.repo.GetPackConfiguration(r.Context(), id); err != nil {
+		writeConfigurationError(w, true, id, err)
+		return
+	}
+	versions, err := h.repo.ListPackConfigurationVersions(r.Context(), id)
+	if err != nil {
+		writeConfigurationError(w, true, id, err)
+		return
+	}
+
+	list := make([]configurationVersionJSON, 0, len(versions))
+	for _, version := range versions {
+		list = append(list, newConfigurationVersionJSON(version))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Create saves a configuration from a JSON body or the HTMX save form
+func (h *ConfigurationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+
+	input, err := parseConfiguration(w, r)
+	if err != nil {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	configuration, version, err := h.repo.CreateConfiguration(r.Context(), dbsqlc.CreatePackConfigurationParams{
+		Name:        input.Name,
+		Description: input.Description,
+	}, input.version())
+	if err != nil {
+		writeConfigurationError(w, asJSON, 0, err)
+		return
+	}
+
+	writeConfiguration(w, asJSON, http.StatusCreated, configuration, version)
+}
+
+// Update replaces the name and description of a configuration. Changed pack
+// sizes become a new version from validFrom on, earlier versions are kept.
+func (h *ConfigurationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+
+	id, ok := configurationID(w, r)
+	if !ok {
+		return
+	}
+
+	input, err := parseConfiguration(w, r)
+	if err != nil {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	configuration, version, err := h.repo.UpdateConfiguration(r.Context(), dbsqlc.UpdatePackConfigurationParams{
+		ID:          id,
+		Name:        input.Name,
+		Description: input.Description,
+	}, input.version())
+	if err != nil {
+		writeConfigurationError(w, asJSON, id, err)
+		return
+	}
+
+	writeConfiguration(w, asJSON, http.StatusOK, configuration, version)
+}
+
+// Delete removes a configuration and its versions; calculations that referenced
+// it keep their pack sizes and lose the reference
+func (h *ConfigurationHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := configurationID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.repo.DeletePackConfiguration(r.Context(), id)
+	if err != nil {
+		writeConfigurationError(w, wantsJSON(r), id, err)
+		return
+	}
+	if deleted == 0 {
+		writeError(w, wantsJSON(r), http.StatusNotFound, fmt.Sprintf("Configuration %d not found", id))
+		return
+	}
+
+	w.Header().Set("HX-Trigger", "configurations-changed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// configurationID reads the {id} path value, answering 400 when it is not an ID
+func configurationID(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	id, err := parseConfigurationID(r.PathValue("id"))
+	if err != nil || id == 0 {
+		writeError(w, wantsJSON(r), http.StatusBadRequest, fmt.Sprintf("Invalid configuration ID: %s", r.PathValue("id")))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// parseConfiguration reads a configuration from a JSON body or form fields.
+// Errors name the invalid field like parseCalculateForm.
+func parseConfiguration(w http.ResponseWriter, r *http.Request) (*configurationRequestJSON, error) {
+	var input configurationRequestJSON
+	if isJSONBody(r) {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBodyBytes))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&input); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("JSON body: empty")
+			}
+			return nil, fmt.Errorf("JSON body: %s", err.Error())
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return nil, errors.New("form data")
+		}
+		input.Name = r.FormValue("name")
+		input.Description = r.FormValue("description")
+		for _, sizeStr := range strings.Split(r.FormValue("packSizes"), ",") {
+			sizeStr = strings.TrimSpace(sizeStr)
+			if sizeStr == "" {
+				continue
+			}
+			size, err := strconv.Atoi(sizeStr)
+			if err != nil {
+				return nil, fmt.Errorf("pack size: %s", sizeStr)
+			}
+			input.PackSizes = append(input.PackSizes, size)
+		}
+		if validFromStr := r.FormValue("validFrom"); validFromStr != "" {
+			validFrom, err := parseAt(validFromStr)
+			if err != nil {
+				return nil, fmt.Errorf("valid from: %s", validFromStr)
+			}
+			input.ValidFrom = &validFrom
+		}
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	input.Description = strings.TrimSpace(input.Description)
+	if input.Name == "" {
+		return nil, errors.New("name: empty")
+	}
+	if len(input.Name) > maxConfigurationNameLength {
+		return nil, fmt.Errorf("name: longer than %d characters", maxConfigurationNameLength)
+	}
+	if len(input.PackSizes) == 0 {
+		return nil, errors.New("pack sizes: empty")
+	}
+	for _, size := range input.PackSizes {
+		if size <= 0 || size > math.MaxInt32 {
+			return nil, fmt.Errorf("pack size: %d", size)
+		}
+	}
+
+	return &input, nil
+}
+
+// version is the version the request describes, valid from now when no
+// start was given
+func (input *configurationRequestJSON) version() db.ConfigurationVersion {
+	version := db.ConfigurationVersion{PackSizes: db.ConfigurationSizes(input.PackSizes)}
+	if input.ValidFrom != nil {
+		version.ValidFrom = *input.ValidFrom
+	}
+
+	return version
+}
+
+// parseAt reads a point in time as RFC 3339 or a date, which means its start
+// in UTC; empty means now
+func parseAt(atStr string) (time.Time, error) {
+	atStr = strings.TrimSpace(atStr)
+	if atStr == "" {
+		return time.Now(), nil
+	}
+
+	if at, err := time.Parse(time.RFC3339, atStr); err == nil {
+		return at, nil
+	}
+	at, err := time.Parse(time.DateOnly, atStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date: %s", atStr)
+	}
+
+	return at, nil
+}
+
+// unknownConfigurationError is a calculation referring to a configuration that
+// does not exist or has no version valid now, the client's mistake rather than
+// a server fault
+type unknownConfigurationError struct {
+	id int32
+}
+
+func (e *unknownConfigurationError) Error() string {
+	return fmt.Sprintf("configuration: %d", e.id)
+}
+
+// lookupConfiguration loads the version of a configuration valid now, the one
+// a calculation uses and is linked to
+func lookupConfiguration(ctx context.Context, repo db.Repository, id int32) (dbsqlc.PackConfigurationVersion, error) {
+	if repo == nil {
+		return dbsqlc.PackConfigurationVersion{}, &unknownConfigurationError{id: id}
+	}
+
+	version, err := repo.GetPackConfigurationVersionAt(ctx, dbsqlc.GetPackConfigurationVersionAtParams{
+		ConfigurationID: id,
+		At:              db.Timestamp(time.Now()),
+	})
+	if db.IsNotFound(err) {
+		return dbsqlc.PackConfigurationVersion{}, &unknownConfigurationError{id: id}
+	}
+
+	return version, err
+}
+
+// parseConfigurationID reads an optional configuration ID, 0 when empty
+func parseConfigurationID(idStr string) (int32, error) {
+	idStr = strings.TrimSpace(idStr)
+	if idStr == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 32)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("configuration: %s", idStr)
+	}
+
+	return int32(id), nil
+}
+
+// writeConfiguration answers a saved configuration as JSON, or as a
+// confirmation that refreshes the dropdown for HTMX clients
+func writeConfiguration(w http.ResponseWriter, asJSON bool, status int, configuration dbsqlc.PackConfiguration, version dbsqlc.PackConfigurationVersion) {
+	if asJSON {
+		writeJSON(w, status, newConfigurationJSON(configuration, version))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("HX-Trigger", "configurations-changed")
+	w.WriteHeader(status)
+	w.Write([]byte(fmt.Sprintf("<div class='result-success'>Saved configuration %s</div>",
+		template.HTMLEscapeString(configuration.Name))))
+}
+
+// writeConfigurationError maps repository errors: a missing row is 404, a taken
+// name or a version starting before the latest is 409, a version starting in
+// the past 400 and anything else is a server fault
+func writeConfigurationError(w http.ResponseWriter, asJSON bool, id int32, err error) {
+	var orderErr *db.VersionOrderError
+	switch {
+	case db.IsNotFound(err):
+		writeError(w, asJSON, http.StatusNotFound, fmt.Sprintf("Configuration %d not found", id))
+	case db.IsUniqueViolation(err):
+		writeError(w, asJSON, http.StatusConflict, "A configuration with this name already exists")
+	case errors.As(err, &orderErr):
+		writeError(w, asJSON, http.StatusConflict, fmt.Sprintf("Invalid valid from: %s", err.Error()))
+	case errors.Is(err, db.ErrVersionInPast):
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid valid from: %s, leave it empty to start now", err.Error()))
+	default:
+		log.Printf("configuration query failed: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Configuration request failed, please try again later")
+	}
+}
+
+func newConfigurationJSON(configuration dbsqlc.PackConfiguration, version dbsqlc.PackConfigurationVersion) configurationJSON {
+	versionJSON := newConfigurationVersionJSON(version)
+
+	return configurationJSON{
+		ID:          configuration.ID,
+		Name:        configuration.Name,
+		PackSizes:   versionJSON.PackSizes,
+		Description: configuration.Description,
+		VersionID:   versionJSON.ID,
+		ValidFrom:   versionJSON.ValidFrom,
+		ValidTo:     versionJSON.ValidTo,
+		CreatedAt:   configuration.CreatedAt.Time,
+		UpdatedAt:   configuration.UpdatedAt.Time,
+	}
+}
+
+func newConfigurationVersionJSON(version dbsqlc.PackConfigurationVersion) configurationVersionJSON {
+	versionJSON := configurationVersionJSON{
+		ID:        version.ID,
+		PackSizes: db.PackSizesOf(version.PackSizes),
+		ValidFrom: version.ValidFrom.Time,
+	}
+	if version.ValidTo.Valid {
+		validTo := version.ValidTo.Time
+		versionJSON.ValidTo = &validTo
+	}
+
+	return versionJSON
+}
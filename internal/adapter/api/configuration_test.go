@@ -0,0 +1,249 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ignis/internal/adapter/api"
+	"ignis/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveConfigurations routes requests like the app does, so path values are set
+func serveConfigurations(repo *MockRepository, req *http.Request) *httptest.ResponseRecorder {
+	h := api.NewConfigurationHandler(repo)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/configurations", h.List)
+	mux.HandleFunc("POST /api/v1/configurations", h.Create)
+	mux.HandleFunc("GET /api/v1/configurations/{id}", h.Get)
+	mux.HandleFunc("GET /api/v1/configurations/{id}/versions", h.Versions)
+	mux.HandleFunc("PUT /api/v1/configurations/{id}", h.Update)
+	mux.HandleFunc("DELETE /api/v1/configurations/{id}", h.Delete)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestConfigurationHandler_CRUD(t *testing.T) {
+	repo := &MockRepository{}
+
+	// Create
+	w := serveConfigurations(repo, jsonRequest(http.MethodPost, "/api/v1/configurations",
+		`{"name":" Widgets ","packSizes":[23,31,53],"description":"Main line"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ID        int32  `json:"id"`
+		Name      string `json:"name"`
+		PackSizes []int  `json:"packSizes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode configuration: %v", err)
+	}
+	if created.ID == 0 || created.Name != "Widgets" || !reflect.DeepEqual(created.PackSizes, []int{23, 31, 53}) {
+		t.Errorf("unexpected configuration: %+v", created)
+	}
+
+	// Names are unique
+	w = serveConfigurations(repo, jsonRequest(http.MethodPost, "/api/v1/configurations", `{"name":"Widgets","packSizes":[5]}`))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a taken name, got %d", w.Code)
+	}
+
+	// Update
+	w = serveConfigurations(repo, jsonRequest(http.MethodPut, "/api/v1/configurations/1", `{"name":"Widgets","packSizes":[250,500]}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Get
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/configurations/1", nil)
+	w = serveConfigurations(repo, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"packSizes":[250,500]`) {
+		t.Errorf("expected the updated configuration, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// List as dropdown options
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/configurations", nil)
+	req.Header.Set("HX-Request", "true")
+	w = serveConfigurations(repo, req)
+	if !strings.Contains(w.Body.String(), "<option value='1' data-pack-sizes='250, 500'>Widgets (250, 500)</option>") {
+		t.Errorf("expected an option for the configuration, got %s", w.Body.String())
+	}
+
+	// Delete
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/configurations/1", nil)
+	w = serveConfigurations(repo, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/configurations/1", nil)
+	req.Header.Set("Accept", "application/json")
+	w = serveConfigurations(repo, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestConfigurationHandler_Versions(t *testing.T) {
+	repo := &MockRepository{}
+
+	w := serveConfigurations(repo, jsonRequest(http.MethodPost, "/api/v1/configurations",
+		`{"name":"Cartons","packSizes":[10,20],"validFrom":"2026-01-01T00:00:00Z"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The supplier will switch cartons in June 2096
+	w = serveConfigurations(repo, jsonRequest(http.MethodPut, "/api/v1/configurations/1",
+		`{"name":"Cartons","packSizes":[12,24],"validFrom":"2096-06-01T00:00:00Z"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		at        string
+		status    int
+		packSizes string
+	}{
+		{"2096-03-01", http.StatusOK, `"packSizes":[10,20]`},
+		{"2096-06-01T00:00:00Z", http.StatusOK, `"packSizes":[12,24]`},
+		{"2025-12-31", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w = serveConfigurations(repo, httptest.NewRequest(http.MethodGet, "/api/v1/configurations/1?at="+tt.at, nil))
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.packSizes) {
+			t.Errorf("at %s: expected %d with %s, got %d: %s", tt.at, tt.status, tt.packSizes, w.Code, w.Body.String())
+		}
+	}
+
+	w = serveConfigurations(repo, httptest.NewRequest(http.MethodGet, "/api/v1/configurations/1/versions", nil))
+	var versions []struct {
+		PackSizes []int   `json:"packSizes"`
+		ValidTo   *string `json:"validTo"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &versions); err != nil {
+		t.Fatalf("failed to decode versions: %v\n%s", err, w.Body.String())
+	}
+	if len(versions) != 2 || versions[0].ValidTo == nil || *versions[0].ValidTo != "2096-06-01T00:00:00Z" || versions[1].ValidTo != nil {
+		t.Errorf("expected the first version to end where the second starts, got %+v", versions)
+	}
+
+	// Versions only append
+	w = serveConfigurations(repo, jsonRequest(http.MethodPut, "/api/v1/configurations/1",
+		`{"name":"Cartons","packSizes":[5],"validFrom":"2096-02-01T00:00:00Z"}`))
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for a version before the latest, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestConfigurationHandler_UpdateInPast(t *testing.T) {
+	repo := &MockRepository{}
+
+	// A first version may be backdated, a later one may not rewrite the past
+	w := serveConfigurations(repo, jsonRequest(http.MethodPost, "/api/v1/configurations",
+		`{"name":"Cartons","packSizes":[10,20],"validFrom":"2026-01-01T00:00:00Z"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serveConfigurations(repo, jsonRequest(http.MethodPut, "/api/v1/configurations/1",
+		`{"name":"Cartons","packSizes":[12,24],"validFrom":"2026-02-01T00:00:00Z"}`))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid valid from") {
+		t.Errorf("expected status 400 for a version starting in the past, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.Versions) != 1 || repo.Versions[0].ValidTo.Valid {
+		t.Errorf("expected the current version to stay open, got %+v", repo.Versions)
+	}
+
+	// Without a start the new version starts now
+	w = serveConfigurations(repo, jsonRequest(http.MethodPut, "/api/v1/configurations/1",
+		`{"name":"Cartons","packSizes":[12,24]}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.Versions) != 2 || time.Since(repo.Versions[1].ValidFrom.Time) > time.Minute {
+		t.Errorf("expected a second version starting now, got %+v", repo.Versions)
+	}
+}
+
+func TestConfigurationHandler_InvalidInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   string
+	}{
+		{"empty name", http.MethodPost, "/api/v1/configurations", `{"name":" ","packSizes":[5]}`, "Invalid name: empty"},
+		{"no pack sizes", http.MethodPost, "/api/v1/configurations", `{"name":"Bolts"}`, "Invalid pack sizes: empty"},
+		{"negative pack size", http.MethodPost, "/api/v1/configurations", `{"name":"Bolts","packSizes":[5,-1]}`, "Invalid pack size: -1"},
+		{"bad date", http.MethodGet, "/api/v1/configurations?at=yesterday", "", "Invalid date: yesterday"},
+		{"bad ID", http.MethodPut, "/api/v1/configurations/abc", `{"name":"Bolts","packSizes":[5]}`, "Invalid configuration ID: abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveConfigurations(&MockRepository{}, jsonRequest(tt.method, tt.target, tt.body))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("expected %q in %s", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCalculatorHandler_Calculate_Configuration(t *testing.T) {
+	repo := &MockRepository{}
+	w := serveConfigurations(repo, jsonRequest(http.MethodPost, "/api/v1/configurations", `{"name":"Widgets","packSizes":[23,31,53]}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("failed to create configuration: %d", w.Code)
+	}
+
+	h := api.NewCalculatorHandler(&MockCalculator{Result: &domain.CalculateResult{Packages: map[int]int{53: 1}, Total: 53}}, repo)
+
+	// The configuration's pack sizes replace the entered ones
+	formData := url.Values{}
+	formData.Set("configurationId", "1")
+	formData.Set("packSizes", "1")
+	formData.Set("amount", "53")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+
+	h.Calculate(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	if repo.LastCreated.PackSizes != "23, 31, 53" {
+		t.Errorf("expected the configuration's pack sizes to be stored, got %q", repo.LastCreated.PackSizes)
+	}
+	if !repo.LastCreated.ConfigurationID.Valid || repo.LastCreated.ConfigurationID.Int32 != 1 {
+		t.Errorf("expected configuration 1 to be stored, got %+v", repo.LastCreated.ConfigurationID)
+	}
+	if !repo.LastCreated.ConfigurationVersionID.Valid || repo.LastCreated.ConfigurationVersionID.Int32 != 1 {
+		t.Errorf("expected version 1 to be stored, got %+v", repo.LastCreated.ConfigurationVersionID)
+	}
+
+	// Unknown configurations are invalid input
+	w = httptest.NewRecorder()
+	h.Calculate(w, jsonRequest(http.MethodPost, "/api/v1/calculate", `{"configurationId":9,"amount":53}`))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid configuration: 9") {
+		t.Errorf("expected 400 for an unknown configuration, got %d: %s", w.Code, w.Body.String())
+	}
+}
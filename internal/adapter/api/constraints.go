@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"ignis/internal/domain"
+	"strings"
+)
+
+// constraintsJSON bounds how many packs of each size a plan may use
+type constraintsJSON struct {
+	Min       map[int]int `json:"min,omitempty"`       // pack size -> fewest packs
+	Max       map[int]int `json:"max,omitempty"`       // pack size -> most packs
+	Required  []int       `json:"required,omitempty"`  // sizes every plan uses at least once
+	Forbidden []int       `json:"forbidden,omitempty"` // sizes no plan uses
+}
+
+func (c *constraintsJSON) toConstraints() *domain.PackConstraints {
+	if c == nil {
+		return nil
+	}
+
+	return &domain.PackConstraints{Min: c.Min, Max: c.Max, Required: c.Required, Forbidden: c.Forbidden}
+}
+
+func newConstraintsJSON(constraints *domain.PackConstraints) *constraintsJSON {
+	if constraints == nil {
+		return nil
+	}
+
+	return &constraintsJSON{Min: constraints.Min, Max: constraints.Max, Required: constraints.Required, Forbidden: constraints.Forbidden}
+}
+
+// parseConstraintsForm reads the pack constraints of the calculator form: the
+// fewest and most packs written as "53:1, 23:10", and comma-separated required
+// and forbidden sizes. It returns nil when all of them are empty.
+func parseConstraintsForm(minStr, maxStr, requiredStr, forbiddenStr string) (*domain.PackConstraints, error) {
+	if strings.TrimSpace(minStr+maxStr+requiredStr+forbiddenStr) == "" {
+		return nil, nil
+	}
+
+	constraints := &domain.PackConstraints{}
+	var err error
+	if constraints.Min, err = parseSizeQuantities(minStr); err != nil {
+		return nil, fmt.Errorf("minimum packs: %s", err.Error())
+	}
+	if constraints.Max, err = parseSizeQuantities(maxStr); err != nil {
+		return nil, fmt.Errorf("maximum packs: %s", err.Error())
+	}
+	if constraints.Required, err = parsePackSizeList(requiredStr); err != nil {
+		return nil, fmt.Errorf("required %s", err.Error())
+	}
+	if constraints.Forbidden, err = parsePackSizeList(forbiddenStr); err != nil {
+		return nil, fmt.Errorf("forbidden %s", err.Error())
+	}
+
+	return constraints, nil
+}
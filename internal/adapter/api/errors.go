@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"ignis/internal/domain"
+	"net/http"
+)
+
+// errorStatus maps calculator errors to a response status: invalid input is
+// 400, too many pack sizes is 413, an impossible amount, a pack over the
+// shipment limits or an amount or memory estimate over the limit is 422, a
+// calculation cancelled by shutdown or a deadline is 503 and anything else is
+// a server fault
+func errorStatus(err error) int {
+	var limitErr *domain.LimitError
+	switch {
+	case errors.Is(err, domain.ErrEmptyPackSizes),
+		errors.Is(err, domain.ErrInvalidPackSize),
+		errors.Is(err, domain.ErrInvalidAmount),
+		errors.Is(err, domain.ErrInvalidStock),
+		errors.Is(err, domain.ErrInvalidCost),
+		errors.Is(err, domain.ErrInvalidConstraint),
+		errors.Is(err, domain.ErrInvalidPackaging),
+		errors.Is(err, domain.ErrInvalidShipment),
+		errors.Is(err, domain.ErrInvalidPlanCount):
+		return http.StatusBadRequest
+	case errors.As(err, &limitErr) && limitErr.Limit == domain.LimitPackSizes:
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &limitErr), errors.Is(err, domain.ErrNoCombination), errors.Is(err, domain.ErrOversizedPack):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeErrorFragment writes an escaped HTML error fragment with the given status.
+// HTMX does not swap error responses into hx-target, the page routes them with
+// hx-target-error from the response-targets extension instead.
+func writeErrorFragment(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	w.Write([]byte(fmt.Sprintf("<div class='error'>%s</div>", template.HTMLEscapeString(message))))
+}
+
+// writeError writes a JSON error body or an HTML error fragment with the given status
+func writeError(w http.ResponseWriter, asJSON bool, status int, message string) {
+	if asJSON {
+		writeJSON(w, status, errorResponse{Error: message})
+		return
+	}
+	writeErrorFragment(w, status, message)
+}
+
+// writeCalculateError writes an error for the calculator; HTML fragments are
+// retargeted to the result panel for HTMX clients without hx-target-error
+func writeCalculateError(w http.ResponseWriter, asJSON bool, status int, message string) {
+	if !asJSON {
+		w.Header().Set("HX-Retarget", "#result")
+		w.Header().Set("HX-Reswap", "innerHTML")
+	}
+	writeError(w, asJSON, status, message)
+}
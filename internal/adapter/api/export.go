@@ -0,0 +1,320 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// exportFormats maps the format parameter of an export to its content type
+var exportFormats = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// exportWriteTimeout bounds each write of an export. The server's WriteTimeout
+// covers the whole response and would cut off long exports.
+const exportWriteTimeout = 30 * time.Second
+
+// errXLSXRowLimit reports an export with more rows than a spreadsheet holds
+var errXLSXRowLimit = fmt.Errorf("export exceeds the %d rows of an XLSX sheet", excelize.TotalRows-1)
+
+// historyExporter writes calculations in one export format
+type historyExporter interface {
+	write(calc dbsqlc.Calculation) error
+	// flush sends the rows written so far to the client
+	flush() error
+	// close finishes the file
+	close() error
+	// abort releases the file of a failed export
+	abort()
+}
+
+// ExportHistory streams every calculation matching the history filters as CSV
+// (the default), NDJSON or XLSX. Tabular formats get one packs_<size> column per
+// pack size in the export. Rows are read in chunks and CSV and NDJSON are flushed
+// after each one, so memory stays flat however long the history is.
+func (h *CalculatorHandler) ExportHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	asJSON := wantsJSON(r)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportFormats[format]
+	if !ok {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid format: %s (csv, ndjson or xlsx)", format))
+		return
+	}
+
+	query, err := parseHistoryFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	// The header of a tabular export needs every pack size up front
+	var sizes []int
+	if format != "ndjson" {
+		if sizes, err = db.HistoryPackSizes(ctx, h.repo, query); err != nil {
+			log.Printf("failed to load export pack sizes: %v\n", err)
+			writeError(w, asJSON, http.StatusInternalServerError, "Failed to export history")
+			return
+		}
+	}
+
+	out := &exportWriter{ResponseWriter: w, controller: http.NewResponseController(w)}
+	var exporter historyExporter
+	switch format {
+	case "csv":
+		exporter, err = newCSVExporter(out, sizes)
+	case "ndjson":
+		exporter = &ndjsonExporter{w: out, encoder: json.NewEncoder(out)}
+	case "xlsx":
+		exporter, err = newXLSXExporter(out, sizes)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"history-%s.%s\"", time.Now().Format("20060102"), format))
+
+	if err == nil {
+		err = db.ExportHistory(ctx, h.repo, query, func(calculations []dbsqlc.Calculation) error {
+			for _, calc := range calculations {
+				if err := exporter.write(calc); err != nil {
+					return err
+				}
+			}
+			return exporter.flush()
+		})
+	}
+	if err == nil {
+		err = exporter.close()
+	}
+	if err == nil {
+		return
+	}
+
+	log.Printf("failed to export history: %v\n", err)
+	if exporter != nil {
+		exporter.abort()
+	}
+	if out.started {
+		// Abort the response, so the client sees a broken download rather than a short file
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Del("Content-Disposition")
+	if errors.Is(err, errXLSXRowLimit) {
+		writeError(w, asJSON, http.StatusUnprocessableEntity, fmt.Sprintf("Invalid export: %s, narrow the filters or use CSV", err.Error()))
+		return
+	}
+	writeError(w, asJSON, http.StatusInternalServerError, "Failed to export history")
+}
+
+// exportWriter records whether any of the export reached the client, and moves
+// the write deadline before each write
+type exportWriter struct {
+	http.ResponseWriter
+	controller *http.ResponseController
+	started    bool
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	w.started = true
+	// Writers without deadlines, as in tests, are fine as they are
+	w.controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *exportWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// exportColumns is the header of a tabular export, followed by one column per pack size
+var exportColumns = []string{
+	"id", "created_at", "configuration_id", "configuration_version_id", "pack_sizes", "amount", "mode",
+	"objective", "stock", "total", "overshoot", "pack_count", "total_cost",
+}
+
+func exportHeader(sizes []int) []string {
+	header := append([]string{}, exportColumns...)
+	for _, size := range sizes {
+		header = append(header, fmt.Sprintf("packs_%d", size))
+	}
+
+	return header
+}
+
+// exportRow flattens a calculation into the values of exportHeader: numbers
+// stay numbers, missing values are nil and the total cost is in currency units
+func exportRow(calc dbsqlc.Calculation, sizes []int) ([]any, error) {
+	result, err := db.StoredResult(calc)
+	if err != nil {
+		return nil, fmt.Errorf("calculation %d: %w", calc.ID, err)
+	}
+
+	row := []any{
+		calc.ID, calc.CreatedAt.Time, nil, nil, calc.PackSizes, calc.TargetAmount, calc.Mode,
+		calc.Objective, formatStockJSON(calc.Stock), result.Total, result.Overshoot, result.PackCount, nil,
+	}
+	if calc.ConfigurationID.Valid {
+		row[2] = calc.ConfigurationID.Int32
+	}
+	if calc.ConfigurationVersionID.Valid {
+		row[3] = calc.ConfigurationVersionID.Int32
+	}
+	if calc.TotalCost.Valid {
+		row[12] = float64(calc.TotalCost.Int64) / 100
+	}
+	for _, size := range sizes {
+		row = append(row, result.Packages[size])
+	}
+
+	return row, nil
+}
+
+type csvExporter struct {
+	w     *exportWriter
+	csv   *csv.Writer
+	sizes []int
+}
+
+func newCSVExporter(w *exportWriter, sizes []int) (*csvExporter, error) {
+	exporter := &csvExporter{w: w, csv: csv.NewWriter(w), sizes: sizes}
+	return exporter, exporter.csv.Write(exportHeader(sizes))
+}
+
+func (e *csvExporter) write(calc dbsqlc.Calculation) error {
+	row, err := exportRow(calc, e.sizes)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(row))
+	for i, value := range row {
+		switch value := value.(type) {
+		case nil:
+		case time.Time:
+			record[i] = value.Format(time.RFC3339)
+		case float64:
+			record[i] = strconv.FormatFloat(value, 'f', 2, 64)
+		default:
+			record[i] = fmt.Sprint(value)
+		}
+	}
+
+	return e.csv.Write(record)
+}
+
+func (e *csvExporter) flush() error {
+	e.csv.Flush()
+	e.w.Flush()
+	return e.csv.Error()
+}
+
+func (e *csvExporter) close() error {
+	return e.flush()
+}
+
+func (e *csvExporter) abort() {}
+
+type ndjsonExporter struct {
+	w       *exportWriter
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExporter) write(calc dbsqlc.Calculation) error {
+	return e.encoder.Encode(newCalculationJSON(calc))
+}
+
+func (e *ndjsonExporter) flush() error {
+	e.w.Flush()
+	return nil
+}
+
+func (e *ndjsonExporter) close() error {
+	return nil
+}
+
+func (e *ndjsonExporter) abort() {}
+
+// xlsxExporter streams rows into a worksheet, excelize keeps large sheets in a
+// temporary file. The workbook can only be written once complete, so nothing
+// reaches the client before close.
+type xlsxExporter struct {
+	w         *exportWriter
+	file      *excelize.File
+	sheet     *excelize.StreamWriter
+	sizes     []int
+	dateStyle int
+	rows      int
+}
+
+// newXLSXExporter starts the workbook; the exporter is returned with an error
+// too, so the caller can abort it
+func newXLSXExporter(w *exportWriter, sizes []int) (*xlsxExporter, error) {
+	exporter := &xlsxExporter{w: w, file: excelize.NewFile(), sizes: sizes, rows: 1}
+
+	var err error
+	if exporter.sheet, err = exporter.file.NewStreamWriter("Sheet1"); err != nil {
+		return exporter, err
+	}
+	if exporter.dateStyle, err = exporter.file.NewStyle(&excelize.Style{NumFmt: 22}); err != nil { // m/d/yy h:mm
+		return exporter, err
+	}
+
+	header := exportHeader(sizes)
+	values := make([]any, len(header))
+	for i, name := range header {
+		values[i] = name
+	}
+	return exporter, exporter.sheet.SetRow("A1", values)
+}
+
+func (e *xlsxExporter) write(calc dbsqlc.Calculation) error {
+	if e.rows == excelize.TotalRows {
+		return errXLSXRowLimit
+	}
+	row, err := exportRow(calc, e.sizes)
+	if err != nil {
+		return err
+	}
+	row[1] = excelize.Cell{StyleID: e.dateStyle, Value: row[1]}
+
+	e.rows++
+	cell, err := excelize.CoordinatesToCellName(1, e.rows)
+	if err != nil {
+		return err
+	}
+	return e.sheet.SetRow(cell, row)
+}
+
+func (e *xlsxExporter) flush() error {
+	return nil
+}
+
+func (e *xlsxExporter) close() error {
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	if _, err := e.file.WriteTo(e.w); err != nil {
+		return err
+	}
+	return e.file.Close()
+}
+
+func (e *xlsxExporter) abort() {
+	e.file.Close()
+}
@@ -0,0 +1,175 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"ignis/internal/adapter/api"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func exportHistory(h *api.CalculatorHandler, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ExportHistory(w, httptest.NewRequest(http.MethodGet, "/api/v1/history/export?"+query, nil))
+	return w
+}
+
+// exportRepository is historyRepository with the plans filled in: odd IDs
+// shipped 2×53 and 1×23, even ones 3×10
+func exportRepository(n int) *MockRepository {
+	repo := historyRepository(n)
+	for i := range repo.Calculations {
+		repo.Calculations[i].ResultJson = []byte(`{"53":2,"23":1}`)
+		if i%2 == 1 {
+			repo.Calculations[i].ResultJson = []byte(`{"10":3}`)
+		}
+	}
+	return repo
+}
+
+func TestCalculatorHandler_ExportHistory_CSV(t *testing.T) {
+	h := api.NewCalculatorHandler(nil, exportRepository(3))
+
+	w := exportHistory(h, "maxAmount=200")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") || !strings.Contains(w.Header().Get("Content-Disposition"), ".csv") {
+		t.Errorf("expected a CSV download, got %v", w.Header())
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %v", records)
+	}
+
+	// One column per pack size of the exported calculations, largest first
+	header := records[0]
+	if got := header[len(header)-5:]; !reflect.DeepEqual(got, []string{"packs_53", "packs_31", "packs_23", "packs_10", "packs_5"}) {
+		t.Errorf("unexpected pack size columns %v", got)
+	}
+	if got := records[1]; got[0] != "2" || got[5] != "200" || !reflect.DeepEqual(got[len(got)-5:], []string{"0", "0", "0", "3", "0"}) {
+		t.Errorf("unexpected row %v", got)
+	}
+	if got := records[2]; got[0] != "1" || got[1] != "2026-03-01T12:01:00Z" || !reflect.DeepEqual(got[len(got)-5:], []string{"2", "0", "1", "0", "0"}) {
+		t.Errorf("unexpected row %v", got)
+	}
+}
+
+func TestCalculatorHandler_ExportHistory_Chunks(t *testing.T) {
+	rows := db.ExportChunkSize*2 + 1
+	h := api.NewCalculatorHandler(nil, exportRepository(rows))
+
+	w := exportHistory(h, "format=ndjson")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("expected an NDJSON download, got %d: %v", w.Code, w.Header())
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != rows {
+		t.Fatalf("expected %d lines, got %d", rows, len(lines))
+	}
+	var first, last struct {
+		ID int32 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("failed to decode line: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[rows-1]), &last); err != nil {
+		t.Fatalf("failed to decode line: %v", err)
+	}
+	if first.ID != int32(rows) || last.ID != 1 {
+		t.Errorf("expected every calculation newest first across chunks, got %d to %d", first.ID, last.ID)
+	}
+}
+
+// slowRepository takes delay to read each chunk of calculations
+type slowRepository struct {
+	*MockRepository
+	delay time.Duration
+}
+
+func (r *slowRepository) ListCalculations(ctx context.Context, arg dbsqlc.ListCalculationsParams) ([]dbsqlc.Calculation, error) {
+	time.Sleep(r.delay)
+	return r.MockRepository.ListCalculations(ctx, arg)
+}
+
+func TestCalculatorHandler_ExportHistory_SlowerThanWriteTimeout(t *testing.T) {
+	rows := db.ExportChunkSize*2 + 1
+	h := api.NewCalculatorHandler(nil, &slowRepository{MockRepository: exportRepository(rows), delay: 100 * time.Millisecond})
+
+	// Reading the three chunks takes longer than the server may write a response
+	server := httptest.NewUnstartedServer(http.HandlerFunc(h.ExportHistory))
+	server.Config.WriteTimeout = 150 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/api/v1/history/export?format=ndjson")
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("export was cut off: %v", err)
+	}
+	if lines := strings.Count(string(body), "\n"); resp.StatusCode != http.StatusOK || lines != rows {
+		t.Errorf("expected status 200 and %d lines, got %d and %d", rows, resp.StatusCode, lines)
+	}
+}
+
+func TestCalculatorHandler_ExportHistory_XLSX(t *testing.T) {
+	h := api.NewCalculatorHandler(nil, exportRepository(2))
+
+	w := exportHistory(h, "format=xlsx&packSizes=23,31,53")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+
+	file, err := excelize.OpenReader(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to open workbook: %v", err)
+	}
+	defer file.Close()
+	rows, err := file.GetRows("Sheet1")
+	if err != nil {
+		t.Fatalf("failed to read sheet: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected a header and 1 row, got %v", rows)
+	}
+	if got := rows[0][len(rows[0])-3:]; !reflect.DeepEqual(got, []string{"packs_53", "packs_31", "packs_23"}) {
+		t.Errorf("unexpected pack size columns %v", got)
+	}
+	if got := rows[1]; got[0] != "1" || got[5] != "100" || got[len(got)-3] != "2" {
+		t.Errorf("unexpected row %v", got)
+	}
+}
+
+func TestCalculatorHandler_ExportHistory_Invalid(t *testing.T) {
+	h := api.NewCalculatorHandler(nil, exportRepository(1))
+
+	for query, want := range map[string]string{
+		"format=pdf":   "Invalid format: pdf",
+		"minAmount=-1": "Invalid minAmount: -1",
+	} {
+		w := exportHistory(h, query)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: expected 400 with %q, got %d: %s", query, want, w.Code, w.Body.String())
+		}
+	}
+}
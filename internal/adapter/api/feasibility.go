@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"ignis/internal/adapter/db"
+	"ignis/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// feasibilityJSON reports which amounts a set of pack sizes packs exactly
+type feasibilityJSON struct {
+	PackSizes        []int               `json:"packSizes"`
+	GCD              int                 `json:"gcd"`
+	Warning          string              `json:"warning,omitempty"` // set when every size shares a factor
+	Frobenius        int                 `json:"frobenius"`         // largest multiple of gcd that cannot be packed, -1 for none
+	UnreachableCount int                 `json:"unreachableCount"`
+	Unreachable      []int               `json:"unreachable"` // the smallest unreachable amounts, at most 100
+	Nearest          *nearestAmountsJSON `json:"nearest,omitempty"`
+}
+
+// nearestAmountsJSON are the packable amounts around the requested one
+type nearestAmountsJSON struct {
+	Amount    int  `json:"amount"`
+	Reachable bool `json:"reachable"`
+	Below     int  `json:"below,omitempty"` // omitted when no smaller amount packs
+	Above     int  `json:"above"`
+}
+
+func newFeasibilityJSON(report *domain.FeasibilityReport) feasibilityJSON {
+	entry := feasibilityJSON{
+		PackSizes:        report.PackSizes,
+		GCD:              report.GCD,
+		Warning:          report.Warning(),
+		Frobenius:        report.Frobenius,
+		UnreachableCount: report.UnreachableCount,
+		Unreachable:      report.Unreachable,
+	}
+	if entry.Unreachable == nil {
+		entry.Unreachable = []int{}
+	}
+	if nearest := report.Nearest; nearest != nil {
+		entry.Nearest = &nearestAmountsJSON{Amount: nearest.Amount, Reachable: nearest.Reachable, Below: nearest.Below, Above: nearest.Above}
+	}
+
+	return entry
+}
+
+// Feasibility analyses the pack sizes of the query, and the amount when one is
+// given, answering JSON or an HTML fragment
+func (h *CalculatorHandler) Feasibility(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+	query := r.URL.Query()
+
+	packSizes, err := parsePackSizeList(query.Get("packSizes"))
+	if err != nil {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+	amount := 0
+	if amountStr := strings.TrimSpace(query.Get("amount")); amountStr != "" {
+		if amount, err = strconv.Atoi(amountStr); err != nil {
+			writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid amount: %s", amountStr))
+			return
+		}
+	}
+
+	report, err := h.calculator.AnalyzeFeasibility(r.Context(), packSizes, amount)
+	if err != nil {
+		status := errorStatus(err)
+		if status == http.StatusInternalServerError {
+			log.Printf("feasibility analysis failed: %v\n", err)
+			writeError(w, asJSON, status, "Analysis failed, please try again later")
+			return
+		}
+		writeError(w, asJSON, status, fmt.Sprintf("Analysis error: %s", err.Error()))
+		return
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, newFeasibilityJSON(report))
+		return
+	}
+
+	var html strings.Builder
+	html.WriteString(fmt.Sprintf("<h3>Pack sizes %s</h3>", db.FormatPackSizes(report.PackSizes)))
+	writeFeasibilityHints(&html, report)
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(html.String()))
+}
+
+// writeNoCombinationError writes the error fragment of an amount the pack sizes
+// cannot pack exactly, with hints on the amounts they can
+func (h *CalculatorHandler) writeNoCombinationError(ctx context.Context, w http.ResponseWriter, req domain.CalculateRequest, calcErr error) {
+	message := fmt.Sprintf("Calculation error: %s", calcErr.Error())
+	report, err := h.calculator.AnalyzeFeasibility(ctx, req.PackSizes, req.Amount)
+	if err != nil {
+		log.Printf("feasibility analysis failed: %v\n", err)
+		writeCalculateError(w, false, http.StatusUnprocessableEntity, message)
+		return
+	}
+
+	var html strings.Builder
+	html.WriteString(fmt.Sprintf("<div class='error'>%s", template.HTMLEscapeString(message)))
+	writeFeasibilityHints(&html, report)
+	html.WriteString("</div>")
+
+	w.Header().Set("HX-Retarget", "#result")
+	w.Header().Set("HX-Reswap", "innerHTML")
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write([]byte(html.String()))
+}
+
+// writeFeasibilityHints renders a feasibility report as a list of hints: the
+// nearest packable amounts, the shared factor and the unreachable amounts
+func writeFeasibilityHints(html *strings.Builder, report *domain.FeasibilityReport) {
+	html.WriteString("<ul class='feasibility-hints'>")
+	if nearest := report.Nearest; nearest != nil {
+		if nearest.Reachable {
+			html.WriteString(fmt.Sprintf("<li>%d can be packed exactly</li>", nearest.Amount))
+		}
+		if nearest.Below > 0 {
+			html.WriteString(fmt.Sprintf("<li>Nearest packable amounts: <strong>%d</strong> below, <strong>%d</strong> above</li>", nearest.Below, nearest.Above))
+		} else {
+			html.WriteString(fmt.Sprintf("<li>Smallest packable amount above: <strong>%d</strong></li>", nearest.Above))
+		}
+	}
+	if warning := report.Warning(); warning != "" {
+		html.WriteString(fmt.Sprintf("<li>Note: %s</li>", warning))
+	}
+
+	unit, units := "amount", "amounts"
+	if report.GCD > 1 {
+		unit, units = fmt.Sprintf("multiple of %d", report.GCD), fmt.Sprintf("multiples of %d", report.GCD)
+	}
+	if report.Frobenius < 0 {
+		html.WriteString(fmt.Sprintf("<li>Every %s can be packed</li>", unit))
+	} else {
+		html.WriteString(fmt.Sprintf("<li>Every %s above <strong>%d</strong> can be packed (the Frobenius number)</li>", unit, report.Frobenius))
+		listed := make([]string, len(report.Unreachable))
+		for i, amount := range report.Unreachable {
+			listed[i] = strconv.Itoa(amount)
+		}
+		more := ""
+		if report.UnreachableCount > len(listed) {
+			more = ", …"
+		}
+		html.WriteString(fmt.Sprintf("<li>%d %s up to it cannot: %s%s</li>", report.UnreachableCount, units, strings.Join(listed, ", "), more))
+	}
+	html.WriteString("</ul>")
+}
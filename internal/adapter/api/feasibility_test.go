@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ignis/internal/adapter/api"
+	"ignis/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCalculatorHandler_Feasibility(t *testing.T) {
+	h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), nil)
+
+	t.Run("JSON", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/feasibility?packSizes=10,15&amount=22", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		h.Feasibility(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var got, want any
+		json.Unmarshal(w.Body.Bytes(), &got)
+		json.Unmarshal([]byte(`{
+			"packSizes": [10, 15], "gcd": 5,
+			"warning": "every pack size is a multiple of 5, only multiples of 5 can be packed",
+			"frobenius": 5, "unreachableCount": 1, "unreachable": [5],
+			"nearest": {"amount": 22, "reachable": false, "below": 20, "above": 25}
+		}`), &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %s", want, w.Body.String())
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, query := range []string{"packSizes=", "packSizes=3,x", "packSizes=3,5&amount=x", "packSizes=3,-5"} {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/feasibility?"+query, nil)
+			req.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			h.Feasibility(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status 400, got %d: %s", query, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("hints in the error fragment", func(t *testing.T) {
+		form := url.Values{"packSizes": {"6, 9, 20"}, "amount": {"43"}}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		w := httptest.NewRecorder()
+		h.Calculate(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status 422, got %d: %s", w.Code, w.Body.String())
+		}
+		html := w.Body.String()
+		for _, want := range []string{
+			"no exact combination possible for the requested amount",
+			"Nearest packable amounts: <strong>42</strong> below, <strong>44</strong> above",
+			"Every amount above <strong>43</strong> can be packed",
+			"22 amounts up to it cannot: 1, 2, 3, 4, 5, 7,",
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("expected %q in %s", want, html)
+			}
+		}
+	})
+
+	t.Run("no hints for stock shortages", func(t *testing.T) {
+		form := url.Values{"packSizes": {"6, 9, 20"}, "amount": {"40"}, "stock": {"20:1, 6:0, 9:0"}}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.Calculate(w, req)
+
+		if html := w.Body.String(); w.Code != http.StatusUnprocessableEntity || strings.Contains(html, "feasibility-hints") {
+			t.Errorf("expected a plain 422 error, got %d: %s", w.Code, html)
+		}
+	})
+}
@@ -1,19 +1,30 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"ignis/internal/adapter/db"
-	dbsqlc "ignis/internal/adapter/db/sqlc"
 	"ignis/internal/domain"
+	"log"
+	"math"
 	"net/http"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// maxAlternatives caps how many ranked plans a single request may ask for
+const maxAlternatives = 10
+
+// maxPackPrice caps the price of a single pack, domain.MaxPackCost in cents
+const maxPackPrice = domain.MaxPackCost / 100
+
 type PageData struct {
 	Title   string
 	Message string
@@ -37,16 +48,180 @@ func (h *CalculatorHandler) Calculate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse form data
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+	asJSON := wantsJSON(r)
+
+	// Parse the JSON body or the form
+	var input *calculateInput
+	var err error
+	if isJSONBody(r) {
+		input, err = decodeCalculateJSON(w, r)
+	} else {
+		input, err = parseCalculateForm(r)
+	}
+	if err != nil {
+		writeCalculateError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
 		return
 	}
 
+	// A saved configuration supplies the pack sizes of its version valid now
+	if input.configurationID != 0 {
+		version, err := lookupConfiguration(r.Context(), h.repo, input.configurationID)
+		var unknownErr *unknownConfigurationError
+		switch {
+		case errors.As(err, &unknownErr):
+			writeCalculateError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+			return
+		case err != nil:
+			log.Printf("failed to load configuration: %v\n", err)
+			writeCalculateError(w, asJSON, http.StatusInternalServerError, "Failed to load configuration, please try again later")
+			return
+		}
+		input.req.PackSizes = db.PackSizesOf(version.PackSizes)
+		input.packSizes = db.FormatPackSizes(input.req.PackSizes)
+		input.configurationVersionID = version.ID
+	}
+
+	// Calculate, a single plan can take the calculator's cheapest path
+	var plans []*domain.CalculateResult
+	if input.plans == 1 {
+		var result *domain.CalculateResult
+		result, err = h.calculator.Calculate(r.Context(), input.req)
+		plans = []*domain.CalculateResult{result}
+	} else {
+		plans, err = h.calculator.CalculateTopK(r.Context(), input.req, input.plans)
+	}
+	if err != nil {
+		status := errorStatus(err)
+		if status == http.StatusInternalServerError {
+			log.Printf("calculation failed: %v\n", err)
+			writeCalculateError(w, asJSON, status, "Calculation failed, please try again later")
+			return
+		}
+		// An exact amount the pack sizes alone cannot pack gets hints on those they can
+		var noCombination *domain.NoCombinationError
+		if !asJSON && errors.As(err, &noCombination) && noCombination.Mode == domain.ModeExact &&
+			len(noCombination.Shortages) == 0 && len(noCombination.Conflicts) == 0 {
+			h.writeNoCombinationError(r.Context(), w, input.req, err)
+			return
+		}
+		writeCalculateError(w, asJSON, status, fmt.Sprintf("Calculation error: %s", err.Error()))
+		return
+	}
+
+	id := h.saveCalculation(r.Context(), input, plans[0])
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, newCalculateResponse(id, input.req.Amount, plans))
+		return
+	}
+	writeCalculateHTML(w, input.req.Amount, plans)
+}
+
+// calculateInput is a parsed calculate request, from either a form or a JSON body
+type calculateInput struct {
+	packSizes              string // pack sizes as entered, stored with the calculation
+	configurationID        int32  // saved configuration supplying the pack sizes, 0 for none
+	configurationVersionID int32  // version of that configuration valid at calculation time
+	req                    domain.CalculateRequest
+	plans                  int
+}
+
+// parseCalculateForm reads a calculate request from the HTMX form fields.
+// Errors name the invalid field and value, e.g. "pack size: abc".
+func parseCalculateForm(r *http.Request) (*calculateInput, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, errors.New("form data")
+	}
+
 	packSizesStr := r.FormValue("packSizes")
 	amountStr := r.FormValue("amount")
 
+	// Parse the saved configuration, its pack sizes replace the entered ones
+	configurationID, err := parseConfigurationID(r.FormValue("configurationId"))
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse pack sizes
+	packSizes, err := parsePackSizeList(packSizesStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse amount
+	amount, err := strconv.Atoi(strings.TrimSpace(amountStr))
+	if err != nil {
+		return nil, fmt.Errorf("amount: %s", amountStr)
+	}
+
+	// Parse stock limits
+	stock, err := parseStock(r.FormValue("stock"))
+	if err != nil {
+		return nil, fmt.Errorf("stock: %s", err.Error())
+	}
+
+	// Parse unit costs
+	costs, err := parseCosts(r.FormValue("costs"))
+	if err != nil {
+		return nil, fmt.Errorf("costs: %s", err.Error())
+	}
+
+	// Parse objective
+	objective, err := domain.ParseObjective(r.FormValue("objective"))
+	if err != nil {
+		return nil, fmt.Errorf("objective: %s", r.FormValue("objective"))
+	}
+
+	// Parse mode
+	mode, err := domain.ParseMode(r.FormValue("mode"))
+	if err != nil {
+		return nil, fmt.Errorf("mode: %s", r.FormValue("mode"))
+	}
+
+	// Parse the fewest and most packs of each size
+	constraints, err := parseConstraintsForm(r.FormValue("minPacks"), r.FormValue("maxPacks"), r.FormValue("requiredSizes"), r.FormValue("forbiddenSizes"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the cartons and pallets to ship the packs in
+	packaging, err := parsePackagingForm(r.FormValue("cartonCapacity"), r.FormValue("palletCapacity"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the weight and volume limits to split shipments by
+	shipping, err := parseShippingForm(r.FormValue("weights"), r.FormValue("volumes"), r.FormValue("maxWeight"), r.FormValue("maxVolume"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse number of plans to show, the best one plus alternatives
+	plans, err := parsePlans(r.FormValue("plans"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &calculateInput{
+		packSizes:       packSizesStr,
+		configurationID: configurationID,
+		req: domain.CalculateRequest{
+			PackSizes:   packSizes,
+			Amount:      amount,
+			Mode:        mode,
+			Stock:       stock,
+			Objective:   objective,
+			Costs:       costs,
+			Constraints: constraints,
+			Packaging:   packaging,
+			Shipping:    shipping,
+		},
+		plans: plans,
+	}, nil
+}
+
+// parsePackSizeList reads comma-separated pack sizes, skipping empty entries
+func parsePackSizeList(packSizesStr string) ([]int, error) {
 	packSizesStrSlice := strings.Split(packSizesStr, ",")
 	packSizes := make([]int, 0, len(packSizesStrSlice))
 	for _, sizeStr := range packSizesStrSlice {
@@ -56,38 +231,72 @@ func (h *CalculatorHandler) Calculate(w http.ResponseWriter, r *http.Request) {
 		}
 		size, err := strconv.Atoi(sizeStr)
 		if err != nil {
-			w.Header().Set("Content-Type", "text/html")
-			w.Write([]byte(fmt.Sprintf("<div class='error'>Invalid pack size: %s</div>", sizeStr)))
-			return
+			return nil, fmt.Errorf("pack size: %s", sizeStr)
 		}
 		packSizes = append(packSizes, size)
 	}
 
-	// Parse amount
-	amount, err := strconv.Atoi(strings.TrimSpace(amountStr))
-	if err != nil {
-		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(fmt.Sprintf("<div class='error'>Invalid amount: %s</div>", amountStr)))
-		return
+	return packSizes, nil
+}
+
+// parsePlans reads the number of ranked plans to return, 1 when empty
+func parsePlans(plansStr string) (int, error) {
+	plansStr = strings.TrimSpace(plansStr)
+	if plansStr == "" {
+		return 1, nil
 	}
 
-	// Calculate
-	result, err := h.calculator.Calculate(domain.CalculateRequest{
-		PackSizes: packSizes,
-		Amount:    amount,
-	})
+	plans, err := strconv.Atoi(plansStr)
+	if err != nil || plans < 1 || plans > maxAlternatives {
+		return 0, fmt.Errorf("number of plans: %s (1-%d)", plansStr, maxAlternatives)
+	}
+
+	return plans, nil
+}
+
+// saveCalculation stores the best plan in the history and returns its ID,
+// or 0 when there is no repository or saving failed
+func (h *CalculatorHandler) saveCalculation(ctx context.Context, input *calculateInput, result *domain.CalculateResult) int32 {
+	if h.repo == nil {
+		return 0
+	}
+
+	params := db.NewCreateCalculationParams(input.packSizes, input.req, result)
+	params.ConfigurationID = pgtype.Int4{Int32: input.configurationID, Valid: input.configurationID != 0}
+	params.ConfigurationVersionID = pgtype.Int4{Int32: input.configurationVersionID, Valid: input.configurationVersionID != 0}
+	calc, err := h.repo.CreateCalculation(ctx, params)
 	if err != nil {
-		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(fmt.Sprintf("<div class='error'>Calculation error: %s</div>", err.Error())))
-		return
+		fmt.Printf("failed to save calculation: %v\n", err)
+		return 0
 	}
 
-	// Build HTML response
+	return calc.ID
+}
+
+// writeCalculateHTML renders the best plan, and any alternatives, as an HTMX fragment
+func writeCalculateHTML(w http.ResponseWriter, amount int, plans []*domain.CalculateResult) {
 	var html strings.Builder
 	html.WriteString("<div class='result-success'>")
 	html.WriteString(fmt.Sprintf("<h3>Results for %d items:</h3>", amount))
+	writePlan(&html, plans[0])
+	if len(plans) > 1 {
+		writeAlternatives(&html, plans[1:])
+	}
+	html.WriteString("</div>")
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("HX-Trigger", "calculation-done")
+	w.Write([]byte(html.String()))
+}
+
+// writePlan renders a plan as a table of pack sizes followed by its totals
+func writePlan(html *strings.Builder, result *domain.CalculateResult) {
 	html.WriteString("<table class='result-table'>")
-	html.WriteString("<tr><th>Pack Size</th><th>Quantity</th></tr>")
+	if result.Costs != nil {
+		html.WriteString("<tr><th>Pack Size</th><th>Quantity</th><th>Cost</th></tr>")
+	} else {
+		html.WriteString("<tr><th>Pack Size</th><th>Quantity</th></tr>")
+	}
 
 	// Sort pack sizes for consistent output
 	sortedSizes := make([]int, 0, len(result.Packages))
@@ -98,65 +307,154 @@ func (h *CalculatorHandler) Calculate(w http.ResponseWriter, r *http.Request) {
 
 	for _, packSize := range sortedSizes {
 		count := result.Packages[packSize]
-		html.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%d</td></tr>", packSize, count))
+		if result.Costs != nil {
+			html.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%d</td><td>%s</td></tr>", packSize, count, formatCents(int64(result.Costs[packSize]))))
+		} else {
+			html.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%d</td></tr>", packSize, count))
+		}
 	}
 
 	html.WriteString("</table>")
 	html.WriteString(fmt.Sprintf("<p class='total'>Total items: <strong>%d</strong></p>", result.Total))
-	html.WriteString("</div>")
+	if result.Overshoot > 0 {
+		html.WriteString(fmt.Sprintf("<p class='total'>Overshoot: <strong>%d</strong></p>", result.Overshoot))
+	}
+	if result.Costs != nil {
+		html.WriteString(fmt.Sprintf("<p class='total'>Total cost: <strong>%s</strong></p>", formatCents(int64(result.TotalCost))))
+	}
+	if result.Packaging != nil {
+		writePackagingPlan(html, result.Packaging)
+	}
+	if result.Shipments != nil {
+		writeShipments(html, result.Shipments)
+	}
+}
 
-	w.Header().Set("Content-Type", "text/html")
-	w.Header().Set("HX-Trigger", "calculation-done")
-	w.Write([]byte(html.String()))
+// writeAlternatives renders the runner-up plans as a ranked table
+func writeAlternatives(html *strings.Builder, plans []*domain.CalculateResult) {
+	html.WriteString("<h4>Alternative plans</h4>")
+	html.WriteString("<table class='result-table alternatives-table'>")
+	html.WriteString("<tr><th>#</th><th>Packs</th><th>Pack Count</th><th>Total</th><th>Cost</th></tr>")
+	for i, plan := range plans {
+		cost := "-"
+		if plan.Costs != nil {
+			cost = formatCents(int64(plan.TotalCost))
+		}
+		html.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%d</td><td>%d</td><td>%s</td></tr>",
+			i+2, formatPackages(plan.Packages), plan.PackCount, plan.Total, cost))
+	}
+	html.WriteString("</table>")
+}
 
-	if h.repo == nil {
-		return
+// formatPackages renders a plan as "53×9429, 31×7, 23×2", largest size first
+func formatPackages(packages map[int]int) string {
+	sizes := make([]int, 0, len(packages))
+	for size := range packages {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	entries := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		entries = append(entries, fmt.Sprintf("%d×%d", size, packages[size]))
 	}
 
-	// Save to history asynchronously or synchronously? Let's do it synchronously for simplicity for now
-	ctx := r.Context()
-	resultJson, _ := json.Marshal(result.Packages)
-	_, err = h.repo.CreateCalculation(ctx, dbsqlc.CreateCalculationParams{
-		PackSizes:    packSizesStr,
-		TargetAmount: int32(amount),
-		ResultJson:   resultJson,
-		TotalItems:   int32(result.Total),
-	})
+	return strings.Join(entries, ", ")
+}
+
+// splitSizeEntries splits "53:100, 31:40" into pack sizes and their raw values
+func splitSizeEntries(input string) (map[int]string, error) {
+	entries := make(map[int]string)
+	for _, entry := range strings.Split(input, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		sizeStr, valueStr, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s (expected size:value)", entry)
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(sizeStr))
+		if err != nil {
+			return nil, fmt.Errorf("%s (invalid pack size)", entry)
+		}
+		entries[size] = strings.TrimSpace(valueStr)
+	}
+
+	return entries, nil
+}
+
+// parseStock reads per-size stock limits written as "53:100, 31:unlimited".
+// Sizes that are not listed, or listed as unlimited, have no limit.
+func parseStock(stockStr string) (map[int]int, error) {
+	entries, err := splitSizeEntries(stockStr)
 	if err != nil {
-		fmt.Printf("failed to save calculation: %v\n", err)
+		return nil, err
+	}
+
+	stock := make(map[int]int)
+	for size, quantityStr := range entries {
+		if quantityStr == "unlimited" {
+			continue
+		}
+		quantity, err := strconv.Atoi(quantityStr)
+		if err != nil || quantity < 0 {
+			return nil, fmt.Errorf("%d:%s (invalid quantity)", size, quantityStr)
+		}
+		stock[size] = quantity
 	}
+
+	return stock, nil
 }
 
-func (h *CalculatorHandler) History(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	calculations, err := h.repo.ListCalculations(ctx)
+// parseCosts reads per-pack prices written as "53:4.99, 31:2.50" and returns them in cents
+func parseCosts(costsStr string) (map[int]int, error) {
+	entries, err := splitSizeEntries(costsStr)
 	if err != nil {
-		http.Error(w, "Failed to load history", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	var html strings.Builder
-	html.WriteString("<div class='history-container'>")
-	html.WriteString("<h3>Recent Calculations</h3>")
-	if len(calculations) == 0 {
-		html.WriteString("<p>No history yet.</p>")
-	} else {
-		html.WriteString("<table class='history-table'>")
-		html.WriteString("<tr><th>Date</th><th>Packs</th><th>Amount</th><th>Total</th></tr>")
-		for _, calc := range calculations {
-			html.WriteString("<tr>")
-			html.WriteString(fmt.Sprintf("<td>%s</td>", calc.CreatedAt.Time.Format("2006-01-02 15:04")))
-			html.WriteString(fmt.Sprintf("<td>%s</td>", calc.PackSizes))
-			html.WriteString(fmt.Sprintf("<td>%d</td>", calc.TargetAmount))
-			html.WriteString(fmt.Sprintf("<td>%d</td>", calc.TotalItems))
-			html.WriteString("</tr>")
+	costs := make(map[int]int)
+	for size, priceStr := range entries {
+		price, err := strconv.ParseFloat(priceStr, 64)
+		if err != nil || math.IsNaN(price) || math.IsInf(price, 0) || price < 0 {
+			return nil, fmt.Errorf("%d:%s (invalid price)", size, priceStr)
 		}
-		html.WriteString("</table>")
+		if price > maxPackPrice {
+			return nil, fmt.Errorf("%d:%s (price over %d)", size, priceStr, maxPackPrice)
+		}
+		costs[size] = int(math.Round(price * 100))
 	}
-	html.WriteString("</div>")
 
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(html.String()))
+	return costs, nil
+}
+
+// formatCents renders an amount in cents as "12.34"
+func formatCents(cents int64) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+// formatStockJSON renders stored stock limits as "53:100, 31:40", or "unlimited"
+func formatStockJSON(stockJson []byte) string {
+	var stock map[int]int
+	if err := json.Unmarshal(stockJson, &stock); err != nil || len(stock) == 0 {
+		return "unlimited"
+	}
+
+	sizes := make([]int, 0, len(stock))
+	for size := range stock {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	entries := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		entries = append(entries, fmt.Sprintf("%d:%d", size, stock[size]))
+	}
+
+	return strings.Join(entries, ", ")
 }
 
 func RootHandler(w http.ResponseWriter, r *http.Request) {
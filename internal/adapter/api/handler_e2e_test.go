@@ -1,12 +1,14 @@
 package api_test
 
 import (
+	"encoding/json"
 	"ignis/internal/adapter/api"
 	"ignis/internal/service"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -69,3 +71,203 @@ func TestCalculatorE2E(t *testing.T) {
 		t.Errorf("expected HTML to contain total amount")
 	}
 }
+
+func TestCalculatorE2E_Overfill(t *testing.T) {
+	calcService := service.NewPackageCalculatorService()
+	h := api.NewCalculatorHandler(calcService, nil)
+
+	formData := url.Values{}
+	formData.Set("packSizes", "5, 10")
+	formData.Set("amount", "7")
+	formData.Set("mode", "overfill")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+
+	h.Calculate(w, req)
+
+	html := w.Body.String()
+	if !strings.Contains(html, "<td>10</td><td>1</td>") {
+		t.Errorf("expected a single 10 pack, got:\n%s", html)
+	}
+	if !strings.Contains(html, "Total items: <strong>10</strong>") {
+		t.Errorf("expected shipped total of 10, got:\n%s", html)
+	}
+	if !strings.Contains(html, "Overshoot: <strong>3</strong>") {
+		t.Errorf("expected overshoot of 3, got:\n%s", html)
+	}
+}
+
+func TestCalculatorE2E_Alternatives(t *testing.T) {
+	calcService := service.NewPackageCalculatorService()
+	h := api.NewCalculatorHandler(calcService, nil)
+
+	formData := url.Values{}
+	formData.Set("packSizes", "5, 10")
+	formData.Set("amount", "20")
+	formData.Set("plans", "3")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+
+	h.Calculate(w, req)
+
+	html := w.Body.String()
+	if !strings.Contains(html, "<td>10</td><td>2</td>") {
+		t.Errorf("expected best plan of two 10 packs, got:\n%s", html)
+	}
+
+	// Runner-ups ranked by pack count
+	expectedRows := []string{
+		"<td>2</td><td>10×1, 5×2</td><td>3</td>",
+		"<td>3</td><td>5×4</td><td>4</td>",
+	}
+	lastIdx := -1
+	for _, expected := range expectedRows {
+		idx := strings.Index(html, expected)
+		if idx == -1 {
+			t.Errorf("expected HTML to contain %q, but it didn't.\nBody: %s", expected, html)
+		}
+		if idx < lastIdx {
+			t.Errorf("expected %q after the previous alternative", expected)
+		}
+		lastIdx = idx
+	}
+}
+
+func TestCalculatorE2E_JSON(t *testing.T) {
+	calcService := service.NewPackageCalculatorService()
+	mockRepo := &MockRepository{}
+	h := api.NewCalculatorHandler(calcService, mockRepo)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(`{"packSizes":[23,31,53],"amount":500000}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	h.Calculate(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+
+	var resp struct {
+		ID        int32          `json:"id"`
+		Packages  map[string]int `json:"packages"`
+		Total     int            `json:"total"`
+		PackCount int            `json:"packCount"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v\n%s", err, w.Body.String())
+	}
+
+	want := map[string]int{"53": 9429, "31": 7, "23": 2}
+	if !reflect.DeepEqual(resp.Packages, want) {
+		t.Errorf("expected packages %v, got %v", want, resp.Packages)
+	}
+	if resp.Total != 500000 || resp.PackCount != 9438 {
+		t.Errorf("expected total 500000 in 9438 packs, got %d in %d", resp.Total, resp.PackCount)
+	}
+	if resp.ID != 1 {
+		t.Errorf("expected calculation ID 1, got %d", resp.ID)
+	}
+	if mockRepo.LastCreated.PackSizes != "23, 31, 53" {
+		t.Errorf("expected stored pack sizes %q, got %q", "23, 31, 53", mockRepo.LastCreated.PackSizes)
+	}
+}
+
+func TestCalculatorE2E_Batch(t *testing.T) {
+	calcService := service.NewPackageCalculatorService()
+	mockRepo := &MockRepository{}
+	h := api.NewCalculatorHandler(calcService, mockRepo)
+
+	body := `{
+		"packSizes": [23, 31, 53],
+		"amounts": [500000, 7],
+		"items": [
+			{"packSizes": [5, 10], "amount": 7, "mode": "overfill"},
+			{"packSizes": [5, 10], "amount": 20, "mode": "under"}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	h.CalculateBatch(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		BatchID   int32 `json:"batchId"`
+		Succeeded int   `json:"succeeded"`
+		Failed    int   `json:"failed"`
+		Items     []struct {
+			Index     int            `json:"index"`
+			ID        int32          `json:"id"`
+			Packages  map[string]int `json:"packages"`
+			Overshoot int            `json:"overshoot"`
+			Error     string         `json:"error"`
+			Status    int            `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v\n%s", err, w.Body.String())
+	}
+
+	if resp.BatchID != 1 || resp.Succeeded != 2 || resp.Failed != 2 || len(resp.Items) != 4 {
+		t.Fatalf("expected batch 1 with 2 succeeded and 2 failed items, got %+v", resp)
+	}
+	if !reflect.DeepEqual(resp.Items[0].Packages, map[string]int{"53": 9429, "31": 7, "23": 2}) || resp.Items[0].ID == 0 {
+		t.Errorf("item 0: unexpected result %+v", resp.Items[0])
+	}
+	if resp.Items[1].Status != http.StatusUnprocessableEntity || resp.Items[1].Packages != nil {
+		t.Errorf("item 1: expected status 422, got %+v", resp.Items[1])
+	}
+	if resp.Items[2].Overshoot != 3 || resp.Items[2].Packages["10"] != 1 {
+		t.Errorf("item 2: expected one 10 pack with overshoot 3, got %+v", resp.Items[2])
+	}
+	if resp.Items[3].Status != http.StatusBadRequest || !strings.Contains(resp.Items[3].Error, "Invalid mode: under") {
+		t.Errorf("item 3: expected status 400 for the mode, got %+v", resp.Items[3])
+	}
+
+	if len(mockRepo.Batches) != 1 || len(mockRepo.Calculations) != 2 {
+		t.Errorf("expected the batch stored once with 2 calculations, got %d batches and %d calculations", len(mockRepo.Batches), len(mockRepo.Calculations))
+	}
+}
+
+func TestCalculatorE2E_Batch_Invalid(t *testing.T) {
+	h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), nil)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"form body", "application/x-www-form-urlencoded", "amount=5", http.StatusUnsupportedMediaType},
+		{"empty batch", "application/json", `{"packSizes": [5]}`, http.StatusBadRequest},
+		{"malformed", "application/json", `{"amounts": [5,`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			h.CalculateBatch(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
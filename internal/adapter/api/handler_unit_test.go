@@ -2,25 +2,44 @@ package api_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"ignis/internal/adapter/api"
+	"ignis/internal/adapter/db"
 	dbsqlc "ignis/internal/adapter/db/sqlc"
 	"ignis/internal/domain"
+	"ignis/internal/service"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-// MockRepository implements db.Repository
+// MockRepository implements the db.Repository methods the handlers use, the
+// others are left to the embedded nil interface
 type MockRepository struct {
+	db.Repository
 	Calculations []dbsqlc.Calculation
 	CreateErr    error
 	ListErr      error
 	LastCreated  dbsqlc.CreateCalculationParams
+	Batches      [][]db.BatchItem
+	// Configurations is keyed by ID, names must be unique like the table's
+	Configurations map[int32]dbsqlc.PackConfiguration
+	Versions       []dbsqlc.PackConfigurationVersion
+	Jobs           []dbsqlc.CalculationJob
+	Orders         []dbsqlc.CalculationOrder
 }
 
 func (m *MockRepository) CreateCalculation(ctx context.Context, arg dbsqlc.CreateCalculationParams) (dbsqlc.Calculation, error) {
@@ -29,39 +48,380 @@ func (m *MockRepository) CreateCalculation(ctx context.Context, arg dbsqlc.Creat
 		return dbsqlc.Calculation{}, m.CreateErr
 	}
 	calc := dbsqlc.Calculation{
-		ID:           1,
-		PackSizes:    arg.PackSizes,
-		TargetAmount: arg.TargetAmount,
-		ResultJson:   arg.ResultJson,
-		TotalItems:   arg.TotalItems,
-		CreatedAt:    pgtype.Timestamp{Time: time.Now(), Valid: true},
+		ID:              int32(len(m.Calculations) + 1),
+		PackSizes:       arg.PackSizes,
+		TargetAmount:    arg.TargetAmount,
+		ResultJson:      arg.ResultJson,
+		TotalItems:      arg.TotalItems,
+		Stock:           arg.Stock,
+		CreatedAt:       pgtype.Timestamp{Time: time.Now(), Valid: true},
+		ConfigurationID: arg.ConfigurationID,
+		PackSizeSet:     arg.PackSizeSet,
+		Objective:       arg.Objective,
+		Costs:           arg.Costs,
+		TotalCost:       arg.TotalCost,
+		Mode:            arg.Mode,
+		OrderReference:  arg.OrderReference,
+		Sku:             arg.Sku,
+		OrderID:         arg.OrderID,
+		Request:         arg.Request,
 	}
 	m.Calculations = append(m.Calculations, calc)
 	return calc, nil
 }
 
-func (m *MockRepository) ListCalculations(ctx context.Context) ([]dbsqlc.Calculation, error) {
+// ListCalculations applies the page and filters like the query does, newest first
+func (m *MockRepository) ListCalculations(ctx context.Context, arg dbsqlc.ListCalculationsParams) ([]dbsqlc.Calculation, error) {
 	if m.ListErr != nil {
 		return nil, m.ListErr
 	}
-	return m.Calculations, nil
+
+	calculations := slices.Clone(m.Calculations)
+	sort.SliceStable(calculations, func(i, j int) bool {
+		if !calculations[i].CreatedAt.Time.Equal(calculations[j].CreatedAt.Time) {
+			return calculations[i].CreatedAt.Time.After(calculations[j].CreatedAt.Time)
+		}
+		return calculations[i].ID > calculations[j].ID
+	})
+
+	var page []dbsqlc.Calculation
+	for _, calc := range calculations {
+		switch {
+		case calc.DeletedAt.Valid:
+		case arg.BeforeCreatedAt.Valid && !calc.CreatedAt.Time.Before(arg.BeforeCreatedAt.Time) &&
+			!(calc.CreatedAt.Time.Equal(arg.BeforeCreatedAt.Time) && calc.ID < arg.BeforeID.Int32):
+		case arg.MinAmount.Valid && calc.TargetAmount < arg.MinAmount.Int64:
+		case arg.MaxAmount.Valid && calc.TargetAmount > arg.MaxAmount.Int64:
+		case arg.PackSizeSet != nil && !slices.Equal(calc.PackSizeSet, arg.PackSizeSet):
+		case arg.CreatedFrom.Valid && calc.CreatedAt.Time.Before(arg.CreatedFrom.Time):
+		case arg.CreatedTo.Valid && !calc.CreatedAt.Time.Before(arg.CreatedTo.Time):
+		case arg.ConfigurationID.Valid && calc.ConfigurationID != arg.ConfigurationID:
+		default:
+			page = append(page, calc)
+		}
+		if len(page) == int(arg.PageSize) {
+			break
+		}
+	}
+	return page, nil
+}
+
+func (m *MockRepository) ListCalculationPackSizes(ctx context.Context, arg dbsqlc.ListCalculationPackSizesParams) ([]int32, error) {
+	calculations, err := m.ListCalculations(ctx, dbsqlc.ListCalculationsParams{
+		MinAmount:       arg.MinAmount,
+		MaxAmount:       arg.MaxAmount,
+		PackSizeSet:     arg.PackSizeSet,
+		CreatedFrom:     arg.CreatedFrom,
+		CreatedTo:       arg.CreatedTo,
+		ConfigurationID: arg.ConfigurationID,
+		PageSize:        math.MaxInt32,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sizes []int32
+	for _, calc := range calculations {
+		sizes = append(sizes, calc.PackSizeSet...)
+	}
+	slices.Sort(sizes)
+	slices.Reverse(sizes)
+	return slices.Compact(sizes), nil
+}
+
+func (m *MockRepository) GetCalculation(ctx context.Context, id int32) (dbsqlc.Calculation, error) {
+	for _, calc := range m.Calculations {
+		if calc.ID == id && !calc.DeletedAt.Valid {
+			return calc, nil
+		}
+	}
+	return dbsqlc.Calculation{}, pgx.ErrNoRows
+}
+
+func (m *MockRepository) DeleteCalculation(ctx context.Context, id int32) (int64, error) {
+	for i, calc := range m.Calculations {
+		if calc.ID == id && !calc.DeletedAt.Valid {
+			m.Calculations[i].DeletedAt = pgtype.Timestamp{Time: time.Now(), Valid: true}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *MockRepository) CreateCalculationBatch(ctx context.Context, arg dbsqlc.CreateCalculationBatchParams) (dbsqlc.CalculationBatch, error) {
+	return dbsqlc.CalculationBatch{ID: int32(len(m.Batches) + 1), ItemCount: arg.ItemCount, FailedCount: arg.FailedCount, Errors: arg.Errors}, nil
+}
+
+func (m *MockRepository) SaveBatch(ctx context.Context, items []db.BatchItem) (dbsqlc.CalculationBatch, []int32, error) {
+	if m.CreateErr != nil {
+		return dbsqlc.CalculationBatch{}, nil, m.CreateErr
+	}
+	m.Batches = append(m.Batches, items)
+
+	ids := make([]int32, len(items))
+	for i, item := range items {
+		if item.Calculation != nil {
+			calc, _ := m.CreateCalculation(ctx, *item.Calculation)
+			ids[i] = calc.ID
+		}
+	}
+	return dbsqlc.CalculationBatch{ID: int32(len(m.Batches))}, ids, nil
+}
+
+func (m *MockRepository) SaveOrder(ctx context.Context, arg dbsqlc.CreateCalculationOrderParams, items []db.BatchItem) (dbsqlc.CalculationOrder, []int32, error) {
+	if m.CreateErr != nil {
+		return dbsqlc.CalculationOrder{}, nil, m.CreateErr
+	}
+
+	failed := make([]db.ItemError, 0)
+	for i, item := range items {
+		if item.Calculation == nil {
+			failed = append(failed, db.ItemError{Index: i, Error: item.Error})
+		}
+	}
+	errorsJson, _ := json.Marshal(failed)
+	order := dbsqlc.CalculationOrder{
+		ID:          int32(len(m.Orders) + 1),
+		Reference:   arg.Reference,
+		LineCount:   int32(len(items)),
+		FailedCount: int32(len(failed)),
+		Errors:      errorsJson,
+		TotalAmount: arg.TotalAmount,
+		TotalItems:  arg.TotalItems,
+		TotalPacks:  arg.TotalPacks,
+		Overshoot:   arg.Overshoot,
+		TotalCost:   arg.TotalCost,
+		CreatedAt:   pgtype.Timestamp{Time: time.Now(), Valid: true},
+	}
+	m.Orders = append(m.Orders, order)
+
+	ids := make([]int32, len(items))
+	for i, item := range items {
+		if item.Calculation != nil {
+			params := *item.Calculation
+			params.OrderID = pgtype.Int4{Int32: order.ID, Valid: true}
+			params.OrderReference = order.Reference
+			calc, _ := m.CreateCalculation(ctx, params)
+			ids[i] = calc.ID
+		}
+	}
+	return order, ids, nil
+}
+
+func (m *MockRepository) GetCalculationOrder(ctx context.Context, id int32) (dbsqlc.CalculationOrder, error) {
+	if id < 1 || int(id) > len(m.Orders) {
+		return dbsqlc.CalculationOrder{}, pgx.ErrNoRows
+	}
+	return m.Orders[id-1], nil
+}
+
+func (m *MockRepository) ListOrderCalculations(ctx context.Context, orderID int32) ([]dbsqlc.Calculation, error) {
+	var calculations []dbsqlc.Calculation
+	for _, calc := range m.Calculations {
+		if calc.OrderID.Valid && calc.OrderID.Int32 == orderID && !calc.DeletedAt.Valid {
+			calculations = append(calculations, calc)
+		}
+	}
+	return calculations, nil
+}
+
+func (m *MockRepository) CreateConfiguration(ctx context.Context, arg dbsqlc.CreatePackConfigurationParams, version db.ConfigurationVersion) (dbsqlc.PackConfiguration, dbsqlc.PackConfigurationVersion, error) {
+	if m.CreateErr != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, m.CreateErr
+	}
+	if m.nameTaken(arg.Name, 0) {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, &pgconn.PgError{Code: "23505"}
+	}
+	if m.Configurations == nil {
+		m.Configurations = make(map[int32]dbsqlc.PackConfiguration)
+	}
+
+	configuration := dbsqlc.PackConfiguration{
+		ID:          int32(len(m.Configurations) + 1),
+		Name:        arg.Name,
+		Description: arg.Description,
+		CreatedAt:   pgtype.Timestamp{Time: time.Now(), Valid: true},
+		UpdatedAt:   pgtype.Timestamp{Time: time.Now(), Valid: true},
+	}
+	m.Configurations[configuration.ID] = configuration
+	return configuration, m.addVersion(configuration.ID, version), nil
+}
+
+func (m *MockRepository) UpdateConfiguration(ctx context.Context, arg dbsqlc.UpdatePackConfigurationParams, version db.ConfigurationVersion) (dbsqlc.PackConfiguration, dbsqlc.PackConfigurationVersion, error) {
+	configuration, ok := m.Configurations[arg.ID]
+	if !ok {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, pgx.ErrNoRows
+	}
+	if m.nameTaken(arg.Name, arg.ID) {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, &pgconn.PgError{Code: "23505"}
+	}
+	configuration.Name = arg.Name
+	configuration.Description = arg.Description
+	m.Configurations[arg.ID] = configuration
+
+	versions, _ := m.ListPackConfigurationVersions(ctx, arg.ID)
+	latest := &m.Versions[versions[len(versions)-1].ID-1]
+	if reflect.DeepEqual(latest.PackSizes, version.PackSizes) {
+		return configuration, *latest, nil
+	}
+	now := time.Now()
+	if version.ValidFrom.IsZero() {
+		version.ValidFrom = now
+	}
+	if err := db.CheckVersionStart(version.ValidFrom, latest.ValidFrom.Time, now); err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, err
+	}
+	latest.ValidTo = db.Timestamp(version.ValidFrom)
+	return configuration, m.addVersion(arg.ID, version), nil
+}
+
+func (m *MockRepository) nameTaken(name string, id int32) bool {
+	for _, configuration := range m.Configurations {
+		if configuration.Name == name && configuration.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// addVersion appends a version, IDs are positions in Versions plus one
+func (m *MockRepository) addVersion(configurationID int32, version db.ConfigurationVersion) dbsqlc.PackConfigurationVersion {
+	created := dbsqlc.PackConfigurationVersion{
+		ID:              int32(len(m.Versions) + 1),
+		ConfigurationID: configurationID,
+		PackSizes:       version.PackSizes,
+		ValidFrom:       db.Timestamp(validFromOrNow(version)),
+	}
+	m.Versions = append(m.Versions, created)
+	return created
+}
+
+// validFromOrNow is the start of a version, now when none was given
+func validFromOrNow(version db.ConfigurationVersion) time.Time {
+	if version.ValidFrom.IsZero() {
+		return time.Now()
+	}
+	return version.ValidFrom
+}
+
+func (m *MockRepository) GetPackConfiguration(ctx context.Context, id int32) (dbsqlc.PackConfiguration, error) {
+	configuration, ok := m.Configurations[id]
+	if !ok {
+		return dbsqlc.PackConfiguration{}, pgx.ErrNoRows
+	}
+	return configuration, nil
+}
+
+func (m *MockRepository) ListPackConfigurations(ctx context.Context) ([]dbsqlc.PackConfiguration, error) {
+	configurations := make([]dbsqlc.PackConfiguration, 0, len(m.Configurations))
+	for _, configuration := range m.Configurations {
+		configurations = append(configurations, configuration)
+	}
+	sort.Slice(configurations, func(i, j int) bool { return configurations[i].Name < configurations[j].Name })
+	return configurations, nil
+}
+
+func (m *MockRepository) DeletePackConfiguration(ctx context.Context, id int32) (int64, error) {
+	if _, ok := m.Configurations[id]; !ok {
+		return 0, nil
+	}
+	delete(m.Configurations, id)
+	return 1, nil
+}
+
+func (m *MockRepository) ListPackConfigurationVersions(ctx context.Context, configurationID int32) ([]dbsqlc.PackConfigurationVersion, error) {
+	var versions []dbsqlc.PackConfigurationVersion
+	for _, version := range m.Versions {
+		if version.ConfigurationID == configurationID {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (m *MockRepository) GetPackConfigurationVersionAt(ctx context.Context, arg dbsqlc.GetPackConfigurationVersionAtParams) (dbsqlc.PackConfigurationVersion, error) {
+	versions, _ := m.ListPackConfigurationVersionsAt(ctx, arg.At)
+	for _, version := range versions {
+		if version.ConfigurationID == arg.ConfigurationID {
+			return version, nil
+		}
+	}
+	return dbsqlc.PackConfigurationVersion{}, pgx.ErrNoRows
+}
+
+func (m *MockRepository) ListPackConfigurationVersionsAt(ctx context.Context, at pgtype.Timestamp) ([]dbsqlc.PackConfigurationVersion, error) {
+	var versions []dbsqlc.PackConfigurationVersion
+	for _, version := range m.Versions {
+		if _, ok := m.Configurations[version.ConfigurationID]; !ok {
+			continue
+		}
+		if !version.ValidFrom.Time.After(at.Time) && (!version.ValidTo.Valid || version.ValidTo.Time.After(at.Time)) {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (m *MockRepository) CreateJob(ctx context.Context, arg dbsqlc.CreateJobParams) (dbsqlc.CalculationJob, error) {
+	if m.CreateErr != nil {
+		return dbsqlc.CalculationJob{}, m.CreateErr
+	}
+	job := dbsqlc.CalculationJob{
+		ID:        int32(len(m.Jobs) + 1),
+		Status:    db.JobQueued,
+		Request:   arg.Request,
+		Total:     arg.Total,
+		CreatedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+	}
+	m.Jobs = append(m.Jobs, job)
+	return job, nil
+}
+
+func (m *MockRepository) GetJob(ctx context.Context, id int32) (dbsqlc.CalculationJob, error) {
+	if id < 1 || int(id) > len(m.Jobs) {
+		return dbsqlc.CalculationJob{}, pgx.ErrNoRows
+	}
+	return m.Jobs[id-1], nil
 }
 
 func (m *MockRepository) Close() {}
 
 // MockCalculator implements domain.PackageCalculator
 type MockCalculator struct {
-	Result *domain.CalculateResult
-	Err    error
+	Result      *domain.CalculateResult
+	Err         error
+	Feasibility *domain.FeasibilityReport
 }
 
-func (m *MockCalculator) Calculate(req domain.CalculateRequest) (*domain.CalculateResult, error) {
+func (m *MockCalculator) AnalyzeFeasibility(ctx context.Context, packSizes []int, amount int) (*domain.FeasibilityReport, error) {
+	if m.Feasibility == nil {
+		return nil, errors.New("no feasibility report")
+	}
+	return m.Feasibility, nil
+}
+
+func (m *MockCalculator) Calculate(ctx context.Context, req domain.CalculateRequest) (*domain.CalculateResult, error) {
 	if m.Err != nil {
 		return nil, m.Err
 	}
 	return m.Result, nil
 }
 
+func (m *MockCalculator) CalculateBatch(ctx context.Context, reqs []domain.CalculateRequest) ([]domain.BatchResult, error) {
+	results := make([]domain.BatchResult, len(reqs))
+	for i := range reqs {
+		results[i] = domain.BatchResult{Result: m.Result, Err: m.Err}
+	}
+	return results, nil
+}
+
+func (m *MockCalculator) CalculateTopK(ctx context.Context, req domain.CalculateRequest, k int) ([]*domain.CalculateResult, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return []*domain.CalculateResult{m.Result}, nil
+}
+
 func TestCalculatorHandler_Calculate_Persistence(t *testing.T) {
 	mockCalc := &MockCalculator{
 		Result: &domain.CalculateResult{
@@ -75,6 +435,7 @@ func TestCalculatorHandler_Calculate_Persistence(t *testing.T) {
 	formData := url.Values{}
 	formData.Set("packSizes", "23, 31, 53")
 	formData.Set("amount", "53")
+	formData.Set("stock", "53:10, 31:unlimited")
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -93,6 +454,363 @@ func TestCalculatorHandler_Calculate_Persistence(t *testing.T) {
 	if mockRepo.LastCreated.PackSizes != "23, 31, 53" {
 		t.Errorf("expected repo to be called with pack sizes '23, 31, 53', got %v", mockRepo.LastCreated.PackSizes)
 	}
+	if string(mockRepo.LastCreated.Stock) != `{"53":10}` {
+		t.Errorf("expected repo to be called with stock {\"53\":10}, got %s", mockRepo.LastCreated.Stock)
+	}
+}
+
+func TestCalculatorHandler_Calculate_PersistsLargeAmounts(t *testing.T) {
+	mockCalc := &MockCalculator{
+		Result: &domain.CalculateResult{Packages: map[int]int{1_000_000_000: 3}, Total: 3_000_000_000, PackCount: 3},
+	}
+	mockRepo := &MockRepository{}
+	h := api.NewCalculatorHandler(mockCalc, mockRepo)
+
+	formData := url.Values{}
+	formData.Set("packSizes", "1000000000")
+	formData.Set("amount", "3000000000")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.Calculate(httptest.NewRecorder(), req)
+
+	if mockRepo.LastCreated.TargetAmount != 3_000_000_000 || mockRepo.LastCreated.TotalItems != 3_000_000_000 {
+		t.Fatalf("expected amount and total 3000000000, got %d and %d", mockRepo.LastCreated.TargetAmount, mockRepo.LastCreated.TotalItems)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/history", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	h.History(w, req)
+
+	var history []struct {
+		Amount int64 `json:"amount"`
+		Total  int64 `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+	if len(history) != 1 || history[0].Amount != 3_000_000_000 || history[0].Total != 3_000_000_000 {
+		t.Errorf("expected the stored amount and total in the history, got %+v", history)
+	}
+}
+
+func TestCalculatorHandler_Calculate_PersistsObjectiveAndCost(t *testing.T) {
+	mockCalc := &MockCalculator{
+		Result: &domain.CalculateResult{
+			Packages:  map[int]int{5: 4},
+			Total:     20,
+			Costs:     map[int]int{5: 400},
+			TotalCost: 400,
+		},
+	}
+	mockRepo := &MockRepository{}
+	h := api.NewCalculatorHandler(mockCalc, mockRepo)
+
+	formData := url.Values{}
+	formData.Set("packSizes", "5, 10")
+	formData.Set("amount", "20")
+	formData.Set("costs", "5:1, 10:3.00")
+	formData.Set("objective", "cost")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	h.Calculate(w, req)
+
+	if !strings.Contains(w.Body.String(), "Total cost: <strong>4.00</strong>") {
+		t.Errorf("expected total cost in result, got:\n%s", w.Body.String())
+	}
+	if mockRepo.LastCreated.Objective != "cost" {
+		t.Errorf("expected repo to be called with objective 'cost', got %v", mockRepo.LastCreated.Objective)
+	}
+	if string(mockRepo.LastCreated.Costs) != `{"10":300,"5":100}` {
+		t.Errorf("expected repo to be called with costs in cents, got %s", mockRepo.LastCreated.Costs)
+	}
+	if !mockRepo.LastCreated.TotalCost.Valid || mockRepo.LastCreated.TotalCost.Int64 != 400 {
+		t.Errorf("expected repo to be called with total cost 400, got %+v", mockRepo.LastCreated.TotalCost)
+	}
+}
+
+func TestCalculatorHandler_Calculate_ErrorStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{"invalid amount", domain.ErrInvalidAmount, http.StatusBadRequest, "amount must be greater than zero"},
+		{"invalid cost", fmt.Errorf("%w: missing cost for pack size 10", domain.ErrInvalidCost), http.StatusBadRequest, "missing cost for pack size 10"},
+		{"no combination", &domain.NoCombinationError{Amount: 7}, http.StatusUnprocessableEntity, "no exact combination possible"},
+		{"too many pack sizes", &domain.LimitError{Limit: domain.LimitPackSizes, Value: 100, Max: 64}, http.StatusRequestEntityTooLarge, "exceeds the limit of 64"},
+		{"amount over the limit", &domain.LimitError{Limit: domain.LimitAmount, Value: 3e9, Max: 2e9}, http.StatusUnprocessableEntity, "exceeds the limit of 2000000000"},
+		{"memory over the limit", &domain.LimitError{Limit: domain.LimitMemory, Value: 1 << 30, Max: 1 << 29}, http.StatusUnprocessableEntity, "exceeds the limit of 536870912"},
+		{"cancelled by shutdown", context.Canceled, http.StatusServiceUnavailable, "context canceled"},
+		{"server fault", errors.New("boom"), http.StatusInternalServerError, "Calculation failed, please try again later"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := api.NewCalculatorHandler(&MockCalculator{Err: tt.err}, nil)
+
+			formData := url.Values{}
+			formData.Set("packSizes", "23, 31, 53")
+			formData.Set("amount", "500000")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("HX-Request", "true")
+			w := httptest.NewRecorder()
+
+			h.Calculate(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), "<div class='error'>") || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("expected error fragment containing %q, got %s", tt.wantBody, w.Body.String())
+			}
+			if w.Header().Get("HX-Retarget") != "#result" {
+				t.Errorf("expected HX-Retarget #result, got %q", w.Header().Get("HX-Retarget"))
+			}
+		})
+	}
+}
+
+func TestCalculatorHandler_Calculate_InvalidInput(t *testing.T) {
+	h := api.NewCalculatorHandler(&MockCalculator{}, nil)
+
+	formData := url.Values{}
+	formData.Set("packSizes", "23, <b>31</b>")
+	formData.Set("amount", "53")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	h.Calculate(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Invalid pack size: &lt;b&gt;31&lt;/b&gt;") {
+		t.Errorf("expected escaped input in error fragment, got %s", w.Body.String())
+	}
+}
+
+func TestCalculatorHandler_Calculate_InvalidCosts(t *testing.T) {
+	tests := []struct {
+		costs    string
+		wantBody string
+	}{
+		{"5:NaN", "Invalid costs: 5:NaN (invalid price)"},
+		{"5:Inf", "Invalid costs: 5:Inf (invalid price)"},
+		{"5:-1", "Invalid costs: 5:-1 (invalid price)"},
+		{"5:1e300", "Invalid costs: 5:1e300 (price over 1000000)"},
+		{"5:1000000.01", "Invalid costs: 5:1000000.01 (price over 1000000)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.costs, func(t *testing.T) {
+			h := api.NewCalculatorHandler(&MockCalculator{}, nil)
+
+			formData := url.Values{}
+			formData.Set("packSizes", "5, 10")
+			formData.Set("amount", "20")
+			formData.Set("costs", tt.costs)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+
+			h.Calculate(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("expected %q in body, got %s", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+// calculateResponse is the part of a JSON calculate response the option
+// tests compare
+type calculateResponse struct {
+	Packages  map[int]int        `json:"packages"`
+	Packaging *packagingResponse `json:"packaging"`
+	Shipments []shipmentResponse `json:"shipments"`
+}
+
+type packagingResponse struct {
+	Levels     []levelResponse     `json:"levels"`
+	Containers []containerResponse `json:"containers"`
+}
+
+type levelResponse struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type shipmentResponse struct {
+	Count  int         `json:"count"`
+	Packs  map[int]int `json:"packs"`
+	Weight int         `json:"weight"`
+	Volume int         `json:"volume"`
+}
+
+type containerResponse struct {
+	Name     string              `json:"name"`
+	Count    int                 `json:"count"`
+	Packs    map[int]int         `json:"packs"`
+	Contents []containerResponse `json:"contents"`
+}
+
+// TestCalculatorHandler_Calculate_Options runs the request options through the
+// real calculator: a JSON body is compared with want, a form answer is searched
+// for wantBody
+func TestCalculatorHandler_Calculate_Options(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		form       url.Values
+		wantStatus int
+		want       *calculateResponse
+		wantBody   []string
+	}{
+		{
+			name:       "packaging JSON",
+			body:       `{"packSizes":[5,10],"amount":100,"packaging":{"container":"carton","capacity":{"5":8,"10":4},"outer":[{"name":"pallet","capacity":2}]}}`,
+			wantStatus: http.StatusOK,
+			want: &calculateResponse{
+				Packages: map[int]int{10: 10},
+				Packaging: &packagingResponse{
+					Levels: []levelResponse{{Name: "carton", Count: 3}, {Name: "pallet", Count: 2}},
+					Containers: []containerResponse{
+						{Name: "pallet", Count: 1, Contents: []containerResponse{{Name: "carton", Count: 2, Packs: map[int]int{10: 4}}}},
+						{Name: "pallet", Count: 1, Contents: []containerResponse{{Name: "carton", Count: 1, Packs: map[int]int{10: 2}}}},
+					},
+				},
+			},
+		},
+		{
+			name:       "packaging form",
+			form:       url.Values{"packSizes": {"5, 10"}, "amount": {"100"}, "cartonCapacity": {"10:4, 5:8"}, "palletCapacity": {"2"}},
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"Packaging: <strong>2 × pallet, 3 × carton</strong>", "<li>1 × pallet holding<ul><li>2 × carton: 4 packs of 10</li></ul></li>"},
+		},
+		{
+			name:       "carton capacity missing a size",
+			form:       url.Values{"packSizes": {"5, 10"}, "amount": {"100"}, "cartonCapacity": {"10:4"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid packaging: no carton capacity for packs of 5"},
+		},
+		{
+			name:       "invalid carton capacity",
+			form:       url.Values{"packSizes": {"5, 10"}, "amount": {"100"}, "cartonCapacity": {"10:x"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"Invalid carton capacity: 10:x (invalid quantity)"},
+		},
+		{
+			name:       "empty pallets",
+			form:       url.Values{"packSizes": {"5, 10"}, "amount": {"100"}, "cartonCapacity": {"10:4, 5:8"}, "palletCapacity": {"0"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid packaging: pallet capacity must be greater than zero"},
+		},
+		{
+			// 10×10 packs of 4 kg with at most 10 kg a shipment: 5 shipments of 2
+			name:       "shipping JSON",
+			body:       `{"packSizes":[5,10],"amount":100,"shipping":{"weights":{"5":2,"10":4},"maxWeight":10}}`,
+			wantStatus: http.StatusOK,
+			want: &calculateResponse{
+				Packages:  map[int]int{10: 10},
+				Shipments: []shipmentResponse{{Count: 5, Packs: map[int]int{10: 2}, Weight: 8}},
+			},
+		},
+		{
+			name:       "shipping form",
+			form:       url.Values{"packSizes": {"5, 10"}, "amount": {"100"}, "weights": {"10:4, 5:2"}, "maxWeight": {"10"}},
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"Shipments: <strong>5</strong>", "<tr><td>5</td><td>10×2</td><td>8</td><td>0</td></tr>"},
+		},
+		{
+			name:       "pack over the shipment limit",
+			body:       `{"packSizes":[5,10],"amount":100,"shipping":{"weights":{"5":2,"10":12},"maxWeight":10}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   []string{"pack exceeds the shipment limits: a pack of 10 weighs 12, over the maximum of 10"},
+		},
+		{
+			name:       "missing pack weight",
+			body:       `{"packSizes":[5,10],"amount":100,"shipping":{"weights":{"5":2},"maxWeight":10}}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid shipment limits: missing weight for pack size 10"},
+		},
+		{
+			name:       "no shipment limit",
+			body:       `{"packSizes":[5,10],"amount":100,"shipping":{"weights":{"5":2,"10":4}}}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid shipment limits: a maximum weight or volume is required"},
+		},
+		{
+			name:       "constraints JSON",
+			body:       `{"packSizes":[23,31,53],"amount":500000,"constraints":{"required":[23],"max":{"53":9000}}}`,
+			wantStatus: http.StatusOK,
+			want:       &calculateResponse{Packages: map[int]int{23: 8, 31: 736, 53: 9000}},
+		},
+		{
+			name:       "constraints form",
+			form:       url.Values{"packSizes": {"23, 31, 53"}, "amount": {"263"}, "minPacks": {"53:1"}, "forbiddenSizes": {"31"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   []string{"pack constraints cannot be met: pack size 31 forbidden, at least 1 of pack size 53"},
+		},
+		{
+			name:       "required size not offered",
+			body:       `{"packSizes":[23,31,53],"amount":263,"constraints":{"required":[50]}}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid pack constraint: pack size 50 is not offered"},
+		},
+		{
+			name:       "minimum over maximum",
+			body:       `{"packSizes":[23,31,53],"amount":263,"constraints":{"min":{"23":2},"max":{"23":1}}}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   []string{"invalid pack constraint: pack size 23 needs at least 2 and at most 1 packs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), nil)
+
+			req := jsonRequest(http.MethodPost, "/api/v1/calculate", tt.body)
+			if tt.form != nil {
+				req = httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(tt.form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			w := httptest.NewRecorder()
+
+			h.Calculate(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.want != nil {
+				var resp calculateResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("failed to decode response: %v\n%s", err, w.Body.String())
+				}
+				if !reflect.DeepEqual(resp, *tt.want) {
+					t.Errorf("expected %+v, got %s", *tt.want, w.Body.String())
+				}
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("expected %q in body, got %s", want, w.Body.String())
+				}
+			}
+		})
+	}
 }
 
 func TestCalculatorHandler_History(t *testing.T) {
@@ -101,6 +819,7 @@ func TestCalculatorHandler_History(t *testing.T) {
 			{
 				ID:           1,
 				PackSizes:    "23, 31, 53",
+				Stock:        []byte(`{"53":9500}`),
 				TargetAmount: 500000,
 				TotalItems:   500000,
 				CreatedAt:    pgtype.Timestamp{Time: time.Now(), Valid: true},
@@ -122,7 +841,150 @@ func TestCalculatorHandler_History(t *testing.T) {
 	if !strings.Contains(body, "23, 31, 53") {
 		t.Errorf("expected history to contain pack sizes, but it didn't")
 	}
+	if !strings.Contains(body, "53:9500") {
+		t.Errorf("expected history to contain stock limits, but it didn't")
+	}
 	if !strings.Contains(body, "500000") {
 		t.Errorf("expected history to contain amount, but it didn't")
 	}
 }
+
+func TestCalculatorHandler_Calculate_Negotiation(t *testing.T) {
+	mockCalc := &MockCalculator{
+		Result: &domain.CalculateResult{Packages: map[int]int{53: 1}, Total: 53, PackCount: 1},
+	}
+	h := api.NewCalculatorHandler(mockCalc, nil)
+
+	tests := []struct {
+		name     string
+		accept   string
+		htmx     bool
+		wantJSON bool
+	}{
+		{"browser form", "text/html,application/xhtml+xml", false, false},
+		{"accepts JSON", "application/json", false, true},
+		{"HTMX accepting JSON", "application/json", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formData := url.Values{}
+			formData.Set("packSizes", "53")
+			formData.Set("amount", "53")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(formData.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Accept", tt.accept)
+			if tt.htmx {
+				req.Header.Set("HX-Request", "true")
+			}
+			w := httptest.NewRecorder()
+
+			h.Calculate(w, req)
+
+			gotJSON := w.Header().Get("Content-Type") == "application/json"
+			if gotJSON != tt.wantJSON {
+				t.Errorf("expected JSON %v, got Content-Type %q", tt.wantJSON, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestCalculatorHandler_Calculate_JSONError(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+		wantError  string
+	}{
+		{"malformed body", `{"packSizes":`, nil, http.StatusBadRequest, "Invalid JSON body"},
+		{"unknown field", `{"packSizes":[53],"amount":53,"size":1}`, nil, http.StatusBadRequest, "unknown field"},
+		{"unknown mode", `{"packSizes":[53],"amount":53,"mode":"under"}`, nil, http.StatusBadRequest, "Invalid mode: under"},
+		{"no combination", `{"packSizes":[5],"amount":7}`, &domain.NoCombinationError{Amount: 7}, http.StatusUnprocessableEntity, "no exact combination possible"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := api.NewCalculatorHandler(&MockCalculator{Err: tt.err}, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/calculate", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			h.Calculate(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			var resp struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("expected a JSON error, got %s", w.Body.String())
+			}
+			if !strings.Contains(resp.Error, tt.wantError) {
+				t.Errorf("expected error containing %q, got %q", tt.wantError, resp.Error)
+			}
+			if w.Header().Get("HX-Retarget") != "" {
+				t.Errorf("expected no HX-Retarget on JSON errors")
+			}
+		})
+	}
+}
+
+func TestCalculatorHandler_History_JSON(t *testing.T) {
+	mockRepo := &MockRepository{
+		Calculations: []dbsqlc.Calculation{
+			{
+				ID:           7,
+				PackSizes:    "23, 31, 53",
+				TargetAmount: 500000,
+				ResultJson:   []byte(`{"23":2,"31":7,"53":9429}`),
+				TotalItems:   500000,
+				Stock:        []byte(`{}`),
+				Objective:    "cost",
+				Costs:        []byte(`{"23":100,"31":130,"53":200}`),
+				TotalCost:    pgtype.Int8{Int64: 1886910, Valid: true},
+				CreatedAt:    pgtype.Timestamp{Time: time.Now(), Valid: true},
+			},
+		},
+	}
+	h := api.NewCalculatorHandler(nil, mockRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/history", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	h.History(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %v", w.Code)
+	}
+
+	var history []struct {
+		ID        int32          `json:"id"`
+		PackSizes []int          `json:"packSizes"`
+		Amount    int32          `json:"amount"`
+		Packages  map[string]int `json:"packages"`
+		Objective string         `json:"objective"`
+		TotalCost *int64         `json:"totalCost"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("failed to decode history: %v\n%s", err, w.Body.String())
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(history))
+	}
+
+	entry := history[0]
+	if entry.ID != 7 || entry.Amount != 500000 || entry.Packages["53"] != 9429 || entry.Objective != "cost" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if !reflect.DeepEqual(entry.PackSizes, []int{23, 31, 53}) {
+		t.Errorf("expected pack sizes [23 31 53], got %v", entry.PackSizes)
+	}
+	if entry.TotalCost == nil || *entry.TotalCost != 1886910 {
+		t.Errorf("expected total cost 1886910, got %v", entry.TotalCost)
+	}
+}
@@ -0,0 +1,474 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/domain"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// History lists stored calculations newest first, one page at a time. Query
+// parameters filter by amount range, pack-size set, date range and
+// configuration, and "cursor" continues after the previous page. JSON clients
+// find the next page in the Link header, HTMX clients get a "Load more" row
+// that swaps in the next rows.
+func (h *CalculatorHandler) History(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	asJSON := wantsJSON(r)
+
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+
+	calculations, next, err := db.ListHistory(ctx, h.repo, query)
+	if err != nil {
+		log.Printf("failed to load history: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to load history")
+		return
+	}
+
+	var nextURL string
+	if next != nil {
+		nextURL = historyPageURL(r.URL, next)
+	}
+
+	if asJSON {
+		if next != nil {
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextURL))
+		}
+		writeJSON(w, http.StatusOK, newHistoryResponse(calculations))
+		return
+	}
+
+	// Name the configuration of each row, an unnamed ID still groups rows
+	names := make(map[int32]string)
+	configurations, err := h.repo.ListPackConfigurations(ctx)
+	if err != nil {
+		log.Printf("failed to load configurations: %v\n", err)
+	}
+	for _, configuration := range configurations {
+		names[configuration.ID] = configuration.Name
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+
+	// Later pages are rows for the table of the first one
+	if query.After != nil {
+		var html strings.Builder
+		writeHistoryRows(&html, calculations, names, nextURL)
+		w.Write([]byte(html.String()))
+		return
+	}
+
+	var html strings.Builder
+	html.WriteString("<div class='history-container'>")
+	html.WriteString("<h3>Recent Calculations</h3>")
+	if len(calculations) == 0 {
+		html.WriteString("<p>No history yet.</p>")
+	} else {
+		html.WriteString("<table class='history-table'>")
+		html.WriteString("<tr><th>Date</th><th>Configuration</th><th>Packs</th><th>Stock</th><th>Amount</th><th>Total</th><th>Objective</th><th>Cost</th><th></th></tr>")
+		writeHistoryRows(&html, calculations, names, nextURL)
+		html.WriteString("</table>")
+	}
+	html.WriteString("</div>")
+
+	w.Write([]byte(html.String()))
+}
+
+// historyColumns is the number of columns of the history table
+const historyColumns = 9
+
+// writeHistoryRows renders calculations as table rows, followed by a row whose
+// button replaces itself with the next page when there is one
+func writeHistoryRows(html *strings.Builder, calculations []dbsqlc.Calculation, names map[int32]string, nextURL string) {
+	for _, calc := range calculations {
+		html.WriteString("<tr>")
+		html.WriteString(fmt.Sprintf("<td>%s</td>", calc.CreatedAt.Time.Format("2006-01-02 15:04")))
+		switch name, ok := names[calc.ConfigurationID.Int32]; {
+		case !calc.ConfigurationID.Valid:
+			html.WriteString("<td>-</td>")
+		case ok:
+			html.WriteString(fmt.Sprintf("<td>%s</td>", template.HTMLEscapeString(name)))
+		default:
+			html.WriteString(fmt.Sprintf("<td>#%d</td>", calc.ConfigurationID.Int32))
+		}
+		html.WriteString(fmt.Sprintf("<td>%s</td>", calc.PackSizes))
+		html.WriteString(fmt.Sprintf("<td>%s</td>", formatStockJSON(calc.Stock)))
+		html.WriteString(fmt.Sprintf("<td>%d</td>", calc.TargetAmount))
+		html.WriteString(fmt.Sprintf("<td>%d</td>", calc.TotalItems))
+		html.WriteString(fmt.Sprintf("<td>%s</td>", calc.Objective))
+		if calc.TotalCost.Valid {
+			html.WriteString(fmt.Sprintf("<td>%s</td>", formatCents(calc.TotalCost.Int64)))
+		} else {
+			html.WriteString("<td>-</td>")
+		}
+		writeHistoryActions(html, calc.ID)
+		html.WriteString("</tr>")
+	}
+
+	if nextURL != "" {
+		html.WriteString(fmt.Sprintf("<tr class='history-more'><td colspan='%d'>", historyColumns))
+		html.WriteString(fmt.Sprintf("<button hx-get='%s' hx-params='none' hx-target='closest tr' hx-swap='outerHTML'>Load more</button>",
+			template.HTMLEscapeString(nextURL)))
+		html.WriteString("</td></tr>")
+	}
+}
+
+// writeHistoryActions renders the detail, replay and delete buttons of a row.
+// They send no filter fields, and their errors go to the detail panel.
+func writeHistoryActions(html *strings.Builder, id int32) {
+	html.WriteString("<td class='history-actions' hx-params='none' hx-target-error='#history-detail'>")
+	html.WriteString(fmt.Sprintf("<button hx-get='/api/v1/history/%d' hx-target='#history-detail'>Details</button>", id))
+	html.WriteString(fmt.Sprintf("<button hx-post='/api/v1/history/%d/replay' hx-target='#history-detail'>Replay</button>", id))
+	html.WriteString(fmt.Sprintf("<button hx-delete='/api/v1/history/%d' hx-target='closest tr' hx-swap='outerHTML' hx-confirm='Delete calculation #%d?'>Delete</button>", id, id))
+	html.WriteString("</td>")
+}
+
+// parseHistoryQuery reads the page and filters of a history request. Empty
+// parameters are ignored, so the filter form can send all of its fields.
+// Errors name the invalid parameter and value, e.g. "minAmount: abc".
+func parseHistoryQuery(values url.Values) (db.HistoryQuery, error) {
+	query, err := parseHistoryFilters(values)
+	if err != nil {
+		return db.HistoryQuery{}, err
+	}
+
+	if cursorStr := values.Get("cursor"); cursorStr != "" {
+		cursor, err := db.DecodeHistoryCursor(cursorStr)
+		if err != nil {
+			return db.HistoryQuery{}, fmt.Errorf("cursor: %s", cursorStr)
+		}
+		query.After = &cursor
+	}
+
+	if limitStr := strings.TrimSpace(values.Get("limit")); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > db.MaxHistoryPageSize {
+			return db.HistoryQuery{}, fmt.Errorf("limit: %s (1-%d)", limitStr, db.MaxHistoryPageSize)
+		}
+		query.PageSize = limit
+	}
+
+	return query, nil
+}
+
+// parseHistoryFilters reads the filters shared by the history and its export
+func parseHistoryFilters(values url.Values) (db.HistoryQuery, error) {
+	var query db.HistoryQuery
+
+	var err error
+	if query.MinAmount, err = parseAmountFilter(values, "minAmount"); err != nil {
+		return db.HistoryQuery{}, err
+	}
+	if query.MaxAmount, err = parseAmountFilter(values, "maxAmount"); err != nil {
+		return db.HistoryQuery{}, err
+	}
+
+	for _, sizeStr := range strings.Split(values.Get("packSizes"), ",") {
+		sizeStr = strings.TrimSpace(sizeStr)
+		if sizeStr == "" {
+			continue
+		}
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < 1 {
+			return db.HistoryQuery{}, fmt.Errorf("pack size: %s", sizeStr)
+		}
+		query.PackSizes = append(query.PackSizes, size)
+	}
+
+	if fromStr := values.Get("from"); fromStr != "" {
+		if query.From, err = parseAt(fromStr); err != nil {
+			return db.HistoryQuery{}, fmt.Errorf("from: %s", fromStr)
+		}
+	}
+	if toStr := values.Get("to"); toStr != "" {
+		if query.To, err = parseAt(toStr); err != nil {
+			return db.HistoryQuery{}, fmt.Errorf("to: %s", toStr)
+		}
+		// A date includes the whole day
+		if _, err := time.Parse(time.DateOnly, strings.TrimSpace(toStr)); err == nil {
+			query.To = query.To.AddDate(0, 0, 1)
+		}
+	}
+
+	if query.ConfigurationID, err = parseConfigurationID(values.Get("configurationId")); err != nil {
+		return db.HistoryQuery{}, err
+	}
+
+	return query, nil
+}
+
+// parseAmountFilter reads an optional positive amount bound, 0 when empty
+func parseAmountFilter(values url.Values, name string) (int, error) {
+	amountStr := strings.TrimSpace(values.Get(name))
+	if amountStr == "" {
+		return 0, nil
+	}
+
+	amount, err := strconv.ParseInt(amountStr, 10, 32)
+	if err != nil || amount < 1 {
+		return 0, errors.New(name + ": " + amountStr)
+	}
+
+	return int(amount), nil
+}
+
+// historyPageURL is the request URL continuing after the cursor, keeping filters
+func historyPageURL(u *url.URL, cursor *db.HistoryCursor) string {
+	values := u.Query()
+	values.Set("cursor", cursor.Encode())
+
+	return u.Path + "?" + values.Encode()
+}
+
+// HistoryDetail shows a stored calculation with its plan broken down, rebuilt
+// from the stored result
+func (h *CalculatorHandler) HistoryDetail(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+	calc, ok := h.storedCalculation(w, r, asJSON)
+	if !ok {
+		return
+	}
+
+	req, err := db.StoredRequest(calc)
+	var result *domain.CalculateResult
+	if err == nil {
+		result, err = db.StoredResult(calc)
+	}
+	if err != nil {
+		log.Printf("failed to read calculation %d: %v\n", calc.ID, err)
+		writeError(w, asJSON, http.StatusInternalServerError, fmt.Sprintf("Failed to read calculation %d", calc.ID))
+		return
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, calculationDetailJSON{
+			calculationJSON: newCalculationJSON(calc),
+			Constraints:     newConstraintsJSON(req.Constraints),
+			Packaging:       newPackagingJSON(req.Packaging),
+			Shipping:        newShippingJSON(req.Shipping),
+			Plan:            newPlanJSON(result),
+		})
+		return
+	}
+
+	var html strings.Builder
+	html.WriteString("<div class='result-success'>")
+	html.WriteString(fmt.Sprintf("<h3>Calculation #%d: %d items</h3>", calc.ID, calc.TargetAmount))
+	html.WriteString(fmt.Sprintf("<p>%s, packs %s, stock %s, %s mode, %s objective</p>",
+		calc.CreatedAt.Time.Format("2006-01-02 15:04"), template.HTMLEscapeString(calc.PackSizes),
+		formatStockJSON(calc.Stock), calc.Mode, calc.Objective))
+	if req.Constraints != nil {
+		constraints := make([]string, 0)
+		for _, constraint := range req.Constraints.List() {
+			constraints = append(constraints, constraint.String())
+		}
+		html.WriteString(fmt.Sprintf("<p>Constraints: %s</p>", strings.Join(constraints, ", ")))
+	}
+	if req.Packaging != nil {
+		html.WriteString(fmt.Sprintf("<p>Packaging: %s</p>", template.HTMLEscapeString(formatPackaging(*req.Packaging))))
+	}
+	if limits := req.Shipping; limits != nil {
+		html.WriteString(fmt.Sprintf("<p>Shipments of at most %s</p>", formatShipmentLimits(*limits)))
+	}
+	writePlan(&html, result)
+	html.WriteString("</div>")
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(html.String()))
+}
+
+// ReplayCalculation runs the stored input of a calculation through the current
+// calculator and reports how the plan differs from the stored one. The replay
+// is not saved. An input that no longer calculates is a difference, not an error.
+func (h *CalculatorHandler) ReplayCalculation(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+	calc, ok := h.storedCalculation(w, r, asJSON)
+	if !ok {
+		return
+	}
+
+	req, err := db.StoredRequest(calc)
+	var stored *domain.CalculateResult
+	if err == nil {
+		stored, err = db.StoredResult(calc)
+	}
+	if err != nil {
+		log.Printf("failed to read calculation %d: %v\n", calc.ID, err)
+		writeError(w, asJSON, http.StatusInternalServerError, fmt.Sprintf("Failed to read calculation %d", calc.ID))
+		return
+	}
+
+	resp := replayJSON{
+		ID:          calc.ID,
+		Amount:      calc.TargetAmount,
+		Stored:      newPlanJSON(stored),
+		Differences: []replayDifferenceJSON{},
+	}
+	current, err := h.calculator.Calculate(r.Context(), req)
+	if err != nil {
+		status := errorStatus(err)
+		if status >= http.StatusInternalServerError {
+			log.Printf("replay of calculation %d failed: %v\n", calc.ID, err)
+			writeError(w, asJSON, status, "Replay failed, please try again later")
+			return
+		}
+		resp.Error = fmt.Sprintf("Calculation error: %s", err.Error())
+		resp.Changed = true
+	} else {
+		plan := newPlanJSON(current)
+		resp.Current = &plan
+		resp.Differences = planDifferences(stored, current)
+		resp.Changed = len(resp.Differences) > 0
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	var html strings.Builder
+	html.WriteString("<div class='result-success'>")
+	html.WriteString(fmt.Sprintf("<h3>Replay of calculation #%d: %d items</h3>", calc.ID, calc.TargetAmount))
+	switch {
+	case resp.Error != "":
+		html.WriteString(fmt.Sprintf("<p class='error'>The stored input no longer calculates: %s</p>", template.HTMLEscapeString(resp.Error)))
+	case !resp.Changed:
+		html.WriteString("<p>The current calculator gives the same plan.</p>")
+	default:
+		html.WriteString("<p>The current calculator gives a different plan:</p>")
+		html.WriteString("<table class='result-table'><tr><th></th><th>Stored</th><th>Current</th></tr>")
+		for _, diff := range resp.Differences {
+			stored, current := strconv.Itoa(diff.Stored), strconv.Itoa(diff.Current)
+			if diff.Field == "totalCost" {
+				stored, current = formatCents(int64(diff.Stored)), formatCents(int64(diff.Current))
+			}
+			html.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%s</td></tr>", differenceLabel(diff.Field), stored, current))
+		}
+		html.WriteString("</table>")
+		html.WriteString("<h4>Current plan</h4>")
+		writePlan(&html, current)
+	}
+	html.WriteString("</div>")
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(html.String()))
+}
+
+// DeleteCalculation removes a calculation from the history. The row is kept,
+// marked deleted. HTMX clients get an empty 200 that swaps the row away.
+func (h *CalculatorHandler) DeleteCalculation(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+	id, ok := calculationID(w, r, asJSON)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.repo.DeleteCalculation(r.Context(), id)
+	if err != nil {
+		log.Printf("failed to delete calculation %d: %v\n", id, err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to delete calculation, please try again later")
+		return
+	}
+	if deleted == 0 {
+		writeError(w, asJSON, http.StatusNotFound, fmt.Sprintf("Calculation %d not found", id))
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// calculationID reads the {id} path value, answering 400 when it is not an ID
+func calculationID(w http.ResponseWriter, r *http.Request, asJSON bool) (int32, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil || id <= 0 {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid calculation ID: %s", r.PathValue("id")))
+		return 0, false
+	}
+
+	return int32(id), true
+}
+
+// storedCalculation loads the calculation of the {id} path value, answering
+// 400, 404 or 500 when there is none to show
+func (h *CalculatorHandler) storedCalculation(w http.ResponseWriter, r *http.Request, asJSON bool) (dbsqlc.Calculation, bool) {
+	id, ok := calculationID(w, r, asJSON)
+	if !ok {
+		return dbsqlc.Calculation{}, false
+	}
+
+	calc, err := h.repo.GetCalculation(r.Context(), id)
+	switch {
+	case db.IsNotFound(err):
+		writeError(w, asJSON, http.StatusNotFound, fmt.Sprintf("Calculation %d not found", id))
+		return dbsqlc.Calculation{}, false
+	case err != nil:
+		log.Printf("failed to load calculation %d: %v\n", id, err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to load calculation, please try again later")
+		return dbsqlc.Calculation{}, false
+	}
+
+	return calc, true
+}
+
+// planDifferences lists the pack counts and totals that differ between two
+// plans, largest pack size first
+func planDifferences(stored, current *domain.CalculateResult) []replayDifferenceJSON {
+	sizes := make([]int, 0, len(stored.Packages)+len(current.Packages))
+	for size := range stored.Packages {
+		sizes = append(sizes, size)
+	}
+	for size := range current.Packages {
+		if _, ok := stored.Packages[size]; !ok {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	differences := []replayDifferenceJSON{}
+	add := func(field string, stored, current int) {
+		if stored != current {
+			differences = append(differences, replayDifferenceJSON{Field: field, Stored: stored, Current: current})
+		}
+	}
+	for _, size := range sizes {
+		add(fmt.Sprintf("packages.%d", size), stored.Packages[size], current.Packages[size])
+	}
+	add("total", stored.Total, current.Total)
+	add("packCount", stored.PackCount, current.PackCount)
+	if stored.Costs != nil || current.Costs != nil {
+		add("totalCost", stored.TotalCost, current.TotalCost)
+	}
+
+	return differences
+}
+
+// differenceLabel names a replayDifferenceJSON field for the replay table
+func differenceLabel(field string) string {
+	switch field {
+	case "total":
+		return "Total items"
+	case "packCount":
+		return "Pack count"
+	case "totalCost":
+		return "Total cost"
+	default:
+		return "Packs of " + strings.TrimPrefix(field, "packages.")
+	}
+}
@@ -0,0 +1,364 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ignis/internal/adapter/api"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/domain"
+	"ignis/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// historyRepository holds calculations 1..n, one minute apart, with amount 100*ID;
+// even IDs used pack sizes 5 and 10, odd ones 23, 31 and 53
+func historyRepository(n int) *MockRepository {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := &MockRepository{}
+	for i := 1; i <= n; i++ {
+		calc := dbsqlc.Calculation{
+			ID:           int32(i),
+			PackSizes:    "23, 31, 53",
+			PackSizeSet:  []int32{23, 31, 53},
+			TargetAmount: int64(100 * i),
+			TotalItems:   int64(100 * i),
+			Stock:        []byte(`{}`),
+			CreatedAt:    pgtype.Timestamp{Time: start.Add(time.Duration(i) * time.Minute), Valid: true},
+		}
+		if i%2 == 0 {
+			calc.PackSizes = "10, 5"
+			calc.PackSizeSet = []int32{5, 10}
+		}
+		repo.Calculations = append(repo.Calculations, calc)
+	}
+	return repo
+}
+
+func getHistoryJSON(t *testing.T, h *api.CalculatorHandler, target string) ([]int32, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	h.History(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: expected status OK, got %d: %s", target, w.Code, w.Body.String())
+	}
+	var history []struct {
+		ID int32 `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("failed to decode history: %v\n%s", err, w.Body.String())
+	}
+
+	ids := make([]int32, len(history))
+	for i, entry := range history {
+		ids[i] = entry.ID
+	}
+	next := strings.TrimSuffix(strings.TrimPrefix(w.Header().Get("Link"), "<"), `>; rel="next"`)
+	return ids, next
+}
+
+func TestCalculatorHandler_History_Pagination(t *testing.T) {
+	h := api.NewCalculatorHandler(nil, historyRepository(5))
+
+	var pages [][]int32
+	target := "/api/v1/history?limit=2&packSizes=53,23,31"
+	for target != "" {
+		var ids []int32
+		ids, target = getHistoryJSON(t, h, target)
+		pages = append(pages, ids)
+		if len(pages) > 3 {
+			t.Fatalf("expected the pages to end, got %v", pages)
+		}
+	}
+
+	// The filter is kept across pages, the last page has no Link
+	if want := [][]int32{{5, 3}, {1}}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("expected pages %v, got %v", want, pages)
+	}
+}
+
+func TestCalculatorHandler_History_Filters(t *testing.T) {
+	h := api.NewCalculatorHandler(nil, historyRepository(6))
+
+	tests := []struct {
+		query string
+		want  []int32
+	}{
+		{"minAmount=200&maxAmount=400", []int32{4, 3, 2}},
+		{"packSizes=10,5", []int32{6, 4, 2}},
+		{"from=2026-03-01T12:03:00Z&to=2026-03-01T12:05:00Z", []int32{4, 3}},
+		{"to=2026-03-01", []int32{6, 5, 4, 3, 2, 1}},
+		{"to=2026-02-28", []int32{}},
+		{"minAmount=&packSizes=", []int32{6, 5, 4, 3, 2, 1}},
+	}
+	for _, tt := range tests {
+		ids, next := getHistoryJSON(t, h, "/api/v1/history?"+tt.query)
+		if !reflect.DeepEqual(ids, tt.want) || next != "" {
+			t.Errorf("%s: expected %v on one page, got %v (next %q)", tt.query, tt.want, ids, next)
+		}
+	}
+}
+
+func TestCalculatorHandler_History_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"cursor=abc", "Invalid cursor: abc"},
+		{"limit=0", "Invalid limit: 0"},
+		{"limit=500", "Invalid limit: 500"},
+		{"minAmount=-5", "Invalid minAmount: -5"},
+		{"packSizes=5,x", "Invalid pack size: x"},
+		{"from=yesterday", "Invalid from: yesterday"},
+		{"configurationId=abc", "Invalid configuration: abc"},
+	}
+
+	h := api.NewCalculatorHandler(nil, historyRepository(1))
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/history?"+tt.query, nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		h.History(w, req)
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: expected 400 with %q, got %d: %s", tt.query, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCalculatorHandler_History_LoadMore(t *testing.T) {
+	h := api.NewCalculatorHandler(nil, historyRepository(3))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/history?limit=2&minAmount=100", nil)
+	req.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	h.History(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "<table class='history-table'>") {
+		t.Errorf("expected the first page to render the table, got %s", body)
+	}
+	match := regexp.MustCompile(`<tr class='history-more'>.*hx-get='([^']+)'`).FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("expected a load more row, got %s", body)
+	}
+	next := strings.ReplaceAll(match[1], "&amp;", "&")
+	if !strings.Contains(next, "minAmount=100") || !strings.Contains(next, "cursor=") {
+		t.Errorf("expected the next URL to keep the filter, got %s", next)
+	}
+
+	// The next page is bare rows replacing the load more row, and the last one
+	req = httptest.NewRequest(http.MethodGet, next, nil)
+	req.Header.Set("HX-Request", "true")
+	w = httptest.NewRecorder()
+	h.History(w, req)
+
+	body = w.Body.String()
+	if strings.Contains(body, "<table") || strings.Contains(body, "history-more") {
+		t.Errorf("expected only the remaining rows, got %s", body)
+	}
+	if !strings.HasPrefix(body, "<tr>") || strings.Count(body, "<tr>") != 1 {
+		t.Errorf("expected one row, got %s", body)
+	}
+}
+
+// serveHistory routes calculation requests like the app does, so path values are set
+func serveHistory(h *api.CalculatorHandler, req *http.Request) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/history/{id}", h.HistoryDetail)
+	mux.HandleFunc("POST /api/v1/history/{id}/replay", h.ReplayCalculation)
+	mux.HandleFunc("DELETE /api/v1/history/{id}", h.DeleteCalculation)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestCalculatorHandler_HistoryDetail(t *testing.T) {
+	repo := &MockRepository{}
+	h := api.NewCalculatorHandler(&MockCalculator{Result: &domain.CalculateResult{Packages: map[int]int{53: 1}, Total: 53, PackCount: 1}}, repo)
+	h.Calculate(httptest.NewRecorder(), jsonRequest(http.MethodPost, "/api/v1/calculate", `{"packSizes":[23,31,53],"amount":53}`))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/history/1", nil)
+	req.Header.Set("Accept", "application/json")
+	w := serveHistory(h, req)
+	var detail struct {
+		Mode string `json:"mode"`
+		Plan struct {
+			Packages  map[string]int `json:"packages"`
+			PackCount int            `json:"packCount"`
+		} `json:"plan"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("failed to decode detail: %v\n%s", err, w.Body.String())
+	}
+	if detail.Mode != "exact" || detail.Plan.Packages["53"] != 1 || detail.Plan.PackCount != 1 {
+		t.Errorf("unexpected detail: %+v", detail)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/history/1", nil)
+	req.Header.Set("HX-Request", "true")
+	w = serveHistory(h, req)
+	if body := w.Body.String(); !strings.Contains(body, "Calculation #1: 53 items") || !strings.Contains(body, "<tr><td>53</td><td>1</td></tr>") {
+		t.Errorf("expected the plan breakdown, got %s", body)
+	}
+
+	for _, target := range []string{"/api/v1/history/abc", "/api/v1/history/0"} {
+		w = serveHistory(h, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid calculation ID") {
+			t.Errorf("%s: expected 400, got %d: %s", target, w.Code, w.Body.String())
+		}
+	}
+	w = serveHistory(h, httptest.NewRequest(http.MethodGet, "/api/v1/history/9", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown calculation, got %d", w.Code)
+	}
+}
+
+func TestCalculatorHandler_ReplayCalculation(t *testing.T) {
+	repo := &MockRepository{}
+	calc := &MockCalculator{Result: &domain.CalculateResult{Packages: map[int]int{53: 1}, Total: 53, PackCount: 1}}
+	h := api.NewCalculatorHandler(calc, repo)
+	h.Calculate(httptest.NewRecorder(), jsonRequest(http.MethodPost, "/api/v1/calculate", `{"packSizes":[23,30,53],"amount":53}`))
+
+	replay := func() (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/history/1/replay", nil)
+		req.Header.Set("Accept", "application/json")
+		w := serveHistory(h, req)
+		return w.Code, w.Body.String()
+	}
+
+	if status, body := replay(); status != http.StatusOK || !strings.Contains(body, `"changed":false,"differences":[]`) {
+		t.Errorf("expected an unchanged replay, got %d: %s", status, body)
+	}
+
+	// The calculator now prefers two smaller packs
+	calc.Result = &domain.CalculateResult{Packages: map[int]int{30: 1, 23: 1}, Total: 53, PackCount: 2}
+	want := `"changed":true,"differences":[` +
+		`{"field":"packages.53","stored":1,"current":0},` +
+		`{"field":"packages.30","stored":0,"current":1},` +
+		`{"field":"packages.23","stored":0,"current":1},` +
+		`{"field":"packCount","stored":1,"current":2}]`
+	if status, body := replay(); status != http.StatusOK || !strings.Contains(body, want) {
+		t.Errorf("expected the differences %s, got %d: %s", want, status, body)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/history/1/replay", nil)
+	req.Header.Set("HX-Request", "true")
+	if body := serveHistory(h, req).Body.String(); !strings.Contains(body, "<tr><td>Packs of 53</td><td>1</td><td>0</td></tr>") {
+		t.Errorf("expected a difference table, got %s", body)
+	}
+
+	// An input that no longer calculates is reported, not failed
+	calc.Err = &domain.NoCombinationError{Amount: 53}
+	if status, body := replay(); status != http.StatusOK || !strings.Contains(body, `"error":"Calculation error: `) || !strings.Contains(body, `"changed":true`) {
+		t.Errorf("expected the calculation error in the replay, got %d: %s", status, body)
+	}
+}
+
+func TestCalculatorHandler_ReplayCalculation_WholeRequest(t *testing.T) {
+	repo := &MockRepository{}
+	h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), repo)
+	body := `{"packSizes":[3,5],"amount":15,"constraints":{"forbidden":[5]},` +
+		`"packaging":{"container":"box","capacity":{"3":5,"5":3}},"shipping":{"weights":{"3":1,"5":2},"maxWeight":3}}`
+	w := httptest.NewRecorder()
+	h.Calculate(w, jsonRequest(http.MethodPost, "/api/v1/calculate", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Without its constraint the replay would pick three packs of 5
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/history/1/replay", nil)
+	req.Header.Set("Accept", "application/json")
+	w = serveHistory(h, req)
+	var replay struct {
+		Changed bool `json:"changed"`
+		Current struct {
+			Packages  map[string]int `json:"packages"`
+			Shipments []struct {
+				Count int `json:"count"`
+			} `json:"shipments"`
+		} `json:"current"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &replay); err != nil {
+		t.Fatalf("failed to decode replay: %v\n%s", err, w.Body.String())
+	}
+	shipments := 0
+	for _, shipment := range replay.Current.Shipments {
+		shipments += shipment.Count
+	}
+	if replay.Changed || replay.Current.Packages["3"] != 5 || shipments != 2 {
+		t.Errorf("expected an unchanged replay of five packs of 3 in two shipments, got %+v", replay)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/history/1", nil)
+	req.Header.Set("Accept", "application/json")
+	w = serveHistory(h, req)
+	var detail struct {
+		Constraints struct {
+			Forbidden []int `json:"forbidden"`
+		} `json:"constraints"`
+		Packaging struct {
+			Container string `json:"container"`
+		} `json:"packaging"`
+		Shipping struct {
+			MaxWeight int `json:"maxWeight"`
+		} `json:"shipping"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("failed to decode detail: %v\n%s", err, w.Body.String())
+	}
+	if !reflect.DeepEqual(detail.Constraints.Forbidden, []int{5}) || detail.Packaging.Container != "box" || detail.Shipping.MaxWeight != 3 {
+		t.Errorf("expected the stored constraints, packaging and shipping, got %+v", detail)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/history/1", nil)
+	req.Header.Set("HX-Request", "true")
+	html := serveHistory(h, req).Body.String()
+	for _, want := range []string{"<p>Constraints: pack size 5 forbidden</p>", "<p>Packaging: box (5×3, 3×5)</p>", "<p>Shipments of at most weight 3</p>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in the detail, got %s", want, html)
+		}
+	}
+}
+
+func TestCalculatorHandler_DeleteCalculation(t *testing.T) {
+	repo := historyRepository(2)
+	h := api.NewCalculatorHandler(nil, repo)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/history/2", nil)
+	req.Header.Set("HX-Request", "true")
+	w := serveHistory(h, req)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected an empty 200 for HTMX, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if ids, _ := getHistoryJSON(t, h, "/api/v1/history"); !reflect.DeepEqual(ids, []int32{1}) {
+		t.Errorf("expected the deleted calculation to leave the history, got %v", ids)
+	}
+
+	w = serveHistory(h, httptest.NewRequest(http.MethodDelete, "/api/v1/history/2", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 when deleting twice, got %d", w.Code)
+	}
+	w = serveHistory(h, httptest.NewRequest(http.MethodGet, "/api/v1/history/2", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a deleted calculation, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/history/1", nil)
+	req.Header.Set("Accept", "application/json")
+	if w = serveHistory(h, req); w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+}
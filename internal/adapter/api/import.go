@@ -0,0 +1,385 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/domain"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxImportBytes caps the size of an uploaded order file
+const maxImportBytes = 8 << 20
+
+// importColumns are the columns of an order file, in this order when it has no header
+var importColumns = []string{"order_id", "sku", "amount"}
+
+// importResultColumns are appended to every line of the annotated order file
+var importResultColumns = []string{"calculation_id", "pack_sizes", "packages", "total", "overshoot", "pack_count", "error"}
+
+// importLine is one order line of an uploaded file and its outcome
+type importLine struct {
+	record  []string // as read, annotated on the way out
+	orderID string
+	sku     string
+	req     domain.CalculateRequest
+	version dbsqlc.PackConfigurationVersion // configuration supplying the pack sizes, if any
+	result  *domain.CalculateResult
+	id      int32
+	err     string
+}
+
+// ImportOrders calculates every line of an uploaded CSV order file (order_id,
+// sku, amount) and stores the lines as one batch. A line's pack sizes come from
+// the configuration named like its SKU, else from the packSizes or
+// configurationId form fields. Failed lines are reported, never fatal.
+// The answer is the file annotated with each line's packs and error, as a CSV
+// download, or for HTMX a summary with a link to it.
+func (h *CalculatorHandler) ImportOrders(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, asJSON, http.StatusRequestEntityTooLarge, fmt.Sprintf("Order file exceeds %d bytes", maxImportBytes))
+			return
+		}
+		writeError(w, asJSON, http.StatusBadRequest, "Invalid file: upload a CSV order file as multipart/form-data")
+		return
+	}
+	defer file.Close()
+
+	header, lines, err := readOrderFile(file)
+	if err != nil {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+	if len(lines) > maxBatchItems {
+		writeError(w, asJSON, http.StatusRequestEntityTooLarge, fmt.Sprintf("Order file of %d lines exceeds the limit of %d", len(lines), maxBatchItems))
+		return
+	}
+
+	configurationID, defaultSizes, err := parseImportDefaults(r)
+	if err != nil {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+	var defaultVersion dbsqlc.PackConfigurationVersion
+	if configurationID != 0 {
+		defaultVersion, err = lookupConfiguration(r.Context(), h.repo, configurationID)
+		var unknownErr *unknownConfigurationError
+		switch {
+		case errors.As(err, &unknownErr):
+			writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+			return
+		case err != nil:
+			log.Printf("failed to load configuration: %v\n", err)
+			writeError(w, asJSON, http.StatusInternalServerError, "Failed to load configuration, please try again later")
+			return
+		}
+		defaultSizes = db.PackSizesOf(defaultVersion.PackSizes)
+	}
+
+	bySKU, err := h.configurationsByName(r)
+	if err != nil {
+		log.Printf("failed to load configurations: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to load configurations, please try again later")
+		return
+	}
+
+	// Lines that resolve go to the calculator together, sharing DP tables per pack-size set
+	var reqs []domain.CalculateRequest
+	var valid []*importLine
+	for i := range lines {
+		line := &lines[i]
+		if line.err != "" {
+			continue
+		}
+		switch version, ok := bySKU[line.sku]; {
+		case ok:
+			line.version = version
+			line.req.PackSizes = db.PackSizesOf(version.PackSizes)
+		case len(defaultSizes) > 0:
+			line.version = defaultVersion
+			line.req.PackSizes = defaultSizes
+		default:
+			line.err = fmt.Sprintf("Invalid sku: %s (no configuration of that name and no default pack sizes)", line.sku)
+			continue
+		}
+		reqs = append(reqs, line.req)
+		valid = append(valid, line)
+	}
+
+	results, err := h.calculator.CalculateBatch(r.Context(), reqs)
+	if err != nil {
+		status := errorStatus(err)
+		if status == http.StatusInternalServerError {
+			log.Printf("import calculation failed: %v\n", err)
+			writeError(w, asJSON, status, "Calculation failed, please try again later")
+			return
+		}
+		writeError(w, asJSON, status, fmt.Sprintf("Calculation error: %s", err.Error()))
+		return
+	}
+	for j, result := range results {
+		line := valid[j]
+		if result.Err == nil {
+			line.result = result.Result
+			continue
+		}
+		line.err = fmt.Sprintf("Calculation error: %s", result.Err.Error())
+		if errorStatus(result.Err) == http.StatusInternalServerError {
+			log.Printf("import line failed: %v\n", result.Err)
+			line.err = "Calculation failed"
+		}
+	}
+
+	batchID := h.saveImport(r, lines)
+
+	annotated, err := writeAnnotatedOrders(header, lines)
+	if err != nil {
+		log.Printf("failed to write annotated orders: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to write the annotated order file")
+		return
+	}
+
+	filename := fmt.Sprintf("orders-%s-annotated.csv", time.Now().Format("20060102"))
+	if r.Header.Get("HX-Request") == "" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if batchID != 0 {
+			w.Header().Set("X-Batch-ID", strconv.Itoa(int(batchID)))
+		}
+		w.Write(annotated)
+		return
+	}
+
+	writeImportHTML(w, lines, batchID, filename, annotated)
+}
+
+// parseImportDefaults reads the pack sizes of lines whose SKU names no
+// configuration: the configurationId form field, else packSizes
+func parseImportDefaults(r *http.Request) (int32, []int, error) {
+	configurationID, err := parseConfigurationID(r.FormValue("configurationId"))
+	if err != nil || configurationID != 0 {
+		return configurationID, nil, err
+	}
+
+	packSizes, err := parsePackSizeList(r.FormValue("packSizes"))
+	return 0, packSizes, err
+}
+
+// configurationsByName maps configuration names to their version valid now
+func (h *CalculatorHandler) configurationsByName(r *http.Request) (map[string]dbsqlc.PackConfigurationVersion, error) {
+	byName := make(map[string]dbsqlc.PackConfigurationVersion)
+	if h.repo == nil {
+		return byName, nil
+	}
+
+	configurations, err := h.repo.ListPackConfigurations(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	versions, err := h.repo.ListPackConfigurationVersionsAt(r.Context(), db.Timestamp(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[int32]dbsqlc.PackConfigurationVersion, len(versions))
+	for _, version := range versions {
+		active[version.ConfigurationID] = version
+	}
+	for _, configuration := range configurations {
+		if version, ok := active[configuration.ID]; ok {
+			byName[configuration.Name] = version
+		}
+	}
+
+	return byName, nil
+}
+
+// readOrderFile reads the lines of an order file. A first line naming the
+// columns is a header and may order them freely; without one the columns are
+// order_id, sku, amount. Lines that do not parse carry their error, only a
+// file that is not CSV at all fails.
+func readOrderFile(file io.Reader) ([]string, []importLine, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("CSV: %s", err.Error())
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("file: no order lines")
+	}
+
+	header := importColumns
+	index := map[string]int{"order_id": 0, "sku": 1, "amount": 2}
+	if columns, ok := orderFileHeader(records[0]); ok {
+		header, index, records = records[0], columns, records[1:]
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("file: no order lines")
+	}
+
+	lines := make([]importLine, len(records))
+	for i, record := range records {
+		line := &lines[i]
+		line.record = record
+
+		field := func(name string) string {
+			if index[name] < len(record) {
+				return strings.TrimSpace(record[index[name]])
+			}
+			return ""
+		}
+		line.orderID, line.sku = field("order_id"), field("sku")
+
+		amountStr := field("amount")
+		amount, err := strconv.Atoi(amountStr)
+		switch {
+		case len(record) < len(header):
+			line.err = fmt.Sprintf("Invalid line: %d columns, expected %d", len(record), len(header))
+		case line.sku == "":
+			line.err = "Invalid sku: empty"
+		case err != nil:
+			line.err = fmt.Sprintf("Invalid amount: %s", amountStr)
+		}
+		line.req = domain.CalculateRequest{Amount: amount}
+	}
+
+	return header, lines, nil
+}
+
+// orderFileHeader recognizes a header line and returns the column of each field
+func orderFileHeader(record []string) (map[string]int, bool) {
+	columns := make(map[string]int)
+	for i, name := range record {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, false
+		}
+	}
+
+	return columns, true
+}
+
+// saveImport stores the lines as one batch and fills in their calculation IDs,
+// returning the batch ID or 0 when there is no repository or saving failed
+func (h *CalculatorHandler) saveImport(r *http.Request, lines []importLine) int32 {
+	if h.repo == nil {
+		return 0
+	}
+
+	items := make([]db.BatchItem, len(lines))
+	for i, line := range lines {
+		if line.result == nil {
+			items[i].Error = line.err
+			continue
+		}
+		params := db.NewCreateCalculationParams(db.FormatPackSizes(line.req.PackSizes), line.req, line.result)
+		params.ConfigurationID = pgtype.Int4{Int32: line.version.ConfigurationID, Valid: line.version.ID != 0}
+		params.ConfigurationVersionID = pgtype.Int4{Int32: line.version.ID, Valid: line.version.ID != 0}
+		params.OrderReference = line.orderID
+		params.Sku = line.sku
+		items[i].Calculation = &params
+	}
+
+	batch, ids, err := h.repo.SaveBatch(r.Context(), items)
+	if err != nil {
+		log.Printf("failed to save import: %v\n", err)
+		return 0
+	}
+	for i := range lines {
+		lines[i].id = ids[i]
+	}
+
+	return batch.ID
+}
+
+// writeAnnotatedOrders renders the order file with importResultColumns appended to every line
+func writeAnnotatedOrders(header []string, lines []importLine) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	writer.Write(append(append([]string{}, header...), importResultColumns...))
+	for _, line := range lines {
+		record := append([]string{}, line.record...)
+		for len(record) < len(header) {
+			record = append(record, "")
+		}
+
+		annotation := make([]string, len(importResultColumns))
+		if line.id != 0 {
+			annotation[0] = strconv.Itoa(int(line.id))
+		}
+		if line.result != nil {
+			annotation[1] = db.FormatPackSizes(line.req.PackSizes)
+			annotation[2] = formatPackages(line.result.Packages)
+			annotation[3] = strconv.Itoa(line.result.Total)
+			annotation[4] = strconv.Itoa(line.result.Overshoot)
+			annotation[5] = strconv.Itoa(line.result.PackCount)
+		}
+		annotation[6] = line.err
+		writer.Write(append(record, annotation...))
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+// writeImportHTML summarizes an import for HTMX, listing the failed lines and
+// linking the annotated file inline since HTMX cannot download a response
+func writeImportHTML(w http.ResponseWriter, lines []importLine, batchID int32, filename string, annotated []byte) {
+	failed := 0
+	for _, line := range lines {
+		if line.result == nil {
+			failed++
+		}
+	}
+
+	var html strings.Builder
+	html.WriteString("<div class='result-success'>")
+	html.WriteString(fmt.Sprintf("<h3>Imported %d of %d order lines</h3>", len(lines)-failed, len(lines)))
+	if batchID != 0 {
+		html.WriteString(fmt.Sprintf("<p>Saved as batch #%d.</p>", batchID))
+	}
+	html.WriteString(fmt.Sprintf("<p><a download='%s' href='data:text/csv;base64,%s'>Download the annotated order file</a></p>",
+		template.HTMLEscapeString(filename), base64.StdEncoding.EncodeToString(annotated)))
+	if failed > 0 {
+		html.WriteString("<table class='result-table'><tr><th>Line</th><th>Order</th><th>SKU</th><th>Error</th></tr>")
+		for i, line := range lines {
+			if line.result == nil {
+				html.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%s</td><td>%s</td></tr>", i+1,
+					template.HTMLEscapeString(line.orderID), template.HTMLEscapeString(line.sku), template.HTMLEscapeString(line.err)))
+			}
+		}
+		html.WriteString("</table>")
+	}
+	html.WriteString("</div>")
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("HX-Trigger", "calculation-done")
+	w.Write([]byte(html.String()))
+}
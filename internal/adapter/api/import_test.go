@@ -0,0 +1,195 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"ignis/internal/adapter/api"
+	"ignis/internal/service"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// importRequest uploads an order file with the given form fields
+func importRequest(t *testing.T, file string, fields map[string]string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	part, err := writer.CreateFormFile("file", "orders.csv")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write([]byte(file))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func readAnnotated(t *testing.T, data []byte) []map[string]string {
+	t.Helper()
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read annotated CSV: %v", err)
+	}
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(record))
+		for i, name := range records[0] {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestCalculatorHandler_ImportOrders(t *testing.T) {
+	repo := &MockRepository{}
+	if w := serveConfigurations(repo, jsonRequest(http.MethodPost, "/api/v1/configurations", `{"name":"WIDGET","packSizes":[23,31,53]}`)); w.Code != http.StatusCreated {
+		t.Fatalf("failed to create configuration: %d", w.Code)
+	}
+	h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), repo)
+
+	// Columns in any order after a header, failed lines do not stop the rest
+	file := "amount,sku,order_id\n" +
+		"263,WIDGET,A-1\n" +
+		"500,BOLT,A-2\n" +
+		"abc,WIDGET,A-3\n" +
+		"7,WIDGET,A-4\n" +
+		"12\n"
+	w := httptest.NewRecorder()
+	h.ImportOrders(w, importRequest(t, file, map[string]string{"packSizes": "250, 500"}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") || w.Header().Get("X-Batch-ID") != "1" {
+		t.Errorf("expected a CSV download of batch 1, got %v", w.Header())
+	}
+
+	rows := readAnnotated(t, w.Body.Bytes())
+	if len(rows) != 5 {
+		t.Fatalf("expected 5 annotated lines, got %v", rows)
+	}
+	// The SKU names a configuration
+	if got := rows[0]; got["order_id"] != "A-1" || got["pack_sizes"] != "23, 31, 53" || got["packages"] != "31×7, 23×2" ||
+		got["total"] != "263" || got["calculation_id"] != "1" || got["error"] != "" {
+		t.Errorf("unexpected line %v", got)
+	}
+	// The form's pack sizes are the default
+	if got := rows[1]; got["pack_sizes"] != "250, 500" || got["packages"] != "500×1" || got["calculation_id"] != "2" {
+		t.Errorf("unexpected line %v", got)
+	}
+	if got := rows[2]; got["error"] != "Invalid amount: abc" || got["calculation_id"] != "" {
+		t.Errorf("unexpected line %v", got)
+	}
+	if got := rows[3]; !strings.HasPrefix(got["error"], "Calculation error: ") {
+		t.Errorf("expected 7 to be impossible with 23, 31, 53, got %v", got)
+	}
+	if got := rows[4]; got["error"] != "Invalid line: 1 columns, expected 3" {
+		t.Errorf("unexpected line %v", got)
+	}
+
+	// Every line is part of the batch, the calculations know their order line
+	if len(repo.Batches) != 1 || len(repo.Batches[0]) != 5 || len(repo.Calculations) != 2 {
+		t.Fatalf("expected one batch of 5 lines with 2 calculations, got %d batches and %d calculations", len(repo.Batches), len(repo.Calculations))
+	}
+	calc := repo.Calculations[0]
+	if calc.OrderReference != "A-1" || calc.Sku != "WIDGET" || !calc.ConfigurationID.Valid || calc.ConfigurationID.Int32 != 1 {
+		t.Errorf("unexpected stored calculation %+v", calc)
+	}
+	if repo.Calculations[1].ConfigurationID.Valid {
+		t.Errorf("expected no configuration for the default pack sizes, got %+v", repo.Calculations[1].ConfigurationID)
+	}
+}
+
+func TestCalculatorHandler_ImportOrders_NoHeader(t *testing.T) {
+	h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), nil)
+
+	w := httptest.NewRecorder()
+	h.ImportOrders(w, importRequest(t, "A-1,BOLT,750\nA-2,NUT,1\n", map[string]string{"packSizes": "250,500"}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	rows := readAnnotated(t, w.Body.Bytes())
+	if len(rows) != 2 || rows[0]["order_id"] != "A-1" || rows[0]["packages"] != "500×1, 250×1" || rows[1]["error"] == "" {
+		t.Errorf("unexpected lines %v", rows)
+	}
+
+	// Without default pack sizes an unknown SKU cannot be calculated
+	w = httptest.NewRecorder()
+	h.ImportOrders(w, importRequest(t, "A-1,BOLT,750\n", nil))
+	if rows := readAnnotated(t, w.Body.Bytes()); len(rows) != 1 || !strings.HasPrefix(rows[0]["error"], "Invalid sku: BOLT") {
+		t.Errorf("unexpected lines %v", rows)
+	}
+}
+
+func TestCalculatorHandler_ImportOrders_HTMX(t *testing.T) {
+	h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), &MockRepository{})
+
+	req := importRequest(t, "order_id,sku,amount\nA-1,BOLT,750\nA-2,BOLT,x\n", map[string]string{"packSizes": "250,500"})
+	req.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	h.ImportOrders(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("HX-Trigger") != "calculation-done" {
+		t.Fatalf("expected a summary refreshing the history, got %d: %v", w.Code, w.Header())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Imported 1 of 2 order lines") || !strings.Contains(body, "Invalid amount: x") {
+		t.Errorf("unexpected summary %s", body)
+	}
+
+	// The annotated file is linked inline
+	link := regexp.MustCompile(`href='data:text/csv;base64,([^']+)'`).FindStringSubmatch(body)
+	if link == nil {
+		t.Fatalf("expected a download link, got %s", body)
+	}
+	data, err := base64.StdEncoding.DecodeString(link[1])
+	if err != nil {
+		t.Fatalf("failed to decode link: %v", err)
+	}
+	if rows := readAnnotated(t, data); len(rows) != 2 || rows[0]["packages"] != "500×1, 250×1" {
+		t.Errorf("unexpected linked file %v", rows)
+	}
+}
+
+func TestCalculatorHandler_ImportOrders_Invalid(t *testing.T) {
+	h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), nil)
+
+	for name, tc := range map[string]struct {
+		file   string
+		fields map[string]string
+		want   string
+	}{
+		"empty":         {"", nil, "Invalid file: no order lines"},
+		"header only":   {"order_id,sku,amount\n", nil, "Invalid file: no order lines"},
+		"not CSV":       {"a,\"b\n", nil, "Invalid CSV"},
+		"pack size":     {"A-1,BOLT,1\n", map[string]string{"packSizes": "x"}, "Invalid pack size: x"},
+		"configuration": {"A-1,BOLT,1\n", map[string]string{"configurationId": "9"}, "Invalid configuration: 9"},
+	} {
+		w := httptest.NewRecorder()
+		h.ImportOrders(w, importRequest(t, tc.file, tc.fields))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tc.want) {
+			t.Errorf("%s: expected 400 with %q, got %d: %s", name, tc.want, w.Code, w.Body.String())
+		}
+	}
+
+	// The file is required
+	w := httptest.NewRecorder()
+	h.ImportOrders(w, httptest.NewRequest(http.MethodPost, "/api/v1/import", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid file") {
+		t.Errorf("expected 400 without a file, got %d: %s", w.Code, w.Body.String())
+	}
+}
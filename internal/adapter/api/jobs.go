@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/adapter/jobs"
+	"ignis/internal/domain"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jobJSON is a background calculation; Result is set once it succeeded and
+// Error once it failed as a whole
+type jobJSON struct {
+	ID         int32              `json:"id"`
+	Status     string             `json:"status"`
+	Progress   int32              `json:"progress"`
+	Total      int32              `json:"total"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	StartedAt  *time.Time         `json:"startedAt,omitempty"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Result     *batchResponseJSON `json:"result,omitempty"`
+}
+
+// JobHandler queues calculations to run in the background and reports on them
+type JobHandler struct {
+	repo  db.Repository
+	queue *jobs.Pool
+}
+
+// NewJobHandler creates a handler submitting to queue; a nil queue leaves
+// jobs to be found by the workers' polling
+func NewJobHandler(repo db.Repository, queue *jobs.Pool) *JobHandler {
+	return &JobHandler{
+		repo:  repo,
+		queue: queue,
+	}
+}
+
+// NewJobRunner calculates the batch of a job like /api/v1/calculate/batch and
+// stores it the same way, reporting progress after every chunk
+func NewJobRunner(calculator domain.PackageCalculator, repo db.Repository) jobs.Runner {
+	h := NewCalculatorHandler(calculator, repo)
+
+	return func(ctx context.Context, job dbsqlc.CalculationJob, progress func(done int)) ([]byte, error) {
+		var items []batchItemJSON
+		if err := json.Unmarshal(job.Request, &items); err != nil {
+			return nil, fmt.Errorf("Invalid job request: %s", err.Error())
+		}
+
+		resp, err := h.calculateBatch(ctx, items, progress)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil, err
+		case errors.Is(err, errLoadConfiguration), err != nil && errorStatus(err) == http.StatusInternalServerError:
+			log.Printf("job %d failed: %v\n", job.ID, err)
+			return nil, errors.New("Calculation failed, please try again later")
+		case err != nil:
+			return nil, fmt.Errorf("Calculation error: %s", err.Error())
+		}
+
+		return json.Marshal(resp)
+	}
+}
+
+// Submit queues a calculation and answers 202 with the job to poll. It takes
+// the JSON body of /api/v1/calculate/batch, or the calculator form; HTMX
+// clients get a progress fragment that polls the job until it is done.
+func (h *JobHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+
+	var items []batchItemJSON
+	if isJSONBody(r) {
+		var ok bool
+		if items, ok = decodeBatchRequest(w, r); !ok {
+			return
+		}
+	} else {
+		input, err := parseCalculateForm(r)
+		if err != nil {
+			writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+			return
+		}
+		items = []batchItemJSON{{
+			PackSizes:       input.req.PackSizes,
+			ConfigurationID: input.configurationID,
+			Amount:          input.req.Amount,
+			Mode:            input.req.Mode.String(),
+			Objective:       input.req.Objective.String(),
+			Stock:           input.req.Stock,
+			Costs:           input.req.Costs,
+			Constraints:     newConstraintsJSON(input.req.Constraints),
+			Packaging:       newPackagingJSON(input.req.Packaging),
+			Shipping:        newShippingJSON(input.req.Shipping),
+		}}
+	}
+
+	request, err := json.Marshal(items)
+	if err != nil {
+		log.Printf("failed to encode job: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to queue the job")
+		return
+	}
+	job, err := h.repo.CreateJob(r.Context(), dbsqlc.CreateJobParams{Request: request, Total: int32(len(items))})
+	if err != nil {
+		log.Printf("failed to create job: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to queue the job, please try again later")
+		return
+	}
+	if h.queue != nil {
+		h.queue.Notify()
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/jobs/%d", job.ID))
+	if asJSON {
+		writeJSON(w, http.StatusAccepted, newJobJSON(job))
+		return
+	}
+	writeJobHTML(w, http.StatusAccepted, job)
+}
+
+// Get reports the status and progress of a job, and its result once done
+func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil || id <= 0 {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid job ID: %s", r.PathValue("id")))
+		return
+	}
+
+	job, err := h.repo.GetJob(r.Context(), int32(id))
+	switch {
+	case db.IsNotFound(err):
+		writeError(w, asJSON, http.StatusNotFound, fmt.Sprintf("Job %d not found", id))
+		return
+	case err != nil:
+		log.Printf("failed to load job: %v\n", err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to load the job")
+		return
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, newJobJSON(job))
+		return
+	}
+	writeJobHTML(w, http.StatusOK, job)
+}
+
+func newJobJSON(job dbsqlc.CalculationJob) jobJSON {
+	entry := jobJSON{
+		ID:        job.ID,
+		Status:    job.Status,
+		Progress:  job.Progress,
+		Total:     job.Total,
+		CreatedAt: job.CreatedAt.Time,
+		Error:     job.Error,
+	}
+	if job.StartedAt.Valid {
+		entry.StartedAt = &job.StartedAt.Time
+	}
+	if job.FinishedAt.Valid {
+		entry.FinishedAt = &job.FinishedAt.Time
+	}
+	if job.Result != nil {
+		result, err := decodeBatchResponse(job.Result)
+		if err != nil {
+			log.Printf("failed to decode result of job %d: %v\n", job.ID, err)
+		}
+		entry.Result = result
+	}
+
+	return entry
+}
+
+// decodeBatchResponse reads a stored batchResponseJSON. encoding/json cannot
+// set the embedded plan pointer of its items, so plans decode into a plain
+// embedded planJSON that shadows it and are then moved over.
+func decodeBatchResponse(data []byte) (*batchResponseJSON, error) {
+	var stored struct {
+		batchResponseJSON
+		Items []struct {
+			batchItemResultJSON
+			planJSON
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	resp := stored.batchResponseJSON
+	resp.Items = make([]batchItemResultJSON, len(stored.Items))
+	for i, item := range stored.Items {
+		resp.Items[i] = item.batchItemResultJSON
+		if item.Packages != nil {
+			plan := item.planJSON
+			resp.Items[i].planJSON = &plan
+		}
+	}
+
+	return &resp, nil
+}
+
+// writeJobHTML renders a job for HTMX. An unfinished job polls itself every
+// second and is replaced by the next state; a finished one shows its result
+// and refreshes the history.
+func writeJobHTML(w http.ResponseWriter, status int, job dbsqlc.CalculationJob) {
+	entry := newJobJSON(job)
+
+	var html strings.Builder
+	switch {
+	case !db.JobDone(job.Status):
+		html.WriteString(fmt.Sprintf("<div class='job' hx-get='/api/v1/jobs/%d' hx-trigger='every 1s' hx-swap='outerHTML' hx-target='this' hx-target-error='this'>", job.ID))
+		html.WriteString(fmt.Sprintf("<p>Job #%d %s: %d of %d items</p>", job.ID, job.Status, job.Progress, job.Total))
+		html.WriteString(fmt.Sprintf("<progress max='%d' value='%d'></progress>", job.Total, job.Progress))
+		html.WriteString("</div>")
+	case job.Status == db.JobFailed:
+		html.WriteString(fmt.Sprintf("<div class='error'>Job #%d failed: %s</div>", job.ID, template.HTMLEscapeString(job.Error)))
+	case entry.Result != nil && len(entry.Result.Items) == 1:
+		writeJobItemHTML(&html, entry.Result.Items[0])
+	case entry.Result != nil:
+		html.WriteString("<div class='result-success'>")
+		html.WriteString(fmt.Sprintf("<h3>Job #%d done: %d succeeded, %d failed</h3>", job.ID, entry.Result.Succeeded, entry.Result.Failed))
+		if entry.Result.BatchID != 0 {
+			html.WriteString(fmt.Sprintf("<p>Saved as batch #%d.</p>", entry.Result.BatchID))
+		}
+		html.WriteString("</div>")
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if db.JobDone(job.Status) {
+		w.Header().Set("HX-Trigger", "calculation-done")
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(html.String()))
+}
+
+// writeJobItemHTML renders the only item of a job like the calculator renders its result
+func writeJobItemHTML(html *strings.Builder, item batchItemResultJSON) {
+	if item.planJSON == nil {
+		html.WriteString(fmt.Sprintf("<div class='error'>%s</div>", template.HTMLEscapeString(item.Error)))
+		return
+	}
+
+	html.WriteString("<div class='result-success'>")
+	html.WriteString(fmt.Sprintf("<h3>Results for %d items:</h3>", item.Amount))
+	writePlan(html, item.planJSON.result())
+	html.WriteString("</div>")
+}
+
+// result converts a plan back into the calculator's result
+func (p *planJSON) result() *domain.CalculateResult {
+	result := &domain.CalculateResult{
+		Packages:  p.Packages,
+		Total:     p.Total,
+		Overshoot: p.Overshoot,
+		PackCount: p.PackCount,
+		Costs:     p.Costs,
+	}
+	if p.TotalCost != nil {
+		result.TotalCost = *p.TotalCost
+	}
+
+	return result
+}
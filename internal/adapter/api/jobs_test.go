@@ -0,0 +1,156 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"ignis/internal/adapter/api"
+	"ignis/internal/adapter/db"
+	"ignis/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// serveJobs routes requests like the app does, so path values are set
+func serveJobs(repo *MockRepository, req *http.Request) *httptest.ResponseRecorder {
+	h := api.NewJobHandler(repo, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/jobs", h.Submit)
+	mux.HandleFunc("GET /api/v1/jobs/{id}", h.Get)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+// runJob runs a queued job like a worker of the pool and stores its outcome
+func runJob(t *testing.T, repo *MockRepository, id int32) {
+	t.Helper()
+
+	run := api.NewJobRunner(service.NewPackageCalculatorService(), repo)
+	job := &repo.Jobs[id-1]
+	var progress []int
+	result, err := run(context.Background(), *job, func(done int) { progress = append(progress, done) })
+	if err != nil {
+		job.Status, job.Error = db.JobFailed, err.Error()
+		return
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != int(job.Total) {
+		t.Errorf("expected progress up to %d, got %v", job.Total, progress)
+	}
+	job.Status, job.Progress, job.Result = db.JobSucceeded, job.Total, result
+}
+
+func TestJobHandler_Batch(t *testing.T) {
+	repo := &MockRepository{}
+
+	w := serveJobs(repo, jsonRequest(http.MethodPost, "/api/v1/jobs", `{"packSizes":[23,31,53],"amounts":[263,7]}`))
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") != "/api/v1/jobs/1" {
+		t.Fatalf("expected 202 with the job location, got %d: %v %s", w.Code, w.Header(), w.Body.String())
+	}
+	var queued struct {
+		ID     int32  `json:"id"`
+		Status string `json:"status"`
+		Total  int32  `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &queued); err != nil {
+		t.Fatalf("failed to decode job: %v", err)
+	}
+	if queued.ID != 1 || queued.Status != "queued" || queued.Total != 2 {
+		t.Errorf("unexpected job %+v", queued)
+	}
+
+	runJob(t, repo, 1)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/1", nil)
+	req.Header.Set("Accept", "application/json")
+	w = serveJobs(repo, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	var done struct {
+		Status   string `json:"status"`
+		Progress int32  `json:"progress"`
+		Result   struct {
+			BatchID   int32 `json:"batchId"`
+			Succeeded int   `json:"succeeded"`
+			Failed    int   `json:"failed"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &done); err != nil {
+		t.Fatalf("failed to decode job: %v", err)
+	}
+	if done.Status != "succeeded" || done.Progress != 2 || done.Result.Succeeded != 1 || done.Result.Failed != 1 || done.Result.BatchID != 1 {
+		t.Errorf("unexpected job %+v", done)
+	}
+	if len(repo.Batches) != 1 || len(repo.Calculations) != 1 {
+		t.Errorf("expected the batch to be stored like /api/v1/calculate/batch, got %d batches", len(repo.Batches))
+	}
+}
+
+func TestJobHandler_HTMX(t *testing.T) {
+	repo := &MockRepository{}
+
+	// The calculator form queues a single calculation
+	formData := url.Values{}
+	formData.Set("packSizes", "250, 500")
+	formData.Set("amount", "750")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(formData.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	w := serveJobs(repo, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if body := w.Body.String(); !strings.Contains(body, "hx-get='/api/v1/jobs/1'") || !strings.Contains(body, "hx-trigger='every 1s'") {
+		t.Errorf("expected the fragment to poll the job, got %s", body)
+	}
+	if w.Header().Get("HX-Trigger") != "" {
+		t.Errorf("expected no history refresh before the job is done")
+	}
+
+	runJob(t, repo, 1)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/jobs/1", nil)
+	req.Header.Set("HX-Request", "true")
+	w = serveJobs(repo, req)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || strings.Contains(body, "hx-trigger") || !strings.Contains(body, "Results for 750 items") || !strings.Contains(body, "<td>500</td><td>1</td>") {
+		t.Errorf("expected the plan without polling, got %d: %s", w.Code, body)
+	}
+	if w.Header().Get("HX-Trigger") != "calculation-done" {
+		t.Errorf("expected the finished job to refresh the history, got %v", w.Header())
+	}
+}
+
+func TestJobHandler_Invalid(t *testing.T) {
+	repo := &MockRepository{}
+
+	w := serveJobs(repo, jsonRequest(http.MethodPost, "/api/v1/jobs", `{"packSizes":[23]}`))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid batch: no amounts or items") {
+		t.Errorf("expected an empty batch to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader("amount=abc"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if w := serveJobs(repo, req); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid amount: abc") {
+		t.Errorf("expected an invalid form to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.Jobs) != 0 {
+		t.Errorf("expected no job to be queued, got %d", len(repo.Jobs))
+	}
+
+	for target, status := range map[string]int{
+		"/api/v1/jobs/abc": http.StatusBadRequest,
+		"/api/v1/jobs/9":   http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", "application/json")
+		if w := serveJobs(repo, req); w.Code != status {
+			t.Errorf("%s: expected status %d, got %d: %s", target, status, w.Code, w.Body.String())
+		}
+	}
+}
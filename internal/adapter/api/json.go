@@ -0,0 +1,281 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/domain"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxJSONBodyBytes caps the size of a JSON request body
+const maxJSONBodyBytes = 1 << 20
+
+// calculateRequestJSON is the JSON body accepted by /api/v1/calculate
+type calculateRequestJSON struct {
+	PackSizes       []int            `json:"packSizes,omitempty"`       // ignored when a configuration is given
+	ConfigurationID int32            `json:"configurationId,omitempty"` // saved configuration supplying the pack sizes
+	Amount          int              `json:"amount"`
+	Mode            string           `json:"mode,omitempty"`        // "exact" (default) or "overfill"
+	Objective       string           `json:"objective,omitempty"`   // "packs" (default) or "cost"
+	Stock           map[int]int      `json:"stock,omitempty"`       // pack size -> packs in stock, missing sizes are unlimited
+	Costs           map[int]int      `json:"costs,omitempty"`       // pack size -> unit cost in cents
+	Plans           int              `json:"plans,omitempty"`       // ranked plans to return, 1 when omitted
+	Constraints     *constraintsJSON `json:"constraints,omitempty"` // fewest and most packs of each size
+	Packaging       *packagingJSON   `json:"packaging,omitempty"`   // containers to ship the packs in
+	Shipping        *shippingJSON    `json:"shipping,omitempty"`    // weight and volume limits per shipment
+}
+
+// planJSON is a single packing plan in a JSON response
+type planJSON struct {
+	Packages  map[int]int        `json:"packages"`
+	Total     int                `json:"total"`
+	Overshoot int                `json:"overshoot"`
+	PackCount int                `json:"packCount"`
+	Costs     map[int]int        `json:"costs,omitempty"`
+	TotalCost *int               `json:"totalCost,omitempty"`
+	Packaging *packagingPlanJSON `json:"packaging,omitempty"`
+	Shipments []shipmentJSON     `json:"shipments,omitempty"`
+}
+
+// calculateResponseJSON is the JSON answer of /api/v1/calculate; ID is the
+// stored calculation and is omitted when the calculation was not saved
+type calculateResponseJSON struct {
+	ID     int32 `json:"id,omitempty"`
+	Amount int   `json:"amount"`
+	planJSON
+	Alternatives []planJSON `json:"alternatives,omitempty"`
+}
+
+// calculationJSON is a stored calculation in the JSON history
+type calculationJSON struct {
+	ID        int32           `json:"id"`
+	CreatedAt time.Time       `json:"createdAt"`
+	PackSizes []int           `json:"packSizes"`
+	Amount    int64           `json:"amount"`
+	Mode      string          `json:"mode"`
+	Total     int64           `json:"total"`
+	Packages  json.RawMessage `json:"packages"`
+	Stock     json.RawMessage `json:"stock"`
+	Objective string          `json:"objective"`
+	Costs     json.RawMessage `json:"costs"`
+	TotalCost *int64          `json:"totalCost,omitempty"`
+	// ConfigurationID groups calculations by the saved configuration they used,
+	// ConfigurationVersionID names the exact pack sizes of that configuration
+	ConfigurationID        *int32 `json:"configurationId,omitempty"`
+	ConfigurationVersionID *int32 `json:"configurationVersionId,omitempty"`
+	// OrderReference and Sku name the order line of an imported calculation
+	OrderReference string `json:"orderReference,omitempty"`
+	Sku            string `json:"sku,omitempty"`
+	// OrderID is the order the calculation is a line of, if any
+	OrderID *int32 `json:"orderId,omitempty"`
+}
+
+// calculationDetailJSON is a stored calculation with its plan broken down
+type calculationDetailJSON struct {
+	calculationJSON
+	Constraints *constraintsJSON `json:"constraints,omitempty"`
+	Packaging   *packagingJSON   `json:"packaging,omitempty"`
+	Shipping    *shippingJSON    `json:"shipping,omitempty"`
+	Plan        planJSON         `json:"plan"`
+}
+
+// replayJSON compares a stored plan with what the current calculator answers
+// for the same input. Error is set instead of Current when the input no longer
+// calculates, Differences is empty when both plans agree.
+type replayJSON struct {
+	ID          int32                  `json:"id"`
+	Amount      int64                  `json:"amount"`
+	Stored      planJSON               `json:"stored"`
+	Current     *planJSON              `json:"current,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Changed     bool                   `json:"changed"`
+	Differences []replayDifferenceJSON `json:"differences"`
+}
+
+// replayDifferenceJSON is one value that differs between the stored and the
+// current plan: "total", "packCount", "totalCost" or "packages.<size>"
+type replayDifferenceJSON struct {
+	Field   string `json:"field"`
+	Stored  int    `json:"stored"`
+	Current int    `json:"current"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// wantsJSON reports whether the client negotiated a JSON response. HTMX requests
+// always get HTML fragments, other clients get JSON when they accept it or send it.
+func wantsJSON(r *http.Request) bool {
+	if r.Header.Get("HX-Request") != "" {
+		return false
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(accepted)
+		if err == nil && mediaType == "application/json" {
+			return true
+		}
+	}
+
+	return isJSONBody(r)
+}
+
+// isJSONBody reports whether the request body is JSON
+func isJSONBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// decodeCalculateJSON reads a calculate request from a JSON body
+func decodeCalculateJSON(w http.ResponseWriter, r *http.Request) (*calculateInput, error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	var body calculateRequestJSON
+	if err := decoder.Decode(&body); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("JSON body: empty")
+		}
+		return nil, fmt.Errorf("JSON body: %s", err.Error())
+	}
+
+	mode, err := domain.ParseMode(body.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("mode: %s", body.Mode)
+	}
+	objective, err := domain.ParseObjective(body.Objective)
+	if err != nil {
+		return nil, fmt.Errorf("objective: %s", body.Objective)
+	}
+
+	if body.ConfigurationID < 0 {
+		return nil, fmt.Errorf("configuration: %d", body.ConfigurationID)
+	}
+
+	plans := body.Plans
+	if plans == 0 {
+		plans = 1
+	}
+	if plans < 1 || plans > maxAlternatives {
+		return nil, fmt.Errorf("number of plans: %d (1-%d)", body.Plans, maxAlternatives)
+	}
+
+	return &calculateInput{
+		packSizes:       db.FormatPackSizes(body.PackSizes),
+		configurationID: body.ConfigurationID,
+		req: domain.CalculateRequest{
+			PackSizes:   body.PackSizes,
+			Amount:      body.Amount,
+			Mode:        mode,
+			Stock:       body.Stock,
+			Objective:   objective,
+			Costs:       body.Costs,
+			Constraints: body.Constraints.toConstraints(),
+			Packaging:   body.Packaging.toPackaging(),
+			Shipping:    body.Shipping.toLimits(),
+		},
+		plans: plans,
+	}, nil
+}
+
+func newPlanJSON(result *domain.CalculateResult) planJSON {
+	plan := planJSON{
+		Packages:  result.Packages,
+		Total:     result.Total,
+		Overshoot: result.Overshoot,
+		PackCount: result.PackCount,
+		Costs:     result.Costs,
+		Packaging: newPackagingPlanJSON(result.Packaging),
+		Shipments: newShipmentsJSON(result.Shipments),
+	}
+	if result.Costs != nil {
+		totalCost := result.TotalCost
+		plan.TotalCost = &totalCost
+	}
+
+	return plan
+}
+
+func newCalculateResponse(id int32, amount int, plans []*domain.CalculateResult) calculateResponseJSON {
+	resp := calculateResponseJSON{
+		ID:       id,
+		Amount:   amount,
+		planJSON: newPlanJSON(plans[0]),
+	}
+	for _, plan := range plans[1:] {
+		resp.Alternatives = append(resp.Alternatives, newPlanJSON(plan))
+	}
+
+	return resp
+}
+
+func newHistoryResponse(calculations []dbsqlc.Calculation) []calculationJSON {
+	history := make([]calculationJSON, 0, len(calculations))
+	for _, calc := range calculations {
+		history = append(history, newCalculationJSON(calc))
+	}
+
+	return history
+}
+
+func newCalculationJSON(calc dbsqlc.Calculation) calculationJSON {
+	entry := calculationJSON{
+		ID:        calc.ID,
+		CreatedAt: calc.CreatedAt.Time,
+		PackSizes: db.ParsePackSizes(calc.PackSizes),
+		Amount:    calc.TargetAmount,
+		Mode:      calc.Mode,
+		Total:     calc.TotalItems,
+		Packages:  rawJSONOrEmpty(calc.ResultJson),
+		Stock:     rawJSONOrEmpty(calc.Stock),
+		Objective: calc.Objective,
+		Costs:     rawJSONOrEmpty(calc.Costs),
+
+		OrderReference: calc.OrderReference,
+		Sku:            calc.Sku,
+	}
+	if calc.TotalCost.Valid {
+		totalCost := calc.TotalCost.Int64
+		entry.TotalCost = &totalCost
+	}
+	if calc.ConfigurationID.Valid {
+		configurationID := calc.ConfigurationID.Int32
+		entry.ConfigurationID = &configurationID
+	}
+	if calc.ConfigurationVersionID.Valid {
+		configurationVersionID := calc.ConfigurationVersionID.Int32
+		entry.ConfigurationVersionID = &configurationVersionID
+	}
+	if calc.OrderID.Valid {
+		orderID := calc.OrderID.Int32
+		entry.OrderID = &orderID
+	}
+
+	return entry
+}
+
+// rawJSONOrEmpty passes stored JSON through, rows predating a column have none
+func rawJSONOrEmpty(data []byte) json.RawMessage {
+	if len(data) == 0 || !json.Valid(data) {
+		return json.RawMessage("{}")
+	}
+
+	return data
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write JSON response: %v\n", err)
+	}
+}
@@ -0,0 +1,768 @@
+package api
+
+import (
+	"encoding/json"
+	"ignis/internal/adapter/db"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaNames lists the JSON types published under components/schemas
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeOf(calculateRequestJSON{}):     "CalculateRequest",
+	reflect.TypeOf(calculateResponseJSON{}):    "CalculateResponse",
+	reflect.TypeOf(planJSON{}):                 "Plan",
+	reflect.TypeOf(calculationJSON{}):          "Calculation",
+	reflect.TypeOf(errorResponse{}):            "Error",
+	reflect.TypeOf(batchRequestJSON{}):         "BatchRequest",
+	reflect.TypeOf(batchItemJSON{}):            "BatchItem",
+	reflect.TypeOf(batchResponseJSON{}):        "BatchResponse",
+	reflect.TypeOf(batchItemResultJSON{}):      "BatchItemResult",
+	reflect.TypeOf(configurationJSON{}):        "Configuration",
+	reflect.TypeOf(configurationRequestJSON{}): "ConfigurationRequest",
+	reflect.TypeOf(configurationVersionJSON{}): "ConfigurationVersion",
+	reflect.TypeOf(calculationDetailJSON{}):    "CalculationDetail",
+	reflect.TypeOf(replayJSON{}):               "Replay",
+	reflect.TypeOf(replayDifferenceJSON{}):     "ReplayDifference",
+	reflect.TypeOf(jobJSON{}):                  "Job",
+	reflect.TypeOf(cacheStatsJSON{}):           "CacheStats",
+	reflect.TypeOf(constraintsJSON{}):          "PackConstraints",
+	reflect.TypeOf(feasibilityJSON{}):          "Feasibility",
+	reflect.TypeOf(nearestAmountsJSON{}):       "NearestAmounts",
+	reflect.TypeOf(packagingJSON{}):            "Packaging",
+	reflect.TypeOf(outerContainerJSON{}):       "OuterContainer",
+	reflect.TypeOf(packagingPlanJSON{}):        "PackagingPlan",
+	reflect.TypeOf(levelCountJSON{}):           "LevelCount",
+	reflect.TypeOf(containerGroupJSON{}):       "ContainerGroup",
+	reflect.TypeOf(shippingJSON{}):             "Shipping",
+	reflect.TypeOf(shipmentJSON{}):             "Shipment",
+	reflect.TypeOf(orderRequestJSON{}):         "OrderRequest",
+	reflect.TypeOf(orderLineJSON{}):            "OrderLine",
+	reflect.TypeOf(orderResponseJSON{}):        "OrderResponse",
+	reflect.TypeOf(orderLineResultJSON{}):      "OrderLineResult",
+	reflect.TypeOf(orderTotalsJSON{}):          "OrderTotals",
+	reflect.TypeOf(storedOrderJSON{}):          "StoredOrder",
+	reflect.TypeOf(orderErrorJSON{}):           "OrderError",
+}
+
+// OpenAPISpec returns the OpenAPI 3 document of the HTTP API. Schemas are
+// generated from the JSON request and response types, so they follow the
+// handlers whenever a field changes.
+func OpenAPISpec() ([]byte, error) {
+	schemas := make(map[string]any, len(schemaNames))
+	for t, name := range schemaNames {
+		schemas[name] = structSchema(t)
+	}
+
+	errorResponses := func(statuses map[string]string) map[string]any {
+		responses := make(map[string]any, len(statuses))
+		for status, description := range statuses {
+			responses[status] = map[string]any{
+				"description": description,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemaRef("Error")},
+					"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+				},
+			}
+		}
+		return responses
+	}
+
+	calculateResponses := errorResponses(map[string]string{
+		"400": "Invalid input",
+		"413": "Too many pack sizes",
+		"422": "No combination possible (naming the stock or pack constraints in the way), a pack exceeds the shipment limits, or the amount or memory estimate exceeds a limit",
+		"503": "Calculation cancelled by shutdown",
+		"500": "Calculation failed",
+	})
+	calculateResponses["200"] = map[string]any{
+		"description": "The best plan, followed by ranked alternatives when more than one plan was requested",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("CalculateResponse")},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	batchResponses := errorResponses(map[string]string{
+		"400": "Invalid JSON body or an empty batch",
+		"413": "Too many items or too large a body",
+		"415": "The body is not application/json",
+		"503": "Calculation cancelled by shutdown",
+		"500": "Calculation failed",
+	})
+	batchResponses["200"] = map[string]any{
+		"description": "One result per item, failed items carry their error and status",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("BatchResponse")},
+		},
+	}
+
+	submitJobResponses := errorResponses(map[string]string{
+		"400": "Invalid JSON body, form or an empty batch",
+		"413": "Too many items or too large a body",
+		"500": "Failed to queue the job",
+	})
+	submitJobResponses["202"] = map[string]any{
+		"description": "The queued job; HTMX clients get a fragment polling it until it is done",
+		"headers": map[string]any{
+			"Location": map[string]any{
+				"description": "URL of the job",
+				"schema":      map[string]any{"type": "string"},
+			},
+		},
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("Job")},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	jobResponses := errorResponses(map[string]string{
+		"400": "Invalid ID",
+		"404": "No job with this ID",
+		"500": "Failed to load the job",
+	})
+	jobResponses["200"] = map[string]any{
+		"description": "Status and progress of the job, with the batch result once it succeeded",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("Job")},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	cacheStatsResponses := map[string]any{
+		"200": map[string]any{
+			"description": "Hits and misses of the result cache since the server started",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": schemaRef("CacheStats")},
+			},
+		},
+		"404": map[string]any{
+			"description": "The result cache is disabled",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": schemaRef("Error")},
+			},
+		},
+	}
+
+	orderResponses := errorResponses(map[string]string{
+		"400": "Invalid JSON body or form, or an order without lines",
+		"413": "Too many lines or too large a body",
+		"503": "Calculation cancelled by shutdown",
+		"500": "Calculation failed",
+	})
+	orderResponses["200"] = map[string]any{
+		"description": "One result per line, failed lines carry their error, and the totals of the packed lines",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("OrderResponse")},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	feasibilityResponses := errorResponses(map[string]string{
+		"400": "Invalid pack sizes or amount",
+		"413": "Too many pack sizes",
+		"422": "The largest pack size or the amount exceeds a limit",
+		"503": "Analysis cancelled by shutdown",
+		"500": "Analysis failed",
+	})
+	feasibilityResponses["200"] = map[string]any{
+		"description": "Which amounts the pack sizes pack exactly, and the nearest packable amounts when an amount was given",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("Feasibility")},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	storedOrderResponses := errorResponses(map[string]string{
+		"400": "Invalid ID",
+		"404": "No order with this ID",
+		"500": "Failed to load the order",
+	})
+	storedOrderResponses["200"] = map[string]any{
+		"description": "The stored order with the calculations of its packed lines",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("StoredOrder")},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	importResponses := errorResponses(map[string]string{
+		"400": "No file, a file that is not CSV or has no lines, or invalid default pack sizes or configuration",
+		"413": "Too many lines or too large a file",
+		"503": "Calculation cancelled by shutdown",
+		"500": "Calculation failed",
+	})
+	importResponses["200"] = map[string]any{
+		"description": "The order file with calculation_id, pack_sizes, packages, total, overshoot, pack_count and error appended to each line; HTMX clients get a summary linking it",
+		"headers": map[string]any{
+			"X-Batch-ID": map[string]any{
+				"description": "ID of the batch the lines were stored as",
+				"schema":      map[string]any{"type": "integer"},
+			},
+		},
+		"content": map[string]any{
+			"text/csv":  map[string]any{"schema": map[string]any{"type": "string"}},
+			"text/html": map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	historyResponses := errorResponses(map[string]string{
+		"400": "Invalid cursor, limit or filter",
+		"500": "Failed to load history",
+	})
+	historyResponses["200"] = map[string]any{
+		"description": "A page of stored calculations, newest first; HTMX clients get table rows and a \"Load more\" row",
+		"headers": map[string]any{
+			"Link": map[string]any{
+				"description": "URL of the next page as rel=\"next\", absent on the last page",
+				"schema":      map[string]any{"type": "string"},
+			},
+		},
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"type": "array", "items": schemaRef("Calculation")}},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	calculationResponses := errorResponses(map[string]string{
+		"400": "Invalid ID",
+		"404": "No calculation with this ID, or it was deleted",
+		"500": "Failed to load the calculation",
+	})
+	calculationResponses["200"] = map[string]any{
+		"description": "The calculation with its plan broken down",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("CalculationDetail")},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	replayResponses := errorResponses(map[string]string{
+		"400": "Invalid ID",
+		"404": "No calculation with this ID, or it was deleted",
+		"503": "Replay cancelled by shutdown",
+		"500": "Replay failed",
+	})
+	replayResponses["200"] = map[string]any{
+		"description": "The stored and the current plan with their differences; an input that no longer calculates has an error",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemaRef("Replay")},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	exportResponses := errorResponses(map[string]string{
+		"400": "Invalid format or filter",
+		"422": "Too many rows for an XLSX sheet",
+		"500": "Failed to export history",
+	})
+	exportResponses["200"] = map[string]any{
+		"description": "Every matching calculation, newest first, as a download; CSV and XLSX have one packs_<size> column per pack size",
+		"content": map[string]any{
+			"text/csv":             map[string]any{"schema": map[string]any{"type": "string"}},
+			"application/x-ndjson": map[string]any{"schema": schemaRef("Calculation")},
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+		},
+	}
+	exportParameters := append([]any{
+		queryParameter("format", "File format", map[string]any{"type": "string", "enum": []string{"csv", "ndjson", "xlsx"}, "default": "csv"}),
+	}, historyFilterParameters()...)
+
+	deleteCalculationResponses := errorResponses(map[string]string{
+		"400": "Invalid ID",
+		"404": "No calculation with this ID, or it was already deleted",
+		"500": "Failed to delete the calculation",
+	})
+	deleteCalculationResponses["200"] = map[string]any{"description": "Deleted, an empty body for HTMX clients to swap the row away"}
+	deleteCalculationResponses["204"] = map[string]any{"description": "Deleted; the calculation leaves the history but is kept"}
+
+	configurationContent := map[string]any{
+		"application/json": map[string]any{"schema": schemaRef("Configuration")},
+	}
+	configurationBody := map[string]any{
+		"required": true,
+		"content": map[string]any{
+			"application/json":                  map[string]any{"schema": schemaRef("ConfigurationRequest")},
+			"application/x-www-form-urlencoded": map[string]any{"schema": configurationFormSchema()},
+		},
+	}
+	idParameter := map[string]any{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]any{"type": "integer"},
+	}
+	atParameter := map[string]any{
+		"name":        "at",
+		"in":          "query",
+		"description": "Point in time as RFC 3339 or a date, now when omitted",
+		"schema":      map[string]any{"type": "string", "example": "2026-03-01"},
+	}
+
+	listConfigurationResponses := errorResponses(map[string]string{
+		"400": "Invalid date",
+		"500": "Failed to load configurations",
+	})
+	listConfigurationResponses["200"] = map[string]any{
+		"description": "Configurations with a version valid at the date, by name; HTMX clients get <option> elements",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"type": "array", "items": schemaRef("Configuration")}},
+			"text/html":        map[string]any{"schema": map[string]any{"type": "string"}},
+		},
+	}
+
+	createConfigurationResponses := errorResponses(map[string]string{
+		"400": "Invalid name or pack sizes",
+		"409": "The name is already taken",
+		"500": "Failed to save the configuration",
+	})
+	createConfigurationResponses["201"] = map[string]any{"description": "The saved configuration", "content": configurationContent}
+
+	getConfigurationResponses := errorResponses(map[string]string{
+		"400": "Invalid ID or date",
+		"404": "No configuration with this ID, or no version valid at the date",
+		"500": "Failed to load the configuration",
+	})
+	getConfigurationResponses["200"] = map[string]any{"description": "The configuration as of the date", "content": configurationContent}
+
+	versionsResponses := errorResponses(map[string]string{
+		"400": "Invalid ID",
+		"404": "No configuration with this ID",
+		"500": "Failed to load the versions",
+	})
+	versionsResponses["200"] = map[string]any{
+		"description": "Every version of the configuration, oldest first",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": map[string]any{"type": "array", "items": schemaRef("ConfigurationVersion")}},
+		},
+	}
+
+	updateConfigurationResponses := errorResponses(map[string]string{
+		"400": "Invalid ID, name or pack sizes",
+		"404": "No configuration with this ID",
+		"409": "The name is already taken, or changed pack sizes start before the latest version",
+		"500": "Failed to save the configuration",
+	})
+	updateConfigurationResponses["200"] = map[string]any{"description": "The updated configuration with its latest version", "content": configurationContent}
+
+	deleteConfigurationResponses := errorResponses(map[string]string{
+		"400": "Invalid ID",
+		"404": "No configuration with this ID",
+		"500": "Failed to delete the configuration",
+	})
+	deleteConfigurationResponses["204"] = map[string]any{"description": "Deleted with its versions; calculations that used it keep their pack sizes"}
+
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Package Calculator API",
+			"version":     "1.0.0",
+			"description": "Requests with the HX-Request header get HTML fragments, other clients get JSON when they send or accept application/json.",
+		},
+		"servers": []any{map[string]any{"url": "/"}},
+		"paths": map[string]any{
+			"/": map[string]any{
+				"get": map[string]any{
+					"operationId": "index",
+					"summary":     "Calculator page",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The HTMX calculator page",
+							"content": map[string]any{
+								"text/html": map[string]any{"schema": map[string]any{"type": "string"}},
+							},
+						},
+					},
+				},
+			},
+			"/api/v1/calculate": map[string]any{
+				"post": map[string]any{
+					"operationId": "calculate",
+					"summary":     "Calculate the packs to ship for an amount",
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"application/json":                  map[string]any{"schema": schemaRef("CalculateRequest")},
+							"application/x-www-form-urlencoded": map[string]any{"schema": calculateFormSchema()},
+						},
+					},
+					"responses": calculateResponses,
+				},
+			},
+			"/api/v1/calculate/batch": map[string]any{
+				"post": map[string]any{
+					"operationId": "calculateBatch",
+					"summary":     "Calculate many amounts, sharing work between items with the same pack sizes",
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": schemaRef("BatchRequest")},
+						},
+					},
+					"responses": batchResponses,
+				},
+			},
+			"/api/v1/jobs": map[string]any{
+				"post": map[string]any{
+					"operationId": "submitJob",
+					"summary":     "Queue a calculation or batch to run in the background",
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"application/json":                  map[string]any{"schema": schemaRef("BatchRequest")},
+							"application/x-www-form-urlencoded": map[string]any{"schema": calculateFormSchema()},
+						},
+					},
+					"responses": submitJobResponses,
+				},
+			},
+			"/api/v1/jobs/{id}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getJob",
+					"parameters":  []any{idParameter},
+					"summary":     "Poll a background job",
+					"responses":   jobResponses,
+				},
+			},
+			"/api/v1/feasibility": map[string]any{
+				"get": map[string]any{
+					"operationId": "analyzeFeasibility",
+					"summary":     "Report the gcd, Frobenius number and unreachable amounts of a pack-size set",
+					"parameters": []any{
+						queryParameter("packSizes", "Comma-separated pack sizes", map[string]any{"type": "string", "example": "23, 31, 53"}),
+						queryParameter("amount", "Amount to find the nearest packable amounts for", map[string]any{"type": "integer", "minimum": 1}),
+					},
+					"responses": feasibilityResponses,
+				},
+			},
+			"/api/v1/cache/stats": map[string]any{
+				"get": map[string]any{
+					"operationId": "getCacheStats",
+					"summary":     "Report on the result cache of the calculator",
+					"responses":   cacheStatsResponses,
+				},
+			},
+			"/api/v1/orders": map[string]any{
+				"post": map[string]any{
+					"operationId": "createOrder",
+					"summary":     "Pack every line of a multi-SKU order and store it as one order",
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"application/json":                  map[string]any{"schema": schemaRef("OrderRequest")},
+							"application/x-www-form-urlencoded": map[string]any{"schema": orderFormSchema()},
+						},
+					},
+					"responses": orderResponses,
+				},
+			},
+			"/api/v1/orders/{id}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOrder",
+					"parameters":  []any{idParameter},
+					"summary":     "Get a stored order with the plans of its lines",
+					"responses":   storedOrderResponses,
+				},
+			},
+			"/api/v1/import": map[string]any{
+				"post": map[string]any{
+					"operationId": "importOrders",
+					"summary":     "Calculate every line of a CSV order file and return it annotated",
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{
+							"multipart/form-data": map[string]any{"schema": importFormSchema()},
+						},
+					},
+					"responses": importResponses,
+				},
+			},
+			"/api/v1/history": map[string]any{
+				"get": map[string]any{
+					"operationId": "listHistory",
+					"summary":     "List stored calculations, one page at a time",
+					"parameters":  historyParameters(),
+					"responses":   historyResponses,
+				},
+			},
+			"/api/v1/history/export": map[string]any{
+				"get": map[string]any{
+					"operationId": "exportHistory",
+					"parameters":  exportParameters,
+					"summary":     "Download the filtered history as CSV, NDJSON or XLSX",
+					"responses":   exportResponses,
+				},
+			},
+			"/api/v1/history/{id}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getCalculation",
+					"parameters":  []any{idParameter},
+					"summary":     "Get a stored calculation with its full plan",
+					"responses":   calculationResponses,
+				},
+				"delete": map[string]any{
+					"operationId": "deleteCalculation",
+					"parameters":  []any{idParameter},
+					"summary":     "Remove a calculation from the history",
+					"responses":   deleteCalculationResponses,
+				},
+			},
+			"/api/v1/history/{id}/replay": map[string]any{
+				"post": map[string]any{
+					"operationId": "replayCalculation",
+					"parameters":  []any{idParameter},
+					"summary":     "Run the stored input through the current calculator and compare the plans",
+					"responses":   replayResponses,
+				},
+			},
+			"/api/v1/configurations": map[string]any{
+				"get": map[string]any{
+					"operationId": "listConfigurations",
+					"summary":     "List saved pack-size configurations",
+					"parameters":  []any{atParameter},
+					"responses":   listConfigurationResponses,
+				},
+				"post": map[string]any{
+					"operationId": "createConfiguration",
+					"summary":     "Save a named pack-size configuration",
+					"requestBody": configurationBody,
+					"responses":   createConfigurationResponses,
+				},
+			},
+			"/api/v1/configurations/{id}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getConfiguration",
+					"parameters":  []any{idParameter, atParameter},
+					"summary":     "Get a configuration as it was at a date",
+					"responses":   getConfigurationResponses,
+				},
+				"put": map[string]any{
+					"operationId": "updateConfiguration",
+					"parameters":  []any{idParameter},
+					"summary":     "Rename a configuration; changed pack sizes become a new version from validFrom on",
+					"requestBody": configurationBody,
+					"responses":   updateConfigurationResponses,
+				},
+				"delete": map[string]any{
+					"operationId": "deleteConfiguration",
+					"parameters":  []any{idParameter},
+					"summary":     "Delete a configuration",
+					"responses":   deleteConfigurationResponses,
+				},
+			},
+			"/api/v1/configurations/{id}/versions": map[string]any{
+				"get": map[string]any{
+					"operationId": "listConfigurationVersions",
+					"parameters":  []any{idParameter},
+					"summary":     "List the versions of a configuration",
+					"responses":   versionsResponses,
+				},
+			},
+			"/healthz": map[string]any{
+				"get": map[string]any{
+					"operationId": "health",
+					"summary":     "Liveness probe",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The server is up",
+							"content": map[string]any{
+								"text/plain": map[string]any{"schema": map[string]any{"type": "string", "example": "ok"}},
+							},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]any{"schemas": schemas},
+	}
+
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// calculateFormSchema describes the fields posted by the HTMX form
+func calculateFormSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []string{"amount"},
+		"properties": map[string]any{
+			"packSizes":       map[string]any{"type": "string", "example": "23, 31, 53"},
+			"configurationId": map[string]any{"type": "string", "example": "1", "description": "Saved configuration, its pack sizes replace packSizes"},
+			"amount":          map[string]any{"type": "string", "example": "500000"},
+			"stock":           map[string]any{"type": "string", "example": "53:100, 31:unlimited"},
+			"costs":           map[string]any{"type": "string", "example": "53:4.99, 31:2.50"},
+			"objective":       map[string]any{"type": "string", "enum": []string{"packs", "cost"}},
+			"mode":            map[string]any{"type": "string", "enum": []string{"exact", "overfill"}},
+			"plans":           map[string]any{"type": "string", "example": "3"},
+			"minPacks":        map[string]any{"type": "string", "example": "53:1", "description": "Fewest packs of each size"},
+			"maxPacks":        map[string]any{"type": "string", "example": "23:10", "description": "Most packs of each size"},
+			"requiredSizes":   map[string]any{"type": "string", "example": "31", "description": "Comma-separated sizes every plan uses at least once"},
+			"forbiddenSizes":  map[string]any{"type": "string", "example": "23", "description": "Comma-separated sizes no plan uses"},
+			"cartonCapacity":  map[string]any{"type": "string", "example": "53:12, 31:20, 23:20", "description": "Packs of each size filling a carton, sizes may share one"},
+			"palletCapacity":  map[string]any{"type": "string", "example": "40", "description": "Cartons per pallet, needs cartonCapacity"},
+			"weights":         map[string]any{"type": "string", "example": "53:1200, 31:700, 23:500", "description": "Weight of one pack of each size"},
+			"volumes":         map[string]any{"type": "string", "example": "53:3000, 31:1800, 23:1300", "description": "Volume of one pack of each size"},
+			"maxWeight":       map[string]any{"type": "string", "example": "20000", "description": "Most weight per shipment, in the unit of weights"},
+			"maxVolume":       map[string]any{"type": "string", "example": "60000", "description": "Most volume per shipment, in the unit of volumes"},
+		},
+	}
+}
+
+// orderFormSchema describes the order form, one value of each line field per row
+func orderFormSchema() map[string]any {
+	lineField := func(example string) map[string]any {
+		return map[string]any{"type": "array", "items": map[string]any{"type": "string", "example": example}}
+	}
+
+	return map[string]any{
+		"type":     "object",
+		"required": []string{"lineAmount"},
+		"properties": map[string]any{
+			"reference":           map[string]any{"type": "string", "example": "PO-1001"},
+			"mode":                map[string]any{"type": "string", "enum": []string{"exact", "overfill"}},
+			"objective":           map[string]any{"type": "string", "enum": []string{"packs", "cost"}},
+			"sku":                 lineField("WIDGET-1"),
+			"linePackSizes":       lineField("23, 31, 53"),
+			"lineConfigurationId": lineField("1"),
+			"lineAmount":          lineField("500"),
+		},
+	}
+}
+
+func importFormSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []string{"file"},
+		"properties": map[string]any{
+			"file": map[string]any{
+				"type":        "string",
+				"format":      "binary",
+				"description": "CSV with order_id, sku and amount columns, in that order unless a header line names them",
+			},
+			"packSizes":       map[string]any{"type": "string", "example": "23, 31, 53", "description": "Pack sizes of lines whose SKU names no configuration"},
+			"configurationId": map[string]any{"type": "string", "example": "1", "description": "Saved configuration for lines whose SKU names none, it replaces packSizes"},
+		},
+	}
+}
+
+// historyParameters are the page and filter query parameters of the history
+func historyParameters() []any {
+	return append([]any{
+		queryParameter("cursor", "Continues after the previous page, taken from the Link header", map[string]any{"type": "string"}),
+		queryParameter("limit", "Page size", map[string]any{"type": "integer", "minimum": 1, "maximum": db.MaxHistoryPageSize, "default": db.DefaultHistoryPageSize}),
+	}, historyFilterParameters()...)
+}
+
+// historyFilterParameters are the filters shared by the history and its export
+func historyFilterParameters() []any {
+	amount := map[string]any{"type": "integer", "minimum": 1}
+
+	return []any{
+		queryParameter("minAmount", "Smallest amount, inclusive", amount),
+		queryParameter("maxAmount", "Largest amount, inclusive", amount),
+		queryParameter("packSizes", "Comma-separated pack sizes, matched as a set in any order", map[string]any{"type": "string", "example": "23, 31, 53"}),
+		queryParameter("from", "Created at or after, as RFC 3339 or a date", map[string]any{"type": "string", "example": "2026-03-01"}),
+		queryParameter("to", "Created before, as RFC 3339; a date includes the whole day", map[string]any{"type": "string", "example": "2026-03-31"}),
+		queryParameter("configurationId", "Only calculations that used this configuration", map[string]any{"type": "integer", "minimum": 1}),
+	}
+}
+
+func queryParameter(name, description string, schema map[string]any) map[string]any {
+	return map[string]any{"name": name, "in": "query", "description": description, "schema": schema}
+}
+
+// configurationFormSchema describes the fields posted by the save form
+func configurationFormSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []string{"name", "packSizes"},
+		"properties": map[string]any{
+			"name":        map[string]any{"type": "string", "example": "Widgets"},
+			"packSizes":   map[string]any{"type": "string", "example": "23, 31, 53"},
+			"description": map[string]any{"type": "string"},
+			"validFrom":   map[string]any{"type": "string", "example": "2026-06-01", "description": "When changed pack sizes take effect, now when empty"},
+		},
+	}
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// structSchema describes a struct from its json tags; embedded structs are
+// flattened like encoding/json does and fields without omitempty are required,
+// unless they come from an embedded pointer that may be nil
+func structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+
+	var collect func(t reflect.Type, optional bool)
+	collect = func(t reflect.Type, optional bool) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if field.Anonymous && tag == "" {
+				if field.Type.Kind() == reflect.Pointer {
+					collect(field.Type.Elem(), true)
+				} else {
+					collect(field.Type, optional)
+				}
+				continue
+			}
+
+			name, options, _ := strings.Cut(tag, ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			properties[name] = typeSchema(field.Type)
+			if !optional && !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	collect(t, false)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	if name, ok := schemaNames[t]; ok {
+		return schemaRef(name)
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		// Stored JSON columns are maps of pack size to a count or cost
+		return map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "integer"}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		// JSON object keys are strings, pack sizes are sent as "53"
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return map[string]any{}
+	}
+}
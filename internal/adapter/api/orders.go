@@ -0,0 +1,423 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"ignis/internal/adapter/db"
+	"ignis/internal/domain"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxOrderLines caps the number of lines of a single order
+const maxOrderLines = 1000
+
+// orderLineJSON is one product of an order: its SKU and the item to pack,
+// with pack sizes or the configuration of the product
+type orderLineJSON struct {
+	Sku string `json:"sku,omitempty"`
+	batchItemJSON
+}
+
+// orderRequestJSON is the JSON body accepted by /api/v1/orders. Mode and
+// objective apply to the lines that set none of their own.
+type orderRequestJSON struct {
+	Reference string          `json:"reference,omitempty"`
+	Mode      string          `json:"mode,omitempty"`
+	Objective string          `json:"objective,omitempty"`
+	Lines     []orderLineJSON `json:"lines"`
+}
+
+// orderLineResultJSON is the plan of an order line, or its error
+type orderLineResultJSON struct {
+	Sku string `json:"sku,omitempty"`
+	batchItemResultJSON
+}
+
+// orderTotalsJSON sums the lines of an order that could be packed; TotalCost
+// is set when all of them have unit costs
+type orderTotalsJSON struct {
+	Lines     int  `json:"lines"`
+	Failed    int  `json:"failed"`
+	Amount    int  `json:"amount"`
+	Total     int  `json:"total"`
+	Overshoot int  `json:"overshoot"`
+	PackCount int  `json:"packCount"`
+	TotalCost *int `json:"totalCost,omitempty"`
+}
+
+// orderResponseJSON is the JSON answer of POST /api/v1/orders; ID is omitted
+// when the order was not saved
+type orderResponseJSON struct {
+	ID        int32                 `json:"id,omitempty"`
+	Reference string                `json:"reference,omitempty"`
+	Totals    orderTotalsJSON       `json:"totals"`
+	Lines     []orderLineResultJSON `json:"lines"`
+}
+
+// storedOrderJSON is an order from the history: the calculations of its
+// packed lines and the errors of the others, by line index
+type storedOrderJSON struct {
+	ID        int32             `json:"id"`
+	Reference string            `json:"reference,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Totals    orderTotalsJSON   `json:"totals"`
+	Lines     []calculationJSON `json:"lines"`
+	Errors    []orderErrorJSON  `json:"errors"`
+}
+
+// orderErrorJSON is a line of a stored order that could not be packed
+type orderErrorJSON struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// orderInput is a parsed order, from either the order form or a JSON body
+type orderInput struct {
+	reference string
+	skus      []string
+	items     []batchItemJSON
+}
+
+// CreateOrder packs the lines of an order, each product with its own pack
+// sizes, and stores the order with one calculation per packed line. Lines
+// that fail carry their error, the order totals sum the others.
+func (h *CalculatorHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+
+	var input *orderInput
+	var err error
+	if isJSONBody(r) {
+		input, err = decodeOrderJSON(w, r)
+	} else {
+		input, err = parseOrderForm(r)
+	}
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeError(w, asJSON, http.StatusRequestEntityTooLarge, fmt.Sprintf("Order body exceeds %d bytes", maxJSONBodyBytes))
+		return
+	case err != nil:
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid %s", err.Error()))
+		return
+	}
+	if len(input.items) == 0 {
+		writeError(w, asJSON, http.StatusBadRequest, "Invalid order: no lines")
+		return
+	}
+	if len(input.items) > maxOrderLines {
+		writeError(w, asJSON, http.StatusRequestEntityTooLarge, fmt.Sprintf("Order of %d lines exceeds the limit of %d", len(input.items), maxOrderLines))
+		return
+	}
+
+	run, err := h.runBatch(r.Context(), input.items, nil)
+	if err != nil {
+		if !asJSON {
+			writeErrorFragment(w, errorStatus(err), fmt.Sprintf("Calculation error: %s", err.Error()))
+			return
+		}
+		writeBatchError(w, err)
+		return
+	}
+
+	lines := make([]domain.OrderLine, len(input.items))
+	for i := range lines {
+		lines[i] = domain.OrderLine{Sku: input.skus[i], Request: run.reqs[i]}
+	}
+	plans := run.plans()
+	totals := domain.SumOrder(lines, plans)
+
+	resp := orderResponseJSON{
+		Reference: input.reference,
+		Totals:    newOrderTotalsJSON(totals),
+		Lines:     make([]orderLineResultJSON, len(lines)),
+	}
+	resp.ID = h.saveOrder(r.Context(), input, run, totals)
+	for i, item := range run.resp.Items {
+		resp.Lines[i] = orderLineResultJSON{Sku: input.skus[i], batchItemResultJSON: item}
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	var html strings.Builder
+	html.WriteString("<div class='result-success'>")
+	if resp.ID != 0 {
+		html.WriteString(fmt.Sprintf("<h3>Order #%d</h3>", resp.ID))
+	} else {
+		html.WriteString("<h3>Order</h3>")
+	}
+	writeOrderHTML(&html, input.reference, totals, lines, plans, run.resp.Items)
+	html.WriteString("</div>")
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("HX-Trigger", "calculation-done")
+	w.Write([]byte(html.String()))
+}
+
+// GetOrder shows a stored order with the plans of its lines
+func (h *CalculatorHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	asJSON := wantsJSON(r)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil || id <= 0 {
+		writeError(w, asJSON, http.StatusBadRequest, fmt.Sprintf("Invalid order ID: %s", r.PathValue("id")))
+		return
+	}
+
+	order, err := h.repo.GetCalculationOrder(r.Context(), int32(id))
+	switch {
+	case db.IsNotFound(err):
+		writeError(w, asJSON, http.StatusNotFound, fmt.Sprintf("Order %d not found", id))
+		return
+	case err != nil:
+		log.Printf("failed to load order %d: %v\n", id, err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to load order, please try again later")
+		return
+	}
+	calculations, err := h.repo.ListOrderCalculations(r.Context(), order.ID)
+	if err != nil {
+		log.Printf("failed to load the lines of order %d: %v\n", id, err)
+		writeError(w, asJSON, http.StatusInternalServerError, "Failed to load order, please try again later")
+		return
+	}
+	failed, err := db.StoredErrors(order.Errors)
+	if err != nil {
+		log.Printf("failed to read the errors of order %d: %v\n", id, err)
+		writeError(w, asJSON, http.StatusInternalServerError, fmt.Sprintf("Failed to read order %d", id))
+		return
+	}
+
+	if asJSON {
+		resp := storedOrderJSON{
+			ID:        order.ID,
+			Reference: order.Reference,
+			CreatedAt: order.CreatedAt.Time,
+			Totals:    newOrderTotalsJSON(db.StoredOrderTotals(order)),
+			Lines:     newHistoryResponse(calculations),
+			Errors:    make([]orderErrorJSON, len(failed)),
+		}
+		for i, item := range failed {
+			resp.Errors[i] = orderErrorJSON{Index: item.Index, Error: item.Error}
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	// Lines come back in the order they were stored, failed lines have no calculation
+	lines := make([]domain.OrderLine, 0, len(calculations))
+	plans := make([]*domain.CalculateResult, 0, len(calculations))
+	items := make([]batchItemResultJSON, 0, len(calculations))
+	for _, calc := range calculations {
+		result, err := db.StoredResult(calc)
+		if err != nil {
+			log.Printf("failed to read calculation %d: %v\n", calc.ID, err)
+			writeError(w, asJSON, http.StatusInternalServerError, fmt.Sprintf("Failed to read order %d", id))
+			return
+		}
+		lines = append(lines, domain.OrderLine{Sku: calc.Sku, Request: domain.CalculateRequest{Amount: int(calc.TargetAmount)}})
+		plans = append(plans, result)
+		items = append(items, batchItemResultJSON{ID: calc.ID, Amount: int(calc.TargetAmount)})
+	}
+
+	var html strings.Builder
+	html.WriteString("<div class='result-success'>")
+	html.WriteString(fmt.Sprintf("<h3>Order #%d</h3>", order.ID))
+	html.WriteString(fmt.Sprintf("<p>%s</p>", order.CreatedAt.Time.Format("2006-01-02 15:04")))
+	writeOrderHTML(&html, order.Reference, db.StoredOrderTotals(order), lines, plans, items)
+	if len(failed) > 0 {
+		html.WriteString("<table class='result-table'><tr><th>Line</th><th>Error</th></tr>")
+		for _, item := range failed {
+			html.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td></tr>", item.Index+1, template.HTMLEscapeString(item.Error)))
+		}
+		html.WriteString("</table>")
+	}
+	html.WriteString("</div>")
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(html.String()))
+}
+
+// decodeOrderJSON reads an order from a JSON body, lines inherit the order's
+// mode and objective
+func decodeOrderJSON(w http.ResponseWriter, r *http.Request) (*orderInput, error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	var body orderRequestJSON
+	if err := decoder.Decode(&body); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			return nil, err
+		case errors.Is(err, io.EOF):
+			return nil, errors.New("JSON body: empty")
+		default:
+			return nil, fmt.Errorf("JSON body: %s", err.Error())
+		}
+	}
+
+	input := &orderInput{reference: strings.TrimSpace(body.Reference)}
+	for _, line := range body.Lines {
+		item := line.batchItemJSON
+		if item.Mode == "" {
+			item.Mode = body.Mode
+		}
+		if item.Objective == "" {
+			item.Objective = body.Objective
+		}
+		input.skus = append(input.skus, strings.TrimSpace(line.Sku))
+		input.items = append(input.items, item)
+	}
+
+	return input, nil
+}
+
+// parseOrderForm reads an order from the order form: the reference, mode and
+// objective, and one sku, lineConfigurationId, linePackSizes and lineAmount
+// field per row. Blank rows are skipped, errors name the row.
+func parseOrderForm(r *http.Request) (*orderInput, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, errors.New("form data")
+	}
+
+	if _, err := domain.ParseMode(r.FormValue("mode")); err != nil {
+		return nil, fmt.Errorf("mode: %s", r.FormValue("mode"))
+	}
+	if _, err := domain.ParseObjective(r.FormValue("objective")); err != nil {
+		return nil, fmt.Errorf("objective: %s", r.FormValue("objective"))
+	}
+
+	field := func(name string, row int) string {
+		if values := r.Form[name]; row < len(values) {
+			return strings.TrimSpace(values[row])
+		}
+		return ""
+	}
+
+	input := &orderInput{reference: strings.TrimSpace(r.FormValue("reference"))}
+	for row := range len(r.Form["lineAmount"]) {
+		sku, packSizesStr, amountStr := field("sku", row), field("linePackSizes", row), field("lineAmount", row)
+		configurationStr := field("lineConfigurationId", row)
+		if sku == "" && packSizesStr == "" && configurationStr == "" && amountStr == "" {
+			continue
+		}
+
+		configurationID, err := parseConfigurationID(configurationStr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d %s", row+1, err.Error())
+		}
+		packSizes, err := parsePackSizeList(packSizesStr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d %s", row+1, err.Error())
+		}
+		amount, err := strconv.Atoi(amountStr)
+		if err != nil {
+			return nil, fmt.Errorf("line %d amount: %s", row+1, amountStr)
+		}
+
+		input.skus = append(input.skus, sku)
+		input.items = append(input.items, batchItemJSON{
+			PackSizes:       packSizes,
+			ConfigurationID: configurationID,
+			Amount:          amount,
+			Mode:            r.FormValue("mode"),
+			Objective:       r.FormValue("objective"),
+		})
+	}
+
+	return input, nil
+}
+
+// saveOrder stores the order with one calculation per packed line, named by
+// its SKU, and fills in the calculation IDs. It returns the order ID, or 0
+// when there is no repository or saving failed.
+func (h *CalculatorHandler) saveOrder(ctx context.Context, input *orderInput, run *batchRun, totals domain.OrderTotals) int32 {
+	if h.repo == nil {
+		return 0
+	}
+
+	items := run.storedItems()
+	for i := range items {
+		if items[i].Calculation != nil {
+			items[i].Calculation.Sku = input.skus[i]
+		}
+	}
+
+	order, ids, err := h.repo.SaveOrder(ctx, db.NewCreateOrderParams(input.reference, totals), items)
+	if err != nil {
+		log.Printf("failed to save order: %v\n", err)
+		return 0
+	}
+	for i := range run.resp.Items {
+		run.resp.Items[i].ID = ids[i]
+	}
+
+	return order.ID
+}
+
+func newOrderTotalsJSON(totals domain.OrderTotals) orderTotalsJSON {
+	entry := orderTotalsJSON{
+		Lines:     totals.Lines,
+		Failed:    totals.Failed,
+		Amount:    totals.Amount,
+		Total:     totals.Total,
+		Overshoot: totals.Overshoot,
+		PackCount: totals.PackCount,
+	}
+	if totals.Costed {
+		totalCost := totals.TotalCost
+		entry.TotalCost = &totalCost
+	}
+
+	return entry
+}
+
+// writeOrderHTML renders the lines of an order, one row per line with its
+// plan or error, followed by the order totals
+func writeOrderHTML(html *strings.Builder, reference string, totals domain.OrderTotals, lines []domain.OrderLine, plans []*domain.CalculateResult, items []batchItemResultJSON) {
+	if reference != "" {
+		html.WriteString(fmt.Sprintf("<p>Reference: %s</p>", template.HTMLEscapeString(reference)))
+	}
+
+	html.WriteString("<table class='result-table'>")
+	html.WriteString("<tr><th>SKU</th><th>Amount</th><th>Packs</th><th>Total</th><th>Cost</th></tr>")
+	for i, line := range lines {
+		sku := template.HTMLEscapeString(line.Sku)
+		if sku == "" {
+			sku = "-"
+		}
+		plan := plans[i]
+		if plan == nil {
+			html.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td colspan='3' class='error'>%s</td></tr>",
+				sku, items[i].Amount, template.HTMLEscapeString(items[i].Error)))
+			continue
+		}
+		cost := "-"
+		if plan.Costs != nil {
+			cost = formatCents(int64(plan.TotalCost))
+		}
+		html.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%s</td><td>%d</td><td>%s</td></tr>",
+			sku, items[i].Amount, formatPackages(plan.Packages), plan.Total, cost))
+	}
+	html.WriteString("</table>")
+
+	html.WriteString(fmt.Sprintf("<p class='total'>Lines packed: <strong>%d of %d</strong></p>", totals.Lines-totals.Failed, totals.Lines))
+	html.WriteString(fmt.Sprintf("<p class='total'>Total packs: <strong>%d</strong></p>", totals.PackCount))
+	html.WriteString(fmt.Sprintf("<p class='total'>Total items: <strong>%d</strong></p>", totals.Total))
+	if totals.Overshoot > 0 {
+		html.WriteString(fmt.Sprintf("<p class='total'>Overshoot: <strong>%d</strong></p>", totals.Overshoot))
+	}
+	if totals.Costed {
+		html.WriteString(fmt.Sprintf("<p class='total'>Total cost: <strong>%s</strong></p>", formatCents(int64(totals.TotalCost))))
+	}
+}
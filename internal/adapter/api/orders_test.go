@@ -0,0 +1,211 @@
+package api_test
+
+import (
+	"encoding/json"
+	"ignis/internal/adapter/api"
+	"ignis/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func serveOrders(repo *MockRepository, req *http.Request) *httptest.ResponseRecorder {
+	h := api.NewCalculatorHandler(service.NewPackageCalculatorService(), repo)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/orders", h.CreateOrder)
+	mux.HandleFunc("GET /api/v1/orders/{id}", h.GetOrder)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+type orderLineResult struct {
+	Index     int         `json:"index"`
+	ID        int32       `json:"id"`
+	Sku       string      `json:"sku"`
+	Amount    int         `json:"amount"`
+	Total     int         `json:"total"`
+	Overshoot int         `json:"overshoot"`
+	PackCount int         `json:"packCount"`
+	Packages  map[int]int `json:"packages"`
+	TotalCost *int        `json:"totalCost"`
+	Error     string      `json:"error"`
+	Status    int         `json:"status"`
+}
+
+type orderTotals struct {
+	Lines     int  `json:"lines"`
+	Failed    int  `json:"failed"`
+	Amount    int  `json:"amount"`
+	Total     int  `json:"total"`
+	Overshoot int  `json:"overshoot"`
+	PackCount int  `json:"packCount"`
+	TotalCost *int `json:"totalCost"`
+}
+
+func TestCalculatorHandler_CreateOrder(t *testing.T) {
+	repo := &MockRepository{}
+	if w := serveConfigurations(repo, jsonRequest(http.MethodPost, "/api/v1/configurations", `{"name":"WIDGET","packSizes":[23,31,53]}`)); w.Code != http.StatusCreated {
+		t.Fatalf("failed to create configuration: %d", w.Code)
+	}
+
+	// Lines bring their own pack sizes or configuration, the third cannot be packed
+	body := `{"reference":"PO-1","mode":"overfill","lines":[
+		{"sku":"WIDGET","configurationId":1,"amount":263,"mode":"exact"},
+		{"sku":"BOLT","packSizes":[250,500],"amount":251,"costs":{"250":100,"500":150}},
+		{"sku":"NUT","packSizes":[250,500],"amount":7,"mode":"exact"}
+	]}`
+	w := serveOrders(repo, jsonRequest(http.MethodPost, "/api/v1/orders", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		ID        int32             `json:"id"`
+		Reference string            `json:"reference"`
+		Totals    orderTotals       `json:"totals"`
+		Lines     []orderLineResult `json:"lines"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ID != 1 || resp.Reference != "PO-1" || len(resp.Lines) != 3 {
+		t.Fatalf("unexpected order: %+v", resp)
+	}
+
+	widget, bolt, nut := resp.Lines[0], resp.Lines[1], resp.Lines[2]
+	if widget.Sku != "WIDGET" || widget.Total != 263 || widget.Overshoot != 0 || widget.ID == 0 {
+		t.Errorf("unexpected WIDGET line: %+v", widget)
+	}
+	if bolt.Packages[500] != 1 || bolt.Overshoot != 249 || bolt.TotalCost == nil || *bolt.TotalCost != 150 {
+		t.Errorf("expected BOLT to overfill with one 500 pack, got %+v", bolt)
+	}
+	if nut.Error == "" || nut.Status != http.StatusUnprocessableEntity || nut.ID != 0 {
+		t.Errorf("expected NUT to fail, got %+v", nut)
+	}
+
+	want := orderTotals{
+		Lines:     3,
+		Failed:    1,
+		Amount:    263 + 251,
+		Total:     widget.Total + bolt.Total,
+		Overshoot: 249,
+		PackCount: widget.PackCount + bolt.PackCount,
+	}
+	if resp.Totals.TotalCost != nil {
+		t.Errorf("expected no total cost while WIDGET has no costs, got %d", *resp.Totals.TotalCost)
+	}
+	resp.Totals.TotalCost = nil
+	if resp.Totals != want {
+		t.Errorf("expected totals %+v, got %+v", want, resp.Totals)
+	}
+
+	// The order is stored with one calculation per packed line
+	if len(repo.Orders) != 1 || repo.Orders[0].LineCount != 3 || repo.Orders[0].FailedCount != 1 {
+		t.Fatalf("unexpected stored orders: %+v", repo.Orders)
+	}
+	if got := repo.Calculations[bolt.ID-1]; got.Sku != "BOLT" || got.OrderReference != "PO-1" || got.OrderID.Int32 != 1 {
+		t.Errorf("expected the BOLT calculation to be linked to the order, got %+v", got)
+	}
+
+	w = serveOrders(repo, func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/1", nil)
+		req.Header.Set("Accept", "application/json")
+		return req
+	}())
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	var stored struct {
+		Reference string                 `json:"reference"`
+		Totals    orderTotals            `json:"totals"`
+		Lines     []struct{ Sku string } `json:"lines"`
+		Errors    []struct {
+			Index int    `json:"index"`
+			Error string `json:"error"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stored); err != nil {
+		t.Fatalf("failed to decode stored order: %v", err)
+	}
+	if stored.Reference != "PO-1" || stored.Totals != resp.Totals || len(stored.Lines) != 2 || stored.Lines[1].Sku != "BOLT" {
+		t.Errorf("unexpected stored order: %+v", stored)
+	}
+	if len(stored.Errors) != 1 || stored.Errors[0].Index != 2 || stored.Errors[0].Error != nut.Error {
+		t.Errorf("expected the NUT error at index 2, got %+v", stored.Errors)
+	}
+}
+
+func TestCalculatorHandler_CreateOrderForm(t *testing.T) {
+	repo := &MockRepository{}
+	form := url.Values{
+		"reference":           {"PO-<2>"},
+		"mode":                {"exact"},
+		"sku":                 {"WIDGET", "", "BOLT"},
+		"lineConfigurationId": {"", "", ""},
+		"linePackSizes":       {"23, 31, 53", "", "5, 12"},
+		"lineAmount":          {"263", "", "3"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	w := serveOrders(repo, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("HX-Trigger") != "calculation-done" {
+		t.Errorf("expected the history to be refreshed")
+	}
+	html := w.Body.String()
+	for _, want := range []string{"Order #1", "PO-&lt;2&gt;", "<td>WIDGET</td>", "<td>BOLT</td>", "Lines packed: <strong>1 of 2</strong>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in %s", want, html)
+		}
+	}
+	if len(repo.Orders) != 1 || repo.Orders[0].LineCount != 2 {
+		t.Errorf("expected the blank row to be skipped, got %+v", repo.Orders)
+	}
+
+	// A stored order renders with its failed lines
+	w = serveOrders(repo, httptest.NewRequest(http.MethodGet, "/api/v1/orders/1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<td>WIDGET</td>") || !strings.Contains(w.Body.String(), "<th>Line</th>") {
+		t.Errorf("unexpected stored order fragment: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCalculatorHandler_OrderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		want   string
+	}{
+		{"no lines", jsonRequest(http.MethodPost, "/api/v1/orders", `{"lines":[]}`), http.StatusBadRequest, "Invalid order: no lines"},
+		{"unknown field", jsonRequest(http.MethodPost, "/api/v1/orders", `{"lines":[],"extra":1}`), http.StatusBadRequest, "Invalid JSON body"},
+		{"bad amount", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader("sku=A&linePackSizes=5&lineAmount=x"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}(), http.StatusBadRequest, "Invalid line 1 amount: x"},
+		{"bad pack sizes", func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader("sku=A&linePackSizes=5,x&lineAmount=10"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}(), http.StatusBadRequest, "Invalid line 1 pack size"},
+		{"bad ID", httptest.NewRequest(http.MethodGet, "/api/v1/orders/abc", nil), http.StatusBadRequest, "Invalid order ID: abc"},
+		{"missing order", httptest.NewRequest(http.MethodGet, "/api/v1/orders/7", nil), http.StatusNotFound, "Order 7 not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveOrders(&MockRepository{}, tt.req)
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("expected %d with %q, got %d: %s", tt.status, tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"ignis/internal/domain"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// packagingJSON is the container hierarchy to ship the packs in, e.g. cartons
+// holding 12 packs of 53 or 20 of 23, on pallets of 40 cartons
+type packagingJSON struct {
+	Container string               `json:"container"`
+	Capacity  map[int]int          `json:"capacity"` // pack size -> packs filling one container
+	Outer     []outerContainerJSON `json:"outer,omitempty"`
+}
+
+// outerContainerJSON is a level of containers holding those of the level below
+type outerContainerJSON struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+}
+
+// packagingPlanJSON is the containers of a plan, outermost first in the tree
+type packagingPlanJSON struct {
+	Levels     []levelCountJSON     `json:"levels"`
+	Containers []containerGroupJSON `json:"containers"`
+}
+
+// levelCountJSON is how many containers of a level the plan ships in
+type levelCountJSON struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// containerGroupJSON is count identical containers with the packs, or the
+// groups of containers of the level below, in each
+type containerGroupJSON struct {
+	Name     string               `json:"name"`
+	Count    int                  `json:"count"`
+	Packs    map[int]int          `json:"packs,omitempty"`
+	Contents []containerGroupJSON `json:"contents,omitempty"`
+}
+
+func (p *packagingJSON) toPackaging() *domain.Packaging {
+	if p == nil {
+		return nil
+	}
+
+	packaging := &domain.Packaging{Container: p.Container, Capacity: p.Capacity}
+	for _, outer := range p.Outer {
+		packaging.Outer = append(packaging.Outer, domain.OuterContainer{Name: outer.Name, Capacity: outer.Capacity})
+	}
+
+	return packaging
+}
+
+func newPackagingJSON(packaging *domain.Packaging) *packagingJSON {
+	if packaging == nil {
+		return nil
+	}
+
+	entry := &packagingJSON{Container: packaging.Container, Capacity: packaging.Capacity}
+	for _, outer := range packaging.Outer {
+		entry.Outer = append(entry.Outer, outerContainerJSON{Name: outer.Name, Capacity: outer.Capacity})
+	}
+
+	return entry
+}
+
+func newPackagingPlanJSON(plan *domain.PackagingPlan) *packagingPlanJSON {
+	if plan == nil {
+		return nil
+	}
+
+	entry := &packagingPlanJSON{
+		Levels:     make([]levelCountJSON, len(plan.Levels)),
+		Containers: newContainerGroupsJSON(plan.Containers),
+	}
+	for i, level := range plan.Levels {
+		entry.Levels[i] = levelCountJSON{Name: level.Name, Count: level.Count}
+	}
+
+	return entry
+}
+
+func newContainerGroupsJSON(groups []domain.ContainerGroup) []containerGroupJSON {
+	entries := make([]containerGroupJSON, len(groups))
+	for i, group := range groups {
+		entries[i] = containerGroupJSON{
+			Name:     group.Name,
+			Count:    group.Count,
+			Packs:    group.Packs,
+			Contents: newContainerGroupsJSON(group.Contents),
+		}
+	}
+
+	return entries
+}
+
+// parsePackagingForm reads the cartons and pallets of the calculator form:
+// packs per carton written as "53:12, 23:20", and cartons per pallet. It
+// returns nil when both are empty.
+func parsePackagingForm(cartonStr, palletStr string) (*domain.Packaging, error) {
+	cartonStr, palletStr = strings.TrimSpace(cartonStr), strings.TrimSpace(palletStr)
+	if cartonStr == "" && palletStr == "" {
+		return nil, nil
+	}
+
+	capacity, err := parseSizeQuantities(cartonStr)
+	if err != nil {
+		return nil, fmt.Errorf("carton capacity: %s", err.Error())
+	}
+	packaging := &domain.Packaging{Container: "carton", Capacity: capacity}
+
+	if palletStr != "" {
+		cartons, err := strconv.Atoi(palletStr)
+		if err != nil {
+			return nil, fmt.Errorf("pallet capacity: %s", palletStr)
+		}
+		packaging.Outer = []domain.OuterContainer{{Name: "pallet", Capacity: cartons}}
+	}
+
+	return packaging, nil
+}
+
+// writePackagingPlan renders the containers of a plan as their count per level
+// followed by a nested list of the container groups
+func writePackagingPlan(html *strings.Builder, plan *domain.PackagingPlan) {
+	counts := make([]string, len(plan.Levels))
+	for i := range plan.Levels {
+		// Outermost level first, like the tree
+		level := plan.Levels[len(plan.Levels)-1-i]
+		counts[i] = fmt.Sprintf("%d × %s", level.Count, template.HTMLEscapeString(level.Name))
+	}
+	html.WriteString(fmt.Sprintf("<p class='total'>Packaging: <strong>%s</strong></p>", strings.Join(counts, ", ")))
+
+	html.WriteString("<div class='packaging-tree'>")
+	writeContainerGroups(html, plan.Containers)
+	html.WriteString("</div>")
+}
+
+func writeContainerGroups(html *strings.Builder, groups []domain.ContainerGroup) {
+	html.WriteString("<ul>")
+	for _, group := range groups {
+		html.WriteString(fmt.Sprintf("<li>%d × %s", group.Count, template.HTMLEscapeString(group.Name)))
+		if group.Packs != nil {
+			sizes := make([]int, 0, len(group.Packs))
+			for size := range group.Packs {
+				sizes = append(sizes, size)
+			}
+			sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+			packs := make([]string, len(sizes))
+			for i, size := range sizes {
+				packs[i] = fmt.Sprintf("%d packs of %d", group.Packs[size], size)
+			}
+			html.WriteString(": " + strings.Join(packs, ", "))
+		}
+		if len(group.Contents) > 0 {
+			html.WriteString(" holding")
+			writeContainerGroups(html, group.Contents)
+		}
+		html.WriteString("</li>")
+	}
+	html.WriteString("</ul>")
+}
+
+// formatPackaging renders a container hierarchy with the capacity of each
+// level, e.g. "carton (53×12, 23×20) > pallet (40)"
+func formatPackaging(packaging domain.Packaging) string {
+	levels := []string{fmt.Sprintf("%s (%s)", packaging.Container, formatPackages(packaging.Capacity))}
+	for _, outer := range packaging.Outer {
+		levels = append(levels, fmt.Sprintf("%s (%d)", outer.Name, outer.Capacity))
+	}
+
+	return strings.Join(levels, " > ")
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"ignis/internal/domain"
+	"strconv"
+	"strings"
+)
+
+// shippingJSON caps the weight and volume of each shipment; weights and
+// volumes are per pack, in the unit of the maximums
+type shippingJSON struct {
+	Weights   map[int]int `json:"weights,omitempty"` // pack size -> weight of one pack
+	Volumes   map[int]int `json:"volumes,omitempty"` // pack size -> volume of one pack
+	MaxWeight int         `json:"maxWeight,omitempty"`
+	MaxVolume int         `json:"maxVolume,omitempty"`
+}
+
+// shipmentJSON is count identical shipments of a plan
+type shipmentJSON struct {
+	Count  int         `json:"count"`
+	Packs  map[int]int `json:"packs"`
+	Weight int         `json:"weight"`
+	Volume int         `json:"volume"`
+}
+
+func (s *shippingJSON) toLimits() *domain.ShipmentLimits {
+	if s == nil {
+		return nil
+	}
+
+	return &domain.ShipmentLimits{Weights: s.Weights, Volumes: s.Volumes, MaxWeight: s.MaxWeight, MaxVolume: s.MaxVolume}
+}
+
+func newShippingJSON(limits *domain.ShipmentLimits) *shippingJSON {
+	if limits == nil {
+		return nil
+	}
+
+	return &shippingJSON{Weights: limits.Weights, Volumes: limits.Volumes, MaxWeight: limits.MaxWeight, MaxVolume: limits.MaxVolume}
+}
+
+func newShipmentsJSON(shipments []domain.ShipmentGroup) []shipmentJSON {
+	if shipments == nil {
+		return nil
+	}
+
+	entries := make([]shipmentJSON, len(shipments))
+	for i, shipment := range shipments {
+		entries[i] = shipmentJSON{Count: shipment.Count, Packs: shipment.Packs, Weight: shipment.Weight, Volume: shipment.Volume}
+	}
+
+	return entries
+}
+
+// parseShippingForm reads the shipment limits of the calculator form: weights
+// and volumes per pack written as "53:1200, 31:700", and the maximums per
+// shipment. It returns nil when no maximum is given.
+func parseShippingForm(weightsStr, volumesStr, maxWeightStr, maxVolumeStr string) (*domain.ShipmentLimits, error) {
+	maxWeightStr, maxVolumeStr = strings.TrimSpace(maxWeightStr), strings.TrimSpace(maxVolumeStr)
+	if maxWeightStr == "" && maxVolumeStr == "" {
+		return nil, nil
+	}
+
+	limits := &domain.ShipmentLimits{}
+	var err error
+	if limits.Weights, err = parseSizeQuantities(weightsStr); err != nil {
+		return nil, fmt.Errorf("pack weights: %s", err.Error())
+	}
+	if limits.Volumes, err = parseSizeQuantities(volumesStr); err != nil {
+		return nil, fmt.Errorf("pack volumes: %s", err.Error())
+	}
+	if maxWeightStr != "" {
+		if limits.MaxWeight, err = strconv.Atoi(maxWeightStr); err != nil {
+			return nil, fmt.Errorf("maximum weight: %s", maxWeightStr)
+		}
+	}
+	if maxVolumeStr != "" {
+		if limits.MaxVolume, err = strconv.Atoi(maxVolumeStr); err != nil {
+			return nil, fmt.Errorf("maximum volume: %s", maxVolumeStr)
+		}
+	}
+
+	return limits, nil
+}
+
+// parseSizeQuantities reads whole numbers per pack size written as "53:12, 23:20"
+func parseSizeQuantities(input string) (map[int]int, error) {
+	entries, err := splitSizeEntries(input)
+	if err != nil {
+		return nil, err
+	}
+
+	quantities := make(map[int]int, len(entries))
+	for size, quantityStr := range entries {
+		quantity, err := strconv.Atoi(quantityStr)
+		if err != nil {
+			return nil, fmt.Errorf("%d:%s (invalid quantity)", size, quantityStr)
+		}
+		quantities[size] = quantity
+	}
+
+	return quantities, nil
+}
+
+// writeShipments renders the shipments of a plan, identical ones on one row
+func writeShipments(html *strings.Builder, shipments []domain.ShipmentGroup) {
+	count := 0
+	for _, shipment := range shipments {
+		count += shipment.Count
+	}
+	html.WriteString(fmt.Sprintf("<p class='total'>Shipments: <strong>%d</strong></p>", count))
+
+	html.WriteString("<table class='result-table shipments-table'>")
+	html.WriteString("<tr><th>Shipments</th><th>Packs in Each</th><th>Weight</th><th>Volume</th></tr>")
+	for _, shipment := range shipments {
+		html.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%d</td><td>%d</td></tr>",
+			shipment.Count, formatPackages(shipment.Packs), shipment.Weight, shipment.Volume))
+	}
+	html.WriteString("</table>")
+}
+
+// formatShipmentLimits renders the maximums that are set, e.g. "weight 24000
+// and volume 80"
+func formatShipmentLimits(limits domain.ShipmentLimits) string {
+	var maximums []string
+	if limits.MaxWeight > 0 {
+		maximums = append(maximums, fmt.Sprintf("weight %d", limits.MaxWeight))
+	}
+	if limits.MaxVolume > 0 {
+		maximums = append(maximums, fmt.Sprintf("volume %d", limits.MaxVolume))
+	}
+
+	return strings.Join(maximums, " and ")
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// swaggerInitializer replaces the bundle's initializer, which loads the petstore demo
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// NewSwaggerHandler serves the Swagger UI bundled into the binary and the
+// OpenAPI document at /openapi.json. API paths are proxied to the HTTP server
+// at apiAddress, so "Try it out" works without CORS from the swagger address.
+func NewSwaggerHandler(spec []byte, apiAddress string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+	mux.HandleFunc("GET /swagger-initializer.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Write([]byte(swaggerInitializer))
+	})
+	mux.Handle("/", http.FileServerFS(swaggerFiles.FS))
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: apiAddress})
+	mux.Handle("/api/", proxy)
+	mux.Handle("/healthz", proxy)
+
+	return mux
+}
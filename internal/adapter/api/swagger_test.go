@@ -0,0 +1,45 @@
+package api_test
+
+import (
+	"ignis/internal/adapter/api"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSwaggerHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(api.HealthHandler))
+	defer backend.Close()
+
+	spec, err := api.OpenAPISpec()
+	if err != nil {
+		t.Fatalf("failed to build spec: %v", err)
+	}
+	h := api.NewSwaggerHandler(spec, strings.TrimPrefix(backend.URL, "http://"))
+
+	tests := []struct {
+		path     string
+		wantBody string
+	}{
+		{"/openapi.json", `"openapi": "3.0.3"`},
+		{"/", `<div id="swagger-ui">`},
+		{"/swagger-initializer.js", `url: "/openapi.json"`},
+		{"/swagger-ui-bundle.js", "SwaggerUIBundle"},
+		{"/healthz", "ok"}, // proxied to the API server
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status OK, got %d", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("expected body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
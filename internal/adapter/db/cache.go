@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/domain"
+	"time"
+)
+
+// ResultStore keeps calculator results in calculation_cache, so the cache of
+// service.CachingCalculator survives restarts
+type ResultStore struct {
+	queries dbsqlc.Querier
+}
+
+func NewResultStore(queries dbsqlc.Querier) *ResultStore {
+	return &ResultStore{queries: queries}
+}
+
+// Load implements service.ResultStore
+func (s *ResultStore) Load(ctx context.Context, key string) ([]*domain.CalculateResult, time.Time, bool, error) {
+	cached, err := s.queries.GetCachedResult(ctx, key)
+	if IsNotFound(err) {
+		return nil, time.Time{}, false, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	var plans []*domain.CalculateResult
+	if err := json.Unmarshal(cached.Results, &plans); err != nil {
+		return nil, time.Time{}, false, fmt.Errorf("cached result %q: %w", key, err)
+	}
+
+	// Stored in UTC without a time zone, like Timestamp writes them
+	var expiresAt time.Time
+	if cached.ExpiresAt.Valid {
+		expiresAt = cached.ExpiresAt.Time
+	}
+
+	return plans, expiresAt, true, nil
+}
+
+// Save implements service.ResultStore, ttl is rounded up to whole seconds
+func (s *ResultStore) Save(ctx context.Context, key string, plans []*domain.CalculateResult, ttl time.Duration) error {
+	results, err := json.Marshal(plans)
+	if err != nil {
+		return err
+	}
+
+	return s.queries.SaveCachedResult(ctx, dbsqlc.SaveCachedResultParams{
+		Key:        key,
+		Results:    results,
+		TtlSeconds: int32((ttl + time.Second - 1) / time.Second),
+	})
+}
+
+// Prune deletes the expired results, returning how many there were
+func (s *ResultStore) Prune(ctx context.Context) (int64, error) {
+	return s.queries.DeleteExpiredCachedResults(ctx)
+}
@@ -0,0 +1,237 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/domain"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// storedRequest is calculations.request, the whole request of a calculation
+type storedRequest struct {
+	PackSizes   []int              `json:"packSizes"`
+	Amount      int                `json:"amount"`
+	Mode        string             `json:"mode"`
+	Objective   string             `json:"objective"`
+	Stock       map[int]int        `json:"stock,omitempty"`
+	Costs       map[int]int        `json:"costs,omitempty"`
+	Constraints *storedConstraints `json:"constraints,omitempty"`
+	Packaging   *storedPackaging   `json:"packaging,omitempty"`
+	Shipping    *storedShipping    `json:"shipping,omitempty"`
+}
+
+type storedConstraints struct {
+	Min       map[int]int `json:"min,omitempty"`
+	Max       map[int]int `json:"max,omitempty"`
+	Required  []int       `json:"required,omitempty"`
+	Forbidden []int       `json:"forbidden,omitempty"`
+}
+
+type storedPackaging struct {
+	Container string            `json:"container"`
+	Capacity  map[int]int       `json:"capacity"`
+	Outer     []storedContainer `json:"outer,omitempty"`
+}
+
+type storedContainer struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+}
+
+type storedShipping struct {
+	Weights   map[int]int `json:"weights,omitempty"`
+	Volumes   map[int]int `json:"volumes,omitempty"`
+	MaxWeight int         `json:"maxWeight,omitempty"`
+	MaxVolume int         `json:"maxVolume,omitempty"`
+}
+
+func newStoredRequest(req domain.CalculateRequest) storedRequest {
+	stored := storedRequest{
+		PackSizes: req.PackSizes,
+		Amount:    req.Amount,
+		Mode:      req.Mode.String(),
+		Objective: req.Objective.String(),
+		Stock:     req.Stock,
+		Costs:     req.Costs,
+	}
+	if c := req.Constraints; c != nil {
+		stored.Constraints = &storedConstraints{Min: c.Min, Max: c.Max, Required: c.Required, Forbidden: c.Forbidden}
+	}
+	if p := req.Packaging; p != nil {
+		stored.Packaging = &storedPackaging{Container: p.Container, Capacity: p.Capacity}
+		for _, outer := range p.Outer {
+			stored.Packaging.Outer = append(stored.Packaging.Outer, storedContainer{Name: outer.Name, Capacity: outer.Capacity})
+		}
+	}
+	if l := req.Shipping; l != nil {
+		stored.Shipping = &storedShipping{Weights: l.Weights, Volumes: l.Volumes, MaxWeight: l.MaxWeight, MaxVolume: l.MaxVolume}
+	}
+
+	return stored
+}
+
+func (stored storedRequest) request() (domain.CalculateRequest, error) {
+	mode, err := domain.ParseMode(stored.Mode)
+	if err != nil {
+		return domain.CalculateRequest{}, err
+	}
+	objective, err := domain.ParseObjective(stored.Objective)
+	if err != nil {
+		return domain.CalculateRequest{}, err
+	}
+
+	req := domain.CalculateRequest{
+		PackSizes: stored.PackSizes,
+		Amount:    stored.Amount,
+		Mode:      mode,
+		Objective: objective,
+		Stock:     stored.Stock,
+		Costs:     stored.Costs,
+	}
+	if c := stored.Constraints; c != nil {
+		req.Constraints = &domain.PackConstraints{Min: c.Min, Max: c.Max, Required: c.Required, Forbidden: c.Forbidden}
+	}
+	if p := stored.Packaging; p != nil {
+		req.Packaging = &domain.Packaging{Container: p.Container, Capacity: p.Capacity}
+		for _, outer := range p.Outer {
+			req.Packaging.Outer = append(req.Packaging.Outer, domain.OuterContainer{Name: outer.Name, Capacity: outer.Capacity})
+		}
+	}
+	if l := stored.Shipping; l != nil {
+		req.Shipping = &domain.ShipmentLimits{Weights: l.Weights, Volumes: l.Volumes, MaxWeight: l.MaxWeight, MaxVolume: l.MaxVolume}
+	}
+
+	return req, nil
+}
+
+// NewCreateCalculationParams builds the history row for the best plan of a
+// request; packSizes is stored as entered by the client
+func NewCreateCalculationParams(packSizes string, req domain.CalculateRequest, result *domain.CalculateResult) dbsqlc.CreateCalculationParams {
+	resultJson, _ := json.Marshal(result.Packages)
+	stockJson, _ := json.Marshal(req.Stock)
+	costsJson, _ := json.Marshal(req.Costs)
+	requestJson, _ := json.Marshal(newStoredRequest(req))
+
+	return dbsqlc.CreateCalculationParams{
+		PackSizes:    packSizes,
+		TargetAmount: int64(req.Amount),
+		ResultJson:   resultJson,
+		TotalItems:   int64(result.Total),
+		Stock:        stockJson,
+		Objective:    req.Objective.String(),
+		Costs:        costsJson,
+		TotalCost:    pgtype.Int8{Int64: int64(result.TotalCost), Valid: result.Costs != nil},
+		PackSizeSet:  PackSizeSet(req.PackSizes),
+		Mode:         req.Mode.String(),
+		Request:      requestJson,
+	}
+}
+
+// StoredRequest rebuilds the request a calculation was made with, so it can be
+// replayed; stored JSON that does not parse is an error. Rows stored before
+// the whole request was are rebuilt from their columns, without constraints,
+// packaging or shipping.
+func StoredRequest(calc dbsqlc.Calculation) (domain.CalculateRequest, error) {
+	if len(calc.Request) > 0 {
+		var stored storedRequest
+		if err := json.Unmarshal(calc.Request, &stored); err != nil {
+			return domain.CalculateRequest{}, fmt.Errorf("request: %w", err)
+		}
+		return stored.request()
+	}
+
+	mode, err := domain.ParseMode(calc.Mode)
+	if err != nil {
+		return domain.CalculateRequest{}, err
+	}
+	objective, err := domain.ParseObjective(calc.Objective)
+	if err != nil {
+		return domain.CalculateRequest{}, err
+	}
+
+	req := domain.CalculateRequest{
+		PackSizes: ParsePackSizes(calc.PackSizes),
+		Amount:    int(calc.TargetAmount),
+		Mode:      mode,
+		Objective: objective,
+	}
+	if req.Stock, err = storedSizeMap(calc.Stock); err != nil {
+		return domain.CalculateRequest{}, fmt.Errorf("stock: %w", err)
+	}
+	if req.Costs, err = storedSizeMap(calc.Costs); err != nil {
+		return domain.CalculateRequest{}, fmt.Errorf("costs: %w", err)
+	}
+
+	return req, nil
+}
+
+// StoredResult rebuilds the best plan of a calculation. Per-size costs are
+// derived from the stored unit costs, as the calculator does.
+func StoredResult(calc dbsqlc.Calculation) (*domain.CalculateResult, error) {
+	packages, err := storedSizeMap(calc.ResultJson)
+	if err != nil {
+		return nil, fmt.Errorf("result: %w", err)
+	}
+	unitCosts, err := storedSizeMap(calc.Costs)
+	if err != nil {
+		return nil, fmt.Errorf("costs: %w", err)
+	}
+
+	result := &domain.CalculateResult{
+		Packages:  packages,
+		Total:     int(calc.TotalItems),
+		Overshoot: int(calc.TotalItems - calc.TargetAmount),
+		TotalCost: int(calc.TotalCost.Int64),
+	}
+	for _, count := range packages {
+		result.PackCount += count
+	}
+	if calc.TotalCost.Valid {
+		result.Costs = make(map[int]int, len(packages))
+		for size, count := range packages {
+			result.Costs[size] = count * unitCosts[size]
+		}
+	}
+
+	return result, nil
+}
+
+// storedSizeMap reads a pack size keyed JSON object, empty when nothing was stored
+func storedSizeMap(data []byte) (map[int]int, error) {
+	sizes := make(map[int]int)
+	if len(data) == 0 {
+		return sizes, nil
+	}
+	if err := json.Unmarshal(data, &sizes); err != nil {
+		return nil, err
+	}
+
+	return sizes, nil
+}
+
+// FormatPackSizes renders pack sizes the way the form takes them, "23, 31, 53"
+func FormatPackSizes(packSizes []int) string {
+	sizes := make([]string, 0, len(packSizes))
+	for _, size := range packSizes {
+		sizes = append(sizes, strconv.Itoa(size))
+	}
+
+	return strings.Join(sizes, ", ")
+}
+
+// ParsePackSizes reads pack sizes stored as entered, skipping anything
+// that is not a number
+func ParsePackSizes(packSizes string) []int {
+	sizes := make([]int, 0)
+	for _, sizeStr := range strings.Split(packSizes, ",") {
+		if size, err := strconv.Atoi(strings.TrimSpace(sizeStr)); err == nil {
+			sizes = append(sizes, size)
+		}
+	}
+
+	return sizes
+}
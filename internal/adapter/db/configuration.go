@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// uniqueViolation is the Postgres error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// ConfigurationVersion is the pack sizes a configuration has from ValidFrom on
+type ConfigurationVersion struct {
+	PackSizes []int32
+	ValidFrom time.Time // zero means now
+}
+
+// ErrVersionInPast rejects a new version starting before now: calculations
+// made since then used the sizes of the version before it
+var ErrVersionInPast = errors.New("a new version cannot start in the past")
+
+// validFrom is the start of the version, now when none was given
+func (v ConfigurationVersion) validFrom(now time.Time) time.Time {
+	if v.ValidFrom.IsZero() {
+		return now
+	}
+	return v.ValidFrom
+}
+
+// VersionOrderError rejects a version that does not start after the latest
+// one. Versions only append, so calculations keep the sizes valid at the time.
+type VersionOrderError struct {
+	LatestFrom time.Time
+}
+
+func (e *VersionOrderError) Error() string {
+	return fmt.Sprintf("a new version must start after %s", e.LatestFrom.Format(time.RFC3339))
+}
+
+// CheckVersionStart reports whether a new version may start at validFrom when
+// the latest one started at latestFrom: not before now and after the latest
+func CheckVersionStart(validFrom, latestFrom, now time.Time) error {
+	if validFrom.Before(now) {
+		return ErrVersionInPast
+	}
+	if !validFrom.After(latestFrom) {
+		return &VersionOrderError{LatestFrom: latestFrom}
+	}
+
+	return nil
+}
+
+func (r *repository) CreateConfiguration(ctx context.Context, arg dbsqlc.CreatePackConfigurationParams, version ConfigurationVersion) (dbsqlc.PackConfiguration, dbsqlc.PackConfigurationVersion, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := r.Queries.WithTx(tx)
+	configuration, err := q.CreatePackConfiguration(ctx, arg)
+	if err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, fmt.Errorf("create configuration: %w", err)
+	}
+	created, err := q.CreatePackConfigurationVersion(ctx, dbsqlc.CreatePackConfigurationVersionParams{
+		ConfigurationID: configuration.ID,
+		PackSizes:       version.PackSizes,
+		ValidFrom:       Timestamp(version.validFrom(time.Now())),
+	})
+	if err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, fmt.Errorf("create configuration version: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, err
+	}
+
+	return configuration, created, nil
+}
+
+func (r *repository) UpdateConfiguration(ctx context.Context, arg dbsqlc.UpdatePackConfigurationParams, version ConfigurationVersion) (dbsqlc.PackConfiguration, dbsqlc.PackConfigurationVersion, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := r.Queries.WithTx(tx)
+	configuration, err := q.UpdatePackConfiguration(ctx, arg)
+	if err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, err
+	}
+	latest, err := q.GetLatestPackConfigurationVersion(ctx, arg.ID)
+	if err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, fmt.Errorf("load latest version: %w", err)
+	}
+
+	if !slices.Equal(latest.PackSizes, version.PackSizes) {
+		now := time.Now()
+		validFrom := version.validFrom(now)
+		if err := CheckVersionStart(validFrom, latest.ValidFrom.Time, now); err != nil {
+			return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, err
+		}
+		if err := q.ClosePackConfigurationVersion(ctx, dbsqlc.ClosePackConfigurationVersionParams{
+			ID:      latest.ID,
+			ValidTo: Timestamp(validFrom),
+		}); err != nil {
+			return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, fmt.Errorf("close version %d: %w", latest.ID, err)
+		}
+		latest, err = q.CreatePackConfigurationVersion(ctx, dbsqlc.CreatePackConfigurationVersionParams{
+			ConfigurationID: arg.ID,
+			PackSizes:       version.PackSizes,
+			ValidFrom:       Timestamp(validFrom),
+		})
+		if err != nil {
+			return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, fmt.Errorf("create configuration version: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return dbsqlc.PackConfiguration{}, dbsqlc.PackConfigurationVersion{}, err
+	}
+
+	return configuration, latest, nil
+}
+
+// Timestamp converts a time to a timestamp column. Columns are stored without a
+// time zone in UTC, like NOW() on the database.
+func Timestamp(t time.Time) pgtype.Timestamp {
+	return pgtype.Timestamp{Time: t.UTC(), Valid: true}
+}
+
+// ConfigurationSizes converts pack sizes to the integer[] column of pack_configuration_versions
+func ConfigurationSizes(packSizes []int) []int32 {
+	sizes := make([]int32, len(packSizes))
+	for i, size := range packSizes {
+		sizes[i] = int32(size)
+	}
+
+	return sizes
+}
+
+// PackSizesOf converts the stored pack sizes of a configuration version back to ints
+func PackSizesOf(sizes []int32) []int {
+	packSizes := make([]int, len(sizes))
+	for i, size := range sizes {
+		packSizes[i] = int(size)
+	}
+
+	return packSizes
+}
+
+// IsNotFound reports whether a query returned no row
+func IsNotFound(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+// IsUniqueViolation reports whether a write clashed with a unique constraint,
+// e.g. a configuration name that is already taken
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckVersionStart(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	latestFrom := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		validFrom  time.Time
+		latestFrom time.Time
+		wantErr    error
+	}{
+		{"now", now, latestFrom, nil},
+		{"scheduled", now.AddDate(0, 1, 0), latestFrom, nil},
+		{"earlier today", now.Add(-time.Hour), latestFrom, ErrVersionInPast},
+		{"before the latest", now.AddDate(-1, 0, 0), latestFrom, ErrVersionInPast},
+		{"when a scheduled one starts", now.AddDate(0, 1, 0), now.AddDate(0, 1, 0), &VersionOrderError{}},
+		{"before a scheduled one", now.AddDate(0, 1, 0), now.AddDate(0, 2, 0), &VersionOrderError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckVersionStart(tt.validFrom, tt.latestFrom, now)
+
+			var orderErr *VersionOrderError
+			switch tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+			case *VersionOrderError:
+				if !errors.As(err, &orderErr) || !orderErr.LatestFrom.Equal(tt.latestFrom) {
+					t.Errorf("expected a version order error after %s, got %v", tt.latestFrom, err)
+				}
+			default:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// DefaultHistoryPageSize is the page size when the client asks for none
+	DefaultHistoryPageSize = 50
+	// MaxHistoryPageSize caps the calculations returned by one page
+	MaxHistoryPageSize = 200
+)
+
+// HistoryCursor points at the last calculation of a page. Pages are ordered by
+// (created_at, id) newest first, so the next page starts right after it.
+type HistoryCursor struct {
+	CreatedAt time.Time
+	ID        int32
+}
+
+// Encode renders the cursor as an opaque, URL-safe token
+func (c HistoryCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeHistoryCursor reads a token made by HistoryCursor.Encode
+func DecodeHistoryCursor(token string) (HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return HistoryCursor{}, errors.New("malformed cursor")
+	}
+
+	microsStr, idStr, ok := strings.Cut(string(raw), ":")
+	micros, microsErr := strconv.ParseInt(microsStr, 10, 64)
+	id, idErr := strconv.ParseInt(idStr, 10, 32)
+	if !ok || microsErr != nil || idErr != nil {
+		return HistoryCursor{}, errors.New("malformed cursor")
+	}
+
+	return HistoryCursor{CreatedAt: time.UnixMicro(micros).UTC(), ID: int32(id)}, nil
+}
+
+// HistoryQuery selects a page of the history; zero filters match everything
+type HistoryQuery struct {
+	After           *HistoryCursor
+	PageSize        int   // DefaultHistoryPageSize when 0
+	MinAmount       int   // inclusive
+	MaxAmount       int   // inclusive
+	PackSizes       []int // matched as a set, in any order
+	From            time.Time
+	To              time.Time // exclusive
+	ConfigurationID int32
+}
+
+// ExportChunkSize is the number of calculations ExportHistory reads per query
+const ExportChunkSize = 1000
+
+// ListHistory returns a page of calculations and the cursor of the next page,
+// nil on the last one
+func ListHistory(ctx context.Context, q dbsqlc.Querier, query HistoryQuery) ([]dbsqlc.Calculation, *HistoryCursor, error) {
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultHistoryPageSize
+	}
+	pageSize = min(pageSize, MaxHistoryPageSize)
+
+	// One extra row tells whether there is a next page
+	calculations, err := q.ListCalculations(ctx, listCalculationsParams(query, pageSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(calculations) <= pageSize {
+		return calculations, nil, nil
+	}
+
+	calculations = calculations[:pageSize]
+	last := calculations[pageSize-1]
+	return calculations, &HistoryCursor{CreatedAt: last.CreatedAt.Time, ID: last.ID}, nil
+}
+
+// ExportHistory passes every calculation matching the query to fn, newest
+// first, in chunks of ExportChunkSize. Each chunk is a keyset query of its own,
+// so memory stays flat however many rows match; the page size is ignored.
+func ExportHistory(ctx context.Context, q dbsqlc.Querier, query HistoryQuery, fn func([]dbsqlc.Calculation) error) error {
+	for {
+		calculations, err := q.ListCalculations(ctx, listCalculationsParams(query, ExportChunkSize))
+		if err != nil {
+			return err
+		}
+		if len(calculations) > 0 {
+			if err := fn(calculations); err != nil {
+				return err
+			}
+		}
+		if len(calculations) < ExportChunkSize {
+			return nil
+		}
+
+		last := calculations[len(calculations)-1]
+		query.After = &HistoryCursor{CreatedAt: last.CreatedAt.Time, ID: last.ID}
+	}
+}
+
+// HistoryPackSizes lists the pack sizes used by calculations matching the
+// query's filters, largest first
+func HistoryPackSizes(ctx context.Context, q dbsqlc.Querier, query HistoryQuery) ([]int, error) {
+	params := listCalculationsParams(query, 0)
+	sizes, err := q.ListCalculationPackSizes(ctx, dbsqlc.ListCalculationPackSizesParams{
+		MinAmount:       params.MinAmount,
+		MaxAmount:       params.MaxAmount,
+		PackSizeSet:     params.PackSizeSet,
+		CreatedFrom:     params.CreatedFrom,
+		CreatedTo:       params.CreatedTo,
+		ConfigurationID: params.ConfigurationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return PackSizesOf(sizes), nil
+}
+
+// listCalculationsParams turns a query into the parameters of one keyset query
+func listCalculationsParams(query HistoryQuery, limit int) dbsqlc.ListCalculationsParams {
+	params := dbsqlc.ListCalculationsParams{
+		MinAmount:       optionalInt8(int64(query.MinAmount), query.MinAmount != 0),
+		MaxAmount:       optionalInt8(int64(query.MaxAmount), query.MaxAmount != 0),
+		ConfigurationID: optionalInt4(query.ConfigurationID, query.ConfigurationID != 0),
+		PageSize:        int32(limit),
+	}
+	if query.After != nil {
+		params.BeforeCreatedAt = Timestamp(query.After.CreatedAt)
+		params.BeforeID = optionalInt4(query.After.ID, true)
+	}
+	if len(query.PackSizes) > 0 {
+		params.PackSizeSet = PackSizeSet(query.PackSizes)
+	}
+	if !query.From.IsZero() {
+		params.CreatedFrom = Timestamp(query.From)
+	}
+	if !query.To.IsZero() {
+		params.CreatedTo = Timestamp(query.To)
+	}
+
+	return params
+}
+
+// PackSizeSet normalizes pack sizes to the sorted, distinct set stored in
+// calculations.pack_size_set
+func PackSizeSet(packSizes []int) []int32 {
+	set := make([]int32, 0, len(packSizes))
+	for _, size := range packSizes {
+		set = append(set, int32(size))
+	}
+	slices.Sort(set)
+
+	return slices.Compact(set)
+}
+
+func optionalInt4(value int32, valid bool) pgtype.Int4 {
+	return pgtype.Int4{Int32: value, Valid: valid}
+}
+
+func optionalInt8(value int64, valid bool) pgtype.Int8 {
+	return pgtype.Int8{Int64: value, Valid: valid}
+}
@@ -0,0 +1,15 @@
+package db
+
+// Job statuses: a job goes from queued to running to succeeded or failed, and
+// back to queued when its worker shuts down before finishing it
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobDone reports whether a job reached a final status
+func JobDone(status string) bool {
+	return status == JobSucceeded || status == JobFailed
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"encoding/json"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// NewCreateOrderParams builds the order row for its totals; the line counts
+// and errors are filled in by SaveOrder
+func NewCreateOrderParams(reference string, totals domain.OrderTotals) dbsqlc.CreateCalculationOrderParams {
+	return dbsqlc.CreateCalculationOrderParams{
+		Reference:   reference,
+		TotalAmount: int64(totals.Amount),
+		TotalItems:  int64(totals.Total),
+		TotalPacks:  int64(totals.PackCount),
+		Overshoot:   int64(totals.Overshoot),
+		TotalCost:   pgtype.Int8{Int64: int64(totals.TotalCost), Valid: totals.Costed},
+	}
+}
+
+// StoredOrderTotals reads the totals of a stored order
+func StoredOrderTotals(order dbsqlc.CalculationOrder) domain.OrderTotals {
+	return domain.OrderTotals{
+		Lines:     int(order.LineCount),
+		Failed:    int(order.FailedCount),
+		Amount:    int(order.TotalAmount),
+		Total:     int(order.TotalItems),
+		Overshoot: int(order.Overshoot),
+		PackCount: int(order.TotalPacks),
+		TotalCost: int(order.TotalCost.Int64),
+		Costed:    order.TotalCost.Valid,
+	}
+}
+
+// StoredErrors reads the failed items of a stored batch or order
+func StoredErrors(errorsJson []byte) ([]ItemError, error) {
+	failed := make([]ItemError, 0)
+	if len(errorsJson) == 0 {
+		return failed, nil
+	}
+	if err := json.Unmarshal(errorsJson, &failed); err != nil {
+		return nil, err
+	}
+
+	return failed, nil
+}
@@ -1,11 +1,185 @@
 -- name: CreateCalculation :one
 INSERT INTO calculations (
-  pack_sizes, target_amount, result_json, total_items
+  pack_sizes, target_amount, result_json, total_items, stock, objective, costs, total_cost, batch_id, configuration_id, configuration_version_id, pack_size_set, mode, order_reference, sku, order_id, request
 ) VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
 )
 RETURNING *;
 
+-- name: GetCalculation :one
+SELECT * FROM calculations
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: DeleteCalculation :execrows
+UPDATE calculations
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
 -- name: ListCalculations :many
 SELECT * FROM calculations
-ORDER BY created_at DESC;
+WHERE deleted_at IS NULL
+  AND (sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::integer))
+  AND (sqlc.narg(min_amount)::bigint IS NULL OR target_amount >= sqlc.narg(min_amount)::bigint)
+  AND (sqlc.narg(max_amount)::bigint IS NULL OR target_amount <= sqlc.narg(max_amount)::bigint)
+  AND (sqlc.narg(pack_size_set)::integer[] IS NULL OR pack_size_set = sqlc.narg(pack_size_set)::integer[])
+  AND (sqlc.narg(created_from)::timestamp IS NULL OR created_at >= sqlc.narg(created_from)::timestamp)
+  AND (sqlc.narg(created_to)::timestamp IS NULL OR created_at < sqlc.narg(created_to)::timestamp)
+  AND (sqlc.narg(configuration_id)::integer IS NULL OR configuration_id = sqlc.narg(configuration_id)::integer)
+ORDER BY created_at DESC, id DESC
+LIMIT @page_size;
+
+-- name: ListCalculationPackSizes :many
+SELECT DISTINCT size::integer AS size
+FROM calculations, unnest(pack_size_set) AS size
+WHERE deleted_at IS NULL
+  AND (sqlc.narg(min_amount)::bigint IS NULL OR target_amount >= sqlc.narg(min_amount)::bigint)
+  AND (sqlc.narg(max_amount)::bigint IS NULL OR target_amount <= sqlc.narg(max_amount)::bigint)
+  AND (sqlc.narg(pack_size_set)::integer[] IS NULL OR pack_size_set = sqlc.narg(pack_size_set)::integer[])
+  AND (sqlc.narg(created_from)::timestamp IS NULL OR created_at >= sqlc.narg(created_from)::timestamp)
+  AND (sqlc.narg(created_to)::timestamp IS NULL OR created_at < sqlc.narg(created_to)::timestamp)
+  AND (sqlc.narg(configuration_id)::integer IS NULL OR configuration_id = sqlc.narg(configuration_id)::integer)
+ORDER BY size DESC;
+
+-- name: CreateCalculationBatch :one
+INSERT INTO calculation_batches (
+  item_count, failed_count, errors
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: CreateCalculationOrder :one
+INSERT INTO calculation_orders (
+  reference, line_count, failed_count, errors, total_amount, total_items, total_packs, overshoot, total_cost
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetCalculationOrder :one
+SELECT * FROM calculation_orders
+WHERE id = $1;
+
+-- name: ListOrderCalculations :many
+SELECT * FROM calculations
+WHERE order_id = @order_id::integer AND deleted_at IS NULL
+ORDER BY id;
+
+-- name: CreatePackConfiguration :one
+INSERT INTO pack_configurations (
+  name, description
+) VALUES (
+  $1, $2
+)
+RETURNING *;
+
+-- name: GetPackConfiguration :one
+SELECT * FROM pack_configurations
+WHERE id = $1;
+
+-- name: ListPackConfigurations :many
+SELECT * FROM pack_configurations
+ORDER BY name;
+
+-- name: UpdatePackConfiguration :one
+UPDATE pack_configurations
+SET name = $2, description = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeletePackConfiguration :execrows
+DELETE FROM pack_configurations
+WHERE id = $1;
+
+-- name: CreatePackConfigurationVersion :one
+INSERT INTO pack_configuration_versions (
+  configuration_id, pack_sizes, valid_from
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: ClosePackConfigurationVersion :exec
+UPDATE pack_configuration_versions
+SET valid_to = $2
+WHERE id = $1;
+
+-- name: GetLatestPackConfigurationVersion :one
+SELECT * FROM pack_configuration_versions
+WHERE configuration_id = $1
+ORDER BY valid_from DESC
+LIMIT 1;
+
+-- name: GetPackConfigurationVersionAt :one
+SELECT * FROM pack_configuration_versions
+WHERE configuration_id = @configuration_id
+  AND valid_from <= @at::timestamp
+  AND (valid_to IS NULL OR valid_to > @at::timestamp);
+
+-- name: ListPackConfigurationVersions :many
+SELECT * FROM pack_configuration_versions
+WHERE configuration_id = $1
+ORDER BY valid_from;
+
+-- name: ListPackConfigurationVersionsAt :many
+SELECT * FROM pack_configuration_versions
+WHERE valid_from <= @at::timestamp
+  AND (valid_to IS NULL OR valid_to > @at::timestamp);
+
+-- name: CreateJob :one
+INSERT INTO calculation_jobs (
+  request, total
+) VALUES (
+  $1, $2
+)
+RETURNING *;
+
+-- name: GetJob :one
+SELECT * FROM calculation_jobs
+WHERE id = $1;
+
+-- name: ClaimJob :one
+UPDATE calculation_jobs
+SET status = 'running', attempts = attempts + 1, started_at = COALESCE(started_at, NOW()),
+  locked_until = NOW() + make_interval(secs => @lease_seconds::integer)
+WHERE id = (
+  SELECT id FROM calculation_jobs
+  WHERE status = 'queued' OR (status = 'running' AND locked_until < NOW())
+  ORDER BY id
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateJobProgress :execrows
+UPDATE calculation_jobs
+SET progress = @progress, locked_until = NOW() + make_interval(secs => @lease_seconds::integer)
+WHERE id = @id AND attempts = @attempts AND status = 'running';
+
+-- name: FinishJob :execrows
+UPDATE calculation_jobs
+SET status = @status, progress = @progress, result = @result, error = @error, finished_at = NOW(), locked_until = NULL
+WHERE id = @id AND attempts = @attempts AND status = 'running';
+
+-- name: ReleaseJob :execrows
+UPDATE calculation_jobs
+SET status = 'queued', attempts = attempts - 1, locked_until = NULL
+WHERE id = @id AND attempts = @attempts AND status = 'running';
+
+-- name: GetCachedResult :one
+SELECT * FROM calculation_cache
+WHERE key = $1 AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: SaveCachedResult :exec
+INSERT INTO calculation_cache (
+  key, results, expires_at
+) VALUES (
+  @key, @results, CASE WHEN @ttl_seconds::integer > 0 THEN NOW() + make_interval(secs => @ttl_seconds::integer) END
+)
+ON CONFLICT (key) DO UPDATE
+SET results = EXCLUDED.results, created_at = NOW(), expires_at = EXCLUDED.expires_at;
+
+-- name: DeleteExpiredCachedResults :execrows
+DELETE FROM calculation_cache
+WHERE expires_at <= NOW();
@@ -1,16 +1,48 @@
 package db
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	dbsqlc "ignis/internal/adapter/db/sqlc"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Repository interface {
 	dbsqlc.Querier
+	// SaveBatch stores a batch and the calculations of its successful items in
+	// one transaction, returning the batch and one calculation ID per item (0
+	// for failed items)
+	SaveBatch(ctx context.Context, items []BatchItem) (dbsqlc.CalculationBatch, []int32, error)
+	// CreateConfiguration stores a configuration and its first version in one
+	// transaction
+	CreateConfiguration(ctx context.Context, arg dbsqlc.CreatePackConfigurationParams, version ConfigurationVersion) (dbsqlc.PackConfiguration, dbsqlc.PackConfigurationVersion, error)
+	// UpdateConfiguration renames a configuration and, when the pack sizes
+	// changed, closes its latest version where the new one starts. It returns
+	// the latest version afterwards.
+	UpdateConfiguration(ctx context.Context, arg dbsqlc.UpdatePackConfigurationParams, version ConfigurationVersion) (dbsqlc.PackConfiguration, dbsqlc.PackConfigurationVersion, error)
+	// SaveOrder stores an order and the calculations of its packed lines in
+	// one transaction like SaveBatch. The line and failed counts and errors of
+	// arg are taken from the items.
+	SaveOrder(ctx context.Context, arg dbsqlc.CreateCalculationOrderParams, items []BatchItem) (dbsqlc.CalculationOrder, []int32, error)
 	Close()
 }
 
+// BatchItem is one item of a batch to store: the calculation of a successful
+// item, or the error message of a failed one
+type BatchItem struct {
+	Calculation *dbsqlc.CreateCalculationParams
+	Error       string
+}
+
+// ItemError is a failed item as stored in the errors of a batch or an order
+type ItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
 type repository struct {
 	*dbsqlc.Queries
 	pool *pgxpool.Pool
@@ -23,6 +55,113 @@ func NewRepository(pool *pgxpool.Pool) Repository {
 	}
 }
 
+func (r *repository) SaveBatch(ctx context.Context, items []BatchItem) (dbsqlc.CalculationBatch, []int32, error) {
+	errorsJson, failed, err := batchErrors(items)
+	if err != nil {
+		return dbsqlc.CalculationBatch{}, nil, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return dbsqlc.CalculationBatch{}, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := r.Queries.WithTx(tx)
+	batch, err := q.CreateCalculationBatch(ctx, dbsqlc.CreateCalculationBatchParams{
+		ItemCount:   int32(len(items)),
+		FailedCount: int32(failed),
+		Errors:      errorsJson,
+	})
+	if err != nil {
+		return dbsqlc.CalculationBatch{}, nil, fmt.Errorf("create batch: %w", err)
+	}
+
+	ids, err := createCalculations(ctx, q, items, func(params *dbsqlc.CreateCalculationParams) {
+		params.BatchID = pgtype.Int4{Int32: batch.ID, Valid: true}
+	})
+	if err != nil {
+		return dbsqlc.CalculationBatch{}, nil, fmt.Errorf("batch: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return dbsqlc.CalculationBatch{}, nil, err
+	}
+
+	return batch, ids, nil
+}
+
+func (r *repository) SaveOrder(ctx context.Context, arg dbsqlc.CreateCalculationOrderParams, items []BatchItem) (dbsqlc.CalculationOrder, []int32, error) {
+	errorsJson, failed, err := batchErrors(items)
+	if err != nil {
+		return dbsqlc.CalculationOrder{}, nil, err
+	}
+	arg.LineCount = int32(len(items))
+	arg.FailedCount = int32(failed)
+	arg.Errors = errorsJson
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return dbsqlc.CalculationOrder{}, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := r.Queries.WithTx(tx)
+	order, err := q.CreateCalculationOrder(ctx, arg)
+	if err != nil {
+		return dbsqlc.CalculationOrder{}, nil, fmt.Errorf("create order: %w", err)
+	}
+
+	ids, err := createCalculations(ctx, q, items, func(params *dbsqlc.CreateCalculationParams) {
+		params.OrderID = pgtype.Int4{Int32: order.ID, Valid: true}
+		params.OrderReference = order.Reference
+	})
+	if err != nil {
+		return dbsqlc.CalculationOrder{}, nil, fmt.Errorf("order: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return dbsqlc.CalculationOrder{}, nil, err
+	}
+
+	return order, ids, nil
+}
+
+// batchErrors renders the failed items as stored in the errors column,
+// returning how many there are
+func batchErrors(items []BatchItem) ([]byte, int, error) {
+	failed := make([]ItemError, 0)
+	for i, item := range items {
+		if item.Calculation == nil {
+			failed = append(failed, ItemError{Index: i, Error: item.Error})
+		}
+	}
+
+	errorsJson, err := json.Marshal(failed)
+	return errorsJson, len(failed), err
+}
+
+// createCalculations stores the calculations of the successful items, linked
+// to their parent by link, and returns one ID per item (0 for failed items)
+func createCalculations(ctx context.Context, q *dbsqlc.Queries, items []BatchItem, link func(params *dbsqlc.CreateCalculationParams)) ([]int32, error) {
+	ids := make([]int32, len(items))
+	for i, item := range items {
+		if item.Calculation == nil {
+			continue
+		}
+
+		params := *item.Calculation
+		link(&params)
+		calc, err := q.CreateCalculation(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("create calculation %d: %w", i, err)
+		}
+		ids[i] = calc.ID
+	}
+
+	return ids, nil
+}
+
 func (r *repository) Close() {
 	r.pool.Close()
 }
@@ -9,10 +9,85 @@ import (
 )
 
 type Calculation struct {
-	ID           int32
-	PackSizes    string
-	TargetAmount int32
-	ResultJson   []byte
-	TotalItems   int32
-	CreatedAt    pgtype.Timestamp
+	ID                     int32
+	PackSizes              string
+	TargetAmount           int64
+	ResultJson             []byte
+	TotalItems             int64
+	CreatedAt              pgtype.Timestamp
+	Stock                  []byte
+	Objective              string
+	Costs                  []byte
+	TotalCost              pgtype.Int8
+	BatchID                pgtype.Int4
+	ConfigurationID        pgtype.Int4
+	ConfigurationVersionID pgtype.Int4
+	PackSizeSet            []int32
+	Mode                   string
+	DeletedAt              pgtype.Timestamp
+	OrderReference         string
+	Sku                    string
+	OrderID                pgtype.Int4
+	Request                []byte
+}
+
+type CalculationBatch struct {
+	ID          int32
+	ItemCount   int32
+	FailedCount int32
+	Errors      []byte
+	CreatedAt   pgtype.Timestamp
+}
+
+type CalculationCache struct {
+	Key       string
+	Results   []byte
+	CreatedAt pgtype.Timestamp
+	ExpiresAt pgtype.Timestamp
+}
+
+type CalculationJob struct {
+	ID          int32
+	Status      string
+	Request     []byte
+	Total       int32
+	Progress    int32
+	Result      []byte
+	Error       string
+	Attempts    int32
+	LockedUntil pgtype.Timestamp
+	CreatedAt   pgtype.Timestamp
+	StartedAt   pgtype.Timestamp
+	FinishedAt  pgtype.Timestamp
+}
+
+type CalculationOrder struct {
+	ID          int32
+	Reference   string
+	LineCount   int32
+	FailedCount int32
+	Errors      []byte
+	TotalAmount int64
+	TotalItems  int64
+	TotalPacks  int64
+	Overshoot   int64
+	TotalCost   pgtype.Int8
+	CreatedAt   pgtype.Timestamp
+}
+
+type PackConfiguration struct {
+	ID          int32
+	Name        string
+	Description string
+	CreatedAt   pgtype.Timestamp
+	UpdatedAt   pgtype.Timestamp
+}
+
+type PackConfigurationVersion struct {
+	ID              int32
+	ConfigurationID int32
+	PackSizes       []int32
+	ValidFrom       pgtype.Timestamp
+	ValidTo         pgtype.Timestamp
+	CreatedAt       pgtype.Timestamp
 }
@@ -6,11 +6,40 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	ClaimJob(ctx context.Context, leaseSeconds int32) (CalculationJob, error)
+	ClosePackConfigurationVersion(ctx context.Context, arg ClosePackConfigurationVersionParams) error
 	CreateCalculation(ctx context.Context, arg CreateCalculationParams) (Calculation, error)
-	ListCalculations(ctx context.Context) ([]Calculation, error)
+	CreateCalculationBatch(ctx context.Context, arg CreateCalculationBatchParams) (CalculationBatch, error)
+	CreateCalculationOrder(ctx context.Context, arg CreateCalculationOrderParams) (CalculationOrder, error)
+	CreateJob(ctx context.Context, arg CreateJobParams) (CalculationJob, error)
+	CreatePackConfiguration(ctx context.Context, arg CreatePackConfigurationParams) (PackConfiguration, error)
+	CreatePackConfigurationVersion(ctx context.Context, arg CreatePackConfigurationVersionParams) (PackConfigurationVersion, error)
+	DeleteCalculation(ctx context.Context, id int32) (int64, error)
+	DeleteExpiredCachedResults(ctx context.Context) (int64, error)
+	DeletePackConfiguration(ctx context.Context, id int32) (int64, error)
+	FinishJob(ctx context.Context, arg FinishJobParams) (int64, error)
+	GetCachedResult(ctx context.Context, key string) (CalculationCache, error)
+	GetCalculation(ctx context.Context, id int32) (Calculation, error)
+	GetCalculationOrder(ctx context.Context, id int32) (CalculationOrder, error)
+	GetJob(ctx context.Context, id int32) (CalculationJob, error)
+	GetLatestPackConfigurationVersion(ctx context.Context, configurationID int32) (PackConfigurationVersion, error)
+	GetPackConfiguration(ctx context.Context, id int32) (PackConfiguration, error)
+	GetPackConfigurationVersionAt(ctx context.Context, arg GetPackConfigurationVersionAtParams) (PackConfigurationVersion, error)
+	ListCalculationPackSizes(ctx context.Context, arg ListCalculationPackSizesParams) ([]int32, error)
+	ListCalculations(ctx context.Context, arg ListCalculationsParams) ([]Calculation, error)
+	ListOrderCalculations(ctx context.Context, orderID int32) ([]Calculation, error)
+	ListPackConfigurationVersions(ctx context.Context, configurationID int32) ([]PackConfigurationVersion, error)
+	ListPackConfigurationVersionsAt(ctx context.Context, at pgtype.Timestamp) ([]PackConfigurationVersion, error)
+	ListPackConfigurations(ctx context.Context) ([]PackConfiguration, error)
+	ReleaseJob(ctx context.Context, arg ReleaseJobParams) (int64, error)
+	SaveCachedResult(ctx context.Context, arg SaveCachedResultParams) error
+	UpdateJobProgress(ctx context.Context, arg UpdateJobProgressParams) (int64, error)
+	UpdatePackConfiguration(ctx context.Context, arg UpdatePackConfigurationParams) (PackConfiguration, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -7,22 +7,87 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimJob = `-- name: ClaimJob :one
+UPDATE calculation_jobs
+SET status = 'running', attempts = attempts + 1, started_at = COALESCE(started_at, NOW()),
+  locked_until = NOW() + make_interval(secs => $1::integer)
+WHERE id = (
+  SELECT id FROM calculation_jobs
+  WHERE status = 'queued' OR (status = 'running' AND locked_until < NOW())
+  ORDER BY id
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
 )
+RETURNING id, status, request, total, progress, result, error, attempts, locked_until, created_at, started_at, finished_at
+`
+
+func (q *Queries) ClaimJob(ctx context.Context, leaseSeconds int32) (CalculationJob, error) {
+	row := q.db.QueryRow(ctx, claimJob, leaseSeconds)
+	var i CalculationJob
+	err := row.Scan(
+		&i.ID,
+		&i.Status,
+		&i.Request,
+		&i.Total,
+		&i.Progress,
+		&i.Result,
+		&i.Error,
+		&i.Attempts,
+		&i.LockedUntil,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const closePackConfigurationVersion = `-- name: ClosePackConfigurationVersion :exec
+UPDATE pack_configuration_versions
+SET valid_to = $2
+WHERE id = $1
+`
+
+type ClosePackConfigurationVersionParams struct {
+	ID      int32
+	ValidTo pgtype.Timestamp
+}
+
+func (q *Queries) ClosePackConfigurationVersion(ctx context.Context, arg ClosePackConfigurationVersionParams) error {
+	_, err := q.db.Exec(ctx, closePackConfigurationVersion, arg.ID, arg.ValidTo)
+	return err
+}
 
 const createCalculation = `-- name: CreateCalculation :one
 INSERT INTO calculations (
-  pack_sizes, target_amount, result_json, total_items
+  pack_sizes, target_amount, result_json, total_items, stock, objective, costs, total_cost, batch_id, configuration_id, configuration_version_id, pack_size_set, mode, order_reference, sku, order_id, request
 ) VALUES (
-  $1, $2, $3, $4
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
 )
-RETURNING id, pack_sizes, target_amount, result_json, total_items, created_at
+RETURNING id, pack_sizes, target_amount, result_json, total_items, created_at, stock, objective, costs, total_cost, batch_id, configuration_id, configuration_version_id, pack_size_set, mode, deleted_at, order_reference, sku, order_id, request
 `
 
 type CreateCalculationParams struct {
-	PackSizes    string
-	TargetAmount int32
-	ResultJson   []byte
-	TotalItems   int32
+	PackSizes              string
+	TargetAmount           int64
+	ResultJson             []byte
+	TotalItems             int64
+	Stock                  []byte
+	Objective              string
+	Costs                  []byte
+	TotalCost              pgtype.Int8
+	BatchID                pgtype.Int4
+	ConfigurationID        pgtype.Int4
+	ConfigurationVersionID pgtype.Int4
+	PackSizeSet            []int32
+	Mode                   string
+	OrderReference         string
+	Sku                    string
+	OrderID                pgtype.Int4
+	Request                []byte
 }
 
 func (q *Queries) CreateCalculation(ctx context.Context, arg CreateCalculationParams) (Calculation, error) {
@@ -31,7 +96,308 @@ func (q *Queries) CreateCalculation(ctx context.Context, arg CreateCalculationPa
 		arg.TargetAmount,
 		arg.ResultJson,
 		arg.TotalItems,
+		arg.Stock,
+		arg.Objective,
+		arg.Costs,
+		arg.TotalCost,
+		arg.BatchID,
+		arg.ConfigurationID,
+		arg.ConfigurationVersionID,
+		arg.PackSizeSet,
+		arg.Mode,
+		arg.OrderReference,
+		arg.Sku,
+		arg.OrderID,
+		arg.Request,
+	)
+	var i Calculation
+	err := row.Scan(
+		&i.ID,
+		&i.PackSizes,
+		&i.TargetAmount,
+		&i.ResultJson,
+		&i.TotalItems,
+		&i.CreatedAt,
+		&i.Stock,
+		&i.Objective,
+		&i.Costs,
+		&i.TotalCost,
+		&i.BatchID,
+		&i.ConfigurationID,
+		&i.ConfigurationVersionID,
+		&i.PackSizeSet,
+		&i.Mode,
+		&i.DeletedAt,
+		&i.OrderReference,
+		&i.Sku,
+		&i.OrderID,
+		&i.Request,
+	)
+	return i, err
+}
+
+const createCalculationBatch = `-- name: CreateCalculationBatch :one
+INSERT INTO calculation_batches (
+  item_count, failed_count, errors
+) VALUES (
+  $1, $2, $3
+)
+RETURNING id, item_count, failed_count, errors, created_at
+`
+
+type CreateCalculationBatchParams struct {
+	ItemCount   int32
+	FailedCount int32
+	Errors      []byte
+}
+
+func (q *Queries) CreateCalculationBatch(ctx context.Context, arg CreateCalculationBatchParams) (CalculationBatch, error) {
+	row := q.db.QueryRow(ctx, createCalculationBatch, arg.ItemCount, arg.FailedCount, arg.Errors)
+	var i CalculationBatch
+	err := row.Scan(
+		&i.ID,
+		&i.ItemCount,
+		&i.FailedCount,
+		&i.Errors,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createCalculationOrder = `-- name: CreateCalculationOrder :one
+INSERT INTO calculation_orders (
+  reference, line_count, failed_count, errors, total_amount, total_items, total_packs, overshoot, total_cost
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING id, reference, line_count, failed_count, errors, total_amount, total_items, total_packs, overshoot, total_cost, created_at
+`
+
+type CreateCalculationOrderParams struct {
+	Reference   string
+	LineCount   int32
+	FailedCount int32
+	Errors      []byte
+	TotalAmount int64
+	TotalItems  int64
+	TotalPacks  int64
+	Overshoot   int64
+	TotalCost   pgtype.Int8
+}
+
+func (q *Queries) CreateCalculationOrder(ctx context.Context, arg CreateCalculationOrderParams) (CalculationOrder, error) {
+	row := q.db.QueryRow(ctx, createCalculationOrder,
+		arg.Reference,
+		arg.LineCount,
+		arg.FailedCount,
+		arg.Errors,
+		arg.TotalAmount,
+		arg.TotalItems,
+		arg.TotalPacks,
+		arg.Overshoot,
+		arg.TotalCost,
+	)
+	var i CalculationOrder
+	err := row.Scan(
+		&i.ID,
+		&i.Reference,
+		&i.LineCount,
+		&i.FailedCount,
+		&i.Errors,
+		&i.TotalAmount,
+		&i.TotalItems,
+		&i.TotalPacks,
+		&i.Overshoot,
+		&i.TotalCost,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createJob = `-- name: CreateJob :one
+INSERT INTO calculation_jobs (
+  request, total
+) VALUES (
+  $1, $2
+)
+RETURNING id, status, request, total, progress, result, error, attempts, locked_until, created_at, started_at, finished_at
+`
+
+type CreateJobParams struct {
+	Request []byte
+	Total   int32
+}
+
+func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (CalculationJob, error) {
+	row := q.db.QueryRow(ctx, createJob, arg.Request, arg.Total)
+	var i CalculationJob
+	err := row.Scan(
+		&i.ID,
+		&i.Status,
+		&i.Request,
+		&i.Total,
+		&i.Progress,
+		&i.Result,
+		&i.Error,
+		&i.Attempts,
+		&i.LockedUntil,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const createPackConfiguration = `-- name: CreatePackConfiguration :one
+INSERT INTO pack_configurations (
+  name, description
+) VALUES (
+  $1, $2
+)
+RETURNING id, name, description, created_at, updated_at
+`
+
+type CreatePackConfigurationParams struct {
+	Name        string
+	Description string
+}
+
+func (q *Queries) CreatePackConfiguration(ctx context.Context, arg CreatePackConfigurationParams) (PackConfiguration, error) {
+	row := q.db.QueryRow(ctx, createPackConfiguration, arg.Name, arg.Description)
+	var i PackConfiguration
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createPackConfigurationVersion = `-- name: CreatePackConfigurationVersion :one
+INSERT INTO pack_configuration_versions (
+  configuration_id, pack_sizes, valid_from
+) VALUES (
+  $1, $2, $3
+)
+RETURNING id, configuration_id, pack_sizes, valid_from, valid_to, created_at
+`
+
+type CreatePackConfigurationVersionParams struct {
+	ConfigurationID int32
+	PackSizes       []int32
+	ValidFrom       pgtype.Timestamp
+}
+
+func (q *Queries) CreatePackConfigurationVersion(ctx context.Context, arg CreatePackConfigurationVersionParams) (PackConfigurationVersion, error) {
+	row := q.db.QueryRow(ctx, createPackConfigurationVersion, arg.ConfigurationID, arg.PackSizes, arg.ValidFrom)
+	var i PackConfigurationVersion
+	err := row.Scan(
+		&i.ID,
+		&i.ConfigurationID,
+		&i.PackSizes,
+		&i.ValidFrom,
+		&i.ValidTo,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteCalculation = `-- name: DeleteCalculation :execrows
+UPDATE calculations
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) DeleteCalculation(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteCalculation, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredCachedResults = `-- name: DeleteExpiredCachedResults :execrows
+DELETE FROM calculation_cache
+WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredCachedResults(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredCachedResults)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePackConfiguration = `-- name: DeletePackConfiguration :execrows
+DELETE FROM pack_configurations
+WHERE id = $1
+`
+
+func (q *Queries) DeletePackConfiguration(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePackConfiguration, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const finishJob = `-- name: FinishJob :execrows
+UPDATE calculation_jobs
+SET status = $1, progress = $2, result = $3, error = $4, finished_at = NOW(), locked_until = NULL
+WHERE id = $5 AND attempts = $6 AND status = 'running'
+`
+
+type FinishJobParams struct {
+	Status   string
+	Progress int32
+	Result   []byte
+	Error    string
+	ID       int32
+	Attempts int32
+}
+
+func (q *Queries) FinishJob(ctx context.Context, arg FinishJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, finishJob,
+		arg.Status,
+		arg.Progress,
+		arg.Result,
+		arg.Error,
+		arg.ID,
+		arg.Attempts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getCachedResult = `-- name: GetCachedResult :one
+SELECT key, results, created_at, expires_at FROM calculation_cache
+WHERE key = $1 AND (expires_at IS NULL OR expires_at > NOW())
+`
+
+func (q *Queries) GetCachedResult(ctx context.Context, key string) (CalculationCache, error) {
+	row := q.db.QueryRow(ctx, getCachedResult, key)
+	var i CalculationCache
+	err := row.Scan(
+		&i.Key,
+		&i.Results,
+		&i.CreatedAt,
+		&i.ExpiresAt,
 	)
+	return i, err
+}
+
+const getCalculation = `-- name: GetCalculation :one
+SELECT id, pack_sizes, target_amount, result_json, total_items, created_at, stock, objective, costs, total_cost, batch_id, configuration_id, configuration_version_id, pack_size_set, mode, deleted_at, order_reference, sku, order_id, request FROM calculations
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetCalculation(ctx context.Context, id int32) (Calculation, error) {
+	row := q.db.QueryRow(ctx, getCalculation, id)
 	var i Calculation
 	err := row.Scan(
 		&i.ID,
@@ -40,17 +406,273 @@ func (q *Queries) CreateCalculation(ctx context.Context, arg CreateCalculationPa
 		&i.ResultJson,
 		&i.TotalItems,
 		&i.CreatedAt,
+		&i.Stock,
+		&i.Objective,
+		&i.Costs,
+		&i.TotalCost,
+		&i.BatchID,
+		&i.ConfigurationID,
+		&i.ConfigurationVersionID,
+		&i.PackSizeSet,
+		&i.Mode,
+		&i.DeletedAt,
+		&i.OrderReference,
+		&i.Sku,
+		&i.OrderID,
+		&i.Request,
+	)
+	return i, err
+}
+
+const getCalculationOrder = `-- name: GetCalculationOrder :one
+SELECT id, reference, line_count, failed_count, errors, total_amount, total_items, total_packs, overshoot, total_cost, created_at FROM calculation_orders
+WHERE id = $1
+`
+
+func (q *Queries) GetCalculationOrder(ctx context.Context, id int32) (CalculationOrder, error) {
+	row := q.db.QueryRow(ctx, getCalculationOrder, id)
+	var i CalculationOrder
+	err := row.Scan(
+		&i.ID,
+		&i.Reference,
+		&i.LineCount,
+		&i.FailedCount,
+		&i.Errors,
+		&i.TotalAmount,
+		&i.TotalItems,
+		&i.TotalPacks,
+		&i.Overshoot,
+		&i.TotalCost,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getJob = `-- name: GetJob :one
+SELECT id, status, request, total, progress, result, error, attempts, locked_until, created_at, started_at, finished_at FROM calculation_jobs
+WHERE id = $1
+`
+
+func (q *Queries) GetJob(ctx context.Context, id int32) (CalculationJob, error) {
+	row := q.db.QueryRow(ctx, getJob, id)
+	var i CalculationJob
+	err := row.Scan(
+		&i.ID,
+		&i.Status,
+		&i.Request,
+		&i.Total,
+		&i.Progress,
+		&i.Result,
+		&i.Error,
+		&i.Attempts,
+		&i.LockedUntil,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
 	)
 	return i, err
 }
 
+const getLatestPackConfigurationVersion = `-- name: GetLatestPackConfigurationVersion :one
+SELECT id, configuration_id, pack_sizes, valid_from, valid_to, created_at FROM pack_configuration_versions
+WHERE configuration_id = $1
+ORDER BY valid_from DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestPackConfigurationVersion(ctx context.Context, configurationID int32) (PackConfigurationVersion, error) {
+	row := q.db.QueryRow(ctx, getLatestPackConfigurationVersion, configurationID)
+	var i PackConfigurationVersion
+	err := row.Scan(
+		&i.ID,
+		&i.ConfigurationID,
+		&i.PackSizes,
+		&i.ValidFrom,
+		&i.ValidTo,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPackConfiguration = `-- name: GetPackConfiguration :one
+SELECT id, name, description, created_at, updated_at FROM pack_configurations
+WHERE id = $1
+`
+
+func (q *Queries) GetPackConfiguration(ctx context.Context, id int32) (PackConfiguration, error) {
+	row := q.db.QueryRow(ctx, getPackConfiguration, id)
+	var i PackConfiguration
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getPackConfigurationVersionAt = `-- name: GetPackConfigurationVersionAt :one
+SELECT id, configuration_id, pack_sizes, valid_from, valid_to, created_at FROM pack_configuration_versions
+WHERE configuration_id = $1
+  AND valid_from <= $2::timestamp
+  AND (valid_to IS NULL OR valid_to > $2::timestamp)
+`
+
+type GetPackConfigurationVersionAtParams struct {
+	ConfigurationID int32
+	At              pgtype.Timestamp
+}
+
+func (q *Queries) GetPackConfigurationVersionAt(ctx context.Context, arg GetPackConfigurationVersionAtParams) (PackConfigurationVersion, error) {
+	row := q.db.QueryRow(ctx, getPackConfigurationVersionAt, arg.ConfigurationID, arg.At)
+	var i PackConfigurationVersion
+	err := row.Scan(
+		&i.ID,
+		&i.ConfigurationID,
+		&i.PackSizes,
+		&i.ValidFrom,
+		&i.ValidTo,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listCalculationPackSizes = `-- name: ListCalculationPackSizes :many
+SELECT DISTINCT size::integer AS size
+FROM calculations, unnest(pack_size_set) AS size
+WHERE deleted_at IS NULL
+  AND ($1::bigint IS NULL OR target_amount >= $1::bigint)
+  AND ($2::bigint IS NULL OR target_amount <= $2::bigint)
+  AND ($3::integer[] IS NULL OR pack_size_set = $3::integer[])
+  AND ($4::timestamp IS NULL OR created_at >= $4::timestamp)
+  AND ($5::timestamp IS NULL OR created_at < $5::timestamp)
+  AND ($6::integer IS NULL OR configuration_id = $6::integer)
+ORDER BY size DESC
+`
+
+type ListCalculationPackSizesParams struct {
+	MinAmount       pgtype.Int8
+	MaxAmount       pgtype.Int8
+	PackSizeSet     []int32
+	CreatedFrom     pgtype.Timestamp
+	CreatedTo       pgtype.Timestamp
+	ConfigurationID pgtype.Int4
+}
+
+func (q *Queries) ListCalculationPackSizes(ctx context.Context, arg ListCalculationPackSizesParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listCalculationPackSizes,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.PackSizeSet,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.ConfigurationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var size int32
+		if err := rows.Scan(&size); err != nil {
+			return nil, err
+		}
+		items = append(items, size)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listCalculations = `-- name: ListCalculations :many
-SELECT id, pack_sizes, target_amount, result_json, total_items, created_at FROM calculations
-ORDER BY created_at DESC
+SELECT id, pack_sizes, target_amount, result_json, total_items, created_at, stock, objective, costs, total_cost, batch_id, configuration_id, configuration_version_id, pack_size_set, mode, deleted_at, order_reference, sku, order_id, request FROM calculations
+WHERE deleted_at IS NULL
+  AND ($1::timestamp IS NULL
+    OR (created_at, id) < ($1::timestamp, $2::integer))
+  AND ($3::bigint IS NULL OR target_amount >= $3::bigint)
+  AND ($4::bigint IS NULL OR target_amount <= $4::bigint)
+  AND ($5::integer[] IS NULL OR pack_size_set = $5::integer[])
+  AND ($6::timestamp IS NULL OR created_at >= $6::timestamp)
+  AND ($7::timestamp IS NULL OR created_at < $7::timestamp)
+  AND ($8::integer IS NULL OR configuration_id = $8::integer)
+ORDER BY created_at DESC, id DESC
+LIMIT $9
+`
+
+type ListCalculationsParams struct {
+	BeforeCreatedAt pgtype.Timestamp
+	BeforeID        pgtype.Int4
+	MinAmount       pgtype.Int8
+	MaxAmount       pgtype.Int8
+	PackSizeSet     []int32
+	CreatedFrom     pgtype.Timestamp
+	CreatedTo       pgtype.Timestamp
+	ConfigurationID pgtype.Int4
+	PageSize        int32
+}
+
+func (q *Queries) ListCalculations(ctx context.Context, arg ListCalculationsParams) ([]Calculation, error) {
+	rows, err := q.db.Query(ctx, listCalculations,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.PackSizeSet,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.ConfigurationID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Calculation
+	for rows.Next() {
+		var i Calculation
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackSizes,
+			&i.TargetAmount,
+			&i.ResultJson,
+			&i.TotalItems,
+			&i.CreatedAt,
+			&i.Stock,
+			&i.Objective,
+			&i.Costs,
+			&i.TotalCost,
+			&i.BatchID,
+			&i.ConfigurationID,
+			&i.ConfigurationVersionID,
+			&i.PackSizeSet,
+			&i.Mode,
+			&i.DeletedAt,
+			&i.OrderReference,
+			&i.Sku,
+			&i.OrderID,
+			&i.Request,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrderCalculations = `-- name: ListOrderCalculations :many
+SELECT id, pack_sizes, target_amount, result_json, total_items, created_at, stock, objective, costs, total_cost, batch_id, configuration_id, configuration_version_id, pack_size_set, mode, deleted_at, order_reference, sku, order_id, request FROM calculations
+WHERE order_id = $1::integer AND deleted_at IS NULL
+ORDER BY id
 `
 
-func (q *Queries) ListCalculations(ctx context.Context) ([]Calculation, error) {
-	rows, err := q.db.Query(ctx, listCalculations)
+func (q *Queries) ListOrderCalculations(ctx context.Context, orderID int32) ([]Calculation, error) {
+	rows, err := q.db.Query(ctx, listOrderCalculations, orderID)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +687,20 @@ func (q *Queries) ListCalculations(ctx context.Context) ([]Calculation, error) {
 			&i.ResultJson,
 			&i.TotalItems,
 			&i.CreatedAt,
+			&i.Stock,
+			&i.Objective,
+			&i.Costs,
+			&i.TotalCost,
+			&i.BatchID,
+			&i.ConfigurationID,
+			&i.ConfigurationVersionID,
+			&i.PackSizeSet,
+			&i.Mode,
+			&i.DeletedAt,
+			&i.OrderReference,
+			&i.Sku,
+			&i.OrderID,
+			&i.Request,
 		); err != nil {
 			return nil, err
 		}
@@ -75,3 +711,192 @@ func (q *Queries) ListCalculations(ctx context.Context) ([]Calculation, error) {
 	}
 	return items, nil
 }
+
+const listPackConfigurationVersions = `-- name: ListPackConfigurationVersions :many
+SELECT id, configuration_id, pack_sizes, valid_from, valid_to, created_at FROM pack_configuration_versions
+WHERE configuration_id = $1
+ORDER BY valid_from
+`
+
+func (q *Queries) ListPackConfigurationVersions(ctx context.Context, configurationID int32) ([]PackConfigurationVersion, error) {
+	rows, err := q.db.Query(ctx, listPackConfigurationVersions, configurationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackConfigurationVersion
+	for rows.Next() {
+		var i PackConfigurationVersion
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConfigurationID,
+			&i.PackSizes,
+			&i.ValidFrom,
+			&i.ValidTo,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackConfigurationVersionsAt = `-- name: ListPackConfigurationVersionsAt :many
+SELECT id, configuration_id, pack_sizes, valid_from, valid_to, created_at FROM pack_configuration_versions
+WHERE valid_from <= $1::timestamp
+  AND (valid_to IS NULL OR valid_to > $1::timestamp)
+`
+
+func (q *Queries) ListPackConfigurationVersionsAt(ctx context.Context, at pgtype.Timestamp) ([]PackConfigurationVersion, error) {
+	rows, err := q.db.Query(ctx, listPackConfigurationVersionsAt, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackConfigurationVersion
+	for rows.Next() {
+		var i PackConfigurationVersion
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConfigurationID,
+			&i.PackSizes,
+			&i.ValidFrom,
+			&i.ValidTo,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackConfigurations = `-- name: ListPackConfigurations :many
+SELECT id, name, description, created_at, updated_at FROM pack_configurations
+ORDER BY name
+`
+
+func (q *Queries) ListPackConfigurations(ctx context.Context) ([]PackConfiguration, error) {
+	rows, err := q.db.Query(ctx, listPackConfigurations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackConfiguration
+	for rows.Next() {
+		var i PackConfiguration
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const releaseJob = `-- name: ReleaseJob :execrows
+UPDATE calculation_jobs
+SET status = 'queued', attempts = attempts - 1, locked_until = NULL
+WHERE id = $1 AND attempts = $2 AND status = 'running'
+`
+
+type ReleaseJobParams struct {
+	ID       int32
+	Attempts int32
+}
+
+func (q *Queries) ReleaseJob(ctx context.Context, arg ReleaseJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, releaseJob, arg.ID, arg.Attempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const saveCachedResult = `-- name: SaveCachedResult :exec
+INSERT INTO calculation_cache (
+  key, results, expires_at
+) VALUES (
+  $1, $2, CASE WHEN $3::integer > 0 THEN NOW() + make_interval(secs => $3::integer) END
+)
+ON CONFLICT (key) DO UPDATE
+SET results = EXCLUDED.results, created_at = NOW(), expires_at = EXCLUDED.expires_at
+`
+
+type SaveCachedResultParams struct {
+	Key        string
+	Results    []byte
+	TtlSeconds int32
+}
+
+func (q *Queries) SaveCachedResult(ctx context.Context, arg SaveCachedResultParams) error {
+	_, err := q.db.Exec(ctx, saveCachedResult, arg.Key, arg.Results, arg.TtlSeconds)
+	return err
+}
+
+const updateJobProgress = `-- name: UpdateJobProgress :execrows
+UPDATE calculation_jobs
+SET progress = $1, locked_until = NOW() + make_interval(secs => $2::integer)
+WHERE id = $3 AND attempts = $4 AND status = 'running'
+`
+
+type UpdateJobProgressParams struct {
+	Progress     int32
+	LeaseSeconds int32
+	ID           int32
+	Attempts     int32
+}
+
+func (q *Queries) UpdateJobProgress(ctx context.Context, arg UpdateJobProgressParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateJobProgress,
+		arg.Progress,
+		arg.LeaseSeconds,
+		arg.ID,
+		arg.Attempts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updatePackConfiguration = `-- name: UpdatePackConfiguration :one
+UPDATE pack_configurations
+SET name = $2, description = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, name, description, created_at, updated_at
+`
+
+type UpdatePackConfigurationParams struct {
+	ID          int32
+	Name        string
+	Description string
+}
+
+func (q *Queries) UpdatePackConfiguration(ctx context.Context, arg UpdatePackConfigurationParams) (PackConfiguration, error) {
+	row := q.db.QueryRow(ctx, updatePackConfiguration, arg.ID, arg.Name, arg.Description)
+	var i PackConfiguration
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,417 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	calculatorv1 "ignis/api/calculator/v1"
+	"ignis/internal/adapter/db"
+	"ignis/internal/domain"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxAlternatives caps how many ranked plans a single request may ask for
+const maxAlternatives = 10
+
+// maxBatchSize caps the number of requests in a single BatchCalculate call
+const maxBatchSize = 10000
+
+type CalculatorServer struct {
+	calculatorv1.UnimplementedCalculatorServiceServer
+	calculator domain.PackageCalculator
+	repo       db.Repository
+}
+
+func NewCalculatorServer(calculator domain.PackageCalculator, repo db.Repository) *CalculatorServer {
+	return &CalculatorServer{
+		calculator: calculator,
+		repo:       repo,
+	}
+}
+
+func (s *CalculatorServer) Calculate(ctx context.Context, req *calculatorv1.CalculateRequest) (*calculatorv1.CalculateResponse, error) {
+	return s.calculate(ctx, req)
+}
+
+// BatchCalculate answers single-plan items through one CalculateBatch call, so
+// items sharing a pack-size set share the DP tables, and stores the batch as a unit
+func (s *CalculatorServer) BatchCalculate(ctx context.Context, req *calculatorv1.BatchCalculateRequest) (*calculatorv1.BatchCalculateResponse, error) {
+	if len(req.GetRequests()) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d requests exceeds the limit of %d", len(req.GetRequests()), maxBatchSize)
+	}
+
+	outcomes := make([]batchOutcome, len(req.GetRequests()))
+	var batchReqs []domain.CalculateRequest
+	var batchIndexes []int
+	for i, item := range req.GetRequests() {
+		calcReq, plansCount, err := parseRequest(item)
+		outcomes[i] = batchOutcome{req: calcReq, err: err}
+		if err != nil {
+			continue
+		}
+
+		// Ranked alternatives come from CalculateTopK one item at a time
+		if plansCount > 1 {
+			outcomes[i].plans, outcomes[i].err = s.calculator.CalculateTopK(ctx, calcReq, plansCount)
+			if ctx.Err() != nil {
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			continue
+		}
+		batchReqs = append(batchReqs, calcReq)
+		batchIndexes = append(batchIndexes, i)
+	}
+
+	results, err := s.calculator.CalculateBatch(ctx, batchReqs)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	for j, result := range results {
+		i := batchIndexes[j]
+		outcomes[i].err = result.Err
+		if result.Result != nil {
+			outcomes[i].plans = []*domain.CalculateResult{result.Result}
+		}
+	}
+
+	for i := range outcomes {
+		if outcomes[i].err != nil {
+			outcomes[i].err = toStatusError(outcomes[i].err)
+		}
+	}
+	ids := s.saveBatch(ctx, outcomes)
+
+	resp := &calculatorv1.BatchCalculateResponse{
+		Results: make([]*calculatorv1.BatchCalculateResult, 0, len(outcomes)),
+	}
+	for i, outcome := range outcomes {
+		if outcome.err != nil {
+			st := status.Convert(outcome.err)
+			resp.Results = append(resp.Results, &calculatorv1.BatchCalculateResult{
+				Outcome: &calculatorv1.BatchCalculateResult_Error{
+					Error: &calculatorv1.Error{Code: uint32(st.Code()), Message: st.Message()},
+				},
+			})
+			continue
+		}
+
+		itemResp := toResponse(outcome.req.Amount, outcome.plans)
+		itemResp.Id = ids[i]
+		resp.Results = append(resp.Results, &calculatorv1.BatchCalculateResult{
+			Outcome: &calculatorv1.BatchCalculateResult_Response{Response: itemResp},
+		})
+	}
+
+	return resp, nil
+}
+
+// batchOutcome is an item of a batch with its plans or its error, which carries
+// a gRPC status once the batch is calculated
+type batchOutcome struct {
+	req   domain.CalculateRequest
+	plans []*domain.CalculateResult
+	err   error
+}
+
+// saveBatch stores the batch and returns one calculation ID per item, all 0 when
+// there is no repository or saving failed
+func (s *CalculatorServer) saveBatch(ctx context.Context, outcomes []batchOutcome) []int32 {
+	ids := make([]int32, len(outcomes))
+	if s.repo == nil || len(outcomes) == 0 {
+		return ids
+	}
+
+	items := make([]db.BatchItem, len(outcomes))
+	for i, outcome := range outcomes {
+		if outcome.err != nil {
+			items[i].Error = status.Convert(outcome.err).Message()
+			continue
+		}
+		params := db.NewCreateCalculationParams(db.FormatPackSizes(outcome.req.PackSizes), outcome.req, outcome.plans[0])
+		items[i].Calculation = &params
+	}
+
+	_, saved, err := s.repo.SaveBatch(ctx, items)
+	if err != nil {
+		log.Printf("failed to save batch: %v\n", err)
+		return ids
+	}
+
+	return saved
+}
+
+func (s *CalculatorServer) ListHistory(ctx context.Context, req *calculatorv1.ListHistoryRequest) (*calculatorv1.ListHistoryResponse, error) {
+	if s.repo == nil {
+		return nil, status.Error(codes.Unavailable, "history is not available")
+	}
+
+	query, err := toHistoryQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	calculations, next, err := db.ListHistory(ctx, s.repo, query)
+	if err != nil {
+		log.Printf("failed to load history: %v\n", err)
+		return nil, status.Error(codes.Internal, "failed to load history")
+	}
+
+	resp := &calculatorv1.ListHistoryResponse{
+		Calculations: make([]*calculatorv1.Calculation, 0, len(calculations)),
+	}
+	if next != nil {
+		resp.NextPageToken = next.Encode()
+	}
+	for _, calc := range calculations {
+		entry := &calculatorv1.Calculation{
+			Id:        calc.ID,
+			CreatedAt: timestamppb.New(calc.CreatedAt.Time),
+			PackSizes: toInt64s(db.ParsePackSizes(calc.PackSizes)),
+			Amount:    int64(calc.TargetAmount),
+			Total:     int64(calc.TotalItems),
+			Packages:  storedSizeMap(calc.ResultJson),
+			Stock:     storedSizeMap(calc.Stock),
+			Objective: calc.Objective,
+			Costs:     storedSizeMap(calc.Costs),
+		}
+		if calc.TotalCost.Valid {
+			entry.TotalCost = &calc.TotalCost.Int64
+		}
+		resp.Calculations = append(resp.Calculations, entry)
+	}
+
+	return resp, nil
+}
+
+// toHistoryQuery validates the paging and filters of a ListHistory request
+func toHistoryQuery(req *calculatorv1.ListHistoryRequest) (db.HistoryQuery, error) {
+	query := db.HistoryQuery{
+		PageSize:        int(req.GetPageSize()),
+		MinAmount:       int(req.GetMinAmount()),
+		MaxAmount:       int(req.GetMaxAmount()),
+		PackSizes:       toInts(req.GetPackSizes()),
+		ConfigurationID: req.GetConfigurationId(),
+	}
+	if req.GetPageSize() < 0 || req.GetPageSize() > db.MaxHistoryPageSize {
+		return query, status.Errorf(codes.InvalidArgument, "invalid page size: %d (1-%d)", req.GetPageSize(), db.MaxHistoryPageSize)
+	}
+	if req.GetPageToken() != "" {
+		cursor, err := db.DecodeHistoryCursor(req.GetPageToken())
+		if err != nil {
+			return query, status.Errorf(codes.InvalidArgument, "invalid page token: %s", err.Error())
+		}
+		query.After = &cursor
+	}
+	if req.MinAmount != nil && req.GetMinAmount() <= 0 || req.MaxAmount != nil && req.GetMaxAmount() <= 0 {
+		return query, status.Error(codes.InvalidArgument, "invalid amount range: amounts must be positive")
+	}
+	for _, size := range req.GetPackSizes() {
+		if size <= 0 {
+			return query, status.Errorf(codes.InvalidArgument, "invalid pack size: %d", size)
+		}
+	}
+	if req.GetCreatedFrom() != nil {
+		query.From = req.GetCreatedFrom().AsTime()
+	}
+	if req.GetCreatedTo() != nil {
+		query.To = req.GetCreatedTo().AsTime()
+	}
+
+	return query, nil
+}
+
+// calculate runs and stores a single request, errors carry a gRPC status
+func (s *CalculatorServer) calculate(ctx context.Context, req *calculatorv1.CalculateRequest) (*calculatorv1.CalculateResponse, error) {
+	calcReq, plansCount, err := parseRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []*domain.CalculateResult
+	if plansCount == 1 {
+		var result *domain.CalculateResult
+		result, err = s.calculator.Calculate(ctx, calcReq)
+		plans = []*domain.CalculateResult{result}
+	} else {
+		plans, err = s.calculator.CalculateTopK(ctx, calcReq, plansCount)
+	}
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	resp := toResponse(calcReq.Amount, plans)
+	if s.repo != nil {
+		calc, err := s.repo.CreateCalculation(ctx, db.NewCreateCalculationParams(db.FormatPackSizes(calcReq.PackSizes), calcReq, plans[0]))
+		if err != nil {
+			log.Printf("failed to save calculation: %v\n", err)
+		} else {
+			resp.Id = calc.ID
+		}
+	}
+
+	return resp, nil
+}
+
+// parseRequest converts a request and its number of plans, errors carry a gRPC status
+func parseRequest(req *calculatorv1.CalculateRequest) (domain.CalculateRequest, int, error) {
+	calcReq, err := toDomainRequest(req)
+	if err != nil {
+		return calcReq, 0, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	plansCount := int(req.GetPlans())
+	if plansCount == 0 {
+		plansCount = 1
+	}
+	if plansCount < 1 || plansCount > maxAlternatives {
+		return calcReq, 0, status.Errorf(codes.InvalidArgument, "invalid number of plans: %d (1-%d)", req.GetPlans(), maxAlternatives)
+	}
+
+	return calcReq, plansCount, nil
+}
+
+func toResponse(amount int, plans []*domain.CalculateResult) *calculatorv1.CalculateResponse {
+	resp := &calculatorv1.CalculateResponse{
+		Amount: int64(amount),
+		Plan:   toPlan(plans[0]),
+	}
+	for _, plan := range plans[1:] {
+		resp.Alternatives = append(resp.Alternatives, toPlan(plan))
+	}
+
+	return resp
+}
+
+// toStatusError maps calculator errors to gRPC codes, like errorStatus does for HTTP.
+// Errors that already carry a status pass through.
+func toStatusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var limitErr *domain.LimitError
+	switch {
+	case errors.Is(err, domain.ErrEmptyPackSizes),
+		errors.Is(err, domain.ErrInvalidPackSize),
+		errors.Is(err, domain.ErrInvalidAmount),
+		errors.Is(err, domain.ErrInvalidStock),
+		errors.Is(err, domain.ErrInvalidCost),
+		errors.Is(err, domain.ErrInvalidConstraint),
+		errors.Is(err, domain.ErrInvalidPackaging),
+		errors.Is(err, domain.ErrInvalidShipment),
+		errors.Is(err, domain.ErrInvalidPlanCount):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &limitErr):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, domain.ErrNoCombination), errors.Is(err, domain.ErrOversizedPack):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		log.Printf("calculation failed: %v\n", err)
+		return status.Error(codes.Internal, "calculation failed, please try again later")
+	}
+}
+
+func toDomainRequest(req *calculatorv1.CalculateRequest) (domain.CalculateRequest, error) {
+	calcReq := domain.CalculateRequest{
+		PackSizes: toInts(req.GetPackSizes()),
+		Amount:    int(req.GetAmount()),
+		Stock:     toIntMap(req.GetStock()),
+		Costs:     toIntMap(req.GetCosts()),
+	}
+
+	switch req.GetMode() {
+	case calculatorv1.Mode_MODE_UNSPECIFIED, calculatorv1.Mode_MODE_EXACT:
+		calcReq.Mode = domain.ModeExact
+	case calculatorv1.Mode_MODE_OVERFILL:
+		calcReq.Mode = domain.ModeOverfill
+	default:
+		return calcReq, errors.New("unknown mode")
+	}
+
+	switch req.GetObjective() {
+	case calculatorv1.Objective_OBJECTIVE_UNSPECIFIED, calculatorv1.Objective_OBJECTIVE_FEWEST_PACKS:
+		calcReq.Objective = domain.ObjectiveFewestPacks
+	case calculatorv1.Objective_OBJECTIVE_LOWEST_COST:
+		calcReq.Objective = domain.ObjectiveLowestCost
+	default:
+		return calcReq, errors.New("unknown objective")
+	}
+
+	return calcReq, nil
+}
+
+func toPlan(result *domain.CalculateResult) *calculatorv1.Plan {
+	plan := &calculatorv1.Plan{
+		Packages:  toInt64Map(result.Packages),
+		Total:     int64(result.Total),
+		Overshoot: int64(result.Overshoot),
+		PackCount: int64(result.PackCount),
+	}
+	if result.Costs != nil {
+		totalCost := int64(result.TotalCost)
+		plan.Costs = toInt64Map(result.Costs)
+		plan.TotalCost = &totalCost
+	}
+
+	return plan
+}
+
+// storedSizeMap decodes a stored pack size map, rows predating a column have none
+func storedSizeMap(data []byte) map[int64]int64 {
+	var values map[int]int
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil
+	}
+
+	return toInt64Map(values)
+}
+
+func toInts(values []int64) []int {
+	ints := make([]int, 0, len(values))
+	for _, v := range values {
+		ints = append(ints, int(v))
+	}
+
+	return ints
+}
+
+func toInt64s(values []int) []int64 {
+	ints := make([]int64, 0, len(values))
+	for _, v := range values {
+		ints = append(ints, int64(v))
+	}
+
+	return ints
+}
+
+func toIntMap(values map[int64]int64) map[int]int {
+	if values == nil {
+		return nil
+	}
+
+	ints := make(map[int]int, len(values))
+	for k, v := range values {
+		ints[int(k)] = int(v)
+	}
+
+	return ints
+}
+
+func toInt64Map(values map[int]int) map[int64]int64 {
+	if values == nil {
+		return nil
+	}
+
+	ints := make(map[int64]int64, len(values))
+	for k, v := range values {
+		ints[int64(k)] = int64(v)
+	}
+
+	return ints
+}
@@ -0,0 +1,271 @@
+package grpcapi_test
+
+import (
+	"context"
+	calculatorv1 "ignis/api/calculator/v1"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/adapter/grpcapi"
+	"ignis/internal/service"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// mockRepository implements the db.Repository methods the server uses, the
+// others are left to the embedded nil interface
+type mockRepository struct {
+	db.Repository
+	calculations []dbsqlc.Calculation
+	batches      [][]db.BatchItem
+}
+
+func (m *mockRepository) CreateCalculation(ctx context.Context, arg dbsqlc.CreateCalculationParams) (dbsqlc.Calculation, error) {
+	calc := dbsqlc.Calculation{
+		ID:           int32(len(m.calculations) + 1),
+		PackSizes:    arg.PackSizes,
+		TargetAmount: arg.TargetAmount,
+		ResultJson:   arg.ResultJson,
+		TotalItems:   arg.TotalItems,
+		Stock:        arg.Stock,
+		Objective:    arg.Objective,
+		Costs:        arg.Costs,
+		TotalCost:    arg.TotalCost,
+		CreatedAt:    pgtype.Timestamp{Time: time.Now(), Valid: true},
+	}
+	m.calculations = append(m.calculations, calc)
+	return calc, nil
+}
+
+// ListCalculations pages through the calculations newest first; IDs grow with
+// creation time, so the cursor ID alone marks the position
+func (m *mockRepository) ListCalculations(ctx context.Context, arg dbsqlc.ListCalculationsParams) ([]dbsqlc.Calculation, error) {
+	var page []dbsqlc.Calculation
+	for i := len(m.calculations) - 1; i >= 0 && len(page) < int(arg.PageSize); i-- {
+		calc := m.calculations[i]
+		if arg.BeforeID.Valid && calc.ID >= arg.BeforeID.Int32 {
+			continue
+		}
+		if arg.MinAmount.Valid && calc.TargetAmount < arg.MinAmount.Int64 {
+			continue
+		}
+		page = append(page, calc)
+	}
+	return page, nil
+}
+
+func (m *mockRepository) CreateCalculationBatch(ctx context.Context, arg dbsqlc.CreateCalculationBatchParams) (dbsqlc.CalculationBatch, error) {
+	return dbsqlc.CalculationBatch{ID: int32(len(m.batches) + 1), ItemCount: arg.ItemCount, FailedCount: arg.FailedCount, Errors: arg.Errors}, nil
+}
+
+func (m *mockRepository) SaveBatch(ctx context.Context, items []db.BatchItem) (dbsqlc.CalculationBatch, []int32, error) {
+	m.batches = append(m.batches, items)
+
+	ids := make([]int32, len(items))
+	for i, item := range items {
+		if item.Calculation != nil {
+			calc, _ := m.CreateCalculation(ctx, *item.Calculation)
+			ids[i] = calc.ID
+		}
+	}
+	return dbsqlc.CalculationBatch{ID: int32(len(m.batches))}, ids, nil
+}
+
+func (m *mockRepository) Close() {}
+
+func newClient(t *testing.T, repo *mockRepository) calculatorv1.CalculatorServiceClient {
+	t.Helper()
+
+	var calcRepo db.Repository
+	if repo != nil {
+		calcRepo = repo
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	calculatorv1.RegisterCalculatorServiceServer(server, grpcapi.NewCalculatorServer(service.NewPackageCalculatorService(), calcRepo))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return calculatorv1.NewCalculatorServiceClient(conn)
+}
+
+func TestCalculatorServer_Calculate(t *testing.T) {
+	repo := &mockRepository{}
+	client := newClient(t, repo)
+
+	resp, err := client.Calculate(context.Background(), &calculatorv1.CalculateRequest{
+		PackSizes: []int64{23, 31, 53},
+		Amount:    500000,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[int64]int64{53: 9429, 31: 7, 23: 2}
+	if !reflect.DeepEqual(resp.GetPlan().GetPackages(), want) {
+		t.Errorf("expected packages %v, got %v", want, resp.GetPlan().GetPackages())
+	}
+	if resp.GetPlan().GetTotal() != 500000 || resp.GetPlan().GetPackCount() != 9438 {
+		t.Errorf("expected total 500000 in 9438 packs, got %d in %d", resp.GetPlan().GetTotal(), resp.GetPlan().GetPackCount())
+	}
+	if resp.GetId() != 1 || len(repo.calculations) != 1 || repo.calculations[0].PackSizes != "23, 31, 53" {
+		t.Errorf("expected the calculation to be stored with ID 1, got ID %d and %+v", resp.GetId(), repo.calculations)
+	}
+}
+
+func TestCalculatorServer_Calculate_ErrorCodes(t *testing.T) {
+	client := newClient(t, nil)
+
+	tests := []struct {
+		name     string
+		req      *calculatorv1.CalculateRequest
+		wantCode codes.Code
+	}{
+		{"invalid pack size", &calculatorv1.CalculateRequest{PackSizes: []int64{0}, Amount: 5}, codes.InvalidArgument},
+		{"too many plans", &calculatorv1.CalculateRequest{PackSizes: []int64{5}, Amount: 5, Plans: 11}, codes.InvalidArgument},
+		{"no combination", &calculatorv1.CalculateRequest{PackSizes: []int64{5}, Amount: 7}, codes.FailedPrecondition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Calculate(context.Background(), tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("expected code %v, got %v", tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestCalculatorServer_BatchCalculate(t *testing.T) {
+	repo := &mockRepository{}
+	client := newClient(t, repo)
+
+	resp, err := client.BatchCalculate(context.Background(), &calculatorv1.BatchCalculateRequest{
+		Requests: []*calculatorv1.CalculateRequest{
+			{PackSizes: []int64{5, 10}, Amount: 20},
+			{PackSizes: []int64{5}, Amount: 7},
+			{PackSizes: []int64{5, 10}, Amount: 7, Mode: calculatorv1.Mode_MODE_OVERFILL},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := resp.GetResults()
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if got := results[0].GetResponse().GetPlan().GetPackages(); !reflect.DeepEqual(got, map[int64]int64{10: 2}) {
+		t.Errorf("item 0: expected two 10 packs, got %v", got)
+	}
+	if code := codes.Code(results[1].GetError().GetCode()); code != codes.FailedPrecondition {
+		t.Errorf("item 1: expected FailedPrecondition, got %v", results[1])
+	}
+	if overshoot := results[2].GetResponse().GetPlan().GetOvershoot(); overshoot != 3 {
+		t.Errorf("item 2: expected overshoot 3, got %d", overshoot)
+	}
+
+	// Stored as one batch with the failed item's error
+	if len(repo.batches) != 1 || len(repo.calculations) != 2 {
+		t.Fatalf("expected one batch of 2 calculations, got %d batches and %d calculations", len(repo.batches), len(repo.calculations))
+	}
+	if repo.batches[0][1].Calculation != nil || repo.batches[0][1].Error == "" {
+		t.Errorf("expected item 1 stored as failed, got %+v", repo.batches[0][1])
+	}
+	if results[0].GetResponse().GetId() != 1 || results[2].GetResponse().GetId() != 2 {
+		t.Errorf("expected calculation IDs 1 and 2, got %d and %d", results[0].GetResponse().GetId(), results[2].GetResponse().GetId())
+	}
+}
+
+func TestCalculatorServer_ListHistory(t *testing.T) {
+	repo := &mockRepository{}
+	client := newClient(t, repo)
+
+	_, err := client.Calculate(context.Background(), &calculatorv1.CalculateRequest{
+		PackSizes: []int64{5, 10},
+		Amount:    20,
+		Stock:     map[int64]int64{10: 1},
+		Objective: calculatorv1.Objective_OBJECTIVE_LOWEST_COST,
+		Costs:     map[int64]int64{5: 100, 10: 300},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := client.ListHistory(context.Background(), &calculatorv1.ListHistoryRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.GetCalculations()) != 1 {
+		t.Fatalf("expected 1 calculation, got %d", len(resp.GetCalculations()))
+	}
+
+	calc := resp.GetCalculations()[0]
+	if !reflect.DeepEqual(calc.GetPackSizes(), []int64{5, 10}) || calc.GetAmount() != 20 || calc.GetObjective() != "cost" {
+		t.Errorf("unexpected calculation: %v", calc)
+	}
+	if !reflect.DeepEqual(calc.GetPackages(), map[int64]int64{5: 4}) || !reflect.DeepEqual(calc.GetStock(), map[int64]int64{10: 1}) {
+		t.Errorf("unexpected packages or stock: %v", calc)
+	}
+	if calc.TotalCost == nil || calc.GetTotalCost() != 400 {
+		t.Errorf("expected total cost 400, got %v", calc.TotalCost)
+	}
+}
+
+func TestCalculatorServer_ListHistory_Pages(t *testing.T) {
+	repo := &mockRepository{}
+	client := newClient(t, repo)
+
+	for _, amount := range []int64{5, 10, 15} {
+		if _, err := client.Calculate(context.Background(), &calculatorv1.CalculateRequest{PackSizes: []int64{5}, Amount: amount}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var amounts []int64
+	req := &calculatorv1.ListHistoryRequest{PageSize: 2}
+	for pages := 0; ; pages++ {
+		if pages > 2 {
+			t.Fatalf("expected the pages to end, got %v", amounts)
+		}
+		resp, err := client.ListHistory(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, calc := range resp.GetCalculations() {
+			amounts = append(amounts, calc.GetAmount())
+		}
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if !reflect.DeepEqual(amounts, []int64{15, 10, 5}) {
+		t.Errorf("expected amounts [15 10 5], got %v", amounts)
+	}
+
+	for _, req := range []*calculatorv1.ListHistoryRequest{{PageToken: "abc"}, {PageSize: 500}} {
+		_, err := client.ListHistory(context.Background(), req)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%v: expected InvalidArgument, got %v", req, err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultWorkers is the number of jobs processed at once when Options leaves it unset
+	DefaultWorkers = 2
+	// DefaultLease is how long a claimed job stays with its worker without a heartbeat
+	DefaultLease = time.Minute
+	// MaxAttempts is how often a job is claimed before it fails, a job whose
+	// worker keeps dying must not be retried forever
+	MaxAttempts = 3
+)
+
+// pollInterval is how often idle workers look for jobs nobody announced, e.g.
+// those submitted by another instance or left behind by a dead one
+const pollInterval = 2 * time.Second
+
+// heartbeatInterval is how often a running job saves its progress and extends its lease
+const heartbeatInterval = time.Second
+
+// Runner calculates a claimed job and returns its result. It reports the items
+// done so far through progress and stops with the context error once ctx is
+// cancelled. Any other error fails the job, its message is shown to the client.
+type Runner func(ctx context.Context, job dbsqlc.CalculationJob, progress func(done int)) ([]byte, error)
+
+// Options sizes a Pool, zero values fall back to the defaults
+type Options struct {
+	Workers int
+	Lease   time.Duration
+}
+
+// Pool runs jobs stored in Postgres on a fixed number of workers. Jobs are
+// claimed with a lease that the worker renews while it runs, so a job whose
+// worker died is picked up again once the lease runs out, by this process
+// after a restart or by another instance. Each claim counts an attempt, and a
+// worker only writes to the job while the attempt it claimed is the latest: one
+// whose lease was taken over stops without storing anything.
+type Pool struct {
+	repo    db.Repository
+	run     Runner
+	workers int
+	lease   time.Duration
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPool creates a pool, its workers start with Start
+func NewPool(repo db.Repository, run Runner, opts Options) *Pool {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = DefaultLease
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pool{
+		repo:    repo,
+		run:     run,
+		workers: opts.Workers,
+		lease:   opts.Lease,
+		wake:    make(chan struct{}, opts.Workers),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start launches the workers, they first pick up the jobs left unfinished by
+// the previous run
+func (p *Pool) Start() {
+	for range p.workers {
+		p.wg.Go(p.work)
+	}
+}
+
+// Notify wakes an idle worker after a job was submitted
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the workers. Running jobs are cancelled and returned to the
+// queue, so the next start resumes them. It gives up waiting once ctx is done.
+func (p *Pool) Close(ctx context.Context) error {
+	p.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	for {
+		job, err := p.repo.ClaimJob(p.ctx, p.leaseSeconds())
+		switch {
+		case err == nil:
+			p.process(job)
+			continue
+		case p.ctx.Err() != nil:
+			return
+		case !db.IsNotFound(err):
+			log.Printf("failed to claim job: %v\n", err)
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// process runs a claimed job and stores its outcome
+func (p *Pool) process(job dbsqlc.CalculationJob) {
+	// Writes after shutdown must still reach the database
+	storeCtx := context.WithoutCancel(p.ctx)
+
+	if job.Attempts > MaxAttempts {
+		p.finish(storeCtx, job, db.JobFailed, job.Progress, nil, fmt.Sprintf("Job failed after %d attempts", MaxAttempts))
+		return
+	}
+
+	// Losing the lease cancels the run, so it stops before storing its result
+	runCtx, cancelRun := context.WithCancel(p.ctx)
+	defer cancelRun()
+	var leaseLost atomic.Bool
+
+	var progress atomic.Int32
+	stopHeartbeat := p.heartbeat(storeCtx, job, &progress, func() {
+		leaseLost.Store(true)
+		cancelRun()
+	})
+	result, err := p.run(runCtx, job, func(done int) {
+		progress.Store(int32(done))
+	})
+	stopHeartbeat()
+
+	switch {
+	case leaseLost.Load():
+		log.Printf("job %d was taken over while it ran, dropping attempt %d\n", job.ID, job.Attempts)
+	case err != nil && p.ctx.Err() != nil:
+		// Cancelled by shutdown: back to the queue, without counting the attempt
+		if _, err := p.repo.ReleaseJob(storeCtx, dbsqlc.ReleaseJobParams{ID: job.ID, Attempts: job.Attempts}); err != nil {
+			log.Printf("failed to release job %d: %v\n", job.ID, err)
+		}
+	case err != nil:
+		p.finish(storeCtx, job, db.JobFailed, progress.Load(), nil, err.Error())
+	default:
+		p.finish(storeCtx, job, db.JobSucceeded, job.Total, result, "")
+	}
+}
+
+// heartbeat saves the progress of a running job and extends its lease until
+// stopped. It calls lost and stops once the job no longer matches the claimed
+// attempt, another worker owns it then.
+func (p *Pool) heartbeat(ctx context.Context, job dbsqlc.CalculationJob, progress *atomic.Int32, lost func()) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			updated, err := p.repo.UpdateJobProgress(ctx, dbsqlc.UpdateJobProgressParams{
+				Progress:     progress.Load(),
+				LeaseSeconds: p.leaseSeconds(),
+				ID:           job.ID,
+				Attempts:     job.Attempts,
+			})
+			switch {
+			case err != nil:
+				log.Printf("failed to update job %d: %v\n", job.ID, err)
+			case updated == 0:
+				lost()
+				return
+			}
+		}
+	})
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (p *Pool) finish(ctx context.Context, job dbsqlc.CalculationJob, status string, progress int32, result []byte, message string) {
+	finished, err := p.repo.FinishJob(ctx, dbsqlc.FinishJobParams{
+		Status:   status,
+		Progress: progress,
+		Result:   result,
+		Error:    message,
+		ID:       job.ID,
+		Attempts: job.Attempts,
+	})
+	switch {
+	case err != nil:
+		log.Printf("failed to finish job %d: %v\n", job.ID, err)
+	case finished == 0:
+		// The lease ran out and another worker owns the job now
+		log.Printf("job %d was taken over before it finished\n", job.ID)
+	}
+}
+
+func (p *Pool) leaseSeconds() int32 {
+	return int32(max(p.lease/time.Second, 1))
+}
@@ -0,0 +1,229 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"ignis/internal/adapter/db"
+	dbsqlc "ignis/internal/adapter/db/sqlc"
+	"ignis/internal/adapter/jobs"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// queueRepository keeps jobs in memory and claims them like the ClaimJob query
+type queueRepository struct {
+	db.Repository
+	mu   sync.Mutex
+	jobs []dbsqlc.CalculationJob
+}
+
+func (m *queueRepository) add(job dbsqlc.CalculationJob) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.ID = int32(len(m.jobs) + 1)
+	if job.Status == "" {
+		job.Status = db.JobQueued
+	}
+	m.jobs = append(m.jobs, job)
+}
+
+func (m *queueRepository) job(id int32) dbsqlc.CalculationJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jobs[id-1]
+}
+
+func (m *queueRepository) ClaimJob(ctx context.Context, leaseSeconds int32) (dbsqlc.CalculationJob, error) {
+	if err := ctx.Err(); err != nil {
+		return dbsqlc.CalculationJob{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, job := range m.jobs {
+		expired := job.Status == db.JobRunning && job.LockedUntil.Time.Before(time.Now())
+		if job.Status == db.JobQueued || expired {
+			m.jobs[i].Status = db.JobRunning
+			m.jobs[i].Attempts++
+			m.jobs[i].LockedUntil = pgtype.Timestamp{Time: time.Now().Add(time.Duration(leaseSeconds) * time.Second), Valid: true}
+			return m.jobs[i], nil
+		}
+	}
+	return dbsqlc.CalculationJob{}, pgx.ErrNoRows
+}
+
+// takeOver claims a running job again, like ClaimJob once its lease ran out
+func (m *queueRepository) takeOver(id int32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id-1].Attempts++
+}
+
+func (m *queueRepository) UpdateJobProgress(ctx context.Context, arg dbsqlc.UpdateJobProgressParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := &m.jobs[arg.ID-1]
+	if job.Status != db.JobRunning || job.Attempts != arg.Attempts {
+		return 0, nil
+	}
+	job.Progress = arg.Progress
+	return 1, nil
+}
+
+func (m *queueRepository) FinishJob(ctx context.Context, arg dbsqlc.FinishJobParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := &m.jobs[arg.ID-1]
+	if job.Status != db.JobRunning || job.Attempts != arg.Attempts {
+		return 0, nil
+	}
+	job.Status, job.Progress, job.Result, job.Error = arg.Status, arg.Progress, arg.Result, arg.Error
+	return 1, nil
+}
+
+func (m *queueRepository) ReleaseJob(ctx context.Context, arg dbsqlc.ReleaseJobParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := &m.jobs[arg.ID-1]
+	if job.Status != db.JobRunning || job.Attempts != arg.Attempts {
+		return 0, nil
+	}
+	job.Status = db.JobQueued
+	job.Attempts--
+	return 1, nil
+}
+
+// waitFor polls the job until it reaches status
+func waitFor(t *testing.T, repo *queueRepository, id int32, status string) dbsqlc.CalculationJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job := repo.job(id); job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %d did not become %s, it is %+v", id, status, repo.job(id))
+	return dbsqlc.CalculationJob{}
+}
+
+func TestPool_RunsJobs(t *testing.T) {
+	repo := &queueRepository{}
+	pool := jobs.NewPool(repo, func(ctx context.Context, job dbsqlc.CalculationJob, progress func(done int)) ([]byte, error) {
+		progress(1)
+		if string(job.Request) == "fail" {
+			return nil, errors.New("Calculation error: no combination")
+		}
+		return append([]byte("result of "), job.Request...), nil
+	}, jobs.Options{Workers: 2})
+	pool.Start()
+	defer pool.Close(context.Background())
+
+	repo.add(dbsqlc.CalculationJob{Request: []byte("a"), Total: 2})
+	repo.add(dbsqlc.CalculationJob{Request: []byte("fail"), Total: 2})
+	pool.Notify()
+
+	if job := waitFor(t, repo, 1, db.JobSucceeded); string(job.Result) != "result of a" || job.Progress != 2 {
+		t.Errorf("unexpected finished job %+v", job)
+	}
+	if job := waitFor(t, repo, 2, db.JobFailed); job.Error != "Calculation error: no combination" || job.Progress != 1 {
+		t.Errorf("unexpected failed job %+v", job)
+	}
+}
+
+func TestPool_ResumesAfterRestart(t *testing.T) {
+	repo := &queueRepository{}
+	started := make(chan struct{})
+	blocking := func(ctx context.Context, job dbsqlc.CalculationJob, progress func(done int)) ([]byte, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	repo.add(dbsqlc.CalculationJob{Request: []byte("a"), Total: 1})
+	pool := jobs.NewPool(repo, blocking, jobs.Options{Workers: 1})
+	pool.Start()
+	<-started
+
+	// Shutting down hands the running job back to the queue
+	if err := pool.Close(context.Background()); err != nil {
+		t.Fatalf("failed to close pool: %v", err)
+	}
+	if job := repo.job(1); job.Status != db.JobQueued || job.Attempts != 0 {
+		t.Fatalf("expected the job to be queued again, got %+v", job)
+	}
+
+	// The next start finishes it
+	pool = jobs.NewPool(repo, func(ctx context.Context, job dbsqlc.CalculationJob, progress func(done int)) ([]byte, error) {
+		return []byte("done"), nil
+	}, jobs.Options{Workers: 1})
+	pool.Start()
+	defer pool.Close(context.Background())
+
+	waitFor(t, repo, 1, db.JobSucceeded)
+}
+
+func TestPool_ReclaimsExpiredLeases(t *testing.T) {
+	repo := &queueRepository{}
+
+	// Left running by a worker that died, and one whose worker keeps dying
+	expired := pgtype.Timestamp{Time: time.Now().Add(-time.Minute), Valid: true}
+	repo.add(dbsqlc.CalculationJob{Status: db.JobRunning, LockedUntil: expired, Attempts: 1, Total: 1})
+	repo.add(dbsqlc.CalculationJob{Status: db.JobRunning, LockedUntil: expired, Attempts: jobs.MaxAttempts, Total: 1})
+
+	var mu sync.Mutex
+	var ran []int32
+	pool := jobs.NewPool(repo, func(ctx context.Context, job dbsqlc.CalculationJob, progress func(done int)) ([]byte, error) {
+		mu.Lock()
+		ran = append(ran, job.ID)
+		mu.Unlock()
+		return []byte("done"), nil
+	}, jobs.Options{Workers: 1})
+	pool.Start()
+	defer pool.Close(context.Background())
+
+	waitFor(t, repo, 1, db.JobSucceeded)
+	if job := waitFor(t, repo, 2, db.JobFailed); job.Error == "" {
+		t.Errorf("expected the job to fail after %d attempts, got %+v", jobs.MaxAttempts, job)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 1 || ran[0] != 1 {
+		t.Errorf("expected only job 1 to run, ran %v", ran)
+	}
+}
+
+func TestPool_StopsWhenTakenOver(t *testing.T) {
+	repo := &queueRepository{}
+	repo.add(dbsqlc.CalculationJob{Request: []byte("a"), Total: 1})
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	pool := jobs.NewPool(repo, func(ctx context.Context, job dbsqlc.CalculationJob, progress func(done int)) ([]byte, error) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return nil, ctx.Err()
+	}, jobs.Options{Workers: 1})
+	pool.Start()
+	defer pool.Close(context.Background())
+
+	// Another worker claims the job as if this one had missed its lease
+	<-started
+	repo.takeOver(1)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the run to be cancelled once its lease was taken over")
+	}
+	pool.Close(context.Background())
+
+	if job := repo.job(1); job.Status != db.JobRunning || job.Attempts != 2 || job.Result != nil {
+		t.Errorf("expected the job to stay with its new worker, got %+v", job)
+	}
+}
@@ -1,18 +1,133 @@
 package domain
 
+import (
+	"context"
+	"fmt"
+)
+
+// Mode selects how the calculator treats amounts that cannot be packed exactly
+type Mode int
+
+const (
+	// ModeExact only accepts combinations that sum to exactly the requested amount
+	ModeExact Mode = iota
+	// ModeOverfill ships the fewest items at or above the requested amount, then the fewest packs
+	ModeOverfill
+)
+
+// String returns the form/storage representation of the mode
+func (m Mode) String() string {
+	switch m {
+	case ModeOverfill:
+		return "overfill"
+	default:
+		return "exact"
+	}
+}
+
+// ParseMode converts a form value into a Mode, an empty value means ModeExact
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "exact":
+		return ModeExact, nil
+	case "overfill":
+		return ModeOverfill, nil
+	default:
+		return ModeExact, fmt.Errorf("unknown mode: %s", s)
+	}
+}
+
+// Objective selects what the calculator minimizes among valid plans
+type Objective int
+
+const (
+	// ObjectiveFewestPacks minimizes the number of packs
+	ObjectiveFewestPacks Objective = iota
+	// ObjectiveLowestCost minimizes the total cost, then the number of packs
+	ObjectiveLowestCost
+)
+
+// String returns the form/storage representation of the objective
+func (o Objective) String() string {
+	switch o {
+	case ObjectiveLowestCost:
+		return "cost"
+	default:
+		return "packs"
+	}
+}
+
+// ParseObjective converts a form value into an Objective, an empty value means ObjectiveFewestPacks
+func ParseObjective(s string) (Objective, error) {
+	switch s {
+	case "", "packs":
+		return ObjectiveFewestPacks, nil
+	case "cost":
+		return ObjectiveLowestCost, nil
+	default:
+		return ObjectiveFewestPacks, fmt.Errorf("unknown objective: %s", s)
+	}
+}
+
+// MaxPackCost caps the unit cost of a pack in cents, keeping plan costs far
+// from overflowing
+const MaxPackCost = 100_000_000
+
 // CalculateRequest represents the input for package calculation
 type CalculateRequest struct {
-	PackSizes []int
-	Amount    int
+	PackSizes   []int
+	Amount      int
+	Mode        Mode
+	Stock       map[int]int // map[packSize]available, sizes missing from the map are unlimited
+	Objective   Objective
+	Costs       map[int]int      // map[packSize]unit cost in cents, required for ObjectiveLowestCost
+	Constraints *PackConstraints // fewest and most packs of each size, nil for none
+	Packaging   *Packaging       // containers to ship the packs in, nil for none
+	Shipping    *ShipmentLimits  // weight and volume limits to split the packs into shipments by, nil for one shipment
 }
 
 // CalculateResult represents the output of package calculation
 type CalculateResult struct {
-	Packages map[int]int // map[packSize]count
-	Total    int         // total items in all packages
+	Packages  map[int]int     // map[packSize]count
+	Total     int             // total items in all packages
+	Overshoot int             // items shipped above the requested amount
+	PackCount int             // number of packs shipped
+	Costs     map[int]int     // map[packSize]cost of all packs of that size, set when unit costs are given
+	TotalCost int             // cost of all packages in cents, set when unit costs are given
+	Packaging *PackagingPlan  // containers of the packs, set when the request has a packaging
+	Shipments []ShipmentGroup // shipments within the limits of the request, set when it has them
+}
+
+// BatchResult is the outcome of one request of a batch, either Result or Err is set
+type BatchResult struct {
+	Result *CalculateResult
+	Err    error
 }
 
 // PackageCalculator defines the interface for package calculation service
 type PackageCalculator interface {
-	Calculate(req CalculateRequest) (*CalculateResult, error)
+	// Calculate stops with the context error once ctx is cancelled
+	Calculate(ctx context.Context, req CalculateRequest) (*CalculateResult, error)
+	// CalculateTopK returns up to k distinct plans ranked best first, the first
+	// one being the plan Calculate returns
+	CalculateTopK(ctx context.Context, req CalculateRequest, k int) ([]*CalculateResult, error)
+	// CalculateBatch answers every request like Calculate, results are in request
+	// order. Only a cancelled ctx fails the whole batch, other errors are per request.
+	CalculateBatch(ctx context.Context, reqs []CalculateRequest) ([]BatchResult, error)
+	// AnalyzeFeasibility reports which amounts the pack sizes can pack exactly,
+	// with the packable amounts nearest to amount when it is above zero
+	AnalyzeFeasibility(ctx context.Context, packSizes []int, amount int) (*FeasibilityReport, error)
+}
+
+// CacheStats counts the lookups of a calculator that caches its results
+type CacheStats struct {
+	Hits     uint64
+	Misses   uint64
+	Entries  int // results held in memory
+	Capacity int // most results held in memory
+}
+
+// CacheStatsReporter is implemented by calculators that cache their results
+type CacheStatsReporter interface {
+	CacheStats() CacheStats
 }
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PackConstraints bounds how many packs of each size a plan may use, e.g. at
+// least one display pack or at most 10 of the smallest size
+type PackConstraints struct {
+	Min       map[int]int // map[packSize]fewest packs
+	Max       map[int]int // map[packSize]most packs
+	Required  []int       // sizes every plan uses at least once
+	Forbidden []int       // sizes no plan uses
+}
+
+// String identifies the constraints in cache keys
+func (c PackConstraints) String() string {
+	// fmt prints maps sorted by key
+	return fmt.Sprintf("min=%v max=%v required=%v forbidden=%v", c.Min, c.Max, c.Required, c.Forbidden)
+}
+
+// ConstraintKind names the kind of a single pack constraint
+type ConstraintKind string
+
+const (
+	ConstraintRequired  ConstraintKind = "required"
+	ConstraintMin       ConstraintKind = "min"
+	ConstraintMax       ConstraintKind = "max"
+	ConstraintForbidden ConstraintKind = "forbidden"
+)
+
+// PackConstraint is one constraint of a PackConstraints
+type PackConstraint struct {
+	Kind     ConstraintKind
+	PackSize int
+	Count    int // fewest or most packs, set for ConstraintMin and ConstraintMax
+}
+
+func (c PackConstraint) String() string {
+	switch c.Kind {
+	case ConstraintMin:
+		return fmt.Sprintf("at least %d of pack size %d", c.Count, c.PackSize)
+	case ConstraintMax:
+		return fmt.Sprintf("at most %d of pack size %d", c.Count, c.PackSize)
+	case ConstraintForbidden:
+		return fmt.Sprintf("pack size %d forbidden", c.PackSize)
+	default:
+		return fmt.Sprintf("pack size %d required", c.PackSize)
+	}
+}
+
+// List returns the constraints one by one, ordered by pack size
+func (c PackConstraints) List() []PackConstraint {
+	var list []PackConstraint
+	for _, size := range c.Required {
+		list = append(list, PackConstraint{Kind: ConstraintRequired, PackSize: size})
+	}
+	for size, count := range c.Min {
+		list = append(list, PackConstraint{Kind: ConstraintMin, PackSize: size, Count: count})
+	}
+	for size, count := range c.Max {
+		list = append(list, PackConstraint{Kind: ConstraintMax, PackSize: size, Count: count})
+	}
+	for _, size := range c.Forbidden {
+		list = append(list, PackConstraint{Kind: ConstraintForbidden, PackSize: size})
+	}
+
+	// Stable keeps required, min, max, forbidden order within a size
+	sort.SliceStable(list, func(i, j int) bool { return list[i].PackSize < list[j].PackSize })
+
+	return list
+}
+
+// NewPackConstraints collects single constraints into a PackConstraints, the
+// inverse of List
+func NewPackConstraints(list []PackConstraint) *PackConstraints {
+	c := &PackConstraints{}
+	for _, constraint := range list {
+		switch constraint.Kind {
+		case ConstraintRequired:
+			c.Required = append(c.Required, constraint.PackSize)
+		case ConstraintMin:
+			if c.Min == nil {
+				c.Min = make(map[int]int)
+			}
+			c.Min[constraint.PackSize] = constraint.Count
+		case ConstraintMax:
+			if c.Max == nil {
+				c.Max = make(map[int]int)
+			}
+			c.Max[constraint.PackSize] = constraint.Count
+		case ConstraintForbidden:
+			c.Forbidden = append(c.Forbidden, constraint.PackSize)
+		}
+	}
+
+	return c
+}
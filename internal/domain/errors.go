@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrEmptyPackSizes is returned when a request has no pack sizes
+	ErrEmptyPackSizes = errors.New("pack sizes cannot be empty")
+	// ErrInvalidPackSize is returned for pack sizes that are zero or negative
+	ErrInvalidPackSize = errors.New("pack sizes must be greater than zero")
+	// ErrInvalidAmount is returned for amounts that are zero or negative
+	ErrInvalidAmount = errors.New("amount must be greater than zero")
+	// ErrInvalidStock is returned for negative stock limits
+	ErrInvalidStock = errors.New("invalid stock")
+	// ErrInvalidCost is returned for missing, negative or too high pack costs
+	ErrInvalidCost = errors.New("invalid pack cost")
+	// ErrInvalidPackaging is returned for packaging levels without a name or a
+	// positive capacity, or missing the capacity of a pack size
+	ErrInvalidPackaging = errors.New("invalid packaging")
+	// ErrInvalidShipment is returned for shipment limits without a maximum,
+	// with negative values, or missing the weight or volume of a pack size
+	ErrInvalidShipment = errors.New("invalid shipment limits")
+	// ErrOversizedPack is returned when a single pack exceeds the shipment limits
+	ErrOversizedPack = errors.New("pack exceeds the shipment limits")
+	// ErrInvalidConstraint is returned for pack constraints on sizes the request
+	// does not offer, with negative counts or contradicting each other
+	ErrInvalidConstraint = errors.New("invalid pack constraint")
+	// ErrInvalidPlanCount is returned when fewer than one ranked plan is requested
+	ErrInvalidPlanCount = errors.New("number of plans must be greater than zero")
+	// ErrNoCombination is matched by every *NoCombinationError
+	ErrNoCombination = errors.New("no combination possible")
+)
+
+// StockShortage is a pack size the best unlimited plan needs more of than is in stock
+type StockShortage struct {
+	PackSize  int
+	Needed    int
+	Available int
+}
+
+// NoCombinationError reports a valid request that no plan can satisfy
+type NoCombinationError struct {
+	Amount    int
+	Mode      Mode
+	Shortages []StockShortage // set when stock limits caused the failure
+	// Conflicts is set when pack constraints caused the failure: each of them
+	// alone stands in the way of a plan, or all of them together when no single
+	// one does
+	Conflicts []PackConstraint
+}
+
+func (e *NoCombinationError) Error() string {
+	if len(e.Shortages) > 0 {
+		entries := make([]string, 0, len(e.Shortages))
+		for _, s := range e.Shortages {
+			entries = append(entries, fmt.Sprintf("%d (need %d, %d in stock)", s.PackSize, s.Needed, s.Available))
+		}
+		return fmt.Sprintf("insufficient stock for pack sizes: %s", strings.Join(entries, ", "))
+	}
+	if len(e.Conflicts) > 0 {
+		entries := make([]string, 0, len(e.Conflicts))
+		for _, c := range e.Conflicts {
+			entries = append(entries, c.String())
+		}
+		return fmt.Sprintf("pack constraints cannot be met: %s", strings.Join(entries, ", "))
+	}
+	if e.Mode == ModeOverfill {
+		return "no combination possible at or above the requested amount"
+	}
+	return "no exact combination possible for the requested amount"
+}
+
+// Is makes errors.Is(err, ErrNoCombination) match
+func (e *NoCombinationError) Is(target error) bool {
+	return target == ErrNoCombination
+}
+
+// Limit names a resource limit of the calculator
+type Limit string
+
+const (
+	LimitAmount    Limit = "amount"
+	LimitPackSizes Limit = "number of pack sizes"
+	LimitMemory    Limit = "estimated memory (bytes)"
+)
+
+// LimitError reports a request exceeding one of the calculator's resource limits
+type LimitError struct {
+	Limit Limit
+	Value int64
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %d exceeds the limit of %d", e.Limit, e.Value, e.Max)
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "fmt"
+
+// FeasibilityReport describes which amounts a set of pack sizes packs exactly
+type FeasibilityReport struct {
+	PackSizes []int // ascending, without duplicates
+	GCD       int   // every packable amount is a multiple of it
+	// Frobenius is the largest multiple of GCD that cannot be packed, -1 when
+	// every multiple can. With a GCD of 1 it is the largest amount that cannot.
+	Frobenius int
+	// UnreachableCount is how many multiples of GCD up to Frobenius cannot be
+	// packed, Unreachable lists the smallest of them
+	UnreachableCount int
+	Unreachable      []int
+	Nearest          *NearestAmounts // set when an amount was analysed
+}
+
+// NearestAmounts are the packable amounts closest to an analysed amount
+type NearestAmounts struct {
+	Amount    int
+	Reachable bool // Amount itself can be packed
+	Below     int  // largest packable amount below Amount, 0 when there is none
+	Above     int  // smallest packable amount above Amount
+}
+
+// Warning explains why most amounts cannot be packed when every pack size
+// shares a factor, it is empty otherwise
+func (r FeasibilityReport) Warning() string {
+	if r.GCD <= 1 {
+		return ""
+	}
+
+	return fmt.Sprintf("every pack size is a multiple of %d, only multiples of %d can be packed", r.GCD, r.GCD)
+}
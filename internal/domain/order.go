@@ -0,0 +1,42 @@
+package domain
+
+// OrderLine is one product of an order, packed with its own pack sizes
+type OrderLine struct {
+	Sku     string
+	Request CalculateRequest
+}
+
+// OrderTotals sums the plans of the lines of an order that could be packed
+type OrderTotals struct {
+	Lines     int // lines of the order, packed or not
+	Failed    int // lines that could not be packed
+	Amount    int // items requested by the packed lines
+	Total     int // items shipped
+	Overshoot int
+	PackCount int
+	TotalCost int  // cost of the packed lines in cents, see Costed
+	Costed    bool // every packed line has unit costs, so TotalCost covers all of them
+}
+
+// SumOrder totals the results of the lines of an order, in line order; a nil
+// result is a line that could not be packed
+func SumOrder(lines []OrderLine, results []*CalculateResult) OrderTotals {
+	totals := OrderTotals{Lines: len(lines), Costed: true}
+	packed := 0
+	for i, result := range results {
+		if result == nil {
+			totals.Failed++
+			continue
+		}
+		packed++
+		totals.Amount += lines[i].Request.Amount
+		totals.Total += result.Total
+		totals.Overshoot += result.Overshoot
+		totals.PackCount += result.PackCount
+		totals.TotalCost += result.TotalCost
+		totals.Costed = totals.Costed && result.Costs != nil
+	}
+	totals.Costed = totals.Costed && packed > 0
+
+	return totals
+}
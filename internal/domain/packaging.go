@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Packaging is a hierarchy of containers the packs of a plan ship in, e.g.
+// packs into cartons into pallets. The innermost containers hold packs, each
+// level of Outer holds containers of the level below.
+type Packaging struct {
+	Container string      // name of the innermost containers, e.g. "carton"
+	Capacity  map[int]int // map[packSize]packs filling one container; sizes may share a container in proportion
+	Outer     []OuterContainer
+}
+
+// OuterContainer is a level of containers holding those of the level below,
+// e.g. pallets of 40 cartons
+type OuterContainer struct {
+	Name     string
+	Capacity int // containers of the level below filling one of these
+}
+
+// String lists the levels and their capacities, e.g.
+// "carton{23:20 53:12} > pallet{40}"; it identifies a packaging in cache keys
+func (p Packaging) String() string {
+	// fmt prints maps sorted by key
+	levels := []string{fmt.Sprintf("%s%v", p.Container, p.Capacity)}
+	for _, outer := range p.Outer {
+		levels = append(levels, fmt.Sprintf("%s{%d}", outer.Name, outer.Capacity))
+	}
+
+	return strings.Join(levels, " > ")
+}
+
+// ContainerGroup is Count identical containers of one level: the innermost
+// level lists the packs in each container, outer levels the groups of
+// containers inside each one
+type ContainerGroup struct {
+	Name     string
+	Count    int
+	Packs    map[int]int      // map[packSize]count in each container, innermost level only
+	Contents []ContainerGroup // outer levels only
+}
+
+// LevelCount is how many containers of a level a plan ships in
+type LevelCount struct {
+	Name  string
+	Count int
+}
+
+// PackagingPlan is the containers of a plan: the tree of its outermost
+// containers, and the number of containers per level, innermost first
+type PackagingPlan struct {
+	Containers []ContainerGroup
+	Levels     []LevelCount
+}
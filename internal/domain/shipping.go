@@ -0,0 +1,27 @@
+package domain
+
+import "fmt"
+
+// ShipmentLimits caps the weight and volume of each shipment of a plan, e.g.
+// the parcel limits of a courier. Weights and volumes use any unit as long as
+// the maximum uses the same; a zero maximum is no limit.
+type ShipmentLimits struct {
+	Weights   map[int]int // map[packSize]weight of one pack, required when MaxWeight is set
+	Volumes   map[int]int // map[packSize]volume of one pack, required when MaxVolume is set
+	MaxWeight int
+	MaxVolume int
+}
+
+// String identifies the limits in cache keys
+func (l ShipmentLimits) String() string {
+	// fmt prints maps sorted by key
+	return fmt.Sprintf("weights=%v volumes=%v maxWeight=%d maxVolume=%d", l.Weights, l.Volumes, l.MaxWeight, l.MaxVolume)
+}
+
+// ShipmentGroup is Count identical shipments of a plan
+type ShipmentGroup struct {
+	Count  int
+	Packs  map[int]int // map[packSize]count in each shipment
+	Weight int         // of each shipment
+	Volume int         // of each shipment
+}
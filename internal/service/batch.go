@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ignis/internal/domain"
+)
+
+// CalculateBatch answers many requests, filling one set of DP tables for all
+// requests that share pack sizes, stock and pack weights. The shared tables
+// cover the largest amount of the group, so a group costs about as much as its
+// largest request instead of the sum of all of them.
+func (s *PackageCalculatorService) CalculateBatch(ctx context.Context, reqs []domain.CalculateRequest) ([]domain.BatchResult, error) {
+	results := make([]domain.BatchResult, len(reqs))
+	groups := make(map[string]*batchGroup)
+	var order []*batchGroup
+
+	for i, req := range reqs {
+		if err := validateRequest(req); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		// Huge amounts are answered on the residue graph one by one, requests
+		// with pack constraints on stages of their own
+		if usesResidues(req) || req.Constraints != nil {
+			result, err := s.Calculate(ctx, req)
+			if isContextError(err) {
+				return nil, err
+			}
+			results[i] = domain.BatchResult{Result: result, Err: err}
+			continue
+		}
+
+		if err := s.checkLimits(req, tablesMemory(req, false)); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		sizes := uniqueSorted(req.PackSizes)
+		weights, err := packWeights(sizes, req)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		key := fmt.Sprint(sizes, weights, stockFor(sizes, req.Stock))
+		group, ok := groups[key]
+		if !ok {
+			group = &batchGroup{sizes: sizes, weights: weights, stock: req.Stock}
+			groups[key] = group
+			order = append(order, group)
+		}
+		group.items = append(group.items, i)
+		group.limit = max(group.limit, stagesLimit(req))
+	}
+
+	for _, group := range order {
+		if err := s.solveGroup(ctx, group, reqs, results); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// batchGroup is the requests of a batch answered by the same DP stages
+type batchGroup struct {
+	sizes   []int
+	weights []score
+	stock   map[int]int
+	limit   int   // largest amount any request of the group needs
+	items   []int // indexes into the batch
+}
+
+// solveGroup fills the tables of a group once and answers each of its requests.
+// A group whose shared tables would exceed the memory limit is solved request by
+// request, as each of them passed the limit on its own.
+func (s *PackageCalculatorService) solveGroup(ctx context.Context, group *batchGroup, reqs []domain.CalculateRequest, results []domain.BatchResult) error {
+	memory := tablesMemoryFor(group.sizes, group.stock, group.limit, false)
+	if len(group.items) == 1 || (s.limits.MaxMemoryBytes > 0 && memory > s.limits.MaxMemoryBytes) {
+		for _, i := range group.items {
+			result, err := s.Calculate(ctx, reqs[i])
+			if isContextError(err) {
+				return err
+			}
+			results[i] = domain.BatchResult{Result: result, Err: err}
+		}
+		return nil
+	}
+
+	stages, parent, err := s.fillTables(ctx, group.sizes, group.weights, group.stock, group.limit, false)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range group.items {
+		p, err := s.pickTotal(ctx, reqs[i], group.sizes, group.weights, stages, parent)
+		if isContextError(err) {
+			return err
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Result = buildResult(reqs[i], p.packages(), p.total)
+	}
+
+	return nil
+}
+
+// stockFor keeps the stock limits of the given sizes, limits of other sizes
+// do not change the stages
+func stockFor(sizes []int, stock map[int]int) map[int]int {
+	limited := make(map[int]int)
+	for _, size := range sizes {
+		if available, ok := stock[size]; ok {
+			limited[size] = available
+		}
+	}
+
+	return limited
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ignis/internal/domain"
+	"reflect"
+	"testing"
+)
+
+func TestPackageCalculatorService_CalculateBatch(t *testing.T) {
+	service := NewPackageCalculatorService()
+
+	t.Run("matches Calculate item by item", func(t *testing.T) {
+		var reqs []domain.CalculateRequest
+		for amount := 1; amount <= 400; amount += 7 {
+			reqs = append(reqs,
+				domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: amount},
+				domain.CalculateRequest{PackSizes: []int{53, 31, 23}, Amount: amount, Mode: domain.ModeOverfill},
+				domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: amount, Stock: map[int]int{53: 2, 31: 3}},
+				domain.CalculateRequest{
+					PackSizes: []int{5, 12},
+					Amount:    amount,
+					Mode:      domain.ModeOverfill,
+					Objective: domain.ObjectiveLowestCost,
+					Costs:     map[int]int{5: 100, 12: 250},
+				},
+			)
+		}
+		reqs = append(reqs, domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 2_000_000})
+
+		results, err := service.CalculateBatch(context.Background(), reqs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != len(reqs) {
+			t.Fatalf("expected %d results, got %d", len(reqs), len(results))
+		}
+
+		for i, req := range reqs {
+			want, wantErr := service.Calculate(context.Background(), req)
+			if !reflect.DeepEqual(results[i].Result, want) {
+				t.Fatalf("item %d (%+v): expected %+v, got %+v", i, req, want, results[i].Result)
+			}
+			if (wantErr == nil) != (results[i].Err == nil) || (wantErr != nil && wantErr.Error() != results[i].Err.Error()) {
+				t.Fatalf("item %d (%+v): expected error %v, got %v", i, req, wantErr, results[i].Err)
+			}
+		}
+	})
+
+	t.Run("errors are per item", func(t *testing.T) {
+		results, err := service.CalculateBatch(context.Background(), []domain.CalculateRequest{
+			{PackSizes: []int{5, 10}, Amount: 20},
+			{PackSizes: []int{5, 10}, Amount: 7},
+			{PackSizes: []int{5, 0}, Amount: 5},
+			{PackSizes: []int{5, 10}, Amount: 5, Objective: domain.ObjectiveLowestCost},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if results[0].Err != nil || results[0].Result.Packages[10] != 2 {
+			t.Errorf("item 0: expected two 10 packs, got %+v", results[0])
+		}
+		for i, want := range []error{domain.ErrNoCombination, domain.ErrInvalidPackSize, domain.ErrInvalidCost} {
+			if got := results[i+1]; got.Result != nil || !errors.Is(got.Err, want) {
+				t.Errorf("item %d: expected %v, got %+v", i+1, want, got)
+			}
+		}
+	})
+
+	t.Run("cancelled context fails the batch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := service.CalculateBatch(ctx, []domain.CalculateRequest{
+			{PackSizes: []int{5, 10}, Amount: 20},
+			{PackSizes: []int{5, 10}, Amount: 30},
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
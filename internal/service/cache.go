@@ -0,0 +1,267 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"ignis/internal/domain"
+	"log"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResultStore is a persistent cache level behind the in-memory one, so results
+// survive restarts. Its errors are logged and count as misses.
+type ResultStore interface {
+	// Load returns the plans stored under key and when they expire, zero when
+	// they do not; false when there are none or they expired
+	Load(ctx context.Context, key string) ([]*domain.CalculateResult, time.Time, bool, error)
+	// Save stores the plans under key, a zero ttl keeps them until replaced
+	Save(ctx context.Context, key string, plans []*domain.CalculateResult, ttl time.Duration) error
+}
+
+// CacheOptions sizes a CachingCalculator
+type CacheOptions struct {
+	Size  int           // most requests held in memory, at least 1
+	TTL   time.Duration // how long a result stays valid, zero keeps it until evicted
+	Store ResultStore   // optional persistent level
+}
+
+// CachingCalculator decorates a domain.PackageCalculator with an LRU cache of
+// successful results. Requests are keyed by their sorted, deduplicated pack
+// sizes and amount, together with everything else that changes the plan:
+// mode, objective, the stock and costs of those sizes, pack constraints,
+// packaging and shipping limits. Errors are never cached.
+type CachingCalculator struct {
+	next  domain.PackageCalculator
+	size  int
+	ttl   time.Duration
+	store ResultStore
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // most recently used first
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// cacheEntry is one cached request; Calculate entries hold a single plan
+type cacheEntry struct {
+	key       string
+	plans     []*domain.CalculateResult
+	expiresAt time.Time // zero when the entry does not expire
+}
+
+// NewCachingCalculator wraps next with a cache of opts.Size requests
+func NewCachingCalculator(next domain.PackageCalculator, opts CacheOptions) *CachingCalculator {
+	return &CachingCalculator{
+		next:    next,
+		size:    max(opts.Size, 1),
+		ttl:     opts.TTL,
+		store:   opts.Store,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *CachingCalculator) Calculate(ctx context.Context, req domain.CalculateRequest) (*domain.CalculateResult, error) {
+	key := cacheKey(req, 1)
+	if plans, ok := c.get(ctx, key); ok {
+		return plans[0], nil
+	}
+
+	result, err := c.next.Calculate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.put(ctx, key, []*domain.CalculateResult{result})
+
+	return result, nil
+}
+
+func (c *CachingCalculator) CalculateTopK(ctx context.Context, req domain.CalculateRequest, k int) ([]*domain.CalculateResult, error) {
+	key := cacheKey(req, k)
+	if plans, ok := c.get(ctx, key); ok {
+		return plans, nil
+	}
+
+	plans, err := c.next.CalculateTopK(ctx, req, k)
+	if err != nil {
+		return nil, err
+	}
+	c.put(ctx, key, plans)
+
+	return plans, nil
+}
+
+// CalculateBatch answers cached requests itself and passes the others on as
+// one batch, so they still share DP tables
+func (c *CachingCalculator) CalculateBatch(ctx context.Context, reqs []domain.CalculateRequest) ([]domain.BatchResult, error) {
+	results := make([]domain.BatchResult, len(reqs))
+	keys := make([]string, len(reqs))
+	var missed []domain.CalculateRequest
+	var missedIndexes []int
+	for i, req := range reqs {
+		keys[i] = cacheKey(req, 1)
+		if plans, ok := c.get(ctx, keys[i]); ok {
+			results[i].Result = plans[0]
+			continue
+		}
+		missed = append(missed, req)
+		missedIndexes = append(missedIndexes, i)
+	}
+	if len(missed) == 0 {
+		return results, nil
+	}
+
+	missedResults, err := c.next.CalculateBatch(ctx, missed)
+	if err != nil {
+		return nil, err
+	}
+	for j, result := range missedResults {
+		i := missedIndexes[j]
+		results[i] = result
+		if result.Err == nil {
+			c.put(ctx, keys[i], []*domain.CalculateResult{result.Result})
+		}
+	}
+
+	return results, nil
+}
+
+// AnalyzeFeasibility passes the analysis on uncached, it is cheap next to a calculation
+func (c *CachingCalculator) AnalyzeFeasibility(ctx context.Context, packSizes []int, amount int) (*domain.FeasibilityReport, error) {
+	return c.next.AnalyzeFeasibility(ctx, packSizes, amount)
+}
+
+// CacheStats implements domain.CacheStatsReporter
+func (c *CachingCalculator) CacheStats() domain.CacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return domain.CacheStats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Entries:  entries,
+		Capacity: c.size,
+	}
+}
+
+// get looks the key up in memory, then in the store, and counts the outcome.
+// Callers get copies, so changing a result cannot change the cache.
+func (c *CachingCalculator) get(ctx context.Context, key string) ([]*domain.CalculateResult, bool) {
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if entry.expiresAt.IsZero() || time.Now().Before(entry.expiresAt) {
+			c.lru.MoveToFront(element)
+			c.mu.Unlock()
+			c.hits.Add(1)
+			return cloneResults(entry.plans), true
+		}
+		c.remove(element)
+	}
+	c.mu.Unlock()
+
+	if c.store != nil {
+		plans, expiresAt, ok, err := c.store.Load(ctx, key)
+		if err != nil {
+			log.Printf("failed to load cached result: %v\n", err)
+		}
+		if ok && len(plans) > 0 {
+			c.hits.Add(1)
+			// The stored expiry holds, a reload must not extend it
+			c.remember(key, plans, expiresAt)
+			return cloneResults(plans), true
+		}
+	}
+
+	c.misses.Add(1)
+	return nil, false
+}
+
+// put caches copies of the plans of a request in memory and in the store
+func (c *CachingCalculator) put(ctx context.Context, key string, plans []*domain.CalculateResult) {
+	plans = cloneResults(plans)
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+	c.remember(key, plans, expiresAt)
+
+	if c.store != nil {
+		if err := c.store.Save(ctx, key, plans, c.ttl); err != nil {
+			log.Printf("failed to save cached result: %v\n", err)
+		}
+	}
+}
+
+// remember adds the plans to the LRU until expiresAt, evicting the least
+// recently used request when full
+func (c *CachingCalculator) remember(key string, plans []*domain.CalculateResult, expiresAt time.Time) {
+	entry := &cacheEntry{key: key, plans: plans, expiresAt: expiresAt}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an element, c.mu must be held
+func (c *CachingCalculator) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// cacheKey identifies the plans of a request: the same key means the same
+// answer, whatever order or repetitions the pack sizes were given in
+func cacheKey(req domain.CalculateRequest, k int) string {
+	sizes := uniqueSorted(req.PackSizes)
+
+	packaging := "none"
+	if req.Packaging != nil {
+		packaging = req.Packaging.String()
+	}
+	shipping := "none"
+	if req.Shipping != nil {
+		shipping = req.Shipping.String()
+	}
+	constraints := "none"
+	if req.Constraints != nil {
+		constraints = req.Constraints.String()
+	}
+
+	// fmt prints maps sorted by key
+	return fmt.Sprintf("sizes=%v amount=%d mode=%s objective=%s stock=%v costs=%v constraints={%s} packaging=%s shipping={%s} plans=%d",
+		sizes, req.Amount, req.Mode, req.Objective, stockFor(sizes, req.Stock), stockFor(sizes, req.Costs), constraints, packaging, shipping, k)
+}
+
+func cloneResults(plans []*domain.CalculateResult) []*domain.CalculateResult {
+	clones := make([]*domain.CalculateResult, len(plans))
+	for i, plan := range plans {
+		clones[i] = cloneResult(plan)
+	}
+
+	return clones
+}
+
+func cloneResult(result *domain.CalculateResult) *domain.CalculateResult {
+	clone := *result
+	clone.Packages = maps.Clone(result.Packages)
+	clone.Costs = maps.Clone(result.Costs)
+	// The packaging tree and shipments are never changed once built, clones share them
+
+	return &clone
+}
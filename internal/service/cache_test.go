@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"ignis/internal/domain"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingCalculator counts the requests that reach the wrapped calculator
+type countingCalculator struct {
+	domain.PackageCalculator
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *countingCalculator) count(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls += n
+}
+
+func (c *countingCalculator) Calculate(ctx context.Context, req domain.CalculateRequest) (*domain.CalculateResult, error) {
+	c.count(1)
+	return c.PackageCalculator.Calculate(ctx, req)
+}
+
+func (c *countingCalculator) CalculateTopK(ctx context.Context, req domain.CalculateRequest, k int) ([]*domain.CalculateResult, error) {
+	c.count(1)
+	return c.PackageCalculator.CalculateTopK(ctx, req, k)
+}
+
+func (c *countingCalculator) CalculateBatch(ctx context.Context, reqs []domain.CalculateRequest) ([]domain.BatchResult, error) {
+	c.count(len(reqs))
+	return c.PackageCalculator.CalculateBatch(ctx, reqs)
+}
+
+// memoryStore is a ResultStore shared by the caches of a test, like the table
+// is shared across restarts
+type memoryStore struct {
+	mu        sync.Mutex
+	plans     map[string][]*domain.CalculateResult
+	expiresAt map[string]time.Time
+}
+
+func (s *memoryStore) Load(ctx context.Context, key string) ([]*domain.CalculateResult, time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plans, ok := s.plans[key]
+	expiresAt := s.expiresAt[key]
+	if !expiresAt.IsZero() && !time.Now().Before(expiresAt) {
+		return nil, time.Time{}, false, nil
+	}
+	return plans, expiresAt, ok, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, key string, plans []*domain.CalculateResult, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.plans == nil {
+		s.plans = make(map[string][]*domain.CalculateResult)
+		s.expiresAt = make(map[string]time.Time)
+	}
+	s.plans[key] = plans
+	s.expiresAt[key] = time.Time{}
+	if ttl > 0 {
+		s.expiresAt[key] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func TestCachingCalculator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normalizes pack sizes", func(t *testing.T) {
+		next := &countingCalculator{PackageCalculator: NewPackageCalculatorService()}
+		cache := NewCachingCalculator(next, CacheOptions{Size: 10})
+
+		first, err := cache.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, err := cache.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{53, 23, 31, 23}, Amount: 263})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("expected the same plan, got %+v and %+v", first, second)
+		}
+		if next.calls != 1 {
+			t.Errorf("expected 1 calculation, got %d", next.calls)
+		}
+		if stats := cache.CacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 || stats.Capacity != 10 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("keys everything that changes the plan", func(t *testing.T) {
+		next := &countingCalculator{PackageCalculator: NewPackageCalculatorService()}
+		cache := NewCachingCalculator(next, CacheOptions{Size: 10})
+
+		reqs := []domain.CalculateRequest{
+			{PackSizes: []int{23, 31, 53}, Amount: 263},
+			{PackSizes: []int{23, 31, 53}, Amount: 500},
+			{PackSizes: []int{23, 31, 53}, Amount: 263, Mode: domain.ModeOverfill},
+			{PackSizes: []int{23, 31, 53}, Amount: 263, Stock: map[int]int{31: 10}},
+			{PackSizes: []int{23, 31, 53}, Amount: 263, Costs: map[int]int{23: 1, 31: 1, 53: 1}, Objective: domain.ObjectiveLowestCost},
+		}
+		for _, req := range reqs {
+			if _, err := cache.Calculate(ctx, req); err != nil {
+				t.Fatalf("unexpected error for %+v: %v", req, err)
+			}
+		}
+		if _, err := cache.CalculateTopK(ctx, reqs[0], 3); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if next.calls != len(reqs)+1 {
+			t.Errorf("expected every request to be calculated, got %d calculations", next.calls)
+		}
+	})
+
+	t.Run("does not cache errors", func(t *testing.T) {
+		next := &countingCalculator{PackageCalculator: NewPackageCalculatorService()}
+		cache := NewCachingCalculator(next, CacheOptions{Size: 10})
+
+		req := domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 7}
+		for range 2 {
+			if _, err := cache.Calculate(ctx, req); err == nil {
+				t.Fatal("expected an error")
+			}
+		}
+		if next.calls != 2 || cache.CacheStats().Entries != 0 {
+			t.Errorf("expected the error to be calculated twice, got %d calculations", next.calls)
+		}
+	})
+
+	t.Run("evicts the least recently used request", func(t *testing.T) {
+		next := &countingCalculator{PackageCalculator: NewPackageCalculatorService()}
+		cache := NewCachingCalculator(next, CacheOptions{Size: 2})
+
+		for _, amount := range []int{10, 20, 10, 30, 10, 20} {
+			if _, err := cache.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{5, 10}, Amount: amount}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		// 10 stays in use, so 20 is evicted by 30 and calculated again
+		if stats := cache.CacheStats(); stats.Hits != 2 || stats.Misses != 4 || stats.Entries != 2 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("expires entries after the TTL", func(t *testing.T) {
+		next := &countingCalculator{PackageCalculator: NewPackageCalculatorService()}
+		cache := NewCachingCalculator(next, CacheOptions{Size: 10, TTL: 20 * time.Millisecond})
+
+		req := domain.CalculateRequest{PackSizes: []int{5, 10}, Amount: 25}
+		cache.Calculate(ctx, req)
+		cache.Calculate(ctx, req)
+		time.Sleep(30 * time.Millisecond)
+		cache.Calculate(ctx, req)
+
+		if next.calls != 2 {
+			t.Errorf("expected the expired result to be calculated again, got %d calculations", next.calls)
+		}
+	})
+
+	t.Run("returns copies", func(t *testing.T) {
+		cache := NewCachingCalculator(NewPackageCalculatorService(), CacheOptions{Size: 10})
+
+		req := domain.CalculateRequest{PackSizes: []int{5, 10}, Amount: 25}
+		result, _ := cache.Calculate(ctx, req)
+		result.Packages[5] = 100
+
+		cached, _ := cache.Calculate(ctx, req)
+		if cached.Packages[5] != 1 {
+			t.Errorf("expected the cache to keep its plan, got %v", cached.Packages)
+		}
+	})
+
+	t.Run("batches only the misses", func(t *testing.T) {
+		next := &countingCalculator{PackageCalculator: NewPackageCalculatorService()}
+		cache := NewCachingCalculator(next, CacheOptions{Size: 10})
+
+		cache.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263})
+		results, err := cache.CalculateBatch(ctx, []domain.CalculateRequest{
+			{PackSizes: []int{53, 31, 23}, Amount: 263},
+			{PackSizes: []int{23, 31, 53}, Amount: 7},
+			{PackSizes: []int{23, 31, 53}, Amount: 500},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if next.calls != 3 {
+			t.Errorf("expected 1 calculation and a batch of 2, got %d calculations", next.calls)
+		}
+		if results[0].Result == nil || results[0].Result.Total != 263 || results[1].Err == nil || results[2].Result == nil {
+			t.Errorf("unexpected results %+v", results)
+		}
+		if stats := cache.CacheStats(); stats.Entries != 2 {
+			t.Errorf("expected the successful requests to be cached, got %+v", stats)
+		}
+	})
+
+	t.Run("loads results from the store after a restart", func(t *testing.T) {
+		store := &memoryStore{}
+		req := domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263}
+
+		before := NewCachingCalculator(NewPackageCalculatorService(), CacheOptions{Size: 10, Store: store})
+		want, _ := before.Calculate(ctx, req)
+
+		next := &countingCalculator{PackageCalculator: NewPackageCalculatorService()}
+		after := NewCachingCalculator(next, CacheOptions{Size: 10, Store: store})
+		got, err := after.Calculate(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if next.calls != 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("expected the stored plan without calculating, got %+v after %d calculations", got, next.calls)
+		}
+		if stats := after.CacheStats(); stats.Hits != 1 || stats.Entries != 1 {
+			t.Errorf("expected a hit kept in memory, got %+v", stats)
+		}
+	})
+
+	t.Run("keeps the expiry of a stored result", func(t *testing.T) {
+		store := &memoryStore{}
+		req := domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263}
+
+		// The stored row expires soon, the restarted cache keeps results longer
+		before := NewCachingCalculator(NewPackageCalculatorService(), CacheOptions{Size: 10, TTL: 30 * time.Millisecond, Store: store})
+		before.Calculate(ctx, req)
+
+		next := &countingCalculator{PackageCalculator: NewPackageCalculatorService()}
+		after := NewCachingCalculator(next, CacheOptions{Size: 10, TTL: time.Hour, Store: store})
+		after.Calculate(ctx, req)
+		time.Sleep(40 * time.Millisecond)
+		after.Calculate(ctx, req)
+
+		if next.calls != 1 {
+			t.Errorf("expected the result to expire with its row, got %d calculations", next.calls)
+		}
+	})
+}
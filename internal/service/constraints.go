@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ignis/internal/domain"
+	"maps"
+	"slices"
+)
+
+// validateConstraints rejects pack constraints on sizes the request does not
+// offer, with negative counts, or whose minimum is above their maximum; nil
+// constraints are valid
+func validateConstraints(constraints *domain.PackConstraints, packSizes []int, amount int) error {
+	if constraints == nil {
+		return nil
+	}
+
+	for _, constraint := range constraints.List() {
+		if !slices.Contains(packSizes, constraint.PackSize) {
+			return fmt.Errorf("%w: pack size %d is not offered", domain.ErrInvalidConstraint, constraint.PackSize)
+		}
+		if constraint.Count < 0 {
+			return fmt.Errorf("%w: count for pack size %d cannot be negative", domain.ErrInvalidConstraint, constraint.PackSize)
+		}
+	}
+
+	fewest, most := constraintBounds(*constraints)
+	for size, count := range fewest {
+		if count > amount {
+			return fmt.Errorf("%w: at least %d of pack size %d is more packs than the amount", domain.ErrInvalidConstraint, count, size)
+		}
+		if limit, ok := most[size]; ok && count > limit {
+			return fmt.Errorf("%w: pack size %d needs at least %d and at most %d packs", domain.ErrInvalidConstraint, size, count, limit)
+		}
+	}
+
+	return nil
+}
+
+// constraintBounds folds the constraints into the fewest and most packs of
+// each size: a required size needs at least one, a forbidden one at most none
+func constraintBounds(constraints domain.PackConstraints) (fewest, most map[int]int) {
+	fewest, most = make(map[int]int), make(map[int]int)
+	maps.Copy(fewest, constraints.Min)
+	maps.Copy(most, constraints.Max)
+	for _, size := range constraints.Required {
+		fewest[size] = max(fewest[size], 1)
+	}
+	for _, size := range constraints.Forbidden {
+		most[size] = 0
+	}
+
+	return fewest, most
+}
+
+// calculateConstrained answers a request with pack constraints with up to k
+// plans, explaining an infeasible one with the constraints in its way
+func (s *PackageCalculatorService) calculateConstrained(ctx context.Context, req domain.CalculateRequest, k int) ([]*domain.CalculateResult, error) {
+	plans, err := s.solveConstrained(ctx, req, k)
+	if errors.Is(err, domain.ErrNoCombination) {
+		return nil, s.constraintError(ctx, req)
+	}
+
+	return plans, err
+}
+
+// solveConstrained packs the minimum of every size up front and the rest of
+// the amount with the maximums as stock limits. Every plan holds the minimums,
+// so the best plans for the rest are the best plans for the request.
+func (s *PackageCalculatorService) solveConstrained(ctx context.Context, req domain.CalculateRequest, k int) ([]*domain.CalculateResult, error) {
+	// The amount and pack size limits hold for the whole request, the memory
+	// of the rest is checked when it is solved
+	if err := s.checkLimits(req, 0); err != nil {
+		return nil, err
+	}
+
+	fewest, most := constraintBounds(*req.Constraints)
+
+	rest := req
+	rest.Constraints, rest.Packaging, rest.Shipping = nil, nil, nil
+	rest.Stock = make(map[int]int)
+	maps.Copy(rest.Stock, req.Stock)
+	for size, limit := range most {
+		if available, limited := rest.Stock[size]; !limited || limit < available {
+			rest.Stock[size] = limit
+		}
+	}
+	for size, count := range fewest {
+		rest.Amount -= count * size
+		if available, limited := rest.Stock[size]; limited {
+			if available < count {
+				return nil, &domain.NoCombinationError{Amount: req.Amount, Mode: req.Mode}
+			}
+			rest.Stock[size] = available - count
+		}
+	}
+	if len(rest.Stock) == 0 {
+		rest.Stock = nil
+	}
+
+	var plans []*domain.CalculateResult
+	switch {
+	case rest.Amount > 0 && k == 1:
+		result, err := s.Calculate(ctx, rest)
+		if err != nil {
+			return nil, err
+		}
+		plans = []*domain.CalculateResult{result}
+	case rest.Amount > 0:
+		var err error
+		if plans, err = s.CalculateTopK(ctx, rest, k); err != nil {
+			return nil, err
+		}
+	case rest.Amount == 0 || req.Mode == domain.ModeOverfill:
+		// The minimums alone make up the amount, any other pack only adds to it
+		plans = []*domain.CalculateResult{{Packages: map[int]int{}}}
+	default:
+		return nil, &domain.NoCombinationError{Amount: req.Amount, Mode: req.Mode}
+	}
+
+	results := make([]*domain.CalculateResult, len(plans))
+	for i, plan := range plans {
+		packages, total := maps.Clone(plan.Packages), plan.Total
+		for size, count := range fewest {
+			if count > 0 {
+				packages[size] += count
+				total += count * size
+			}
+		}
+		results[i] = buildResult(req, packages, total)
+	}
+
+	return results, nil
+}
+
+// constraintError explains an infeasible request with pack constraints. A
+// request without a plan even unconstrained fails as such, otherwise its
+// constraints are relaxed one at a time and those it has a plan without named.
+func (s *PackageCalculatorService) constraintError(ctx context.Context, req domain.CalculateRequest) error {
+	// Only feasibility matters here, containers and shipments are left out
+	req.Packaging, req.Shipping = nil, nil
+
+	unconstrained := req
+	unconstrained.Constraints = nil
+	if _, err := s.Calculate(ctx, unconstrained); err != nil {
+		return err
+	}
+
+	constraints := req.Constraints.List()
+	noCombination := &domain.NoCombinationError{Amount: req.Amount, Mode: req.Mode}
+	for i, constraint := range constraints {
+		relaxed := req
+		relaxed.Constraints = domain.NewPackConstraints(slices.Delete(slices.Clone(constraints), i, i+1))
+		_, err := s.solveConstrained(ctx, relaxed, 1)
+		switch {
+		case err == nil:
+			noCombination.Conflicts = append(noCombination.Conflicts, constraint)
+		case !errors.Is(err, domain.ErrNoCombination):
+			return err
+		}
+	}
+	if len(noCombination.Conflicts) == 0 {
+		noCombination.Conflicts = constraints
+	}
+
+	return noCombination
+}
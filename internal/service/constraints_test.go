@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ignis/internal/domain"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestPackageCalculatorService_Constraints(t *testing.T) {
+	ctx := context.Background()
+	service := NewPackageCalculatorService()
+
+	t.Run("matches brute force", func(t *testing.T) {
+		sizes := []int{3, 7, 11}
+		rng := rand.New(rand.NewPCG(5, 6))
+		for range 300 {
+			req := domain.CalculateRequest{
+				PackSizes:   sizes,
+				Amount:      1 + rng.IntN(60),
+				Mode:        domain.Mode(rng.IntN(2)),
+				Constraints: &domain.PackConstraints{Min: map[int]int{}, Max: map[int]int{}},
+			}
+			for _, size := range sizes {
+				switch rng.IntN(5) {
+				case 0:
+					req.Constraints.Min[size] = rng.IntN(3)
+				case 1:
+					req.Constraints.Max[size] = rng.IntN(4)
+				case 2:
+					req.Constraints.Required = append(req.Constraints.Required, size)
+				case 3:
+					req.Constraints.Forbidden = append(req.Constraints.Forbidden, size)
+				}
+			}
+			fewest, most := constraintBounds(*req.Constraints)
+			if validateConstraints(req.Constraints, sizes, req.Amount) != nil {
+				continue
+			}
+
+			// Best total, then fewest packs, over every plan within the bounds
+			bestTotal, bestPacks := -1, 0
+			// Minimums of up to 2 packs of each size may overshoot by 42
+			highest := req.Amount + 42 + 11
+			for a := 0; a*3 <= highest; a++ {
+				for b := 0; a*3+b*7 <= highest; b++ {
+					for c := 0; a*3+b*7+c*11 <= highest; c++ {
+						counts := map[int]int{3: a, 7: b, 11: c}
+						total := a*3 + b*7 + c*11
+						within := true
+						for size, count := range counts {
+							if limit, ok := most[size]; count < fewest[size] || ok && count > limit {
+								within = false
+							}
+						}
+						if !within || total < req.Amount || req.Mode == domain.ModeExact && total != req.Amount {
+							continue
+						}
+						if bestTotal < 0 || total < bestTotal || total == bestTotal && a+b+c < bestPacks {
+							bestTotal, bestPacks = total, a+b+c
+						}
+					}
+				}
+			}
+
+			result, err := service.Calculate(ctx, req)
+			if bestTotal < 0 {
+				if !errors.Is(err, domain.ErrNoCombination) {
+					t.Fatalf("%d %+v: expected no combination, got %+v, %v", req.Amount, *req.Constraints, result, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%d %+v: unexpected error: %v", req.Amount, *req.Constraints, err)
+			}
+			if result.Total != bestTotal || result.PackCount != bestPacks {
+				t.Fatalf("%d %+v: expected total %d in %d packs, got %+v", req.Amount, *req.Constraints, bestTotal, bestPacks, result)
+			}
+			for size, count := range result.Packages {
+				if limit, ok := most[size]; count < fewest[size] || ok && count > limit {
+					t.Fatalf("%d %+v: %d packs of %d break the constraints", req.Amount, *req.Constraints, count, size)
+				}
+			}
+		}
+	})
+
+	t.Run("minimums over the amount in overfill mode", func(t *testing.T) {
+		req := domain.CalculateRequest{PackSizes: []int{3, 5}, Amount: 4, Mode: domain.ModeOverfill, Constraints: &domain.PackConstraints{Required: []int{5}}}
+		result, err := service.Calculate(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Total != 5 || result.Overshoot != 1 || result.Packages[5] != 1 || result.PackCount != 1 {
+			t.Errorf("expected a single pack of 5, got %+v", result)
+		}
+	})
+
+	t.Run("ranks constrained plans", func(t *testing.T) {
+		req := domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263, Constraints: &domain.PackConstraints{Min: map[int]int{23: 1}}}
+		plans, err := service.CalculateTopK(ctx, req, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		best, _ := service.Calculate(ctx, req)
+		if len(plans) == 0 || plans[0].PackCount != best.PackCount {
+			t.Fatalf("expected the best plan %+v first, got %+v", best, plans)
+		}
+		for _, plan := range plans {
+			if plan.Total != 263 || plan.Packages[23] < 1 {
+				t.Errorf("plan %+v breaks the constraints", plan)
+			}
+		}
+	})
+
+	t.Run("explains infeasible constraints", func(t *testing.T) {
+		// 10 is only 5+5 with packs of 3 and 5
+		tests := []struct {
+			constraints domain.PackConstraints
+			want        string
+		}{
+			{domain.PackConstraints{Required: []int{3}}, "pack constraints cannot be met: pack size 3 required"},
+			{domain.PackConstraints{Forbidden: []int{5}}, "pack constraints cannot be met: pack size 5 forbidden"},
+			{domain.PackConstraints{Max: map[int]int{5: 1}, Min: map[int]int{3: 0}}, "pack constraints cannot be met: at most 1 of pack size 5"},
+			{domain.PackConstraints{Required: []int{3}, Forbidden: []int{5}}, "pack constraints cannot be met: pack size 3 required, pack size 5 forbidden"},
+		}
+		for _, tt := range tests {
+			_, err := service.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{3, 5}, Amount: 10, Constraints: &tt.constraints})
+			var noCombination *domain.NoCombinationError
+			if !errors.As(err, &noCombination) || err.Error() != tt.want {
+				t.Errorf("%+v: expected %q, got %v", tt.constraints, tt.want, err)
+			}
+		}
+
+		// Without a plan even unconstrained the request fails as such
+		_, err := service.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{3, 5}, Amount: 7, Constraints: &domain.PackConstraints{Required: []int{3}}})
+		var noCombination *domain.NoCombinationError
+		if !errors.As(err, &noCombination) || len(noCombination.Conflicts) > 0 {
+			t.Errorf("expected a plain no combination error, got %v", err)
+		}
+	})
+
+	t.Run("rejects invalid constraints", func(t *testing.T) {
+		for _, constraints := range []domain.PackConstraints{
+			{Required: []int{4}},
+			{Min: map[int]int{3: -1}},
+			{Max: map[int]int{5: -1}},
+			{Min: map[int]int{3: 3}, Max: map[int]int{3: 2}},
+			{Required: []int{5}, Forbidden: []int{5}},
+			{Min: map[int]int{3: 11}},
+		} {
+			_, err := service.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{3, 5}, Amount: 10, Constraints: &constraints})
+			if !errors.Is(err, domain.ErrInvalidConstraint) {
+				t.Errorf("%+v: expected %v, got %v", constraints, domain.ErrInvalidConstraint, err)
+			}
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		reqs := []domain.CalculateRequest{
+			{PackSizes: []int{3, 5}, Amount: 15},
+			{PackSizes: []int{3, 5}, Amount: 15, Constraints: &domain.PackConstraints{Forbidden: []int{5}}},
+		}
+		results, err := service.CalculateBatch(ctx, reqs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := results[0].Result.Packages; got[5] != 3 {
+			t.Errorf("expected 3 packs of 5, got %v", got)
+		}
+		if got := results[1].Result.Packages; got[3] != 5 || got[5] != 0 {
+			t.Errorf("expected 5 packs of 3, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"ignis/internal/domain"
+)
+
+// unreachableListLimit caps the unreachable amounts a feasibility report lists
+const unreachableListLimit = 100
+
+// AnalyzeFeasibility works from the smallest packable amount of each residue
+// modulo the largest size: an amount packs exactly iff it is at least the
+// smallest packable amount of its residue. The largest amount that does not is
+// then the largest of those minus the largest size, and each residue has
+// (smallest - residue) / largest amounts below its smallest one.
+func (s *PackageCalculatorService) AnalyzeFeasibility(ctx context.Context, packSizes []int, amount int) (*domain.FeasibilityReport, error) {
+	if len(packSizes) == 0 {
+		return nil, domain.ErrEmptyPackSizes
+	}
+	for _, size := range packSizes {
+		if size <= 0 {
+			return nil, fmt.Errorf("%w: %d", domain.ErrInvalidPackSize, size)
+		}
+	}
+	if amount < 0 {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	sizes := uniqueSorted(packSizes)
+	if err := s.checkLimits(domain.CalculateRequest{PackSizes: packSizes, Amount: amount}, feasibilityMemory(sizes)); err != nil {
+		return nil, err
+	}
+
+	reach, err := smallestReachablePerResidue(ctx, sizes)
+	if err != nil {
+		return nil, err
+	}
+	largest := sizes[len(sizes)-1]
+	packable := func(x int) bool {
+		smallest := reach[x%largest]
+		return smallest >= 0 && x >= smallest
+	}
+
+	report := &domain.FeasibilityReport{PackSizes: sizes, GCD: sizes[0], Frobenius: -1}
+	for _, size := range sizes[1:] {
+		report.GCD = gcd(report.GCD, size)
+	}
+	for r, smallest := range reach {
+		if smallest < 0 {
+			continue
+		}
+		report.Frobenius = max(report.Frobenius, smallest-largest)
+		report.UnreachableCount += (smallest - r) / largest
+	}
+
+	for x, step := report.GCD, 1; x <= report.Frobenius && len(report.Unreachable) < unreachableListLimit; x, step = x+report.GCD, step+1 {
+		if err := checkCancelled(ctx, step); err != nil {
+			return nil, err
+		}
+		if !packable(x) {
+			report.Unreachable = append(report.Unreachable, x)
+		}
+	}
+
+	// Only multiples of the GCD can pack and multiples of the smallest size
+	// always do, so neither scan takes more than smallest / GCD steps
+	if amount > 0 {
+		nearest := &domain.NearestAmounts{Amount: amount, Reachable: packable(amount)}
+		for x, step := (amount-1)/report.GCD*report.GCD, 1; x > 0 && nearest.Below == 0; x, step = x-report.GCD, step+1 {
+			if err := checkCancelled(ctx, step); err != nil {
+				return nil, err
+			}
+			if packable(x) {
+				nearest.Below = x
+			}
+		}
+		for x, step := (amount/report.GCD+1)*report.GCD, 1; nearest.Above == 0; x, step = x+report.GCD, step+1 {
+			if err := checkCancelled(ctx, step); err != nil {
+				return nil, err
+			}
+			if packable(x) {
+				nearest.Above = x
+			}
+		}
+		report.Nearest = nearest
+	}
+
+	return report, nil
+}
+
+// feasibilityMemory approximates the bytes of a feasibility analysis: the
+// smallest amount per residue modulo the largest size and the queue entries
+// pushed for each of them
+func feasibilityMemory(sizes []int) int64 {
+	const itemBytes = 16 // amountItem
+
+	return int64(sizes[len(sizes)-1]) * (8 + int64(len(sizes))*itemBytes)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
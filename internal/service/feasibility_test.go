@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ignis/internal/domain"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestPackageCalculatorService_AnalyzeFeasibility(t *testing.T) {
+	ctx := context.Background()
+	service := NewPackageCalculatorService()
+
+	tests := []struct {
+		name      string
+		packSizes []int
+		amount    int
+		want      domain.FeasibilityReport
+	}{
+		{
+			name:      "chicken nuggets",
+			packSizes: []int{20, 9, 6},
+			amount:    43,
+			want: domain.FeasibilityReport{
+				PackSizes:        []int{6, 9, 20},
+				GCD:              1,
+				Frobenius:        43,
+				UnreachableCount: 22,
+				Unreachable:      []int{1, 2, 3, 4, 5, 7, 8, 10, 11, 13, 14, 16, 17, 19, 22, 23, 25, 28, 31, 34, 37, 43},
+				Nearest:          &domain.NearestAmounts{Amount: 43, Below: 42, Above: 44},
+			},
+		},
+		{
+			name:      "below the smallest size",
+			packSizes: []int{3, 5},
+			amount:    2,
+			want: domain.FeasibilityReport{
+				PackSizes:        []int{3, 5},
+				GCD:              1,
+				Frobenius:        7,
+				UnreachableCount: 4,
+				Unreachable:      []int{1, 2, 4, 7},
+				Nearest:          &domain.NearestAmounts{Amount: 2, Above: 3},
+			},
+		},
+		{
+			name:      "shared factor",
+			packSizes: []int{4, 6},
+			amount:    7,
+			want: domain.FeasibilityReport{
+				PackSizes:        []int{4, 6},
+				GCD:              2,
+				Frobenius:        2,
+				UnreachableCount: 1,
+				Unreachable:      []int{2},
+				Nearest:          &domain.NearestAmounts{Amount: 7, Below: 6, Above: 8},
+			},
+		},
+		{
+			name:      "large shared factor",
+			packSizes: []int{1500, 1000},
+			amount:    2700,
+			want: domain.FeasibilityReport{
+				PackSizes:        []int{1000, 1500},
+				GCD:              500,
+				Frobenius:        500,
+				UnreachableCount: 1,
+				Unreachable:      []int{500},
+				Nearest:          &domain.NearestAmounts{Amount: 2700, Below: 2500, Above: 3000},
+			},
+		},
+		{
+			name:      "single size without an amount",
+			packSizes: []int{250},
+			want:      domain.FeasibilityReport{PackSizes: []int{250}, GCD: 250, Frobenius: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.AnalyzeFeasibility(ctx, tt.packSizes, tt.amount)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, *got)
+			}
+		})
+	}
+
+	t.Run("matches the DP tables", func(t *testing.T) {
+		rng := rand.New(rand.NewPCG(7, 8))
+		for range 100 {
+			sizes := []int{2 + rng.IntN(30), 2 + rng.IntN(30), 2 + rng.IntN(30)}
+			report, err := service.AnalyzeFeasibility(ctx, sizes, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			limit := 2000
+			stages, _ := fillStages(ctx, uniqueSorted(sizes), []score{{packs: 1}, {packs: 1}, {packs: 1}}, nil, limit)
+			best := stages[len(stages)-1]
+			frobenius, count := -1, 0
+			for amount := 1; amount <= limit; amount++ {
+				if amount%report.GCD == 0 && best[amount] == unreachableScore {
+					frobenius = amount
+					count++
+				}
+			}
+			if report.Frobenius != frobenius || report.UnreachableCount != count {
+				t.Fatalf("%v: expected Frobenius number %d with %d unreachable, got %+v", sizes, frobenius, count, report)
+			}
+		}
+	})
+
+	t.Run("rejects invalid input", func(t *testing.T) {
+		if _, err := service.AnalyzeFeasibility(ctx, nil, 0); !errors.Is(err, domain.ErrEmptyPackSizes) {
+			t.Errorf("expected %v, got %v", domain.ErrEmptyPackSizes, err)
+		}
+		if _, err := service.AnalyzeFeasibility(ctx, []int{3, 0}, 0); !errors.Is(err, domain.ErrInvalidPackSize) {
+			t.Errorf("expected %v, got %v", domain.ErrInvalidPackSize, err)
+		}
+		if _, err := service.AnalyzeFeasibility(ctx, []int{3, 5}, -1); !errors.Is(err, domain.ErrInvalidAmount) {
+			t.Errorf("expected %v, got %v", domain.ErrInvalidAmount, err)
+		}
+	})
+}
@@ -1,76 +1,633 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"fmt"
 	"ignis/internal/domain"
 	"math"
 	"sort"
+	"unsafe"
 )
 
+// unreachable marks amounts that cannot be packed in the DP tables. Scores
+// saturate at it, so only amounts that cannot be packed ever score it.
+const unreachable = math.MaxInt
+
+// cancelCheckInterval is how many DP cells are filled between context checks
+const cancelCheckInterval = 1 << 16
+
+// checkCancelled returns the context error every cancelCheckInterval steps
+func checkCancelled(ctx context.Context, step int) error {
+	if step%cancelCheckInterval != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// score ranks plans: lower cost wins, fewer packs breaks ties.
+// With ObjectiveFewestPacks every pack costs 0, so only packs count.
+type score struct {
+	cost  int
+	packs int
+}
+
+var unreachableScore = score{cost: unreachable, packs: unreachable}
+
+func (a score) less(b score) bool {
+	if a.cost != b.cost {
+		return a.cost < b.cost
+	}
+	return a.packs < b.packs
+}
+
+// plus returns a + n*w, saturating instead of wrapping around
+func (a score) plus(n int, w score) score {
+	if n == 1 {
+		return score{cost: saturatingAdd(a.cost, w.cost), packs: saturatingAdd(a.packs, w.packs)}
+	}
+	return score{cost: saturatingAdd(a.cost, saturatingMul(n, w.cost)), packs: saturatingAdd(a.packs, saturatingMul(n, w.packs))}
+}
+
+func saturatingAdd(a, b int) int {
+	sum := a + b
+	switch {
+	case a > 0 && b > 0 && sum < 0:
+		return math.MaxInt
+	case a < 0 && b < 0 && sum >= 0:
+		return math.MinInt
+	}
+	return sum
+}
+
+func saturatingMul(n, w int) int {
+	if n == 0 || w == 0 {
+		return 0
+	}
+	product := n * w
+	if product/w != n || product/n != w {
+		if (n < 0) != (w < 0) {
+			return math.MinInt
+		}
+		return math.MaxInt
+	}
+	return product
+}
+
+// Limits caps the resources a single request may use, zero means unlimited
+type Limits struct {
+	MaxAmount      int
+	MaxPackSizes   int
+	MaxMemoryBytes int64
+}
+
 // PackageCalculatorService implements the domain.PackageCalculator interface
-type PackageCalculatorService struct{}
+type PackageCalculatorService struct {
+	limits Limits
+	tables *TableRegistry // nil when DP stages are not kept between requests
+}
 
 // NewPackageCalculatorService creates a new instance of PackageCalculatorService
 func NewPackageCalculatorService() *PackageCalculatorService {
 	return &PackageCalculatorService{}
 }
 
-func (s *PackageCalculatorService) Calculate(req domain.CalculateRequest) (*domain.CalculateResult, error) {
+// NewPackageCalculatorServiceWithLimits creates a PackageCalculatorService that
+// rejects requests over the given limits with a *domain.LimitError
+func NewPackageCalculatorServiceWithLimits(limits Limits) *PackageCalculatorService {
+	return &PackageCalculatorService{limits: limits}
+}
+
+// NewPackageCalculatorServiceWithTables is NewPackageCalculatorServiceWithLimits
+// keeping the DP stages of requests without stock limits in tables, so later
+// requests for the same pack sizes reuse them
+func NewPackageCalculatorServiceWithTables(limits Limits, tables *TableRegistry) *PackageCalculatorService {
+	return &PackageCalculatorService{limits: limits, tables: tables}
+}
+
+func (s *PackageCalculatorService) Calculate(ctx context.Context, req domain.CalculateRequest) (*domain.CalculateResult, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+
+	if req.Constraints != nil {
+		plans, err := s.calculateConstrained(ctx, req, 1)
+		if err != nil {
+			return nil, err
+		}
+		return plans[0], nil
+	}
+
+	// Huge amounts would need DP tables of Amount+1 entries, the residue graph
+	// only needs one entry per residue modulo the largest pack size. A huge
+	// largest size can make the graph the bigger of the two, the DP tables are
+	// tried then.
+	if usesResidues(req) && s.checkLimits(req, residueMemory(req)) == nil {
+		if result, ok, err := calculateByResidues(ctx, req); ok {
+			return result, err
+		}
+	}
+
+	p, err := s.solve(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Reconstruct the counts from the tables
+	return buildResult(req, p.packages(), p.total), nil
+}
+
+// problem is a validated request together with its filled DP tables
+type problem struct {
+	req     domain.CalculateRequest
+	sizes   []int
+	weights []score
+	limit   int // largest amount covered by the stages
+	total   int // best shipped total
+	stages  [][]score
+	parent  []int32 // set when stages only holds the last stage, see fillRow
+}
+
+// packages reconstructs the counts of the picked total
+func (p *problem) packages() map[int]int {
+	if p.parent != nil {
+		return followParents(p.parent, p.sizes, p.total)
+	}
+	return reconstruct(p.stages, p.sizes, p.weights, p.req.Stock, p.total)
+}
+
+// solve validates the request, fills the DP tables and picks the shipped total.
+// staged asks for one stage per size even when a single row would do.
+func (s *PackageCalculatorService) solve(ctx context.Context, req domain.CalculateRequest, staged bool) (*problem, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+	if err := s.checkLimits(req, tablesMemory(req, staged)); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 1. Prepare and sort sizes (ascending helps DP efficiency)
+	sizes := uniqueSorted(req.PackSizes)
+	weights, err := packWeights(sizes, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Fill DP tables: O(Amount * PackSizes)
+	stages, parent, err := s.fillTables(ctx, sizes, weights, req.Stock, stagesLimit(req), staged)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.pickTotal(ctx, req, sizes, weights, stages, parent)
+}
+
+// stagesLimit is the largest amount the DP stages must cover for a request.
+// In overfill mode the smallest shippable total above the amount is always
+// below Amount+largest: dropping any pack from a bigger total keeps it >= Amount.
+func stagesLimit(req domain.CalculateRequest) int {
+	if req.Mode == domain.ModeOverfill {
+		sizes := uniqueSorted(req.PackSizes)
+		return req.Amount + sizes[len(sizes)-1] - 1
+	}
+	return req.Amount
+}
+
+// pickTotal picks the shipped total from filled tables: the amount itself, or in
+// overfill mode the first reachable total at or above it. The tables may cover
+// more than the request needs when they are shared by a batch.
+func (s *PackageCalculatorService) pickTotal(ctx context.Context, req domain.CalculateRequest, sizes []int, weights []score, stages [][]score, parent []int32) (*problem, error) {
+	limit := stagesLimit(req)
+	best := stages[len(stages)-1]
+
+	total := req.Amount
+	if req.Mode == domain.ModeOverfill {
+		for total <= limit && best[total] == unreachableScore {
+			total++
+		}
+	}
+
+	// Check if a solution exists
+	if total > limit || best[total] == unreachableScore {
+		if len(req.Stock) > 0 {
+			return nil, s.stockError(ctx, req)
+		}
+		return nil, &domain.NoCombinationError{Amount: req.Amount, Mode: req.Mode}
+	}
+
+	return &problem{
+		req:     req,
+		sizes:   sizes,
+		weights: weights,
+		limit:   limit,
+		total:   total,
+		stages:  stages,
+		parent:  parent,
+	}, nil
+}
+
+// validateRequest rejects requests no solver can answer
+func validateRequest(req domain.CalculateRequest) error {
 	if len(req.PackSizes) == 0 {
-		return nil, errors.New("pack sizes cannot be empty")
+		return domain.ErrEmptyPackSizes
+	}
+	for _, size := range req.PackSizes {
+		if size <= 0 {
+			return fmt.Errorf("%w: %d", domain.ErrInvalidPackSize, size)
+		}
 	}
 	if req.Amount <= 0 {
-		return nil, errors.New("amount must be greater than zero")
+		return domain.ErrInvalidAmount
+	}
+	for size, available := range req.Stock {
+		if available < 0 {
+			return fmt.Errorf("%w: pack size %d cannot have negative stock", domain.ErrInvalidStock, size)
+		}
+	}
+	for size, cost := range req.Costs {
+		if cost < 0 {
+			return fmt.Errorf("%w: cost for pack size %d cannot be negative", domain.ErrInvalidCost, size)
+		}
+		if cost > domain.MaxPackCost {
+			return fmt.Errorf("%w: cost for pack size %d over %d", domain.ErrInvalidCost, size, domain.MaxPackCost)
+		}
 	}
 
-	// 1. Prepare and sort sizes (ascending helps DP efficiency)
-	sizes := make([]int, len(req.PackSizes))
-	copy(sizes, req.PackSizes)
-	sort.Ints(sizes)
+	if err := validateConstraints(req.Constraints, req.PackSizes, req.Amount); err != nil {
+		return err
+	}
+	if err := validatePackaging(req.Packaging, req.PackSizes); err != nil {
+		return err
+	}
+
+	return validateShipping(req.Shipping, req.PackSizes)
+}
+
+// checkLimits rejects requests over the configured limits before any table is allocated
+func (s *PackageCalculatorService) checkLimits(req domain.CalculateRequest, memory int64) error {
+	if s.limits.MaxAmount > 0 && req.Amount > s.limits.MaxAmount {
+		return &domain.LimitError{Limit: domain.LimitAmount, Value: int64(req.Amount), Max: int64(s.limits.MaxAmount)}
+	}
+	if s.limits.MaxPackSizes > 0 && len(req.PackSizes) > s.limits.MaxPackSizes {
+		return &domain.LimitError{Limit: domain.LimitPackSizes, Value: int64(len(req.PackSizes)), Max: int64(s.limits.MaxPackSizes)}
+	}
+	if s.limits.MaxMemoryBytes > 0 && memory > s.limits.MaxMemoryBytes {
+		return &domain.LimitError{Limit: domain.LimitMemory, Value: memory, Max: s.limits.MaxMemoryBytes}
+	}
+
+	return nil
+}
+
+// tablesMemory approximates the bytes of the DP tables for a request
+func tablesMemory(req domain.CalculateRequest, staged bool) int64 {
+	return tablesMemoryFor(uniqueSorted(req.PackSizes), req.Stock, stagesLimit(req), staged)
+}
+
+// tablesMemoryFor approximates the bytes of the DP tables fillTables fills:
+// a single row when it may use one, one stage per size otherwise
+func tablesMemoryFor(sizes []int, stock map[int]int, limit int, staged bool) int64 {
+	if !staged && len(stockFor(sizes, stock)) == 0 {
+		return rowMemoryFor(limit)
+	}
+	return stagesMemoryFor(len(sizes), limit)
+}
+
+// stagesMemoryFor approximates the bytes of the DP stages for the given number
+// of distinct pack sizes covering amounts up to limit
+func stagesMemoryFor(sizes, limit int) int64 {
+	return (int64(limit) + 1) * int64(sizes+1) * int64(unsafe.Sizeof(score{}))
+}
+
+// rowMemoryFor approximates the bytes of a single DP row and its parents
+// covering amounts up to limit
+func rowMemoryFor(limit int) int64 {
+	return (int64(limit) + 1) * (int64(unsafe.Sizeof(score{})) + 4)
+}
+
+// residueMemory approximates the bytes of the residue graph for a request:
+// one label per residue modulo the largest pack size
+func residueMemory(req domain.CalculateRequest) int64 {
+	const labelOverhead = 64 // pointer, label header and heap entry
+	sizes := uniqueSorted(req.PackSizes)
+
+	return int64(sizes[len(sizes)-1]) * (labelOverhead + int64(len(sizes))*8)
+}
 
-	// 2. Setup DP arrays
-	// dp[i] = min packs needed for amount i
-	// parent[i] = the size of the pack used to get to amount i (for reconstruction)
-	dp := make([]int, req.Amount+1)
-	parent := make([]int, req.Amount+1)
+// usesResidues reports whether Calculate tries the residue graph for the request
+func usesResidues(req domain.CalculateRequest) bool {
+	return req.Amount >= residueAmountThreshold && len(req.Stock) == 0 && req.Objective == domain.ObjectiveFewestPacks
+}
 
-	// Initialize DP with "Infinity"
-	for i := 1; i <= req.Amount; i++ {
-		dp[i] = math.MaxInt32
+// buildResult wraps a plan shipping 'total' items into a CalculateResult
+func buildResult(req domain.CalculateRequest, packages map[int]int, total int) *domain.CalculateResult {
+	result := &domain.CalculateResult{
+		Packages:  packages,
+		Total:     total,
+		Overshoot: total - req.Amount,
+	}
+	for _, count := range packages {
+		result.PackCount += count
+	}
+	applyCosts(result, req.Costs)
+	if req.Packaging != nil {
+		result.Packaging = packContainers(packages, *req.Packaging)
+	}
+	if req.Shipping != nil {
+		result.Shipments = splitShipments(packages, *req.Shipping)
 	}
-	dp[0] = 0
 
-	// 3. Fill DP table: O(Amount * PackSizes)
-	//
+	return result
+}
+
+// stockError explains an infeasible stock-bounded request by solving it again
+// without limits and naming the sizes that plan would need more of
+func (s *PackageCalculatorService) stockError(ctx context.Context, req domain.CalculateRequest) error {
+	unbounded := req
+	unbounded.Stock = nil
+	result, err := s.Calculate(ctx, unbounded)
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int, 0, len(result.Packages))
+	for size := range result.Packages {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	noCombination := &domain.NoCombinationError{Amount: req.Amount, Mode: req.Mode}
 	for _, size := range sizes {
-		for i := size; i <= req.Amount; i++ {
-			if dp[i-size] != math.MaxInt32 {
-				// If using this pack results in FEWER total packs than what we had...
-				if dp[i-size]+1 < dp[i] {
-					dp[i] = dp[i-size] + 1
-					parent[i] = size
-				}
+		available, limited := req.Stock[size]
+		if limited && result.Packages[size] > available {
+			noCombination.Shortages = append(noCombination.Shortages, domain.StockShortage{
+				PackSize:  size,
+				Needed:    result.Packages[size],
+				Available: available,
+			})
+		}
+	}
+
+	return noCombination
+}
+
+// uniqueSorted returns the pack sizes in ascending order without duplicates
+func uniqueSorted(packSizes []int) []int {
+	sizes := make([]int, len(packSizes))
+	copy(sizes, packSizes)
+	sort.Ints(sizes)
+
+	unique := sizes[:0]
+	for i, size := range sizes {
+		if i == 0 || size != sizes[i-1] {
+			unique = append(unique, size)
+		}
+	}
+
+	return unique
+}
+
+// packWeights returns the score of a single pack of each size for the requested objective
+func packWeights(sizes []int, req domain.CalculateRequest) ([]score, error) {
+	weights := make([]score, len(sizes))
+	for k, size := range sizes {
+		weights[k] = score{packs: 1}
+		if req.Objective != domain.ObjectiveLowestCost {
+			continue
+		}
+
+		cost, ok := req.Costs[size]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing cost for pack size %d", domain.ErrInvalidCost, size)
+		}
+		weights[k].cost = cost
+	}
+
+	return weights, nil
+}
+
+// applyCosts fills the per-size and total cost of the plan when unit costs are
+// known, saturating like the DP scores
+func applyCosts(result *domain.CalculateResult, costs map[int]int) {
+	if len(costs) == 0 {
+		return
+	}
+
+	result.Costs = make(map[int]int, len(result.Packages))
+	for size, count := range result.Packages {
+		result.Costs[size] = saturatingMul(count, costs[size])
+		result.TotalCost = saturatingAdd(result.TotalCost, result.Costs[size])
+	}
+}
+
+// fillTables returns DP tables covering amounts up to limit. Without stock
+// limits they come from the table registry when there is one and its stages
+// fit the memory limit, or else are a single row with parents unless staged
+// asks for every stage. Stock limits always need every stage.
+func (s *PackageCalculatorService) fillTables(ctx context.Context, sizes []int, weights []score, stock map[int]int, limit int, staged bool) ([][]score, []int32, error) {
+	unlimited := len(stockFor(sizes, stock)) == 0
+	memory := stagesMemoryFor(len(sizes), limit)
+	if s.tables != nil && unlimited && (s.limits.MaxMemoryBytes == 0 || memory <= s.limits.MaxMemoryBytes) {
+		stages, ok, err := s.tables.stages(ctx, sizes, weights, limit, s.limits.MaxMemoryBytes)
+		if ok {
+			return stages, nil, err
+		}
+	}
+
+	if unlimited && !staged {
+		row, parent, err := fillRow(ctx, sizes, weights, limit)
+		if err != nil {
+			return nil, nil, err
+		}
+		return [][]score{row}, parent, nil
+	}
+
+	stages, err := fillStages(ctx, sizes, weights, stock, limit)
+	return stages, nil, err
+}
+
+// fillRow is fillStages for unlimited stock keeping only the last stage: the
+// sizes are added in the same order, in place, and parent[i] is the index of
+// the size that last improved amount i. Following the parents from a total
+// gives the plan reconstruct picks from the full stages.
+func fillRow(ctx context.Context, sizes []int, weights []score, limit int) ([]score, []int32, error) {
+	row := baseStage(limit)
+	parent := make([]int32, limit+1)
+
+	for k, size := range sizes {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		for i := size; i <= limit; i++ {
+			if err := checkCancelled(ctx, i); err != nil {
+				return nil, nil, err
+			}
+			if row[i-size] == unreachableScore {
+				continue
+			}
+			if candidate := row[i-size].plus(1, weights[k]); candidate.less(row[i]) {
+				row[i] = candidate
+				parent[i] = int32(k)
 			}
 		}
 	}
 
-	// 4. Check if a solution exists
-	if dp[req.Amount] == math.MaxInt32 {
-		return nil, errors.New("no exact combination possible for the requested amount")
+	return row, parent, nil
+}
+
+// followParents reads the counts of a reachable total off the parents of fillRow
+func followParents(parent []int32, sizes []int, total int) map[int]int {
+	packages := make(map[int]int)
+	for curr := total; curr > 0; {
+		size := sizes[parent[curr]]
+		packages[size]++
+		curr -= size
 	}
 
-	// 5. Reconstruct the counts by walking backwards through 'parent'
-	//
+	return packages
+}
+
+// fillStages builds one DP table per pack size:
+// stages[k][i] = best score for amount i using only sizes[0..k],
+// never taking more packs of a size than its stock allows.
+func fillStages(ctx context.Context, sizes []int, weights []score, stock map[int]int, limit int) ([][]score, error) {
+	stages := make([][]score, len(sizes))
+	prev := baseStage(limit)
+
+	for k, size := range sizes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var err error
+		available, limited := stock[size]
+		if limited {
+			stages[k], err = boundedStage(ctx, prev, size, weights[k], available)
+		} else {
+			stages[k], err = unboundedStage(ctx, prev, size, weights[k])
+		}
+		if err != nil {
+			return nil, err
+		}
+		prev = stages[k]
+	}
+
+	return stages, nil
+}
+
+// baseStage is the table before any pack size is considered: only 0 is reachable
+func baseStage(limit int) []score {
+	base := make([]score, limit+1)
+	for i := 1; i <= limit; i++ {
+		base[i] = unreachableScore
+	}
+
+	return base
+}
+
+// unboundedStage adds a size with unlimited stock to the previous stage.
+// Ties keep the previous stage so the plan uses as few large packs as possible.
+func unboundedStage(ctx context.Context, prev []score, size int, weight score) ([]score, error) {
+	return extendUnboundedStage(ctx, nil, prev, size, weight)
+}
+
+// extendUnboundedStage is unboundedStage for a stage whose first len(filled)
+// amounts are already known, it only fills the amounts after them
+func extendUnboundedStage(ctx context.Context, filled, prev []score, size int, weight score) ([]score, error) {
+	cur := make([]score, len(prev))
+	copy(cur, filled)
+	copy(cur[len(filled):], prev[len(filled):])
+
+	for i := max(size, len(filled)); i < len(cur); i++ {
+		if err := checkCancelled(ctx, i); err != nil {
+			return nil, err
+		}
+		if cur[i-size] == unreachableScore {
+			continue
+		}
+		if candidate := cur[i-size].plus(1, weight); candidate.less(cur[i]) {
+			cur[i] = candidate
+		}
+	}
+
+	return cur, nil
+}
+
+// boundedStage adds a size with at most 'available' packs to the previous stage:
+// cur[i] = min over c in [0, available] of prev[i-c*size] + c*weight.
+// Each residue class modulo size is scanned with a monotone deque holding the
+// best prev[r+j*size]-j*weight inside the sliding window of the last 'available' steps.
+func boundedStage(ctx context.Context, prev []score, size int, weight score, available int) ([]score, error) {
+	cur := make([]score, len(prev))
+	for i := range cur {
+		cur[i] = unreachableScore
+	}
+
+	deque := make([]int, len(prev)/size+1)
+	for r := 0; r < size && r < len(prev); r++ {
+		head, tail := 0, 0
+		for j := 0; r+j*size < len(prev); j++ {
+			i := r + j*size
+			if err := checkCancelled(ctx, i); err != nil {
+				return nil, err
+			}
+
+			// Add candidate j; on ties the newer one uses fewer packs of this size
+			if prev[i] != unreachableScore {
+				shifted := prev[i].plus(-j, weight)
+				for tail > head && !prev[r+deque[tail-1]*size].plus(-deque[tail-1], weight).less(shifted) {
+					tail--
+				}
+				deque[tail] = j
+				tail++
+			}
+
+			// Drop candidates that would need more packs than in stock
+			for tail > head && j-deque[head] > available {
+				head++
+			}
+
+			if tail > head {
+				front := deque[head]
+				cur[i] = prev[r+front*size].plus(j-front, weight)
+			}
+		}
+	}
+
+	return cur, nil
+}
+
+// reconstruct walks the stages from the largest size down, taking the fewest
+// packs of each size that still reach the optimum of the stage
+func reconstruct(stages [][]score, sizes []int, weights []score, stock map[int]int, total int) map[int]int {
 	resMap := make(map[int]int)
-	curr := req.Amount
-	for curr > 0 {
-		size := parent[curr]
-		resMap[size]++
-		curr -= size
+	curr := total
+
+	for k := len(sizes) - 1; k >= 0; k-- {
+		size := sizes[k]
+		prev := baseStage(0)
+		if k > 0 {
+			prev = stages[k-1]
+		}
+
+		maxCount := curr / size
+		if available, limited := stock[size]; limited && available < maxCount {
+			maxCount = available
+		}
+
+		for c := 0; c <= maxCount; c++ {
+			rest := curr - c*size
+			if rest < len(prev) && prev[rest] != unreachableScore && prev[rest].plus(c, weights[k]) == stages[k][curr] {
+				if c > 0 {
+					resMap[size] = c
+				}
+				curr = rest
+				break
+			}
+		}
 	}
 
-	return &domain.CalculateResult{
-		Packages: resMap,
-		Total:    req.Amount,
-	}, nil
+	return resMap
 }
@@ -1,7 +1,12 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"ignis/internal/domain"
+	"maps"
+	"math"
+	"math/rand/v2"
 	"strings" // Added strings for cleaner error checking
 	"testing"
 )
@@ -13,6 +18,7 @@ func TestPackageCalculatorService_Calculate(t *testing.T) {
 		name        string
 		request     domain.CalculateRequest
 		wantErr     bool
+		wantErrIs   error
 		errContains string
 		validate    func(t *testing.T, result *domain.CalculateResult)
 	}{
@@ -65,8 +71,223 @@ func TestPackageCalculatorService_Calculate(t *testing.T) {
 				Amount:    7,
 			},
 			wantErr:     true,
+			wantErrIs:   domain.ErrNoCombination,
 			errContains: "no exact combination possible",
 		},
+		{
+			name: "overfill - 7 with sizes [5, 10] ships one 10 pack",
+			request: domain.CalculateRequest{
+				PackSizes: []int{5, 10},
+				Amount:    7,
+				Mode:      domain.ModeOverfill,
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				// 5+5 and 10 both ship 10 items, the single pack wins
+				if result.Total != 10 || result.Overshoot != 3 {
+					t.Errorf("expected total 10 with overshoot 3, got %d/%d", result.Total, result.Overshoot)
+				}
+				if len(result.Packages) != 1 || result.Packages[10] != 1 {
+					t.Errorf("unexpected distribution: %+v", result.Packages)
+				}
+			},
+		},
+		{
+			name: "overfill - smallest total wins over fewer packs",
+			request: domain.CalculateRequest{
+				PackSizes: []int{23, 31, 53},
+				Amount:    45,
+				Mode:      domain.ModeOverfill,
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				// 46 (23+23) beats 53 even though 53 is a single pack
+				if result.Total != 46 || result.Overshoot != 1 {
+					t.Errorf("expected total 46 with overshoot 1, got %d/%d", result.Total, result.Overshoot)
+				}
+				if result.Packages[23] != 2 {
+					t.Errorf("unexpected distribution: %+v", result.Packages)
+				}
+			},
+		},
+		{
+			name: "overfill - exact amount has no overshoot",
+			request: domain.CalculateRequest{
+				PackSizes: []int{23, 31, 53},
+				Amount:    500000,
+				Mode:      domain.ModeOverfill,
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				if result.Total != 500000 || result.Overshoot != 0 {
+					t.Errorf("expected total 500000 with no overshoot, got %d/%d", result.Total, result.Overshoot)
+				}
+				if result.Packages[53] != 9429 || result.Packages[31] != 7 || result.Packages[23] != 2 {
+					t.Errorf("unexpected distribution: %+v", result.Packages)
+				}
+			},
+		},
+		{
+			name: "stock - limited 5 packs forces 6+2+2",
+			request: domain.CalculateRequest{
+				PackSizes: []int{6, 5, 2},
+				Amount:    10,
+				Stock:     map[int]int{5: 1},
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				if result.Packages[6] != 1 || result.Packages[2] != 2 || result.Packages[5] != 0 {
+					t.Errorf("unexpected distribution: %+v", result.Packages)
+				}
+			},
+		},
+		{
+			name: "stock - 500000 with only 9000 packs of 53",
+			request: domain.CalculateRequest{
+				PackSizes: []int{23, 31, 53},
+				Amount:    500000,
+				Stock:     map[int]int{53: 9000},
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				if result.Packages[53] > 9000 {
+					t.Errorf("used %d packs of 53 with only 9000 in stock", result.Packages[53])
+				}
+				sum := 0
+				for size, count := range result.Packages {
+					sum += size * count
+				}
+				if sum != 500000 || result.Total != 500000 {
+					t.Errorf("expected exact total 500000, got %d (packs sum to %d)", result.Total, sum)
+				}
+			},
+		},
+		{
+			name: "stock - sizes that ran out are reported",
+			request: domain.CalculateRequest{
+				PackSizes: []int{23, 31, 53},
+				Amount:    500000,
+				Stock:     map[int]int{53: 100, 31: 10, 23: 10},
+			},
+			wantErr:     true,
+			wantErrIs:   domain.ErrNoCombination,
+			errContains: "insufficient stock for pack sizes: 53 (need 9429, 100 in stock)",
+		},
+		{
+			name: "cost - cheaper small packs beat fewer large packs",
+			request: domain.CalculateRequest{
+				PackSizes: []int{5, 10},
+				Amount:    20,
+				Objective: domain.ObjectiveLowestCost,
+				Costs:     map[int]int{5: 100, 10: 300},
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				if result.Packages[5] != 4 || result.Packages[10] != 0 {
+					t.Errorf("unexpected distribution: %+v", result.Packages)
+				}
+				if result.TotalCost != 400 || result.Costs[5] != 400 {
+					t.Errorf("expected total cost 400 from 5 packs, got %d (%+v)", result.TotalCost, result.Costs)
+				}
+			},
+		},
+		{
+			name: "cost - equal cost falls back to fewest packs",
+			request: domain.CalculateRequest{
+				PackSizes: []int{2, 3, 6},
+				Amount:    12,
+				Objective: domain.ObjectiveLowestCost,
+				Costs:     map[int]int{2: 2, 3: 3, 6: 6},
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				if len(result.Packages) != 1 || result.Packages[6] != 2 {
+					t.Errorf("unexpected distribution: %+v", result.Packages)
+				}
+				if result.TotalCost != 12 {
+					t.Errorf("expected total cost 12, got %d", result.TotalCost)
+				}
+			},
+		},
+		{
+			name: "cost - fewest packs objective still reports costs",
+			request: domain.CalculateRequest{
+				PackSizes: []int{5, 10},
+				Amount:    20,
+				Costs:     map[int]int{5: 100, 10: 300},
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				if result.Packages[10] != 2 || result.TotalCost != 600 {
+					t.Errorf("expected two 10 packs costing 600, got %+v costing %d", result.Packages, result.TotalCost)
+				}
+			},
+		},
+		{
+			name: "cost - plan cost beyond 32 bits",
+			request: domain.CalculateRequest{
+				PackSizes: []int{1},
+				Amount:    30000,
+				Objective: domain.ObjectiveLowestCost,
+				Costs:     map[int]int{1: 100000},
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				if result.Packages[1] != 30000 || result.TotalCost != 3_000_000_000 {
+					t.Errorf("expected 30000 packs costing 3000000000, got %+v costing %d", result.Packages, result.TotalCost)
+				}
+			},
+		},
+		{
+			name: "cost - plan cost beyond 32 bits with stock",
+			request: domain.CalculateRequest{
+				PackSizes: []int{1, 2},
+				Amount:    30000,
+				Objective: domain.ObjectiveLowestCost,
+				Costs:     map[int]int{1: 100000, 2: 300000},
+				Stock:     map[int]int{1: 20000},
+			},
+			wantErr: false,
+			validate: func(t *testing.T, result *domain.CalculateResult) {
+				if result.Packages[1] != 20000 || result.Packages[2] != 5000 || result.TotalCost != 3_500_000_000 {
+					t.Errorf("expected 20000 packs of 1 and 5000 of 2 costing 3500000000, got %+v costing %d", result.Packages, result.TotalCost)
+				}
+			},
+		},
+		{
+			name: "error - cost objective without a price for every size",
+			request: domain.CalculateRequest{
+				PackSizes: []int{5, 10},
+				Amount:    20,
+				Objective: domain.ObjectiveLowestCost,
+				Costs:     map[int]int{5: 100},
+			},
+			wantErr:     true,
+			wantErrIs:   domain.ErrInvalidCost,
+			errContains: "missing cost for pack size 10",
+		},
+		{
+			name: "error - negative cost under the packs objective",
+			request: domain.CalculateRequest{
+				PackSizes: []int{5, 10},
+				Amount:    20,
+				Costs:     map[int]int{5: 100, 10: -1},
+			},
+			wantErr:     true,
+			wantErrIs:   domain.ErrInvalidCost,
+			errContains: "cost for pack size 10 cannot be negative",
+		},
+		{
+			name: "error - cost over the maximum",
+			request: domain.CalculateRequest{
+				PackSizes: []int{10},
+				Amount:    20,
+				Costs:     map[int]int{10: 9e18},
+			},
+			wantErr:     true,
+			wantErrIs:   domain.ErrInvalidCost,
+			errContains: "cost for pack size 10 over 100000000",
+		},
 		{
 			name: "error - empty pack sizes",
 			request: domain.CalculateRequest{
@@ -74,6 +295,7 @@ func TestPackageCalculatorService_Calculate(t *testing.T) {
 				Amount:    100,
 			},
 			wantErr:     true,
+			wantErrIs:   domain.ErrEmptyPackSizes,
 			errContains: "pack sizes cannot be empty",
 		},
 		{
@@ -83,13 +305,23 @@ func TestPackageCalculatorService_Calculate(t *testing.T) {
 				Amount:    0,
 			},
 			wantErr:     true,
+			wantErrIs:   domain.ErrInvalidAmount,
 			errContains: "amount must be greater than zero",
 		},
+		{
+			name: "error - zero pack size",
+			request: domain.CalculateRequest{
+				PackSizes: []int{0, 20},
+				Amount:    40,
+			},
+			wantErr:   true,
+			wantErrIs: domain.ErrInvalidPackSize,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := service.Calculate(tt.request)
+			result, err := service.Calculate(context.Background(), tt.request)
 
 			if tt.wantErr {
 				if err == nil {
@@ -99,6 +331,9 @@ func TestPackageCalculatorService_Calculate(t *testing.T) {
 				if tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
 					t.Errorf("expected error containing '%s', got '%s'", tt.errContains, err.Error())
 				}
+				if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+					t.Errorf("expected error matching %v, got %v", tt.wantErrIs, err)
+				}
 				return
 			}
 
@@ -113,3 +348,175 @@ func TestPackageCalculatorService_Calculate(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageCalculatorService_StockMatchesBruteForce(t *testing.T) {
+	service := NewPackageCalculatorService()
+	sizes := []int{3, 7, 11}
+	stock := map[int]int{7: 2, 11: 3}
+
+	// Enumerate every plan within stock and keep the fewest packs per amount
+	fewest := make(map[int]int)
+	for a := 0; a <= 40; a++ {
+		for b := 0; b <= stock[7]; b++ {
+			for c := 0; c <= stock[11]; c++ {
+				amount := a*3 + b*7 + c*11
+				if packs, ok := fewest[amount]; !ok || a+b+c < packs {
+					fewest[amount] = a + b + c
+				}
+			}
+		}
+	}
+
+	for amount := 1; amount <= 100; amount++ {
+		result, err := service.Calculate(context.Background(), domain.CalculateRequest{PackSizes: sizes, Amount: amount, Stock: stock})
+		want, ok := fewest[amount]
+		if !ok {
+			if err == nil {
+				t.Errorf("amount %d: expected error, got %+v", amount, result.Packages)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("amount %d: unexpected error: %v", amount, err)
+			continue
+		}
+
+		packs, sum := 0, 0
+		for size, count := range result.Packages {
+			packs += count
+			sum += size * count
+			if limit, limited := stock[size]; limited && count > limit {
+				t.Errorf("amount %d: used %d packs of %d with %d in stock", amount, count, size, limit)
+			}
+		}
+		if sum != amount || packs != want {
+			t.Errorf("amount %d: expected %d packs summing to %d, got %d packs summing to %d", amount, want, amount, packs, sum)
+		}
+	}
+}
+
+func TestPackageCalculatorService_Limits(t *testing.T) {
+	service := NewPackageCalculatorServiceWithLimits(Limits{
+		MaxAmount:      2_000_000,
+		MaxPackSizes:   3,
+		MaxMemoryBytes: 32 << 20,
+	})
+
+	tests := []struct {
+		name      string
+		request   domain.CalculateRequest
+		wantLimit domain.Limit
+	}{
+		{
+			name:      "amount over the limit",
+			request:   domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 2_000_001},
+			wantLimit: domain.LimitAmount,
+		},
+		{
+			name:      "too many pack sizes",
+			request:   domain.CalculateRequest{PackSizes: []int{1, 2, 3, 4}, Amount: 10},
+			wantLimit: domain.LimitPackSizes,
+		},
+		{
+			name: "DP tables over the memory budget",
+			request: domain.CalculateRequest{
+				PackSizes: []int{23, 31, 53},
+				Amount:    1_500_000,
+				Stock:     map[int]int{53: 100},
+			},
+			wantLimit: domain.LimitMemory,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Calculate(context.Background(), tt.request)
+
+			var limitErr *domain.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected *domain.LimitError, got %v", err)
+			}
+			if limitErr.Limit != tt.wantLimit {
+				t.Errorf("expected limit %q, got %q", tt.wantLimit, limitErr.Limit)
+			}
+		})
+	}
+
+	// The residue graph for the same amount fits easily
+	if _, err := service.Calculate(context.Background(), domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 1_500_000}); err != nil {
+		t.Errorf("unexpected error within limits: %v", err)
+	}
+
+	// With a huge largest size the residue graph is the one over the budget,
+	// the DP tables still fit
+	service = NewPackageCalculatorServiceWithLimits(Limits{MaxMemoryBytes: 128 << 20})
+	result, err := service.Calculate(context.Background(), domain.CalculateRequest{PackSizes: []int{3, 100_000_000}, Amount: 2_100_000})
+	if err != nil {
+		t.Fatalf("unexpected error within limits: %v", err)
+	}
+	if result.Packages[3] != 700_000 || result.PackCount != 700_000 {
+		t.Errorf("expected 700000 packs of 3, got %+v", result)
+	}
+}
+
+func TestPackageCalculatorService_Cancelled(t *testing.T) {
+	service := NewPackageCalculatorService()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	requests := map[string]domain.CalculateRequest{
+		"dp":      {PackSizes: []int{23, 31, 53}, Amount: 500000},
+		"bounded": {PackSizes: []int{23, 31, 53}, Amount: 500000, Stock: map[int]int{53: 9000}},
+		"residue": {PackSizes: []int{23, 31, 53}, Amount: 2_000_000_000},
+	}
+
+	for name, req := range requests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := service.Calculate(ctx, req); !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+			if _, err := service.CalculateTopK(ctx, req, 3); !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled from CalculateTopK, got %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyCosts_Saturates(t *testing.T) {
+	result := &domain.CalculateResult{Packages: map[int]int{1: math.MaxInt / 10, 2: 1}}
+	applyCosts(result, map[int]int{1: domain.MaxPackCost, 2: 1})
+
+	if result.Costs[1] != math.MaxInt || result.TotalCost != math.MaxInt {
+		t.Errorf("expected the costs to saturate at %d, got %v costing %d", math.MaxInt, result.Costs, result.TotalCost)
+	}
+}
+
+func TestFillRow_MatchesStages(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(3, 4))
+
+	for range 200 {
+		sizes := uniqueSorted([]int{1 + rng.IntN(20), 1 + rng.IntN(20), 1 + rng.IntN(40)})
+		weights := make([]score, len(sizes))
+		for k := range weights {
+			// Few distinct costs, so ties between plans are common
+			weights[k] = score{cost: rng.IntN(3), packs: 1}
+		}
+
+		limit := 300
+		stages, _ := fillStages(ctx, sizes, weights, nil, limit)
+		row, parent, _ := fillRow(ctx, sizes, weights, limit)
+		for total := 0; total <= limit; total++ {
+			if row[total] != stages[len(stages)-1][total] {
+				t.Fatalf("%v %v amount %d: row %+v, stages %+v", sizes, weights, total, row[total], stages[len(stages)-1][total])
+			}
+			if row[total] == unreachableScore {
+				continue
+			}
+			want := reconstruct(stages, sizes, weights, nil, total)
+			if got := followParents(parent, sizes, total); !maps.Equal(got, want) {
+				t.Fatalf("%v %v amount %d: parents give %v, stages %v", sizes, weights, total, got, want)
+			}
+		}
+	}
+}
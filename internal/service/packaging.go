@@ -0,0 +1,178 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"ignis/internal/domain"
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// validatePackaging rejects a packaging that cannot hold every pack size of
+// the request; a nil packaging is valid
+func validatePackaging(packaging *domain.Packaging, packSizes []int) error {
+	if packaging == nil {
+		return nil
+	}
+
+	if strings.TrimSpace(packaging.Container) == "" {
+		return fmt.Errorf("%w: containers need a name", domain.ErrInvalidPackaging)
+	}
+	for size, capacity := range packaging.Capacity {
+		if capacity <= 0 {
+			return fmt.Errorf("%w: %s capacity for packs of %d must be greater than zero", domain.ErrInvalidPackaging, packaging.Container, size)
+		}
+	}
+	for _, size := range packSizes {
+		if _, ok := packaging.Capacity[size]; !ok {
+			return fmt.Errorf("%w: no %s capacity for packs of %d", domain.ErrInvalidPackaging, packaging.Container, size)
+		}
+	}
+	for _, outer := range packaging.Outer {
+		if strings.TrimSpace(outer.Name) == "" {
+			return fmt.Errorf("%w: containers need a name", domain.ErrInvalidPackaging)
+		}
+		if outer.Capacity <= 0 {
+			return fmt.Errorf("%w: %s capacity must be greater than zero", domain.ErrInvalidPackaging, outer.Name)
+		}
+	}
+
+	return nil
+}
+
+// packContainers is the second stage of a calculation: it puts the packs of a
+// plan into the innermost containers of the packaging, and those into each
+// outer level in turn, using the fewest containers at every level
+func packContainers(packages map[int]int, packaging domain.Packaging) *domain.PackagingPlan {
+	groups := packInnermost(packages, packaging)
+	plan := &domain.PackagingPlan{
+		Levels: []domain.LevelCount{{Name: packaging.Container, Count: countContainers(groups)}},
+	}
+	for _, outer := range packaging.Outer {
+		groups = packOuter(groups, outer)
+		plan.Levels = append(plan.Levels, domain.LevelCount{Name: outer.Name, Count: countContainers(groups)})
+	}
+	plan.Containers = groups
+
+	return plan
+}
+
+// packInnermost fills whole containers with a single size each, then packs
+// the leftovers first-fit decreasing by the share of a container a pack takes.
+// That needs the fewest containers when the capacities divide one another
+// (e.g. 10, 20 and 40 packs), and close to it otherwise.
+func packInnermost(packages map[int]int, packaging domain.Packaging) []domain.ContainerGroup {
+	sizes := make([]int, 0, len(packages))
+	for size, count := range packages {
+		if count > 0 {
+			sizes = append(sizes, size)
+		}
+	}
+	// Largest share first, then largest size
+	slices.SortFunc(sizes, func(a, b int) int {
+		if c := cmp.Compare(packaging.Capacity[a], packaging.Capacity[b]); c != 0 {
+			return c
+		}
+		return cmp.Compare(b, a)
+	})
+
+	var groups []domain.ContainerGroup
+	for _, size := range sizes {
+		capacity := packaging.Capacity[size]
+		if full := packages[size] / capacity; full > 0 {
+			groups = append(groups, domain.ContainerGroup{Name: packaging.Container, Count: full, Packs: map[int]int{size: capacity}})
+		}
+	}
+
+	// free is the share of each mixed container still empty, kept exact so
+	// packs filling a container to the brim still fit
+	var mixed []domain.ContainerGroup
+	var free []*big.Rat
+	for _, size := range sizes {
+		capacity := big.NewRat(int64(packaging.Capacity[size]), 1)
+		left := packages[size] % packaging.Capacity[size]
+		for i := 0; left > 0 && i < len(mixed); i++ {
+			room := new(big.Rat).Mul(free[i], capacity)
+			take := min(left, int(new(big.Int).Quo(room.Num(), room.Denom()).Int64()))
+			if take == 0 {
+				continue
+			}
+			mixed[i].Packs[size] = take
+			free[i].Sub(free[i], new(big.Rat).Quo(big.NewRat(int64(take), 1), capacity))
+			left -= take
+		}
+		if left > 0 {
+			mixed = append(mixed, domain.ContainerGroup{Name: packaging.Container, Count: 1, Packs: map[int]int{size: left}})
+			free = append(free, new(big.Rat).Sub(big.NewRat(1, 1), new(big.Rat).Quo(big.NewRat(int64(left), 1), capacity)))
+		}
+	}
+	for _, container := range mixed {
+		groups = appendGroup(groups, container)
+	}
+
+	return groups
+}
+
+// packOuter fills containers of an outer level with the groups of the level
+// below in order, so only the last one is partly empty
+func packOuter(inner []domain.ContainerGroup, outer domain.OuterContainer) []domain.ContainerGroup {
+	var groups []domain.ContainerGroup
+	var contents []domain.ContainerGroup // of the container being filled
+	filled := 0
+
+	for _, group := range inner {
+		left := group.Count
+		for left > 0 {
+			// Whole containers of a single group at once
+			if filled == 0 && left >= outer.Capacity {
+				full := left / outer.Capacity
+				part := group
+				part.Count = outer.Capacity
+				groups = appendGroup(groups, domain.ContainerGroup{Name: outer.Name, Count: full, Contents: []domain.ContainerGroup{part}})
+				left -= full * outer.Capacity
+				continue
+			}
+
+			part := group
+			part.Count = min(left, outer.Capacity-filled)
+			contents = append(contents, part)
+			filled += part.Count
+			left -= part.Count
+			if filled == outer.Capacity {
+				groups = appendGroup(groups, domain.ContainerGroup{Name: outer.Name, Count: 1, Contents: contents})
+				contents, filled = nil, 0
+			}
+		}
+	}
+	if filled > 0 {
+		groups = appendGroup(groups, domain.ContainerGroup{Name: outer.Name, Count: 1, Contents: contents})
+	}
+
+	return groups
+}
+
+// appendGroup adds a group, merging it into the last one when their
+// containers hold the same
+func appendGroup(groups []domain.ContainerGroup, group domain.ContainerGroup) []domain.ContainerGroup {
+	if n := len(groups); n > 0 {
+		last := groups[n-1]
+		last.Count = group.Count
+		if reflect.DeepEqual(last, group) {
+			groups[n-1].Count += group.Count
+			return groups
+		}
+	}
+
+	return append(groups, group)
+}
+
+func countContainers(groups []domain.ContainerGroup) int {
+	count := 0
+	for _, group := range groups {
+		count += group.Count
+	}
+
+	return count
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ignis/internal/domain"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestPackageCalculatorService_Packaging(t *testing.T) {
+	ctx := context.Background()
+	service := NewPackageCalculatorService()
+	packaging := &domain.Packaging{
+		Container: "carton",
+		Capacity:  map[int]int{23: 20, 31: 20, 53: 12},
+		Outer:     []domain.OuterContainer{{Name: "pallet", Capacity: 40}},
+	}
+
+	t.Run("packs into cartons and pallets", func(t *testing.T) {
+		// 53×9429, 31×7, 23×2: 785 full cartons of 53 and two mixed ones
+		result, err := service.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 500000, Packaging: packaging})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		carton := func(count int, packs map[int]int) domain.ContainerGroup {
+			return domain.ContainerGroup{Name: "carton", Count: count, Packs: packs}
+		}
+		want := &domain.PackagingPlan{
+			Containers: []domain.ContainerGroup{
+				{Name: "pallet", Count: 19, Contents: []domain.ContainerGroup{carton(40, map[int]int{53: 12})}},
+				{Name: "pallet", Count: 1, Contents: []domain.ContainerGroup{
+					carton(25, map[int]int{53: 12}),
+					carton(1, map[int]int{53: 9, 31: 5}),
+					carton(1, map[int]int{31: 2, 23: 2}),
+				}},
+			},
+			Levels: []domain.LevelCount{{Name: "carton", Count: 787}, {Name: "pallet", Count: 20}},
+		}
+		if !reflect.DeepEqual(result.Packaging, want) {
+			t.Errorf("expected %+v, got %+v", want, result.Packaging)
+		}
+	})
+
+	t.Run("every plan is packed", func(t *testing.T) {
+		plans, err := service.CalculateTopK(ctx, domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263, Packaging: packaging}, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, plan := range plans {
+			if plan.Packaging == nil || plan.Packaging.Levels[1].Count != 1 {
+				t.Errorf("expected a pallet for %+v, got %+v", plan.Packages, plan.Packaging)
+			}
+		}
+	})
+
+	t.Run("fewest cartons when capacities divide one another", func(t *testing.T) {
+		capacity := map[int]int{3: 10, 5: 20, 7: 40}
+		rng := rand.New(rand.NewPCG(1, 2))
+		for range 200 {
+			packages := map[int]int{3: rng.IntN(100), 5: rng.IntN(100), 7: rng.IntN(100)}
+
+			// Every carton holds 40 eighths: a pack of 3 takes 4, of 5 takes 2, of 7 one
+			eighths := packages[3]*4 + packages[5]*2 + packages[7]
+			fewest := (eighths + 39) / 40
+
+			plan := packContainers(packages, domain.Packaging{Container: "carton", Capacity: capacity})
+			if plan.Levels[0].Count != fewest {
+				t.Fatalf("%v: expected %d cartons, got %d", packages, fewest, plan.Levels[0].Count)
+			}
+			packed := make(map[int]int)
+			for _, group := range plan.Containers {
+				share := 0
+				for size, count := range group.Packs {
+					packed[size] += group.Count * count
+					share += count * 40 / capacity[size]
+				}
+				if share > 40 {
+					t.Fatalf("%v: overfull carton %v", packages, group.Packs)
+				}
+			}
+			for size, count := range packages {
+				if packed[size] != count {
+					t.Fatalf("%v: packed %d packs of %d", packages, packed[size], size)
+				}
+			}
+		}
+	})
+
+	t.Run("rejects invalid packaging", func(t *testing.T) {
+		tests := []domain.Packaging{
+			{Capacity: map[int]int{23: 20, 31: 20, 53: 12}},
+			{Container: "carton", Capacity: map[int]int{23: 20, 53: 12}},
+			{Container: "carton", Capacity: map[int]int{23: 20, 31: 0, 53: 12}},
+			{Container: "carton", Capacity: map[int]int{23: 20, 31: 20, 53: 12}, Outer: []domain.OuterContainer{{Name: "pallet"}}},
+		}
+		for _, packaging := range tests {
+			_, err := service.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263, Packaging: &packaging})
+			if !errors.Is(err, domain.ErrInvalidPackaging) {
+				t.Errorf("%v: expected ErrInvalidPackaging, got %v", packaging, err)
+			}
+		}
+	})
+
+	t.Run("cached per packaging", func(t *testing.T) {
+		cached := NewCachingCalculator(service, CacheOptions{Size: 10})
+		req := domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263}
+		if result, _ := cached.Calculate(ctx, req); result.Packaging != nil {
+			t.Fatalf("expected no packaging, got %+v", result.Packaging)
+		}
+
+		req.Packaging = packaging
+		if result, _ := cached.Calculate(ctx, req); result.Packaging == nil {
+			t.Errorf("expected the packaging of the request, not a cached plan without it")
+		}
+	})
+}
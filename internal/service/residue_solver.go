@@ -0,0 +1,240 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"ignis/internal/domain"
+)
+
+// residueAmountThreshold is the amount from which Calculate answers fewest-packs
+// requests on the residue graph instead of allocating Amount+1 DP entries
+const residueAmountThreshold = 1 << 20
+
+// calculateByResidues answers a request with memory proportional to the largest
+// pack size instead of the amount.
+//
+// Any plan is a multiset of smaller packs summing to T plus (Amount-T)/M packs of
+// the largest size M, so it needs (Amount + W)/M packs where W is the sum of
+// (M - size) over the smaller packs. Minimizing packs therefore means finding the
+// lightest W among multisets with T ≡ Amount (mod M): a shortest path over the
+// residues 0..M-1 with positive edge weights. Filling with the largest size keeps
+// every weight positive; filling with the smallest would make every edge negative.
+//
+// It reports ok=false when the request is outside what the graph answers (stock
+// limits, cost objective, or an amount too small for the filler), and the caller
+// falls back to the DP tables.
+func calculateByResidues(ctx context.Context, req domain.CalculateRequest) (*domain.CalculateResult, bool, error) {
+	if len(req.Stock) > 0 || req.Objective != domain.ObjectiveFewestPacks {
+		return nil, false, nil
+	}
+
+	sizes := uniqueSorted(req.PackSizes)
+	if sizes[0] <= 0 {
+		return nil, false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, true, err
+	}
+
+	total := req.Amount
+	if req.Mode == domain.ModeOverfill {
+		reachable, err := smallestReachableAtLeast(ctx, sizes, req.Amount)
+		if err != nil {
+			return nil, true, err
+		}
+		total = reachable
+	}
+
+	largest := sizes[len(sizes)-1]
+	labels, err := shortestResiduePaths(ctx, sizes)
+	if err != nil {
+		return nil, true, err
+	}
+	label := labels[total%largest]
+	if label == nil {
+		return nil, true, &domain.NoCombinationError{Amount: req.Amount, Mode: req.Mode}
+	}
+
+	packages := make(map[int]int)
+	smaller := 0
+	for k, count := range label.counts {
+		if count > 0 {
+			packages[sizes[k]] = count
+			smaller += count * sizes[k]
+		}
+	}
+
+	// The lightest path may overshoot small totals; the DP handles those
+	if smaller > total {
+		return nil, false, nil
+	}
+	if fillers := (total - smaller) / largest; fillers > 0 {
+		packages[largest] = fillers
+	}
+
+	return buildResult(req, packages, total), true, nil
+}
+
+// residueLabel is the best known multiset of packs smaller than the largest size
+// reaching a residue. Labels compare lexicographically: lighter weight first, then
+// more small packs (fewer largest fillers), then fewer packs of each size from the
+// second largest down. That is the plan the DP stages reconstruct, so both solvers
+// return identical plans.
+type residueLabel struct {
+	weight int   // sum of (largest - size) over the packs taken
+	packs  int   // number of packs taken
+	counts []int // packs taken per size, indexed like sizes
+}
+
+func (a *residueLabel) less(b *residueLabel) bool {
+	if a.weight != b.weight {
+		return a.weight < b.weight
+	}
+	if a.packs != b.packs {
+		return a.packs > b.packs
+	}
+	for k := len(a.counts) - 1; k >= 0; k-- {
+		if a.counts[k] != b.counts[k] {
+			return a.counts[k] < b.counts[k]
+		}
+	}
+	return false
+}
+
+// shortestResiduePaths runs Dijkstra over residues modulo the largest size,
+// where taking a pack of sizes[k] moves r to (r + sizes[k]) mod largest
+func shortestResiduePaths(ctx context.Context, sizes []int) ([]*residueLabel, error) {
+	largest := sizes[len(sizes)-1]
+	labels := make([]*residueLabel, largest)
+	labels[0] = &residueLabel{counts: make([]int, len(sizes))}
+
+	queue := &residueQueue{}
+	heap.Push(queue, residueItem{residue: 0, label: labels[0]})
+
+	for step := 1; queue.Len() > 0; step++ {
+		if err := checkCancelled(ctx, step); err != nil {
+			return nil, err
+		}
+		item := heap.Pop(queue).(residueItem)
+		if item.label != labels[item.residue] {
+			continue // stale entry
+		}
+
+		for k, size := range sizes[:len(sizes)-1] {
+			next := (item.residue + size) % largest
+			candidate := &residueLabel{
+				weight: item.label.weight + largest - size,
+				packs:  item.label.packs + 1,
+				counts: make([]int, len(sizes)),
+			}
+			copy(candidate.counts, item.label.counts)
+			candidate.counts[k]++
+
+			if labels[next] == nil || candidate.less(labels[next]) {
+				labels[next] = candidate
+				heap.Push(queue, residueItem{residue: next, label: candidate})
+			}
+		}
+	}
+
+	return labels, nil
+}
+
+// smallestReachableAtLeast returns the smallest packable total >= amount.
+// An amount x is packable iff x >= reach[x mod largest], where reach[r] is the
+// smallest packable amount with residue r.
+func smallestReachableAtLeast(ctx context.Context, sizes []int, amount int) (int, error) {
+	largest := sizes[len(sizes)-1]
+	reach, err := smallestReachablePerResidue(ctx, sizes)
+	if err != nil {
+		return 0, err
+	}
+
+	best := -1
+	for r, smallest := range reach {
+		if smallest < 0 {
+			continue
+		}
+		candidate := amount + (r-amount%largest+largest)%largest
+		if candidate < smallest {
+			candidate = smallest
+		}
+		if best < 0 || candidate < best {
+			best = candidate
+		}
+	}
+
+	return best, nil
+}
+
+// smallestReachablePerResidue returns, for each residue modulo the largest size,
+// the smallest packable amount with that residue or -1 when there is none
+func smallestReachablePerResidue(ctx context.Context, sizes []int) ([]int, error) {
+	largest := sizes[len(sizes)-1]
+	reach := make([]int, largest)
+	for r := range reach {
+		reach[r] = -1
+	}
+	reach[0] = 0
+
+	queue := &amountQueue{}
+	heap.Push(queue, amountItem{residue: 0, amount: 0})
+
+	for step := 1; queue.Len() > 0; step++ {
+		if err := checkCancelled(ctx, step); err != nil {
+			return nil, err
+		}
+		item := heap.Pop(queue).(amountItem)
+		if item.amount != reach[item.residue] {
+			continue // stale entry
+		}
+
+		for _, size := range sizes[:len(sizes)-1] {
+			next := item.amount + size
+			if r := next % largest; reach[r] < 0 || next < reach[r] {
+				reach[r] = next
+				heap.Push(queue, amountItem{residue: r, amount: next})
+			}
+		}
+	}
+
+	return reach, nil
+}
+
+type residueItem struct {
+	residue int
+	label   *residueLabel
+}
+
+type residueQueue []residueItem
+
+func (q residueQueue) Len() int           { return len(q) }
+func (q residueQueue) Less(i, j int) bool { return q[i].label.less(q[j].label) }
+func (q residueQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *residueQueue) Push(x any)        { *q = append(*q, x.(residueItem)) }
+
+func (q *residueQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+type amountItem struct {
+	residue int
+	amount  int
+}
+
+type amountQueue []amountItem
+
+func (q amountQueue) Len() int           { return len(q) }
+func (q amountQueue) Less(i, j int) bool { return q[i].amount < q[j].amount }
+func (q amountQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *amountQueue) Push(x any)        { *q = append(*q, x.(amountItem)) }
+
+func (q *amountQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
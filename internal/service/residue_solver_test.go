@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"ignis/internal/domain"
+	"reflect"
+	"testing"
+)
+
+func TestCalculateByResidues_MatchesDP(t *testing.T) {
+	service := NewPackageCalculatorService()
+	packSizeSets := [][]int{
+		{6, 5, 2},
+		{3, 5, 7},
+		{6, 9, 20},
+		{4, 6},
+		{23, 31, 53},
+		{7},
+	}
+
+	for _, sizes := range packSizeSets {
+		for _, mode := range []domain.Mode{domain.ModeExact, domain.ModeOverfill} {
+			t.Run(fmt.Sprintf("%v/%s", sizes, mode), func(t *testing.T) {
+				// Below this bound the lightest residue path may overshoot the amount
+				sorted := uniqueSorted(sizes)
+				bound := 0
+				if len(sorted) > 1 {
+					bound = (sorted[len(sorted)-1] - 1) * sorted[len(sorted)-2]
+				}
+
+				for amount := 1; amount <= bound+300; amount++ {
+					req := domain.CalculateRequest{PackSizes: sizes, Amount: amount, Mode: mode}
+					want, wantErr := service.Calculate(context.Background(), req)
+					got, ok, gotErr := calculateByResidues(context.Background(), req)
+
+					if !ok {
+						if amount >= bound {
+							t.Fatalf("amount %d: residue solver fell back above the bound %d", amount, bound)
+						}
+						continue
+					}
+					if (wantErr != nil) != (gotErr != nil) {
+						t.Fatalf("amount %d: DP error %v, residue error %v", amount, wantErr, gotErr)
+					}
+					if wantErr == nil && !reflect.DeepEqual(want, got) {
+						t.Fatalf("amount %d: DP %+v, residue %+v", amount, want, got)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestPackageCalculatorService_HugeAmount(t *testing.T) {
+	service := NewPackageCalculatorService()
+
+	// A DP table for this amount would need gigabytes
+	result, err := service.Calculate(context.Background(), domain.CalculateRequest{
+		PackSizes: []int{23, 31, 53},
+		Amount:    2_000_000_000,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sum := 0
+	for size, count := range result.Packages {
+		sum += size * count
+	}
+	if sum != 2_000_000_000 || result.Total != 2_000_000_000 {
+		t.Errorf("expected exact total 2000000000, got %d (packs sum to %d)", result.Total, sum)
+	}
+	if result.Packages[53] < 37_000_000 {
+		t.Errorf("expected the plan to be mostly 53 packs, got %+v", result.Packages)
+	}
+}
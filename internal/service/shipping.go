@@ -0,0 +1,167 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"ignis/internal/domain"
+	"maps"
+	"math"
+	"slices"
+)
+
+// validateShipping rejects shipment limits that are incomplete or that a
+// single pack of the request exceeds; nil limits are valid
+func validateShipping(limits *domain.ShipmentLimits, packSizes []int) error {
+	if limits == nil {
+		return nil
+	}
+
+	if limits.MaxWeight < 0 || limits.MaxVolume < 0 {
+		return fmt.Errorf("%w: maximums cannot be negative", domain.ErrInvalidShipment)
+	}
+	if limits.MaxWeight == 0 && limits.MaxVolume == 0 {
+		return fmt.Errorf("%w: a maximum weight or volume is required", domain.ErrInvalidShipment)
+	}
+	for size, weight := range limits.Weights {
+		if weight < 0 {
+			return fmt.Errorf("%w: weight for pack size %d cannot be negative", domain.ErrInvalidShipment, size)
+		}
+	}
+	for size, volume := range limits.Volumes {
+		if volume < 0 {
+			return fmt.Errorf("%w: volume for pack size %d cannot be negative", domain.ErrInvalidShipment, size)
+		}
+	}
+
+	for _, size := range packSizes {
+		if limits.MaxWeight > 0 {
+			weight, ok := limits.Weights[size]
+			if !ok {
+				return fmt.Errorf("%w: missing weight for pack size %d", domain.ErrInvalidShipment, size)
+			}
+			if weight > limits.MaxWeight {
+				return fmt.Errorf("%w: a pack of %d weighs %d, over the maximum of %d", domain.ErrOversizedPack, size, weight, limits.MaxWeight)
+			}
+		}
+		if limits.MaxVolume > 0 {
+			volume, ok := limits.Volumes[size]
+			if !ok {
+				return fmt.Errorf("%w: missing volume for pack size %d", domain.ErrInvalidShipment, size)
+			}
+			if volume > limits.MaxVolume {
+				return fmt.Errorf("%w: a pack of %d has a volume of %d, over the maximum of %d", domain.ErrOversizedPack, size, volume, limits.MaxVolume)
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitShipments is bin packing on top of a plan: it splits the packs into
+// shipments within the limits, first-fit decreasing by the larger share of a
+// shipment's weight or volume a pack takes. That heuristic stays close to the
+// fewest shipments, and works on groups of identical shipments, so plans of
+// millions of packs split as fast as small ones.
+func splitShipments(packages map[int]int, limits domain.ShipmentLimits) []domain.ShipmentGroup {
+	share := func(size int) float64 {
+		weight, volume := 0.0, 0.0
+		if limits.MaxWeight > 0 {
+			weight = float64(limits.Weights[size]) / float64(limits.MaxWeight)
+		}
+		if limits.MaxVolume > 0 {
+			volume = float64(limits.Volumes[size]) / float64(limits.MaxVolume)
+		}
+		return max(weight, volume)
+	}
+
+	sizes := make([]int, 0, len(packages))
+	for size, count := range packages {
+		if count > 0 {
+			sizes = append(sizes, size)
+		}
+	}
+	// Largest share first, then largest size
+	slices.SortFunc(sizes, func(a, b int) int {
+		if c := cmp.Compare(share(b), share(a)); c != 0 {
+			return c
+		}
+		return cmp.Compare(b, a)
+	})
+
+	var groups []domain.ShipmentGroup
+	for _, size := range sizes {
+		weight, volume := limits.Weights[size], limits.Volumes[size]
+		left := packages[size]
+
+		// First fit: every shipment of a group takes as many as fit, until the
+		// packs run out part way through a group, which then splits
+		for i := 0; left > 0 && i < len(groups); i++ {
+			group := groups[i]
+			fit := packsThatFit(limits, group.Weight, group.Volume, weight, volume)
+			if fit == 0 {
+				continue
+			}
+			if fit <= left/group.Count { // all of them fill up, Count*fit may overflow
+				groups[i] = addPacks(group, group.Count, size, fit, weight, volume)
+				left -= group.Count * fit
+				continue
+			}
+
+			full := left / fit
+			var split []domain.ShipmentGroup
+			if full > 0 {
+				split = append(split, addPacks(group, full, size, fit, weight, volume))
+			}
+			if rest := left - full*fit; rest > 0 {
+				split = append(split, addPacks(group, 1, size, rest, weight, volume))
+				full++
+			}
+			if untouched := group.Count - full; untouched > 0 {
+				group.Count = untouched
+				split = append(split, group)
+			}
+			groups = slices.Replace(groups, i, i+1, split...)
+			left = 0
+		}
+
+		// The rest go to new shipments, as full as they can be
+		if left > 0 {
+			fit := packsThatFit(limits, 0, 0, weight, volume)
+			empty := domain.ShipmentGroup{Packs: map[int]int{}}
+			if full := left / fit; full > 0 {
+				groups = append(groups, addPacks(empty, full, size, fit, weight, volume))
+			}
+			if rest := left % fit; rest > 0 {
+				groups = append(groups, addPacks(empty, 1, size, rest, weight, volume))
+			}
+		}
+	}
+
+	return groups
+}
+
+// packsThatFit is how many packs of the given weight and volume still fit in
+// a shipment holding weight and volume already
+func packsThatFit(limits domain.ShipmentLimits, usedWeight, usedVolume, weight, volume int) int {
+	fit := math.MaxInt
+	if limits.MaxWeight > 0 && weight > 0 {
+		fit = min(fit, (limits.MaxWeight-usedWeight)/weight)
+	}
+	if limits.MaxVolume > 0 && volume > 0 {
+		fit = min(fit, (limits.MaxVolume-usedVolume)/volume)
+	}
+
+	return fit
+}
+
+// addPacks returns count shipments like those of group with packs more packs
+// of size in each
+func addPacks(group domain.ShipmentGroup, count, size, packs, weight, volume int) domain.ShipmentGroup {
+	group.Count = count
+	group.Packs = maps.Clone(group.Packs)
+	group.Packs[size] += packs
+	group.Weight += packs * weight
+	group.Volume += packs * volume
+
+	return group
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ignis/internal/domain"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestPackageCalculatorService_Shipping(t *testing.T) {
+	ctx := context.Background()
+	service := NewPackageCalculatorService()
+
+	// checkShipments verifies every pack ships exactly once within the limits
+	checkShipments := func(t *testing.T, packages map[int]int, limits domain.ShipmentLimits, shipments []domain.ShipmentGroup) int {
+		t.Helper()
+		shipped := make(map[int]int)
+		count := 0
+		for _, group := range shipments {
+			weight, volume := 0, 0
+			for size, packs := range group.Packs {
+				shipped[size] += group.Count * packs
+				weight += packs * limits.Weights[size]
+				volume += packs * limits.Volumes[size]
+			}
+			if weight != group.Weight || volume != group.Volume {
+				t.Fatalf("%+v: expected weight %d and volume %d", group, weight, volume)
+			}
+			if limits.MaxWeight > 0 && weight > limits.MaxWeight || limits.MaxVolume > 0 && volume > limits.MaxVolume {
+				t.Fatalf("%+v: over the limits %+v", group, limits)
+			}
+			count += group.Count
+		}
+		for size, packs := range packages {
+			if shipped[size] != packs {
+				t.Fatalf("expected %d packs of %d to ship, got %d", packs, size, shipped[size])
+			}
+		}
+		return count
+	}
+
+	t.Run("splits by weight", func(t *testing.T) {
+		// 53×9429, 31×7, 23×2 weighing 10 per item: 37 packs of 53 per shipment
+		limits := &domain.ShipmentLimits{Weights: map[int]int{23: 230, 31: 310, 53: 530}, MaxWeight: 20000}
+		result, err := service.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 500000, Shipping: limits})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if count := checkShipments(t, result.Packages, *limits, result.Shipments); count != 255 {
+			t.Errorf("expected 255 shipments, got %d", count)
+		}
+		if len(result.Shipments) > 6 {
+			t.Errorf("expected identical shipments to be grouped, got %d groups", len(result.Shipments))
+		}
+	})
+
+	t.Run("splits by weight and volume", func(t *testing.T) {
+		limits := domain.ShipmentLimits{
+			Weights:   map[int]int{5: 1, 10: 4},
+			Volumes:   map[int]int{5: 3, 10: 2},
+			MaxWeight: 10,
+			MaxVolume: 10,
+		}
+		// A 10 takes 40% of the weight, a 5 30% of the volume
+		got := splitShipments(map[int]int{10: 5, 5: 5}, limits)
+		want := []domain.ShipmentGroup{
+			{Count: 2, Packs: map[int]int{10: 2, 5: 2}, Weight: 10, Volume: 10},
+			{Count: 1, Packs: map[int]int{10: 1, 5: 1}, Weight: 5, Volume: 5},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("first fit bound", func(t *testing.T) {
+		rng := rand.New(rand.NewPCG(3, 4))
+		for range 200 {
+			limits := domain.ShipmentLimits{
+				Weights:   map[int]int{3: 1 + rng.IntN(50), 7: 1 + rng.IntN(50), 11: 1 + rng.IntN(50)},
+				MaxWeight: 50 + rng.IntN(100),
+			}
+			packages := map[int]int{3: rng.IntN(200), 7: rng.IntN(200), 11: rng.IntN(200)}
+
+			count := checkShipments(t, packages, limits, splitShipments(packages, limits))
+
+			// First fit leaves at most one shipment half empty
+			total := 0
+			for size, packs := range packages {
+				total += packs * limits.Weights[size]
+			}
+			if fewest := (total + limits.MaxWeight - 1) / limits.MaxWeight; count > 2*fewest+1 {
+				t.Fatalf("%v %+v: %d shipments for a lower bound of %d", packages, limits, count, fewest)
+			}
+		}
+	})
+
+	t.Run("rejects invalid limits", func(t *testing.T) {
+		tests := []struct {
+			limits domain.ShipmentLimits
+			want   error
+		}{
+			{domain.ShipmentLimits{Weights: map[int]int{23: 1, 31: 1, 53: 1}}, domain.ErrInvalidShipment},
+			{domain.ShipmentLimits{Weights: map[int]int{23: 1, 31: 1}, MaxWeight: 10}, domain.ErrInvalidShipment},
+			{domain.ShipmentLimits{Weights: map[int]int{23: 1, 31: 1, 53: -1}, MaxWeight: 10}, domain.ErrInvalidShipment},
+			{domain.ShipmentLimits{Weights: map[int]int{23: 1, 31: 1, 53: 11}, MaxWeight: 10}, domain.ErrOversizedPack},
+			{domain.ShipmentLimits{Volumes: map[int]int{23: 1, 31: 20, 53: 1}, MaxVolume: 10}, domain.ErrOversizedPack},
+		}
+		for _, tt := range tests {
+			_, err := service.Calculate(ctx, domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 263, Shipping: &tt.limits})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%+v: expected %v, got %v", tt.limits, tt.want, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// tableHeadroom is the share of its current limit a table grows by at least,
+// so a run of slowly increasing amounts does not copy the table every time
+const tableHeadroom = 4 // grow by at least a quarter
+
+// stageTable holds the DP stages of one pack-size set with unlimited stock and
+// extends them when a request needs a larger amount. Filled stages are never
+// written again: an extension builds longer copies and publishes them at once,
+// so readers use a snapshot without locking.
+type stageTable struct {
+	key     string
+	sizes   []int
+	weights []score
+
+	grow     sync.Mutex // serializes extensions
+	snapshot atomic.Pointer[stageSnapshot]
+
+	accounted int64 // bytes counted by the registry, guarded by its mutex
+}
+
+// stageSnapshot is the stages of a table covering amounts up to limit
+type stageSnapshot struct {
+	limit  int
+	stages [][]score
+}
+
+// memory approximates the bytes held by the table
+func (t *stageTable) memory() int64 {
+	snap := t.snapshot.Load()
+	if snap == nil {
+		return 0
+	}
+
+	return stagesMemoryFor(len(t.sizes), snap.limit)
+}
+
+// stagesFor returns stages covering amounts up to limit, extending the table
+// up to maxLimit when they are too short. It also returns how many bytes the
+// table grew by.
+func (t *stageTable) stagesFor(ctx context.Context, limit, maxLimit int) ([][]score, int64, error) {
+	if snap := t.snapshot.Load(); snap != nil && snap.limit >= limit {
+		return snap.stages, 0, nil
+	}
+
+	t.grow.Lock()
+	defer t.grow.Unlock()
+
+	// Another request may have extended the table while this one waited
+	old := t.snapshot.Load()
+	if old != nil && old.limit >= limit {
+		return old.stages, 0, nil
+	}
+
+	target := limit
+	var oldStages [][]score
+	if old != nil {
+		target = max(limit, min(old.limit+old.limit/tableHeadroom, maxLimit))
+		oldStages = old.stages
+	}
+
+	stages, err := extendStages(ctx, oldStages, t.sizes, t.weights, target)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	before := t.memory()
+	t.snapshot.Store(&stageSnapshot{limit: target, stages: stages})
+	return stages, t.memory() - before, nil
+}
+
+// extendStages is fillStages for unlimited stock, reusing the stages already
+// filled for smaller amounts. old may be nil.
+func extendStages(ctx context.Context, old [][]score, sizes []int, weights []score, limit int) ([][]score, error) {
+	stages := make([][]score, len(sizes))
+	prev := baseStage(limit)
+
+	for k, size := range sizes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var filled []score
+		if old != nil {
+			filled = old[k]
+		}
+
+		var err error
+		stages[k], err = extendUnboundedStage(ctx, filled, prev, size, weights[k])
+		if err != nil {
+			return nil, err
+		}
+		prev = stages[k]
+	}
+
+	return stages, nil
+}
+
+// TableRegistry keeps the DP stages of recently used pack-size sets, so
+// requests for the same sizes only fill the amounts no earlier request
+// needed. The least recently used tables are dropped once the tables hold
+// more than the capacity.
+type TableRegistry struct {
+	capacity int64
+
+	mu     sync.Mutex
+	bytes  int64
+	tables map[string]*list.Element
+	lru    *list.List // most recently used first
+}
+
+// NewTableRegistry keeps up to capacityBytes of DP stages
+func NewTableRegistry(capacityBytes int64) *TableRegistry {
+	return &TableRegistry{
+		capacity: capacityBytes,
+		tables:   make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Memory returns the bytes currently held by the tables
+func (r *TableRegistry) Memory() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.bytes
+}
+
+// stages returns the stages of the pack-size set covering amounts up to limit.
+// ok is false when a table that large would not fit the registry; the caller
+// then fills its own stages. Headroom never grows the table past maxBytes, the
+// memory limit of the request, zero for none.
+func (r *TableRegistry) stages(ctx context.Context, sizes []int, weights []score, limit int, maxBytes int64) ([][]score, bool, error) {
+	if stagesMemoryFor(len(sizes), limit) > r.capacity {
+		return nil, false, nil
+	}
+
+	maxLimit := maxStagesLimit(len(sizes), r.capacity)
+	if maxBytes > 0 {
+		maxLimit = min(maxLimit, maxStagesLimit(len(sizes), maxBytes))
+	}
+
+	table := r.table(sizes, weights)
+	stages, grown, err := table.stagesFor(ctx, limit, maxLimit)
+	if err != nil {
+		r.grew(table, 0)
+		return nil, true, err
+	}
+	r.grew(table, grown)
+
+	return stages, true, nil
+}
+
+// maxStagesLimit is the largest limit the stages of that many sizes may cover
+// within bytes
+func maxStagesLimit(sizes int, bytes int64) int {
+	return int(bytes/stagesMemoryFor(sizes, 0)) - 1
+}
+
+// table finds or adds the table of a pack-size set and marks it as used
+func (r *TableRegistry) table(sizes []int, weights []score) *stageTable {
+	key := fmt.Sprint(sizes, weights)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, ok := r.tables[key]; ok {
+		r.lru.MoveToFront(element)
+		return element.Value.(*stageTable)
+	}
+
+	table := &stageTable{key: key, sizes: sizes, weights: weights}
+	r.tables[key] = r.lru.PushFront(table)
+	return table
+}
+
+// grew accounts for an extended table and drops the least recently used
+// others until the tables fit again. A table whose first fill failed is
+// dropped, so failed requests leave nothing behind.
+func (r *TableRegistry) grew(table *stageTable, bytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A table dropped while it was extended no longer counts
+	element, ok := r.tables[table.key]
+	if !ok || element.Value != table {
+		return
+	}
+	if table.snapshot.Load() == nil {
+		r.remove(element)
+		return
+	}
+
+	table.accounted += bytes
+	r.bytes += bytes
+	for r.bytes > r.capacity && r.lru.Back() != element {
+		r.remove(r.lru.Back())
+	}
+}
+
+// remove drops a table, r.mu must be held
+func (r *TableRegistry) remove(element *list.Element) {
+	table := element.Value.(*stageTable)
+	r.lru.Remove(element)
+	delete(r.tables, table.key)
+	r.bytes -= table.accounted
+}
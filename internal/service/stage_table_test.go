@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"ignis/internal/domain"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestPackageCalculatorService_TableRegistry(t *testing.T) {
+	ctx := context.Background()
+	plain := NewPackageCalculatorService()
+
+	t.Run("matches fresh tables", func(t *testing.T) {
+		service := NewPackageCalculatorServiceWithTables(Limits{}, NewTableRegistry(64<<20))
+
+		// Growing, shrinking and repeated amounts, in every mode and objective
+		reqs := requestsForAmounts([]int{263, 500, 12001, 251, 12001, 12002, 40000, 7})
+		for _, req := range reqs {
+			want, wantErr := plain.Calculate(ctx, req)
+			got, err := service.Calculate(ctx, req)
+			if !reflect.DeepEqual(err, wantErr) || !reflect.DeepEqual(got, want) {
+				t.Fatalf("%+v: expected %+v (%v), got %+v (%v)", req, want, wantErr, got, err)
+			}
+
+			wantPlans, _ := plain.CalculateTopK(ctx, req, 3)
+			gotPlans, _ := service.CalculateTopK(ctx, req, 3)
+			if !reflect.DeepEqual(gotPlans, wantPlans) {
+				t.Fatalf("%+v: expected plans %+v, got %+v", req, wantPlans, gotPlans)
+			}
+		}
+
+		wantBatch, _ := plain.CalculateBatch(ctx, reqs)
+		gotBatch, _ := service.CalculateBatch(ctx, reqs)
+		if !reflect.DeepEqual(gotBatch, wantBatch) {
+			t.Errorf("expected the batch to match")
+		}
+	})
+
+	t.Run("extends a table with headroom", func(t *testing.T) {
+		registry := NewTableRegistry(64 << 20)
+		sizes, weights := []int{23, 31, 53}, []score{{packs: 1}, {packs: 1}, {packs: 1}}
+
+		first, _, _ := registry.stages(ctx, sizes, weights, 1000, 0)
+		if len(first[0]) != 1001 {
+			t.Fatalf("expected the first fill to cover the amount, got %d entries", len(first[0]))
+		}
+		smaller, _, _ := registry.stages(ctx, sizes, weights, 500, 0)
+		if &smaller[0][0] != &first[0][0] {
+			t.Errorf("expected smaller amounts to reuse the table")
+		}
+		extended, _, _ := registry.stages(ctx, sizes, weights, 1001, 0)
+		if len(extended[0]) != 1251 {
+			t.Errorf("expected the table to grow by a quarter, got %d entries", len(extended[0]))
+		}
+		if first[0][1000] != extended[0][1000] || len(first[0]) != 1001 {
+			t.Errorf("expected the old snapshot to stay untouched")
+		}
+		if registry.Memory() != stagesMemoryFor(len(sizes), 1250) {
+			t.Errorf("expected %d bytes, got %d", stagesMemoryFor(len(sizes), 1250), registry.Memory())
+		}
+	})
+
+	t.Run("keeps headroom within the request memory limit", func(t *testing.T) {
+		registry := NewTableRegistry(64 << 20)
+		sizes, weights := []int{23, 31, 53}, []score{{packs: 1}, {packs: 1}, {packs: 1}}
+		maxBytes := stagesMemoryFor(len(sizes), 1100)
+
+		registry.stages(ctx, sizes, weights, 1000, maxBytes)
+		extended, _, _ := registry.stages(ctx, sizes, weights, 1001, maxBytes)
+		if len(extended[0]) != 1101 {
+			t.Errorf("expected the table to grow only to the memory limit, got %d entries", len(extended[0]))
+		}
+	})
+
+	t.Run("stays within its capacity", func(t *testing.T) {
+		weights := []score{{packs: 1}, {packs: 1}}
+		capacity := stagesMemoryFor(len(weights), 25_000)
+		registry := NewTableRegistry(capacity)
+
+		for _, sizes := range [][]int{{3, 7}, {4, 9}, {5, 11}, {3, 7}} {
+			if _, _, err := registry.stages(ctx, sizes, weights, 10_000, 0); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if registry.Memory() > capacity {
+				t.Fatalf("expected at most %d bytes, got %d", capacity, registry.Memory())
+			}
+		}
+		if len(registry.tables) != 2 {
+			t.Errorf("expected the least recently used tables to be dropped, got %d tables", len(registry.tables))
+		}
+
+		// A table that alone exceeds the capacity is not kept
+		if _, ok, _ := registry.stages(ctx, []int{2, 13}, weights, 30_000, 0); ok {
+			t.Errorf("expected an oversized table to be left to the caller")
+		}
+	})
+
+	t.Run("does not keep cancelled fills", func(t *testing.T) {
+		registry := NewTableRegistry(64 << 20)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		if _, _, err := registry.stages(cancelled, []int{3, 7}, []score{{packs: 1}, {packs: 1}}, 1000, 0); err == nil {
+			t.Fatal("expected an error")
+		}
+		if len(registry.tables) != 0 || registry.Memory() != 0 {
+			t.Errorf("expected no table, got %d tables of %d bytes", len(registry.tables), registry.Memory())
+		}
+	})
+
+	t.Run("concurrent readers", func(t *testing.T) {
+		service := NewPackageCalculatorServiceWithTables(Limits{}, NewTableRegistry(64<<20))
+		reqs := requestsForAmounts([]int{100, 5000, 263, 20000, 9999, 15000, 500, 30000})
+
+		var wg sync.WaitGroup
+		for worker := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range reqs {
+					req := reqs[(i+worker)%len(reqs)]
+					want, _ := plain.Calculate(ctx, req)
+					if got, _ := service.Calculate(ctx, req); !reflect.DeepEqual(got, want) {
+						t.Errorf("%+v: expected %+v, got %+v", req, want, got)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// requestsForAmounts asks for each amount in both modes and for both objectives
+func requestsForAmounts(amounts []int) []domain.CalculateRequest {
+	var reqs []domain.CalculateRequest
+	for _, amount := range amounts {
+		reqs = append(reqs,
+			domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: amount},
+			domain.CalculateRequest{PackSizes: []int{53, 31, 23}, Amount: amount, Mode: domain.ModeOverfill},
+			domain.CalculateRequest{
+				PackSizes: []int{5, 12},
+				Amount:    amount,
+				Mode:      domain.ModeOverfill,
+				Objective: domain.ObjectiveLowestCost,
+				Costs:     map[int]int{5: 100, 12: 250},
+			},
+		)
+	}
+
+	return reqs
+}
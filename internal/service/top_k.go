@@ -0,0 +1,152 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"ignis/internal/domain"
+)
+
+// CalculateTopK returns up to k distinct plans ranked by overshoot, then by the
+// objective score. The filled DP stages are an (in the unbounded case exact)
+// lower bound on the score still needed, so a best-first search over partial
+// plans pops complete plans in rank order without enumerating all of them.
+func (s *PackageCalculatorService) CalculateTopK(ctx context.Context, req domain.CalculateRequest, k int) ([]*domain.CalculateResult, error) {
+	if k <= 0 {
+		return nil, domain.ErrInvalidPlanCount
+	}
+	if req.Constraints != nil {
+		if err := validateRequest(req); err != nil {
+			return nil, err
+		}
+		return s.calculateConstrained(ctx, req, k)
+	}
+
+	// The search bounds partial plans with every stage
+	p, err := s.solve(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := &planQueue{}
+	last := len(p.sizes) - 1
+	for total := p.total; total <= p.limit; total++ {
+		queue.push(p, nil, last, total, 0, 0, score{})
+	}
+
+	results := make([]*domain.CalculateResult, 0, k)
+	for step := 1; queue.Len() > 0 && len(results) < k; step++ {
+		if err := checkCancelled(ctx, step); err != nil {
+			return nil, err
+		}
+		node := heap.Pop(queue).(*planNode)
+		if node.stage < 0 {
+			results = append(results, buildResult(req, node.packages(), node.total))
+			continue
+		}
+
+		size := p.sizes[node.stage]
+		available, limited := p.req.Stock[size]
+
+		// Take one more pack of the current size...
+		if node.curr >= size && (!limited || node.count < available) {
+			queue.push(p, node, node.stage, node.curr-size, node.count+1, size, node.g.plus(1, p.weights[node.stage]))
+		}
+		// ...or settle its count and move on to the next smaller size
+		queue.push(p, node, node.stage-1, node.curr, 0, 0, node.g)
+	}
+
+	return results, nil
+}
+
+// planNode is a partial plan: packs of sizes above 'stage' are settled,
+// 'count' packs of sizes[stage] are taken and 'curr' items are left to pack
+type planNode struct {
+	parent *planNode
+	taken  int // pack size taken by this step, 0 when the step moved to a smaller size
+	total  int // shipped total the plan is heading for
+	stage  int // index of the size being taken, -1 once the plan is complete
+	curr   int
+	count  int
+	g      score // score of the packs taken so far
+	f      score // g plus the best score still needed
+	seq    int   // insertion order, keeps the search deterministic
+}
+
+// packages collects the pack counts along the path to the node
+func (n *planNode) packages() map[int]int {
+	resMap := make(map[int]int)
+	for node := n; node != nil; node = node.parent {
+		if node.taken > 0 {
+			resMap[node.taken]++
+		}
+	}
+
+	return resMap
+}
+
+// planQueue orders partial plans by shipped total, then by their bound on the final
+// score. Ties prefer nodes further down the sizes, so among equal plans the one
+// with the fewest large packs, the plan Calculate returns, completes first.
+type planQueue struct {
+	nodes []*planNode
+	seq   int
+}
+
+// push adds a child of parent unless the remaining amount cannot be packed
+func (q *planQueue) push(p *problem, parent *planNode, stage, curr, count, taken int, g score) {
+	var rest score
+	switch {
+	case stage < 0 && curr != 0:
+		return
+	case stage >= 0:
+		rest = p.stages[stage][curr]
+		if rest == unreachableScore {
+			return
+		}
+	}
+
+	total := curr
+	if parent != nil {
+		total = parent.total
+	}
+
+	q.seq++
+	heap.Push(q, &planNode{
+		parent: parent,
+		taken:  taken,
+		total:  total,
+		stage:  stage,
+		curr:   curr,
+		count:  count,
+		g:      g,
+		f:      g.plus(1, rest),
+		seq:    q.seq,
+	})
+}
+
+func (q *planQueue) Len() int { return len(q.nodes) }
+
+func (q *planQueue) Less(i, j int) bool {
+	a, b := q.nodes[i], q.nodes[j]
+	if a.total != b.total {
+		return a.total < b.total
+	}
+	if a.f != b.f {
+		return a.f.less(b.f)
+	}
+	if a.stage != b.stage {
+		return a.stage < b.stage
+	}
+	return a.seq < b.seq
+}
+
+func (q *planQueue) Swap(i, j int) { q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i] }
+
+func (q *planQueue) Push(x any) { q.nodes = append(q.nodes, x.(*planNode)) }
+
+func (q *planQueue) Pop() any {
+	old := q.nodes
+	node := old[len(old)-1]
+	q.nodes = old[:len(old)-1]
+	return node
+}
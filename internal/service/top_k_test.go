@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ignis/internal/domain"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPackageCalculatorService_CalculateTopK(t *testing.T) {
+	service := NewPackageCalculatorService()
+
+	t.Run("first plan matches Calculate and plans are ranked", func(t *testing.T) {
+		req := domain.CalculateRequest{PackSizes: []int{23, 31, 53}, Amount: 500000}
+
+		best, err := service.Calculate(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		plans, err := service.CalculateTopK(context.Background(), req, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(plans) != 5 {
+			t.Fatalf("expected 5 plans, got %d", len(plans))
+		}
+		if !reflect.DeepEqual(plans[0].Packages, best.Packages) {
+			t.Errorf("expected first plan %+v, got %+v", best.Packages, plans[0].Packages)
+		}
+
+		seen := make(map[string]bool)
+		for i, plan := range plans {
+			sum := 0
+			for size, count := range plan.Packages {
+				sum += size * count
+			}
+			if sum != 500000 || plan.Total != 500000 {
+				t.Errorf("plan %d: expected exact total 500000, got %d (packs sum to %d)", i, plan.Total, sum)
+			}
+			if i > 0 && plan.PackCount < plans[i-1].PackCount {
+				t.Errorf("plan %d: %d packs ranked after %d packs", i, plan.PackCount, plans[i-1].PackCount)
+			}
+
+			key := fmt.Sprint(plan.Packages)
+			if seen[key] {
+				t.Errorf("plan %d: duplicate plan %s", i, key)
+			}
+			seen[key] = true
+		}
+	})
+
+	t.Run("pack counts match brute force", func(t *testing.T) {
+		// Every plan for 60 with sizes 3, 5, 7, by pack count
+		var want []int
+		for a := 0; a <= 20; a++ {
+			for b := 0; b <= 12; b++ {
+				for c := 0; c <= 8; c++ {
+					if a*3+b*5+c*7 == 60 {
+						want = append(want, a+b+c)
+					}
+				}
+			}
+		}
+		sort.Ints(want)
+
+		plans, err := service.CalculateTopK(context.Background(), domain.CalculateRequest{PackSizes: []int{3, 5, 7}, Amount: 60}, 100)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(plans) != len(want) {
+			t.Fatalf("expected all %d plans, got %d", len(want), len(plans))
+		}
+		for i, plan := range plans {
+			if plan.PackCount != want[i] {
+				t.Errorf("plan %d: expected %d packs, got %d", i, want[i], plan.PackCount)
+			}
+		}
+	})
+
+	t.Run("lowest cost ranks by cost", func(t *testing.T) {
+		plans, err := service.CalculateTopK(context.Background(), domain.CalculateRequest{
+			PackSizes: []int{5, 10},
+			Amount:    20,
+			Objective: domain.ObjectiveLowestCost,
+			Costs:     map[int]int{5: 100, 10: 300},
+		}, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		costs := make([]int, 0, len(plans))
+		for _, plan := range plans {
+			costs = append(costs, plan.TotalCost)
+		}
+		if !reflect.DeepEqual(costs, []int{400, 500, 600}) {
+			t.Errorf("expected costs [400 500 600], got %v", costs)
+		}
+	})
+
+	t.Run("overfill ranks larger totals after smaller ones", func(t *testing.T) {
+		plans, err := service.CalculateTopK(context.Background(), domain.CalculateRequest{
+			PackSizes: []int{5, 10},
+			Amount:    7,
+			Mode:      domain.ModeOverfill,
+		}, 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(plans) != 3 {
+			t.Fatalf("expected 3 plans, got %d", len(plans))
+		}
+		// Both plans shipping 10 items come before the first one shipping 15
+		if plans[0].Packages[10] != 1 || plans[1].Packages[5] != 2 || plans[2].Total != 15 {
+			t.Errorf("unexpected plans: %+v, %+v, %+v", plans[0], plans[1], plans[2])
+		}
+		if plans[1].Overshoot != 3 || plans[2].Overshoot != 8 {
+			t.Errorf("expected overshoots 3 and 8, got %d and %d", plans[1].Overshoot, plans[2].Overshoot)
+		}
+	})
+
+	t.Run("error - k must be positive", func(t *testing.T) {
+		_, err := service.CalculateTopK(context.Background(), domain.CalculateRequest{PackSizes: []int{5}, Amount: 5}, 0)
+		if !errors.Is(err, domain.ErrInvalidPlanCount) {
+			t.Errorf("expected ErrInvalidPlanCount for k = 0, got %v", err)
+		}
+	})
+}
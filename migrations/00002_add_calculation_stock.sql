@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE calculations ADD COLUMN stock jsonb NOT NULL DEFAULT '{}'::jsonb;
+
+-- +goose Down
+ALTER TABLE calculations DROP COLUMN stock;
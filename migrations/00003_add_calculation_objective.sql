@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE calculations
+  ADD COLUMN objective text NOT NULL DEFAULT 'packs',
+  ADD COLUMN costs jsonb NOT NULL DEFAULT '{}'::jsonb,
+  ADD COLUMN total_cost bigint;
+
+-- +goose Down
+ALTER TABLE calculations
+  DROP COLUMN total_cost,
+  DROP COLUMN costs,
+  DROP COLUMN objective;
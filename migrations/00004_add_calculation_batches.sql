@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE calculation_batches (
+  id SERIAL PRIMARY KEY,
+  item_count integer NOT NULL,
+  failed_count integer NOT NULL,
+  errors jsonb NOT NULL DEFAULT '[]'::jsonb,
+  created_at timestamp NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE calculations ADD COLUMN batch_id integer REFERENCES calculation_batches (id);
+
+CREATE INDEX calculations_batch_id_idx ON calculations (batch_id);
+
+-- +goose Down
+DROP INDEX calculations_batch_id_idx;
+
+ALTER TABLE calculations DROP COLUMN batch_id;
+
+DROP TABLE calculation_batches;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE pack_configurations (
+  id SERIAL PRIMARY KEY,
+  name text NOT NULL UNIQUE,
+  pack_sizes integer[] NOT NULL,
+  description text NOT NULL DEFAULT '',
+  created_at timestamp NOT NULL DEFAULT NOW(),
+  updated_at timestamp NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE calculations
+  ADD COLUMN configuration_id integer REFERENCES pack_configurations (id) ON DELETE SET NULL;
+
+CREATE INDEX calculations_configuration_id_idx ON calculations (configuration_id);
+
+-- +goose Down
+DROP INDEX calculations_configuration_id_idx;
+
+ALTER TABLE calculations DROP COLUMN configuration_id;
+
+DROP TABLE pack_configurations;
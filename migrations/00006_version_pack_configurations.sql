@@ -0,0 +1,50 @@
+-- +goose Up
+CREATE TABLE pack_configuration_versions (
+  id SERIAL PRIMARY KEY,
+  configuration_id integer NOT NULL REFERENCES pack_configurations (id) ON DELETE CASCADE,
+  pack_sizes integer[] NOT NULL,
+  valid_from timestamp NOT NULL,
+  valid_to timestamp,
+  created_at timestamp NOT NULL DEFAULT NOW(),
+  CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+CREATE INDEX pack_configuration_versions_configuration_id_valid_from_idx
+  ON pack_configuration_versions (configuration_id, valid_from);
+
+-- Existing configurations become their first version
+INSERT INTO pack_configuration_versions (configuration_id, pack_sizes, valid_from)
+SELECT id, pack_sizes, created_at FROM pack_configurations;
+
+ALTER TABLE calculations
+  ADD COLUMN configuration_version_id integer REFERENCES pack_configuration_versions (id) ON DELETE SET NULL;
+
+UPDATE calculations c
+SET configuration_version_id = v.id
+FROM pack_configuration_versions v
+WHERE v.configuration_id = c.configuration_id;
+
+CREATE INDEX calculations_configuration_version_id_idx ON calculations (configuration_version_id);
+
+ALTER TABLE pack_configurations DROP COLUMN pack_sizes;
+
+-- +goose Down
+ALTER TABLE pack_configurations ADD COLUMN pack_sizes integer[];
+
+UPDATE pack_configurations c
+SET pack_sizes = (
+  SELECT v.pack_sizes FROM pack_configuration_versions v
+  WHERE v.configuration_id = c.id
+  ORDER BY v.valid_from DESC
+  LIMIT 1
+);
+
+DELETE FROM pack_configurations WHERE pack_sizes IS NULL;
+
+ALTER TABLE pack_configurations ALTER COLUMN pack_sizes SET NOT NULL;
+
+DROP INDEX calculations_configuration_version_id_idx;
+
+ALTER TABLE calculations DROP COLUMN configuration_version_id;
+
+DROP TABLE pack_configuration_versions;
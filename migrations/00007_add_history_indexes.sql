@@ -0,0 +1,28 @@
+-- +goose Up
+-- pack_sizes keeps the text as entered; pack_size_set is its sorted, distinct
+-- sizes so the history can be filtered by pack-size set
+ALTER TABLE calculations ADD COLUMN pack_size_set integer[] NOT NULL DEFAULT '{}';
+
+UPDATE calculations
+SET pack_size_set = ARRAY(
+  SELECT DISTINCT trim(size)::integer
+  FROM unnest(string_to_array(pack_sizes, ',')) AS size
+  WHERE trim(size) ~ '^[0-9]{1,9}$'
+  ORDER BY 1
+);
+
+-- Keyset pagination walks (created_at, id) newest first
+CREATE INDEX calculations_created_at_id_idx ON calculations (created_at DESC, id DESC);
+
+CREATE INDEX calculations_pack_size_set_created_at_id_idx ON calculations (pack_size_set, created_at DESC, id DESC);
+
+CREATE INDEX calculations_target_amount_idx ON calculations (target_amount);
+
+-- +goose Down
+DROP INDEX calculations_target_amount_idx;
+
+DROP INDEX calculations_pack_size_set_created_at_id_idx;
+
+DROP INDEX calculations_created_at_id_idx;
+
+ALTER TABLE calculations DROP COLUMN pack_size_set;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- mode completes the stored input so a calculation can be replayed; older rows
+-- that packed more than asked for must have been overfill
+ALTER TABLE calculations
+  ADD COLUMN mode text NOT NULL DEFAULT 'exact',
+  ADD COLUMN deleted_at timestamp;
+
+UPDATE calculations SET mode = 'overfill' WHERE total_items > target_amount;
+
+-- Deleted calculations are kept but leave the history
+DROP INDEX calculations_created_at_id_idx;
+CREATE INDEX calculations_created_at_id_idx ON calculations (created_at DESC, id DESC) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX calculations_created_at_id_idx;
+CREATE INDEX calculations_created_at_id_idx ON calculations (created_at DESC, id DESC);
+
+ALTER TABLE calculations
+  DROP COLUMN deleted_at,
+  DROP COLUMN mode;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Calculations imported from order files keep the order and product they were for
+ALTER TABLE calculations
+  ADD COLUMN order_reference text NOT NULL DEFAULT '',
+  ADD COLUMN sku text NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE calculations
+  DROP COLUMN sku,
+  DROP COLUMN order_reference;
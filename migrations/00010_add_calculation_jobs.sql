@@ -0,0 +1,22 @@
+-- +goose Up
+-- A job is a batch request calculated in the background. Workers claim queued
+-- jobs, or running ones whose lease ran out because their worker died.
+CREATE TABLE calculation_jobs (
+  id SERIAL PRIMARY KEY,
+  status text NOT NULL DEFAULT 'queued',
+  request jsonb NOT NULL,
+  total integer NOT NULL,
+  progress integer NOT NULL DEFAULT 0,
+  result jsonb,
+  error text NOT NULL DEFAULT '',
+  attempts integer NOT NULL DEFAULT 0,
+  locked_until timestamp,
+  created_at timestamp NOT NULL DEFAULT NOW(),
+  started_at timestamp,
+  finished_at timestamp
+);
+
+CREATE INDEX calculation_jobs_pending_idx ON calculation_jobs (id) WHERE status IN ('queued', 'running');
+
+-- +goose Down
+DROP TABLE calculation_jobs;
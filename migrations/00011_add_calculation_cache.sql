@@ -0,0 +1,14 @@
+-- +goose Up
+-- Results of the calculator cache, so they survive restarts. The key is the
+-- normalized request, expires_at is NULL for entries that never expire.
+CREATE TABLE calculation_cache (
+  key text PRIMARY KEY,
+  results jsonb NOT NULL,
+  created_at timestamp NOT NULL DEFAULT NOW(),
+  expires_at timestamp
+);
+
+CREATE INDEX calculation_cache_expires_at_idx ON calculation_cache (expires_at) WHERE expires_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE calculation_cache;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- An order packs several products at once, each line is a calculation linked
+-- to it. The totals sum the lines that could be packed.
+CREATE TABLE calculation_orders (
+  id SERIAL PRIMARY KEY,
+  reference text NOT NULL DEFAULT '',
+  line_count integer NOT NULL,
+  failed_count integer NOT NULL,
+  errors jsonb NOT NULL DEFAULT '[]'::jsonb,
+  total_amount integer NOT NULL,
+  total_items integer NOT NULL,
+  total_packs integer NOT NULL,
+  overshoot integer NOT NULL,
+  total_cost bigint,
+  created_at timestamp NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE calculations ADD COLUMN order_id integer REFERENCES calculation_orders (id);
+
+CREATE INDEX calculations_order_id_idx ON calculations (order_id);
+
+-- +goose Down
+DROP INDEX calculations_order_id_idx;
+
+ALTER TABLE calculations DROP COLUMN order_id;
+
+DROP TABLE calculation_orders;
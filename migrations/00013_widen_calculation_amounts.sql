@@ -0,0 +1,23 @@
+-- +goose Up
+-- Amounts go up to CALC_MAX_AMOUNT and totals past it, both can exceed the
+-- range of an integer column
+ALTER TABLE calculations
+  ALTER COLUMN target_amount TYPE bigint,
+  ALTER COLUMN total_items TYPE bigint;
+
+ALTER TABLE calculation_orders
+  ALTER COLUMN total_amount TYPE bigint,
+  ALTER COLUMN total_items TYPE bigint,
+  ALTER COLUMN total_packs TYPE bigint,
+  ALTER COLUMN overshoot TYPE bigint;
+
+-- +goose Down
+ALTER TABLE calculation_orders
+  ALTER COLUMN total_amount TYPE integer,
+  ALTER COLUMN total_items TYPE integer,
+  ALTER COLUMN total_packs TYPE integer,
+  ALTER COLUMN overshoot TYPE integer;
+
+ALTER TABLE calculations
+  ALTER COLUMN target_amount TYPE integer,
+  ALTER COLUMN total_items TYPE integer;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- request keeps the whole input of a calculation, pack constraints, packaging
+-- and shipping included, so a replay runs with all of it. Older rows have none
+-- and are rebuilt from the other columns.
+ALTER TABLE calculations ADD COLUMN request jsonb;
+
+-- +goose Down
+ALTER TABLE calculations DROP COLUMN request;